@@ -0,0 +1,22 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/Intiqo/app-platform/internal/pkg/config"
+)
+
+// runCommand runs the command given on the command line instead of the server
+func runCommand(cfg config.AppConfig, awsCfg aws.Config, db *pgxpool.Pool, args []string) error {
+	switch args[0] {
+	case "settings":
+		return runSettingsCommand(cfg, awsCfg, db, args[1:])
+	case "migrate":
+		return runMigrateCommand(db, args[1:])
+	default:
+		return fmt.Errorf("unknown command %q, expected one of: settings, migrate", args[0])
+	}
+}
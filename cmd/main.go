@@ -6,13 +6,16 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/jackc/pgx/v5/pgxpool"
 	_ "github.com/joho/godotenv/autoload"
 	"github.com/labstack/echo/v4"
 
+	"github.com/Intiqo/app-platform/internal/database"
 	"github.com/Intiqo/app-platform/internal/dependency"
 	"github.com/Intiqo/app-platform/internal/http/swagger"
 	"github.com/Intiqo/app-platform/internal/pkg/config"
@@ -52,13 +55,41 @@ func main() {
 	// Defer closing the database connection
 	defer db.Close()
 
+	// Run the command given on the command line instead of the server, if any
+	if len(os.Args) > 1 {
+		err = runCommand(cfg, awsCfg, db, os.Args[1:])
+		if err != nil {
+			log.Fatalf("failed to run command: %v", err)
+		}
+		return
+	}
+
+	// Apply the pending migrations, if configured to
+	if cfg.DatabaseMigrateOnStart {
+		migrateOnStart(db)
+	}
+
 	// Initialize the dependencies
-	api, err := dependency.NewAppApi(
+	api, cleanup, err := dependency.NewAppApi(
 		cfg, awsCfg, db,
 	)
 	if err != nil {
 		log.Fatalf("failed to create app api: %v", err)
 	}
+	// Defer releasing the dependencies, before the database connection is closed
+	defer cleanup()
+
+	// Publish the events recorded in the outbox
+	_, relayCleanup, err := dependency.NewOutboxRelay(cfg, db)
+	if err != nil {
+		log.Fatalf("failed to create outbox relay: %v", err)
+	}
+	// Defer stopping the relay, before the database connection is closed
+	defer relayCleanup()
+
+	// Start running background jobs
+	queue := dependency.NewJobQueue(cfg, db)
+	queue.Start()
 
 	// Set up the echo server
 	e := echo.New()
@@ -78,9 +109,9 @@ func main() {
 		e.Logger.Info(e.Start(fmt.Sprintf("0.0.0.0:%d", cfg.AppPort)))
 	}()
 
-	// Wait for interrupt signal to gracefully shutdown the server with a timeout of 10 seconds
+	// Wait for interrupt signal to gracefully shutdown the server & the jobs with a timeout of 10 seconds
 	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, os.Interrupt)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
 	<-quit
 
 	log.Println("Server is shutting down...")
@@ -92,6 +123,12 @@ func main() {
 		e.Logger.Fatal(err)
 	}
 
+	// Let the running jobs finish, interrupting the ones that don't finish in time so that they run again later
+	log.Println("Waiting for running jobs to finish...")
+	if err := queue.Shutdown(ctx); err != nil {
+		log.Printf("Interrupted the running jobs: %v", err)
+	}
+
 	log.Println("Server gracefully stopped")
 }
 
@@ -106,7 +143,10 @@ func getConfigOptions() config.Options {
 	}
 	switch cfgSource {
 	case config.SourceEnv:
-		cfgOptions.ConfigFile = ".env"
+		cfgOptions.ConfigFile = os.Getenv(config.ConfigFileKey)
+		if cfgOptions.ConfigFile == "" {
+			cfgOptions.ConfigFile = ".env"
+		}
 		cfgOptions.AwsProfile = os.Getenv(config.AwsProfileKey)
 	case config.SourceAWSSecretsManager:
 		cfgOptions.AwsProfile = os.Getenv(config.AwsProfileKey)
@@ -115,6 +155,21 @@ func getConfigOptions() config.Options {
 	return cfgOptions
 }
 
+// migrateOnStart applies the pending migrations, waiting for any other instance that is migrating to finish first
+func migrateOnStart(db *pgxpool.Pool) {
+	m, err := database.NewMigrator(db)
+	if err != nil {
+		log.Fatalf("failed to load migrations: %v", err)
+	}
+	result, err := m.Up(context.Background())
+	if err != nil {
+		log.Fatalf("failed to migrate database: %v", err)
+	}
+	for _, v := range result {
+		log.Printf("applied migration %s\n", v.Name)
+	}
+}
+
 func validateAwsSession(cfg aws.Config) {
 	// Create an STS client
 	svc := sts.NewFromConfig(cfg)
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/Intiqo/app-platform/internal/database"
+)
+
+// runMigrateCommand runs one of the migrate subcommands:
+//
+//	app migrate up
+//	app migrate down
+//	app migrate redo
+//	app migrate status
+//	app migrate create [-dir directory] name
+func runMigrateCommand(db *pgxpool.Pool, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing migrate subcommand, expected one of: up, down, redo, status, create")
+	}
+	if args[0] == "create" {
+		return createMigration(args[1:])
+	}
+
+	m, err := database.NewMigrator(db)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		result, err := m.Up(ctx)
+		printMigrations("Applied", result)
+		return err
+	case "down":
+		result, err := m.Down(ctx)
+		printMigrations("Rolled back", result)
+		return err
+	case "redo":
+		result, err := m.Redo(ctx)
+		printMigrations("Redone", result)
+		return err
+	case "status":
+		return printMigrationStatus(m)
+	default:
+		return fmt.Errorf("unknown migrate subcommand %q, expected one of: up, down, redo, status, create", args[0])
+	}
+}
+
+// createMigration creates an empty migration to fill in
+func createMigration(args []string) error {
+	// Parse the flags
+	fs := flag.NewFlagSet("migrate create", flag.ContinueOnError)
+	dir := fs.String("dir", "internal/database/migrations", "directory to create the migration in")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("expected the name of the migration, e.g. app migrate create add_users")
+	}
+
+	// Create the migration
+	result, err := database.CreateMigration(*dir, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Created %s\n", result)
+	return nil
+}
+
+// printMigrations prints the migrations that were applied or rolled back
+func printMigrations(verb string, migrations []database.Migration) {
+	for _, v := range migrations {
+		fmt.Printf("%s %s\n", verb, v.Name)
+	}
+	if len(migrations) == 0 {
+		fmt.Println("No migrations to run")
+	}
+}
+
+// printMigrationStatus prints every migration along with the time it was applied
+func printMigrationStatus(m *database.Migrator) error {
+	result, err := m.Status(context.Background())
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "APPLIED AT\tMIGRATION")
+	for _, v := range result {
+		appliedAt := "Pending"
+		if v.AppliedAt != nil {
+			appliedAt = v.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%s\t%s\n", appliedAt, v.Name)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"gopkg.in/yaml.v3"
+
+	"github.com/Intiqo/app-platform/internal/dependency"
+	"github.com/Intiqo/app-platform/internal/domain"
+	"github.com/Intiqo/app-platform/internal/pkg/config"
+)
+
+// runSettingsCommand runs one of the settings subcommands:
+//
+//	app settings export [-format json|yaml] [-output file]
+//	app settings import -file file [-format json|yaml] [-dry-run] [-prune]
+func runSettingsCommand(cfg config.AppConfig, awsCfg aws.Config, db *pgxpool.Pool, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing settings subcommand, expected one of: export, import")
+	}
+
+	// Initialize the setting service
+	s, cleanup, err := dependency.NewSettingService(cfg, awsCfg, db)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	switch args[0] {
+	case "export":
+		return exportSettings(s, args[1:])
+	case "import":
+		return importSettings(s, args[1:])
+	default:
+		return fmt.Errorf("unknown settings subcommand %q, expected one of: export, import", args[0])
+	}
+}
+
+// exportSettings writes all the settings to a file or to the standard output
+func exportSettings(s domain.SettingService, args []string) error {
+	// Parse the flags
+	fs := flag.NewFlagSet("settings export", flag.ContinueOnError)
+	format := fs.String("format", "", "format of the document, json or yaml. Inferred from the output file, defaulting to json")
+	output := fs.String("output", "", "file to write the settings to. Defaults to the standard output")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	// Export the settings of every organization
+	result, err := s.Export(domain.ContextAsPlatform(context.Background()))
+	if err != nil {
+		return err
+	}
+	b, err := encodeSettingExport(documentFormat(*format, *output), result)
+	if err != nil {
+		return err
+	}
+
+	// Write the document
+	if *output == "" {
+		_, err = os.Stdout.Write(b)
+		return err
+	}
+	return os.WriteFile(*output, b, 0o644)
+}
+
+// importSettings imports the settings from a file or from the standard input, and prints the changes
+func importSettings(s domain.SettingService, args []string) error {
+	// Parse the flags
+	fs := flag.NewFlagSet("settings import", flag.ContinueOnError)
+	format := fs.String("format", "", "format of the document, json or yaml. Inferred from the input file, defaulting to json")
+	file := fs.String("file", "", "file to read the settings from. Defaults to the standard input")
+	dryRun := fs.Bool("dry-run", false, "print the changes without making them")
+	prune := fs.Bool("prune", false, "remove the settings that are not part of the document")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	// Read the document
+	var b []byte
+	if *file == "" {
+		b, err = io.ReadAll(os.Stdin)
+	} else {
+		b, err = os.ReadFile(*file)
+	}
+	if err != nil {
+		return err
+	}
+	in := domain.ImportSettingsInput{
+		DryRun: *dryRun,
+		Prune:  *prune,
+	}
+	err = decodeSettingExport(documentFormat(*format, *file), b, &in.SettingExport)
+	if err != nil {
+		return err
+	}
+
+	// Import the settings, which can belong to any organization
+	result, err := s.Import(domain.ContextAsPlatform(context.Background()), in)
+	if err != nil {
+		return err
+	}
+
+	// Print the changes
+	for _, v := range result.Added {
+		fmt.Printf("+ %s = %q\n", describeExportItem(v), v.Value)
+	}
+	for _, v := range result.Changed {
+		fmt.Printf("~ %s = %q -> %q\n", describeExportItem(v.SettingExportItem), v.PreviousValue, v.Value)
+	}
+	for _, v := range result.Removed {
+		fmt.Printf("- %s = %q\n", describeExportItem(v), v.Value)
+	}
+	verb := "Applied"
+	if result.DryRun {
+		verb = "Would apply"
+	}
+	fmt.Printf("%s %d additions, %d changes and %d removals\n", verb, len(result.Added), len(result.Changed), len(result.Removed))
+	return nil
+}
+
+// documentFormat returns the format of a document, inferring it from the name of its file when it's not given
+func documentFormat(format, file string) string {
+	if format != "" {
+		return format
+	}
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml":
+		return domain.SettingExportFormatYAML
+	default:
+		return domain.SettingExportFormatJSON
+	}
+}
+
+func encodeSettingExport(format string, export domain.SettingExport) ([]byte, error) {
+	switch format {
+	case domain.SettingExportFormatJSON:
+		return json.MarshalIndent(export, "", "  ")
+	case domain.SettingExportFormatYAML:
+		return yaml.Marshal(export)
+	default:
+		return nil, fmt.Errorf("unsupported format %q", format)
+	}
+}
+
+func decodeSettingExport(format string, b []byte, export *domain.SettingExport) error {
+	switch format {
+	case domain.SettingExportFormatJSON:
+		return json.Unmarshal(b, export)
+	case domain.SettingExportFormatYAML:
+		return yaml.Unmarshal(b, export)
+	default:
+		return fmt.Errorf("unsupported format %q", format)
+	}
+}
+
+// describeExportItem describes a setting by its key and scope
+func describeExportItem(item domain.SettingExportItem) string {
+	if item.ScopeID == nil {
+		return item.Key
+	}
+	return fmt.Sprintf("%s (%s %s)", item.Key, item.Scope, item.ScopeID)
+}
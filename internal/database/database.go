@@ -3,7 +3,9 @@ package database
 import (
 	"context"
 	"fmt"
-	"log"
+	"net"
+	"net/url"
+	"strconv"
 
 	pgxuuid "github.com/jackc/pgx-gofrs-uuid"
 	"github.com/jackc/pgx/v5"
@@ -12,14 +14,87 @@ import (
 	"github.com/Intiqo/app-platform/internal/pkg/config"
 )
 
+// defaultSslMode keeps connecting without TLS unless DB_SSL_MODE says otherwise
+const defaultSslMode = "disable"
+
 // NewDB returns a new database connection pool
-func NewDB(cfg config.AppConfig) *pgxpool.Pool {
-	dsn := fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable", cfg.DatabaseUsername, cfg.DatabasePassword, cfg.DatabaseHost, cfg.DatabasePort, cfg.DatabaseName)
+func NewDB(cfg config.AppConfig) (*pgxpool.Pool, error) {
+	dbconfig, err := newPoolConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
 
-	// Create a database connection pool
-	dbconfig, err := pgxpool.ParseConfig(dsn)
+	// Create the connection pool
+	dbp, err := pgxpool.NewWithConfig(context.Background(), dbconfig)
 	if err != nil {
-		log.Fatalf("Unable to parse DATABASE_URL: %v\n", err)
+		return nil, fmt.Errorf("failed to create the database connection pool: %w", err)
+	}
+	return dbp, nil
+}
+
+// newPoolConfig builds the configuration of the connection pool to the primary database.
+// The pool settings that aren't configured are left to their pgxpool defaults.
+func newPoolConfig(cfg config.AppConfig) (*pgxpool.Config, error) {
+	// Build the connection string, escaping the credentials
+	params := url.Values{}
+	params.Set("sslmode", defaultSslMode)
+	if cfg.DatabaseSslMode != "" {
+		params.Set("sslmode", cfg.DatabaseSslMode)
+	}
+	if cfg.DatabaseSslRootCert != "" {
+		params.Set("sslrootcert", cfg.DatabaseSslRootCert)
+	}
+	dsn := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(cfg.DatabaseUsername, cfg.DatabasePassword),
+		Host:     net.JoinHostPort(cfg.DatabaseHost, cfg.DatabasePort),
+		Path:     "/" + cfg.DatabaseName,
+		RawQuery: params.Encode(),
+	}
+	dbconfig, err := pgxpool.ParseConfig(dsn.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the database config: %w", err)
+	}
+	err = configurePool(dbconfig, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return dbconfig, nil
+}
+
+// configurePool applies the pool & session settings shared by the primary & the replicas
+func configurePool(dbconfig *pgxpool.Config, cfg config.AppConfig) error {
+	// Size the pool
+	if cfg.DatabaseMaxConns < 0 || cfg.DatabaseMinConns < 0 {
+		return fmt.Errorf("invalid database config: DB_MAX_CONNS & DB_MIN_CONNS can't be negative")
+	}
+	if cfg.DatabaseMaxConns > 0 {
+		dbconfig.MaxConns = int32(cfg.DatabaseMaxConns)
+	}
+	if cfg.DatabaseMinConns > 0 {
+		dbconfig.MinConns = int32(cfg.DatabaseMinConns)
+	}
+	if dbconfig.MinConns > dbconfig.MaxConns {
+		return fmt.Errorf("invalid database config: DB_MIN_CONNS (%d) is greater than DB_MAX_CONNS (%d)", dbconfig.MinConns, dbconfig.MaxConns)
+	}
+	if cfg.DatabaseMaxConnLifetime > 0 {
+		dbconfig.MaxConnLifetime = cfg.DatabaseMaxConnLifetime
+	}
+	if cfg.DatabaseMaxConnIdleTime > 0 {
+		dbconfig.MaxConnIdleTime = cfg.DatabaseMaxConnIdleTime
+	}
+	if cfg.DatabaseHealthCheckPeriod > 0 {
+		dbconfig.HealthCheckPeriod = cfg.DatabaseHealthCheckPeriod
+	}
+
+	// Set up the sessions, naming them after the app so that they can be told apart in pg_stat_activity
+	runtimeParams := dbconfig.ConnConfig.RuntimeParams
+	runtimeParams["application_name"] = cfg.DatabaseApplicationName
+	if runtimeParams["application_name"] == "" {
+		runtimeParams["application_name"] = cfg.AppName
+	}
+	if cfg.DatabaseStatementTimeout > 0 {
+		runtimeParams["statement_timeout"] = strconv.FormatInt(cfg.DatabaseStatementTimeout.Milliseconds(), 10)
 	}
 
 	// Register the uuid type
@@ -27,10 +102,5 @@ func NewDB(cfg config.AppConfig) *pgxpool.Pool {
 		pgxuuid.Register(conn.TypeMap())
 		return nil
 	}
-	// Create the connection pool
-	dbp, err := pgxpool.NewWithConfig(context.Background(), dbconfig)
-	if err != nil {
-		log.Fatalf("Unable to create connection pool: %v\n", err)
-	}
-	return dbp
+	return nil
 }
@@ -0,0 +1,87 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Intiqo/app-platform/internal/pkg/config"
+)
+
+func TestNewPoolConfig(t *testing.T) {
+	base := config.AppConfig{
+		AppName:          "app",
+		DatabaseHost:     "localhost",
+		DatabasePort:     "5432",
+		DatabaseUsername: "app",
+		DatabasePassword: "p@ss/word?",
+		DatabaseName:     "app",
+	}
+
+	t.Run("success - keep the defaults", func(t *testing.T) {
+		got, err := newPoolConfig(base)
+		if err != nil {
+			t.Fatalf("Error building the pool config: %v", err)
+		}
+		if got.ConnConfig.Password != base.DatabasePassword {
+			t.Errorf("Expected the password to survive escaping, got %q", got.ConnConfig.Password)
+		}
+		if got.ConnConfig.TLSConfig != nil {
+			t.Errorf("Expected TLS to be disabled by default")
+		}
+		if got.ConnConfig.RuntimeParams["application_name"] != "app" {
+			t.Errorf("Expected the application name to default to the app name, got %q", got.ConnConfig.RuntimeParams["application_name"])
+		}
+		if _, ok := got.ConnConfig.RuntimeParams["statement_timeout"]; ok {
+			t.Errorf("Expected no statement timeout by default")
+		}
+	})
+
+	t.Run("success - apply the pool & session settings", func(t *testing.T) {
+		cfg := base
+		cfg.DatabaseSslMode = "require"
+		cfg.DatabaseMaxConns = 20
+		cfg.DatabaseMinConns = 2
+		cfg.DatabaseMaxConnLifetime = 2 * time.Hour
+		cfg.DatabaseMaxConnIdleTime = 10 * time.Minute
+		cfg.DatabaseHealthCheckPeriod = 30 * time.Second
+		cfg.DatabaseStatementTimeout = 15 * time.Second
+		cfg.DatabaseApplicationName = "app-worker"
+		got, err := newPoolConfig(cfg)
+		if err != nil {
+			t.Fatalf("Error building the pool config: %v", err)
+		}
+		if got.ConnConfig.TLSConfig == nil {
+			t.Errorf("Expected TLS to be required")
+		}
+		if got.MaxConns != 20 || got.MinConns != 2 {
+			t.Errorf("Expected the pool to hold 2 to 20 connections, got %d to %d", got.MinConns, got.MaxConns)
+		}
+		if got.MaxConnLifetime != 2*time.Hour || got.MaxConnIdleTime != 10*time.Minute || got.HealthCheckPeriod != 30*time.Second {
+			t.Errorf("Expected the connection periods to be applied, got %s, %s & %s", got.MaxConnLifetime, got.MaxConnIdleTime, got.HealthCheckPeriod)
+		}
+		if got.ConnConfig.RuntimeParams["statement_timeout"] != "15000" {
+			t.Errorf("Expected a statement timeout of 15000ms, got %q", got.ConnConfig.RuntimeParams["statement_timeout"])
+		}
+		if got.ConnConfig.RuntimeParams["application_name"] != "app-worker" {
+			t.Errorf("Expected the application name to be app-worker, got %q", got.ConnConfig.RuntimeParams["application_name"])
+		}
+	})
+
+	for name, fn := range map[string]func(cfg *config.AppConfig){
+		"invalid sslmode": func(cfg *config.AppConfig) { cfg.DatabaseSslMode = "sometimes" },
+		"missing root certificate": func(cfg *config.AppConfig) {
+			cfg.DatabaseSslMode, cfg.DatabaseSslRootCert = "verify-full", "/nonexistent/ca.pem"
+		},
+		"more min than max conns":  func(cfg *config.AppConfig) { cfg.DatabaseMaxConns, cfg.DatabaseMinConns = 2, 5 },
+		"negative number of conns": func(cfg *config.AppConfig) { cfg.DatabaseMaxConns = -1 },
+	} {
+		t.Run("failure - "+name, func(t *testing.T) {
+			cfg := base
+			fn(&cfg)
+			_, err := newPoolConfig(cfg)
+			if err == nil {
+				t.Errorf("Expected an error building the pool config")
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION notify_setting_change() RETURNS TRIGGER AS $$
+DECLARE
+  entity RECORD;
+  operation TEXT := TG_OP;
+BEGIN
+  IF TG_OP = 'DELETE' THEN
+    entity := OLD;
+  ELSE
+    entity := NEW;
+  END IF;
+
+  -- Soft deletes are reported as deletes
+  IF TG_OP = 'UPDATE' AND NEW.deleted_at IS NOT NULL AND OLD.deleted_at IS NULL THEN
+    operation := 'DELETE';
+  END IF;
+
+  PERFORM pg_notify('settings_changed', json_build_object('operation', operation, 'id', entity.id, 'key', entity.key)::TEXT);
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- +goose StatementEnd
+-- +goose StatementBegin
+CREATE TRIGGER settings_notify_change
+AFTER INSERT OR UPDATE OR DELETE ON settings
+FOR EACH ROW EXECUTE FUNCTION notify_setting_change();
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS settings_notify_change ON settings;
+
+DROP FUNCTION IF EXISTS notify_setting_change();
+
+-- +goose StatementEnd
@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS setting_revisions (
+  id UUID DEFAULT gen_random_uuid() NOT NULL,
+  setting_id UUID NOT NULL REFERENCES settings (id),
+  key VARCHAR NOT NULL,
+  value TEXT NOT NULL,
+  operation VARCHAR NOT NULL,
+  changed_by UUID,
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL,
+  PRIMARY KEY (id)
+);
+
+CREATE INDEX IF NOT EXISTS setting_revisions_key_created_at_idx ON setting_revisions (key, created_at DESC);
+
+CREATE INDEX IF NOT EXISTS setting_revisions_setting_id_created_at_idx ON setting_revisions (setting_id, created_at DESC);
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS setting_revisions;
+
+-- +goose StatementEnd
@@ -0,0 +1,30 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE settings
+  ADD COLUMN IF NOT EXISTS scope VARCHAR DEFAULT 'global' NOT NULL,
+  ADD COLUMN IF NOT EXISTS scope_id UUID,
+  ADD CONSTRAINT settings_scope_check CHECK (
+    (scope = 'global' AND scope_id IS NULL) OR (scope IN ('organization', 'user') AND scope_id IS NOT NULL)
+  );
+
+CREATE INDEX IF NOT EXISTS settings_key_scope_idx ON settings (key, scope, scope_id) WHERE deleted_at IS NULL;
+
+ALTER TABLE setting_revisions
+  ADD COLUMN IF NOT EXISTS scope VARCHAR DEFAULT 'global' NOT NULL,
+  ADD COLUMN IF NOT EXISTS scope_id UUID;
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE setting_revisions
+  DROP COLUMN IF EXISTS scope_id,
+  DROP COLUMN IF EXISTS scope;
+
+DROP INDEX IF EXISTS settings_key_scope_idx;
+
+ALTER TABLE settings
+  DROP CONSTRAINT IF EXISTS settings_scope_check,
+  DROP COLUMN IF EXISTS scope_id,
+  DROP COLUMN IF EXISTS scope;
+
+-- +goose StatementEnd
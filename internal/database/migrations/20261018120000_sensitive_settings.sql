@@ -0,0 +1,44 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS setting_data_keys (
+  id UUID DEFAULT gen_random_uuid() NOT NULL,
+  encrypted_key TEXT NOT NULL,
+  master_key_name VARCHAR NOT NULL,
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL,
+  retired_at TIMESTAMP WITH TIME ZONE,
+  PRIMARY KEY (id)
+);
+
+-- Only one data key can be used to encrypt new values at a time
+CREATE UNIQUE INDEX IF NOT EXISTS setting_data_keys_active_idx ON setting_data_keys ((retired_at IS NULL)) WHERE retired_at IS NULL;
+
+ALTER TABLE settings
+  ADD COLUMN IF NOT EXISTS sensitive BOOLEAN DEFAULT FALSE NOT NULL,
+  ADD COLUMN IF NOT EXISTS data_key_id UUID REFERENCES setting_data_keys (id);
+
+CREATE INDEX IF NOT EXISTS settings_data_key_id_idx ON settings (data_key_id) WHERE sensitive;
+
+ALTER TABLE setting_revisions
+  ADD COLUMN IF NOT EXISTS sensitive BOOLEAN DEFAULT FALSE NOT NULL,
+  ADD COLUMN IF NOT EXISTS data_key_id UUID REFERENCES setting_data_keys (id);
+
+CREATE INDEX IF NOT EXISTS setting_revisions_data_key_id_idx ON setting_revisions (data_key_id) WHERE sensitive;
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS setting_revisions_data_key_id_idx;
+
+ALTER TABLE setting_revisions
+  DROP COLUMN IF EXISTS data_key_id,
+  DROP COLUMN IF EXISTS sensitive;
+
+DROP INDEX IF EXISTS settings_data_key_id_idx;
+
+ALTER TABLE settings
+  DROP COLUMN IF EXISTS data_key_id,
+  DROP COLUMN IF EXISTS sensitive;
+
+DROP TABLE IF EXISTS setting_data_keys;
+
+-- +goose StatementEnd
@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE settings
+  ADD COLUMN IF NOT EXISTS version BIGINT DEFAULT 1 NOT NULL;
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE settings
+  DROP COLUMN IF EXISTS version;
+
+-- +goose StatementEnd
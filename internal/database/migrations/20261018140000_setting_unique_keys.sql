@@ -0,0 +1,34 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Keep only the most recently updated of the settings that were duplicated before keys were unique,
+-- recording the deletion of the others in their history so that they can be rolled back to
+WITH duplicates AS (
+  UPDATE settings s SET deleted_at = NOW()
+  FROM settings d
+  WHERE s.deleted_at IS NULL
+    AND d.deleted_at IS NULL
+    AND d.key = s.key
+    AND d.scope = s.scope
+    AND d.scope_id IS NOT DISTINCT FROM s.scope_id
+    AND (d.updated_at, d.id) > (s.updated_at, s.id)
+  RETURNING s.id, s.key, s.value, s.scope, s.scope_id, s.sensitive, s.data_key_id
+)
+INSERT INTO setting_revisions (setting_id, key, value, scope, scope_id, sensitive, data_key_id, operation)
+SELECT DISTINCT id, key, value, scope, scope_id, sensitive, data_key_id, 'delete'
+FROM duplicates;
+
+-- A key can only be used once per scope, global settings having no scope ID
+CREATE UNIQUE INDEX IF NOT EXISTS settings_key_scope_unique_idx
+  ON settings (key, scope, COALESCE(scope_id, '00000000-0000-0000-0000-000000000000'))
+  WHERE deleted_at IS NULL;
+
+DROP INDEX IF EXISTS settings_key_scope_idx;
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS settings_key_scope_idx ON settings (key, scope, scope_id) WHERE deleted_at IS NULL;
+
+DROP INDEX IF EXISTS settings_key_scope_unique_idx;
+
+-- +goose StatementEnd
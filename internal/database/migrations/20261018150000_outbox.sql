@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS outbox (
+  id UUID DEFAULT gen_random_uuid() NOT NULL,
+  topic VARCHAR NOT NULL,
+  aggregate_id UUID NOT NULL,
+  payload JSONB NOT NULL,
+  attempts INT DEFAULT 0 NOT NULL,
+  last_error TEXT,
+  available_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL,
+  published_at TIMESTAMP WITH TIME ZONE,
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL,
+  PRIMARY KEY (id)
+);
+
+-- Only the events that are yet to be published are looked up by the relay
+CREATE INDEX IF NOT EXISTS outbox_pending_idx ON outbox (available_at, created_at) WHERE published_at IS NULL;
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS outbox;
+
+-- +goose StatementEnd
@@ -0,0 +1,35 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS jobs (
+  id UUID DEFAULT gen_random_uuid() NOT NULL,
+  name VARCHAR NOT NULL,
+  args JSONB DEFAULT '{}'::JSONB NOT NULL,
+  status VARCHAR DEFAULT 'pending' NOT NULL,
+  unique_key VARCHAR,
+  attempts INT DEFAULT 0 NOT NULL,
+  max_attempts INT DEFAULT 5 NOT NULL,
+  last_error TEXT,
+  run_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL,
+  locked_at TIMESTAMP WITH TIME ZONE,
+  finished_at TIMESTAMP WITH TIME ZONE,
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL,
+  updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL,
+  PRIMARY KEY (id),
+  CONSTRAINT jobs_status_check CHECK (status IN ('pending', 'running', 'succeeded', 'dead'))
+);
+
+-- Only the jobs that are waiting to run are looked up by the workers
+CREATE INDEX IF NOT EXISTS jobs_pending_idx ON jobs (run_at) WHERE status = 'pending';
+
+-- Running jobs are looked up to rescue the ones whose worker went away
+CREATE INDEX IF NOT EXISTS jobs_running_idx ON jobs (locked_at) WHERE status = 'running';
+
+-- A unique key can only be used by one job at a time, until that job is finished
+CREATE UNIQUE INDEX IF NOT EXISTS jobs_unique_key_idx ON jobs (unique_key) WHERE status IN ('pending', 'running');
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS jobs;
+
+-- +goose StatementEnd
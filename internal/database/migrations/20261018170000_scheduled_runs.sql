@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS scheduled_runs (
+  id UUID DEFAULT gen_random_uuid() NOT NULL,
+  task VARCHAR NOT NULL,
+  scheduled_at TIMESTAMP WITH TIME ZONE NOT NULL,
+  status VARCHAR DEFAULT 'running' NOT NULL,
+  error TEXT,
+  instance VARCHAR NOT NULL,
+  started_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL,
+  finished_at TIMESTAMP WITH TIME ZONE,
+  PRIMARY KEY (id),
+  CONSTRAINT scheduled_runs_status_check CHECK (status IN ('running', 'succeeded', 'failed'))
+);
+
+-- A run is only ever started once, even if the leadership changes hands while it is due
+CREATE UNIQUE INDEX IF NOT EXISTS scheduled_runs_task_scheduled_at_idx ON scheduled_runs (task, scheduled_at DESC);
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS scheduled_runs;
+
+-- +goose StatementEnd
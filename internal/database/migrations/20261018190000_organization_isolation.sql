@@ -0,0 +1,73 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Settings & their revisions are owned by an organization, or shared by all of them when it's NULL
+ALTER TABLE settings
+  ADD COLUMN IF NOT EXISTS organization_id UUID;
+
+ALTER TABLE setting_revisions
+  ADD COLUMN IF NOT EXISTS organization_id UUID;
+
+UPDATE settings SET organization_id = scope_id WHERE scope = 'organization' AND organization_id IS NULL;
+UPDATE setting_revisions SET organization_id = scope_id WHERE scope = 'organization' AND organization_id IS NULL;
+
+CREATE INDEX IF NOT EXISTS settings_organization_idx ON settings (organization_id) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS setting_revisions_organization_idx ON setting_revisions (organization_id);
+
+-- The role that the platform switches to for the transactions of callers acting for an organization.
+-- Roles are shared by all the databases of the cluster, so it is only created once.
+DO $$
+BEGIN
+  IF NOT EXISTS (SELECT FROM pg_roles WHERE rolname = 'app_tenant') THEN
+    CREATE ROLE app_tenant NOLOGIN;
+  END IF;
+END
+$$;
+
+GRANT app_tenant TO CURRENT_USER;
+GRANT USAGE ON SCHEMA public TO app_tenant;
+GRANT SELECT, INSERT, UPDATE, DELETE ON ALL TABLES IN SCHEMA public TO app_tenant;
+ALTER DEFAULT PRIVILEGES IN SCHEMA public GRANT SELECT, INSERT, UPDATE, DELETE ON TABLES TO app_tenant;
+
+-- Organizations can see their own rows along with the shared ones, but only change their own.
+-- The policies only apply to app_tenant, so the platform itself still sees every row.
+ALTER TABLE settings ENABLE ROW LEVEL SECURITY;
+ALTER TABLE setting_revisions ENABLE ROW LEVEL SECURITY;
+
+CREATE POLICY settings_organization_read ON settings FOR SELECT TO app_tenant
+  USING (organization_id IS NULL OR organization_id = NULLIF(current_setting('app.org_id', true), '')::uuid);
+CREATE POLICY settings_organization_write ON settings FOR ALL TO app_tenant
+  USING (organization_id = NULLIF(current_setting('app.org_id', true), '')::uuid)
+  WITH CHECK (organization_id = NULLIF(current_setting('app.org_id', true), '')::uuid);
+
+CREATE POLICY setting_revisions_organization_read ON setting_revisions FOR SELECT TO app_tenant
+  USING (organization_id IS NULL OR organization_id = NULLIF(current_setting('app.org_id', true), '')::uuid);
+CREATE POLICY setting_revisions_organization_write ON setting_revisions FOR ALL TO app_tenant
+  USING (organization_id = NULLIF(current_setting('app.org_id', true), '')::uuid)
+  WITH CHECK (organization_id = NULLIF(current_setting('app.org_id', true), '')::uuid);
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP POLICY IF EXISTS setting_revisions_organization_write ON setting_revisions;
+DROP POLICY IF EXISTS setting_revisions_organization_read ON setting_revisions;
+DROP POLICY IF EXISTS settings_organization_write ON settings;
+DROP POLICY IF EXISTS settings_organization_read ON settings;
+
+ALTER TABLE setting_revisions DISABLE ROW LEVEL SECURITY;
+ALTER TABLE settings DISABLE ROW LEVEL SECURITY;
+
+-- The role is left in place, since the other databases of the cluster might still use it
+ALTER DEFAULT PRIVILEGES IN SCHEMA public REVOKE SELECT, INSERT, UPDATE, DELETE ON TABLES FROM app_tenant;
+REVOKE SELECT, INSERT, UPDATE, DELETE ON ALL TABLES IN SCHEMA public FROM app_tenant;
+REVOKE USAGE ON SCHEMA public FROM app_tenant;
+
+DROP INDEX IF EXISTS setting_revisions_organization_idx;
+DROP INDEX IF EXISTS settings_organization_idx;
+
+ALTER TABLE setting_revisions
+  DROP COLUMN IF EXISTS organization_id;
+
+ALTER TABLE settings
+  DROP COLUMN IF EXISTS organization_id;
+
+-- +goose StatementEnd
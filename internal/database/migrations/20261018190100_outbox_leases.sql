@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Events are claimed by a relay for a while instead of being locked while they are published
+ALTER TABLE outbox
+  ADD COLUMN IF NOT EXISTS locked_at TIMESTAMP WITH TIME ZONE;
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE outbox
+  DROP COLUMN IF EXISTS locked_at;
+
+-- +goose StatementEnd
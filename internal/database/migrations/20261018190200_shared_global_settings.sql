@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Only global settings are shared by all the organizations. Other settings owned by no organization, such as the user settings
+-- created by the platform itself, are kept from every organization instead.
+ALTER POLICY settings_organization_read ON settings
+  USING (scope = 'global' OR organization_id = NULLIF(current_setting('app.org_id', true), '')::uuid);
+
+ALTER POLICY setting_revisions_organization_read ON setting_revisions
+  USING (scope = 'global' OR organization_id = NULLIF(current_setting('app.org_id', true), '')::uuid);
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+ALTER POLICY setting_revisions_organization_read ON setting_revisions
+  USING (organization_id IS NULL OR organization_id = NULLIF(current_setting('app.org_id', true), '')::uuid);
+
+ALTER POLICY settings_organization_read ON settings
+  USING (organization_id IS NULL OR organization_id = NULLIF(current_setting('app.org_id', true), '')::uuid);
+
+-- +goose StatementEnd
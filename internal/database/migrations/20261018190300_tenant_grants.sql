@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+-- The tenant role only needs the tables owned by organizations, rather than every table including the ones made later
+ALTER DEFAULT PRIVILEGES IN SCHEMA public REVOKE SELECT, INSERT, UPDATE, DELETE ON TABLES FROM app_tenant;
+REVOKE SELECT, INSERT, UPDATE, DELETE ON ALL TABLES IN SCHEMA public FROM app_tenant;
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON settings, setting_revisions TO app_tenant;
+
+-- Changes to settings record their events in the outbox, in the same transaction.
+-- Only the columns that are written, and the ones read back once they are, are granted.
+GRANT INSERT (topic, aggregate_id, payload) ON outbox TO app_tenant;
+GRANT SELECT (id, available_at, created_at) ON outbox TO app_tenant;
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+REVOKE SELECT (id, available_at, created_at) ON outbox FROM app_tenant;
+REVOKE INSERT (topic, aggregate_id, payload) ON outbox FROM app_tenant;
+REVOKE SELECT, INSERT, UPDATE, DELETE ON settings, setting_revisions FROM app_tenant;
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON ALL TABLES IN SCHEMA public TO app_tenant;
+ALTER DEFAULT PRIVILEGES IN SCHEMA public GRANT SELECT, INSERT, UPDATE, DELETE ON TABLES TO app_tenant;
+
+-- +goose StatementEnd
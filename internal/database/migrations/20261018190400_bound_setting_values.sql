@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Sensitive values are now bound to their setting & data key when they are encrypted.
+-- The data keys that encrypted values before then are retired as legacy keys, so that their values are re-encrypted in the background.
+ALTER TABLE setting_data_keys
+  ADD COLUMN IF NOT EXISTS legacy BOOLEAN DEFAULT FALSE NOT NULL;
+
+UPDATE setting_data_keys SET legacy = TRUE, retired_at = COALESCE(retired_at, NOW());
+
+-- The tenant role finds the active data key within its transactions, without being able to read the encrypted keys
+GRANT SELECT (id, master_key_name, retired_at) ON setting_data_keys TO app_tenant;
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+REVOKE SELECT (id, master_key_name, retired_at) ON setting_data_keys FROM app_tenant;
+
+-- Values encrypted since can't be decrypted without their binding, so only roll back before any is written
+ALTER TABLE setting_data_keys
+  DROP COLUMN IF EXISTS legacy;
+
+-- +goose StatementEnd
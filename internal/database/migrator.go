@@ -0,0 +1,424 @@
+package database
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// migrationsFS holds the migrations, embedded in the binary
+//
+//go:embed migrations/*.sql
+var migrationsFS embed.FS
+
+// versionTable is the table recording the applied migrations.
+// It is the table used by the goose CLI, so that databases migrated with it carry on from where they are.
+const versionTable = "goose_db_version"
+
+// recordApplied & recordRolledBack record that a migration was applied & rolled back, the way goose does
+const (
+	recordApplied    = `INSERT INTO ` + versionTable + ` (version_id, is_applied) VALUES ($1, TRUE)`
+	recordRolledBack = `DELETE FROM ` + versionTable + ` WHERE version_id = $1`
+)
+
+// migrationLockKey is the key of the advisory lock held while migrating, so that instances don't migrate at the same time
+const migrationLockKey = "app-platform:migrations"
+
+// migrationNamePattern is the pattern of the names given to new migrations
+var migrationNamePattern = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// Migration is a migration embedded in the binary
+type Migration struct {
+	// Version is the timestamp that prefixes the file name, which orders the migrations
+	Version int64
+	Name    string
+
+	up   []string
+	down []string
+	// noTransaction is set for the migrations that can't run in a transaction, e.g. to create an index concurrently
+	noTransaction bool
+}
+
+// MigrationStatus is a migration along with the time it was applied, if it was
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// Migrator applies & rolls back the embedded migrations
+type Migrator struct {
+	db         *pgxpool.Pool
+	migrations []Migration
+}
+
+// NewMigrator creates a new migrator for the migrations embedded in the binary
+func NewMigrator(db *pgxpool.Pool) (*Migrator, error) {
+	migrations, err := loadMigrations(migrationsFS, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{
+		db:         db,
+		migrations: migrations,
+	}, nil
+}
+
+// Up applies the pending migrations in order, and returns the ones applied
+func (m *Migrator) Up(ctx context.Context) (result []Migration, err error) {
+	err = m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, v := range m.migrations {
+			if _, ok := applied[v.Version]; ok {
+				continue
+			}
+			err = m.apply(ctx, conn, v, v.up, recordApplied)
+			if err != nil {
+				return err
+			}
+			result = append(result, v)
+		}
+		return nil
+	})
+	return result, err
+}
+
+// Down rolls back the latest applied migration, and returns it
+func (m *Migrator) Down(ctx context.Context) (result []Migration, err error) {
+	err = m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		result, err = m.down(ctx, conn)
+		return err
+	})
+	return result, err
+}
+
+// Redo rolls back the latest applied migration & applies it again, and returns it
+func (m *Migrator) Redo(ctx context.Context) (result []Migration, err error) {
+	err = m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		result, err = m.down(ctx, conn)
+		if err != nil || len(result) == 0 {
+			return err
+		}
+		return m.apply(ctx, conn, result[0], result[0].up, recordApplied)
+	})
+	return result, err
+}
+
+// Status lists the migrations, along with the time they were applied
+func (m *Migrator) Status(ctx context.Context) (result []MigrationStatus, err error) {
+	err = m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		result = make([]MigrationStatus, 0, len(m.migrations))
+		for _, v := range m.migrations {
+			status := MigrationStatus{Migration: v}
+			if t, ok := applied[v.Version]; ok {
+				status.AppliedAt = &t
+			}
+			result = append(result, status)
+		}
+		return nil
+	})
+	return result, err
+}
+
+// down rolls back the latest applied migration, if any
+func (m *Migrator) down(ctx context.Context, conn *pgxpool.Conn) (result []Migration, err error) {
+	applied, err := m.applied(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range slices.Backward(m.migrations) {
+		if _, ok := applied[v.Version]; !ok {
+			continue
+		}
+		err = m.apply(ctx, conn, v, v.down, recordRolledBack)
+		if err != nil {
+			return nil, err
+		}
+		return []Migration{v}, nil
+	}
+	return nil, nil
+}
+
+// withLock runs the function on a connection holding the migration lock, creating the version table if needed
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *pgxpool.Conn) error) (err error) {
+	// Acquire a dedicated connection, since the lock belongs to the session
+	conn, err := m.db.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	// Lift the statement timeout for the session, since waiting for the lock & migrating can take a while
+	_, err = conn.Exec(ctx, `SET statement_timeout = 0`)
+	if err != nil {
+		return err
+	}
+
+	// Wait for the lock, and release it once done, restoring the statement timeout
+	_, err = conn.Exec(ctx, `SELECT pg_advisory_lock(hashtext($1))`, migrationLockKey)
+	if err != nil {
+		_ = conn.Conn().Close(context.WithoutCancel(ctx))
+		return fmt.Errorf("failed to take the migration lock: %w", err)
+	}
+	defer func() {
+		ctx := context.WithoutCancel(ctx)
+		_, unlockErr := conn.Exec(ctx, `SELECT pg_advisory_unlock(hashtext($1))`, migrationLockKey)
+		if unlockErr == nil {
+			_, unlockErr = conn.Exec(ctx, `RESET statement_timeout`)
+		}
+		if unlockErr != nil {
+			// Close the session rather than return it to the pool still holding the lock, or without its timeout
+			_ = conn.Conn().Close(ctx)
+			err = errors.Join(err, fmt.Errorf("failed to release the migration lock: %w", unlockErr))
+		}
+	}()
+
+	err = m.ensureVersionTable(ctx, conn)
+	if err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+// ensureVersionTable creates the version table, unless it exists
+func (m *Migrator) ensureVersionTable(ctx context.Context, conn *pgxpool.Conn) error {
+	var exists bool
+	err := conn.QueryRow(ctx, `SELECT to_regclass($1) IS NOT NULL`, versionTable).Scan(&exists)
+	if err != nil || exists {
+		return err
+	}
+
+	// Create the table the way goose does, starting from version 0
+	return pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `CREATE TABLE `+versionTable+` (
+			id SERIAL NOT NULL,
+			version_id BIGINT NOT NULL,
+			is_applied BOOLEAN NOT NULL,
+			tstamp TIMESTAMP DEFAULT NOW(),
+			PRIMARY KEY (id)
+		)`)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, `INSERT INTO `+versionTable+` (version_id, is_applied) VALUES (0, TRUE)`)
+		return err
+	})
+}
+
+// applied finds the applied migrations, along with the time they were applied, by version
+func (m *Migrator) applied(ctx context.Context, conn *pgxpool.Conn) (result map[int64]time.Time, err error) {
+	rows, err := conn.Query(ctx, `SELECT version_id, is_applied, tstamp FROM `+versionTable+` ORDER BY id DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// The latest row of a version tells if it is applied
+	result = make(map[int64]time.Time)
+	seen := make(map[int64]bool)
+	for rows.Next() {
+		var version int64
+		var isApplied bool
+		var tstamp *time.Time
+		err = rows.Scan(&version, &isApplied, &tstamp)
+		if err != nil {
+			return nil, err
+		}
+		if seen[version] {
+			continue
+		}
+		seen[version] = true
+		if isApplied && version != 0 {
+			result[version] = time.Time{}
+			if tstamp != nil {
+				result[version] = *tstamp
+			}
+		}
+	}
+	return result, rows.Err()
+}
+
+// apply runs the statements of a migration and records it with the query given, in a transaction unless the migration opts out of it
+func (m *Migrator) apply(ctx context.Context, conn *pgxpool.Conn, v Migration, statements []string, record string) error {
+	if v.noTransaction {
+		return runMigration(ctx, conn, v, statements, record)
+	}
+	return pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+		return runMigration(ctx, tx, v, statements, record)
+	})
+}
+
+// runMigration runs the statements of a migration one by one, and records it
+func runMigration(ctx context.Context, q interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}, v Migration, statements []string, record string) error {
+	for _, s := range statements {
+		_, err := q.Exec(ctx, s)
+		if err != nil {
+			return fmt.Errorf("failed to run the migration %s: %w", v.Name, err)
+		}
+	}
+	_, err := q.Exec(ctx, record, v.Version)
+	return err
+}
+
+// loadMigrations loads & parses the migrations in the directory, ordered by version
+func loadMigrations(fsys fs.FS, dir string) (result []Migration, err error) {
+	names, err := fs.Glob(fsys, path.Join(dir, "*.sql"))
+	if err != nil {
+		return nil, err
+	}
+	versions := make(map[int64]string)
+	for _, name := range names {
+		b, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		v, err := parseMigration(path.Base(name), string(b))
+		if err != nil {
+			return nil, err
+		}
+		if other, ok := versions[v.Version]; ok {
+			return nil, fmt.Errorf("migrations %s & %s have the same version", other, v.Name)
+		}
+		versions[v.Version] = v.Name
+		result = append(result, v)
+	}
+	slices.SortFunc(result, func(a, b Migration) int {
+		return cmp.Compare(a.Version, b.Version)
+	})
+	return result, nil
+}
+
+// parseMigration parses a migration in the goose format.
+// Statements run up to the line ending with a semicolon, or from a StatementBegin annotation to a StatementEnd one.
+func parseMigration(name string, content string) (result Migration, err error) {
+	result.Name = name
+	prefix, _, ok := strings.Cut(name, "_")
+	if !ok {
+		return result, fmt.Errorf("invalid migration name %s: expected <version>_<name>.sql", name)
+	}
+	result.Version, err = strconv.ParseInt(prefix, 10, 64)
+	if err != nil || result.Version <= 0 {
+		return result, fmt.Errorf("invalid migration name %s: expected <version>_<name>.sql", name)
+	}
+
+	var target *[]string
+	var statement strings.Builder
+	var inBlock, hasUp bool
+	flush := func() {
+		if s := strings.TrimSpace(statement.String()); s != "" {
+			*target = append(*target, s)
+		}
+		statement.Reset()
+	}
+	pending := func() bool {
+		for _, line := range strings.Split(statement.String(), "\n") {
+			line = strings.TrimSpace(line)
+			if line != "" && !strings.HasPrefix(line, "--") {
+				return true
+			}
+		}
+		return false
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+
+		// Handle the annotations
+		if annotation, ok := strings.CutPrefix(trimmed, "-- +goose"); ok {
+			annotation = strings.ToUpper(strings.TrimSpace(annotation))
+			switch {
+			case annotation == "UP" || annotation == "DOWN":
+				if inBlock || pending() {
+					return result, fmt.Errorf("invalid migration %s: unterminated statement before the %s annotation", name, annotation)
+				}
+				statement.Reset()
+				target = &result.up
+				hasUp = true
+				if annotation == "DOWN" {
+					target = &result.down
+				}
+			case annotation == "STATEMENTBEGIN" && target != nil && !inBlock:
+				if pending() {
+					return result, fmt.Errorf("invalid migration %s: unterminated statement before StatementBegin", name)
+				}
+				statement.Reset()
+				inBlock = true
+			case annotation == "STATEMENTEND" && inBlock:
+				flush()
+				inBlock = false
+			case annotation == "NO TRANSACTION":
+				result.noTransaction = true
+			default:
+				return result, fmt.Errorf("invalid migration %s: unexpected annotation %q", name, trimmed)
+			}
+			continue
+		}
+
+		// Ignore what comes before the up migration, which can only be comments
+		if target == nil {
+			if trimmed != "" && !strings.HasPrefix(trimmed, "--") {
+				return result, fmt.Errorf("invalid migration %s: statement before the Up annotation", name)
+			}
+			continue
+		}
+
+		statement.WriteString(line)
+		statement.WriteString("\n")
+		code, _, _ := strings.Cut(trimmed, "--")
+		if !inBlock && strings.HasSuffix(strings.TrimSpace(code), ";") {
+			flush()
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return result, fmt.Errorf("invalid migration %s: %w", name, err)
+	}
+	if !hasUp {
+		return result, fmt.Errorf("invalid migration %s: missing the Up annotation", name)
+	}
+	if inBlock || pending() {
+		return result, fmt.Errorf("invalid migration %s: unterminated statement", name)
+	}
+	return result, nil
+}
+
+// CreateMigration creates an empty migration in the directory, versioned with the current time, and returns its path
+func CreateMigration(dir string, name string) (result string, err error) {
+	if !migrationNamePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid migration name %q: expected lowercase letters, digits & underscores", name)
+	}
+	result = filepath.Join(dir, fmt.Sprintf("%s_%s.sql", time.Now().UTC().Format("20060102150405"), name))
+	content := "-- +goose Up\n-- +goose StatementBegin\n\n-- +goose StatementEnd\n-- +goose Down\n-- +goose StatementBegin\n\n-- +goose StatementEnd\n"
+	f, err := os.OpenFile(result, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return "", err
+	}
+	_, err = f.WriteString(content)
+	return result, errors.Join(err, f.Close())
+}
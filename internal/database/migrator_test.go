@@ -0,0 +1,140 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestParseMigration(t *testing.T) {
+	t.Run("success - parse the statements of both directions", func(t *testing.T) {
+		content := `-- Creates the widgets
+-- +goose Up
+CREATE TABLE widgets (id INT); -- the table
+CREATE INDEX widgets_id_idx
+  ON widgets (id);
+
+-- +goose StatementBegin
+CREATE FUNCTION touch() RETURNS TRIGGER AS $$
+BEGIN
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose Down
+DROP FUNCTION touch;
+DROP TABLE widgets;
+`
+		got, err := parseMigration("20261018090000_widgets.sql", content)
+		if err != nil {
+			t.Fatalf("Error parsing the migration: %v", err)
+		}
+		if got.Version != 20261018090000 {
+			t.Errorf("Expected the version to be 20261018090000, got %d", got.Version)
+		}
+		if len(got.up) != 3 || len(got.down) != 2 {
+			t.Fatalf("Expected 3 up & 2 down statements, got %q & %q", got.up, got.down)
+		}
+		if !strings.HasPrefix(got.up[1], "CREATE INDEX") || !strings.HasSuffix(got.up[1], "ON widgets (id);") {
+			t.Errorf("Expected the index to be created by a statement spanning two lines, got %q", got.up[1])
+		}
+		if !strings.HasSuffix(got.up[2], "$$ LANGUAGE plpgsql;") {
+			t.Errorf("Expected the function to be created by a single statement, got %q", got.up[2])
+		}
+		if got.noTransaction {
+			t.Errorf("Expected the migration to run in a transaction")
+		}
+	})
+
+	t.Run("success - opt out of the transaction", func(t *testing.T) {
+		content := "-- +goose NO TRANSACTION\n-- +goose Up\nCREATE INDEX CONCURRENTLY widgets_id_idx ON widgets (id);\n-- +goose Down\nDROP INDEX widgets_id_idx;\n"
+		got, err := parseMigration("20261018090000_widgets.sql", content)
+		if err != nil {
+			t.Fatalf("Error parsing the migration: %v", err)
+		}
+		if !got.noTransaction {
+			t.Errorf("Expected the migration to run outside of a transaction")
+		}
+	})
+
+	for name, tt := range map[string]struct {
+		name    string
+		content string
+	}{
+		"missing version":        {"widgets.sql", "-- +goose Up\nSELECT 1;\n"},
+		"missing up annotation":  {"1_widgets.sql", "SELECT 1;\n"},
+		"unterminated statement": {"1_widgets.sql", "-- +goose Up\nSELECT 1\n-- +goose Down\n"},
+		"unterminated block":     {"1_widgets.sql", "-- +goose Up\n-- +goose StatementBegin\nSELECT 1;\n"},
+		"unknown annotation":     {"1_widgets.sql", "-- +goose Up\n-- +goose Sideways\n"},
+	} {
+		t.Run("failure - "+name, func(t *testing.T) {
+			_, err := parseMigration(tt.name, tt.content)
+			if err == nil {
+				t.Errorf("Expected an error parsing the migration")
+			}
+		})
+	}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	t.Run("success - load the embedded migrations in order", func(t *testing.T) {
+		got, err := loadMigrations(migrationsFS, "migrations")
+		if err != nil {
+			t.Fatalf("Error loading the migrations: %v", err)
+		}
+		if len(got) == 0 {
+			t.Fatalf("Expected the migrations to be embedded")
+		}
+		if !slices.IsSortedFunc(got, func(a, b Migration) int { return int(a.Version - b.Version) }) {
+			t.Errorf("Expected the migrations to be ordered by version")
+		}
+		for _, v := range got {
+			if len(v.up) == 0 || len(v.down) == 0 {
+				t.Errorf("Expected the migration %s to have both up & down statements", v.Name)
+			}
+		}
+	})
+
+	t.Run("failure - duplicate version", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"migrations/1_a.sql": {Data: []byte("-- +goose Up\nSELECT 1;\n")},
+			"migrations/1_b.sql": {Data: []byte("-- +goose Up\nSELECT 1;\n")},
+		}
+		_, err := loadMigrations(fsys, "migrations")
+		if err == nil {
+			t.Errorf("Expected an error loading migrations with the same version")
+		}
+	})
+}
+
+func TestCreateMigration(t *testing.T) {
+	t.Run("success - create an empty migration", func(t *testing.T) {
+		dir := t.TempDir()
+		got, err := CreateMigration(dir, "add_widgets")
+		if err != nil {
+			t.Fatalf("Error creating the migration: %v", err)
+		}
+		if filepath.Dir(got) != dir || !strings.HasSuffix(got, "_add_widgets.sql") {
+			t.Errorf("Expected the migration to be created in %s, got %s", dir, got)
+		}
+		b, err := os.ReadFile(got)
+		if err != nil {
+			t.Fatalf("Error reading the migration: %v", err)
+		}
+		_, err = parseMigration(filepath.Base(got), string(b))
+		if err != nil {
+			t.Errorf("Expected the new migration to be valid, got %v", err)
+		}
+	})
+
+	t.Run("failure - invalid name", func(t *testing.T) {
+		_, err := CreateMigration(t.TempDir(), "Add Widgets")
+		if err == nil {
+			t.Errorf("Expected an error creating a migration with an invalid name")
+		}
+	})
+}
@@ -0,0 +1,141 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/Intiqo/app-platform/internal/pkg/config"
+)
+
+// replicaCheckPeriod is how often the replicas are checked for health
+const replicaCheckPeriod = 5 * time.Second
+
+// replicaCheckTimeout is the time a replica has to answer a health check before it is considered down
+const replicaCheckTimeout = 2 * time.Second
+
+// Replicas routes reads to the read replicas, in turns among the ones that are healthy.
+// A nil or empty Replicas has no replica to offer, so that reads go to the primary.
+type Replicas struct {
+	replicas []*replica
+	next     atomic.Uint64
+}
+
+// replica is a read replica, along with its health
+type replica struct {
+	host    string
+	pool    *pgxpool.Pool
+	healthy atomic.Bool
+}
+
+// NewReplicas connects to the read replicas in DB_REPLICA_URLS, and starts checking their health.
+// Replicas only take reads once they pass their first health check.
+// The returned cleanup function stops the health checks and closes the connections to the replicas.
+func NewReplicas(cfg config.AppConfig) (*Replicas, func(), error) {
+	r := &Replicas{}
+	closeAll := func() {
+		for _, v := range r.replicas {
+			v.pool.Close()
+		}
+	}
+
+	// Connect to the replicas, with the same pool & session settings as the primary
+	for _, dsn := range strings.Split(cfg.DatabaseReplicaUrls, ",") {
+		dsn = strings.TrimSpace(dsn)
+		if dsn == "" {
+			continue
+		}
+		dbconfig, err := pgxpool.ParseConfig(dsn)
+		if err != nil {
+			closeAll()
+			return nil, nil, fmt.Errorf("failed to parse the config of read replica %d: %w", len(r.replicas)+1, err)
+		}
+		err = configurePool(dbconfig, cfg)
+		if err != nil {
+			closeAll()
+			return nil, nil, err
+		}
+		pool, err := pgxpool.NewWithConfig(context.Background(), dbconfig)
+		if err != nil {
+			closeAll()
+			return nil, nil, fmt.Errorf("failed to create the connection pool of read replica %s: %w", dbconfig.ConnConfig.Host, err)
+		}
+		r.replicas = append(r.replicas, &replica{host: dbconfig.ConnConfig.Host, pool: pool})
+	}
+	if len(r.replicas) == 0 {
+		return r, func() {}, nil
+	}
+
+	// Check the health of the replicas until cleaned up
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		r.monitor(ctx)
+	}()
+	cleanup := func() {
+		cancel()
+		wg.Wait()
+		closeAll()
+	}
+	return r, cleanup, nil
+}
+
+// Pick picks the replica whose turn it is to take a read, skipping the unhealthy ones.
+// It returns nil when no replica is healthy.
+func (r *Replicas) Pick() *pgxpool.Pool {
+	if r == nil || len(r.replicas) == 0 {
+		return nil
+	}
+	// Skipped replicas use up their turn, so that the healthy ones keep taking the same share of the reads
+	for range r.replicas {
+		v := r.replicas[r.next.Add(1)%uint64(len(r.replicas))]
+		if v.healthy.Load() {
+			return v.pool
+		}
+	}
+	return nil
+}
+
+// monitor checks the health of the replicas right away, and then periodically until the context is done
+func (r *Replicas) monitor(ctx context.Context) {
+	ticker := time.NewTicker(replicaCheckPeriod)
+	defer ticker.Stop()
+	for {
+		for _, v := range r.replicas {
+			v.check(ctx)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// check checks if the replica answers in time, logging when its health changes
+func (v *replica) check(ctx context.Context) {
+	checkCtx, cancel := context.WithTimeout(ctx, replicaCheckTimeout)
+	err := v.pool.Ping(checkCtx)
+	cancel()
+	if ctx.Err() != nil {
+		return
+	}
+
+	healthy := err == nil
+	if v.healthy.Swap(healthy) == healthy {
+		return
+	}
+	if healthy {
+		slog.Info("read replica is healthy, sending reads to it", "host", v.host)
+	} else {
+		slog.Warn("read replica is unhealthy, sending reads elsewhere", "host", v.host, "error", err)
+	}
+}
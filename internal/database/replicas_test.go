@@ -0,0 +1,55 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/Intiqo/app-platform/internal/pkg/config"
+)
+
+func TestReplicas(t *testing.T) {
+	t.Run("success - take reads in turns among the healthy replicas", func(t *testing.T) {
+		a, b, c := &replica{pool: new(pgxpool.Pool)}, &replica{pool: new(pgxpool.Pool)}, &replica{pool: new(pgxpool.Pool)}
+		a.healthy.Store(true)
+		c.healthy.Store(true)
+		r := &Replicas{replicas: []*replica{a, b, c}}
+
+		picked := map[*pgxpool.Pool]int{}
+		for range 10 {
+			picked[r.Pick()]++
+		}
+		if picked[a.pool] != 5 || picked[c.pool] != 5 || picked[b.pool] != 0 {
+			t.Errorf("Expected the reads to be shared by the healthy replicas, got %d, %d & %d", picked[a.pool], picked[b.pool], picked[c.pool])
+		}
+	})
+
+	t.Run("success - offer no replica when none is healthy", func(t *testing.T) {
+		r := &Replicas{replicas: []*replica{{pool: new(pgxpool.Pool)}}}
+		if r.Pick() != nil {
+			t.Errorf("Expected no replica to be picked")
+		}
+		var none *Replicas
+		if none.Pick() != nil {
+			t.Errorf("Expected no replica to be picked without replicas")
+		}
+	})
+
+	t.Run("success - connect to no replica unless configured", func(t *testing.T) {
+		r, cleanup, err := NewReplicas(config.AppConfig{DatabaseReplicaUrls: " , "})
+		if err != nil {
+			t.Fatalf("Error creating the replicas: %v", err)
+		}
+		defer cleanup()
+		if r.Pick() != nil {
+			t.Errorf("Expected no replica to be picked")
+		}
+	})
+
+	t.Run("failure - invalid replica url", func(t *testing.T) {
+		_, _, err := NewReplicas(config.AppConfig{DatabaseReplicaUrls: "postgres://replica:5432/app?sslmode=sometimes"})
+		if err == nil {
+			t.Errorf("Expected an error creating the replicas")
+		}
+	})
+}
@@ -8,12 +8,17 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 
 	"github.com/Intiqo/app-platform/internal/database"
+	"github.com/Intiqo/app-platform/internal/domain"
 	"github.com/Intiqo/app-platform/internal/http/api"
 	"github.com/Intiqo/app-platform/internal/http/handler"
+	"github.com/Intiqo/app-platform/internal/jobs"
 	aAws "github.com/Intiqo/app-platform/internal/pkg/cloud/aws"
 	"github.com/Intiqo/app-platform/internal/pkg/config"
+	"github.com/Intiqo/app-platform/internal/pkg/publisher"
 	"github.com/Intiqo/app-platform/internal/pkg/secrets"
+	"github.com/Intiqo/app-platform/internal/pkg/security"
 	"github.com/Intiqo/app-platform/internal/repository"
+	"github.com/Intiqo/app-platform/internal/scheduler"
 	"github.com/Intiqo/app-platform/internal/service"
 )
 
@@ -41,19 +46,87 @@ func NewDatabase(cfg config.AppConfig) (*pgxpool.Pool, error) {
 	return &pgxpool.Pool{}, nil
 }
 
+// NewSettingService returns a new SettingService, for use outside of the API
+func NewSettingService(cfg config.AppConfig, awsCfg aws.Config, db *pgxpool.Pool) (domain.SettingService, func(), error) {
+	wire.Build(
+		secrets.NewAWSSecretsManager,
+
+		database.NewReplicas,
+
+		repository.NewTransactioner,
+		repository.NewSettingKeyring,
+		repository.NewSettingRepository,
+		repository.NewSettingRevisionRepository,
+		repository.NewSettingChangeListener,
+		repository.NewOutboxRepository,
+
+		service.NewSettingService,
+		service.NewPolicyService,
+	)
+
+	return nil, nil, nil
+}
+
+// NewOutboxRelay returns a new OutboxRelay, which publishes the events recorded in the outbox in the background
+func NewOutboxRelay(cfg config.AppConfig, db *pgxpool.Pool) (domain.OutboxRelay, func(), error) {
+	wire.Build(
+		publisher.NewPublisher,
+
+		repository.NewTransactioner,
+		repository.NewOutboxRepository,
+
+		service.NewOutboxRelay,
+	)
+
+	return nil, nil, nil
+}
+
+// NewJobQueue returns a new job Queue
+func NewJobQueue(cfg config.AppConfig, db *pgxpool.Pool) *jobs.Queue {
+	wire.Build(
+		repository.NewJobRepository,
+
+		jobs.NewQueue,
+	)
+
+	return &jobs.Queue{}
+}
+
 // NewAppApi returns a new AppApi
-func NewAppApi(cfg config.AppConfig, awsCfg aws.Config, db *pgxpool.Pool) (*api.AppApi, error) {
+func NewAppApi(cfg config.AppConfig, awsCfg aws.Config, db *pgxpool.Pool) (*api.AppApi, func(), error) {
 	// Build the dependency graph
 	wire.Build(
+		secrets.NewAWSSecretsManager,
+
+		database.NewReplicas,
+
 		repository.NewTransactioner,
+		repository.NewSettingKeyring,
 		repository.NewSettingRepository,
+		repository.NewSettingRevisionRepository,
+		repository.NewSettingChangeListener,
+		repository.NewOutboxRepository,
+		repository.NewScheduledRunRepository,
+		repository.NewAdvisoryLocker,
+		repository.NewTokenStore,
+
+		security.NewKeyring,
+		security.NewJwtSecurityManager,
+
+		scheduler.NewScheduler,
 
 		service.NewSettingService,
+		service.NewFeatureFlagService,
+		service.NewSchedulerService,
+		service.NewPolicyService,
 
 		handler.NewSettingHandler,
+		handler.NewFeatureFlagHandler,
+		handler.NewSchedulerHandler,
+		handler.NewAuthHandler,
 
 		api.NewAppApi,
 	)
 
-	return &api.AppApi{}, nil
+	return &api.AppApi{}, nil, nil
 }
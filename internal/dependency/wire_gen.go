@@ -8,12 +8,17 @@ package dependency
 
 import (
 	"github.com/Intiqo/app-platform/internal/database"
+	"github.com/Intiqo/app-platform/internal/domain"
 	"github.com/Intiqo/app-platform/internal/http/api"
 	"github.com/Intiqo/app-platform/internal/http/handler"
+	"github.com/Intiqo/app-platform/internal/jobs"
 	aws2 "github.com/Intiqo/app-platform/internal/pkg/cloud/aws"
 	"github.com/Intiqo/app-platform/internal/pkg/config"
+	"github.com/Intiqo/app-platform/internal/pkg/publisher"
 	"github.com/Intiqo/app-platform/internal/pkg/secrets"
+	"github.com/Intiqo/app-platform/internal/pkg/security"
 	"github.com/Intiqo/app-platform/internal/repository"
+	"github.com/Intiqo/app-platform/internal/scheduler"
 	"github.com/Intiqo/app-platform/internal/service"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -38,16 +43,122 @@ func NewConfig(awsCfg aws.Config, options config.Options) (config.AppConfig, err
 
 // NewDatabase returns a new database connection pool
 func NewDatabase(cfg config.AppConfig) (*pgxpool.Pool, error) {
-	pool := database.NewDB(cfg)
+	pool, err := database.NewDB(cfg)
+	if err != nil {
+		return nil, err
+	}
 	return pool, nil
 }
 
+// NewSettingService returns a new SettingService, for use outside of the API
+func NewSettingService(cfg config.AppConfig, awsCfg aws.Config, db *pgxpool.Pool) (domain.SettingService, func(), error) {
+	transactioner := repository.NewTransactioner(db)
+	replicas, cleanup, err := database.NewReplicas(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	manager := secrets.NewAWSSecretsManager(awsCfg)
+	settingKeyring := repository.NewSettingKeyring(cfg, db, manager)
+	settingRepository := repository.NewSettingRepository(db, replicas, settingKeyring)
+	settingRevisionRepository := repository.NewSettingRevisionRepository(db, replicas, settingKeyring)
+	settingChangeListener := repository.NewSettingChangeListener(db)
+	outboxRepository := repository.NewOutboxRepository(db)
+	policyService, err := service.NewPolicyService(cfg)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	settingService, cleanup2, err := service.NewSettingService(transactioner, settingRepository, settingRevisionRepository, settingKeyring, settingChangeListener, outboxRepository, policyService)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	return settingService, func() {
+		cleanup2()
+		cleanup()
+	}, nil
+}
+
+// NewOutboxRelay returns a new OutboxRelay, which publishes the events recorded in the outbox in the background
+func NewOutboxRelay(cfg config.AppConfig, db *pgxpool.Pool) (domain.OutboxRelay, func(), error) {
+	publisherPublisher, err := publisher.NewPublisher(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	transactioner := repository.NewTransactioner(db)
+	outboxRepository := repository.NewOutboxRepository(db)
+	outboxRelay, cleanup, err := service.NewOutboxRelay(transactioner, outboxRepository, publisherPublisher)
+	if err != nil {
+		return nil, nil, err
+	}
+	return outboxRelay, func() {
+		cleanup()
+	}, nil
+}
+
+// NewJobQueue returns a new job Queue
+func NewJobQueue(cfg config.AppConfig, db *pgxpool.Pool) *jobs.Queue {
+	jobRepository := repository.NewJobRepository(db)
+	queue := jobs.NewQueue(cfg, jobRepository)
+	return queue
+}
+
 // NewAppApi returns a new AppApi
-func NewAppApi(cfg config.AppConfig, awsCfg aws.Config, db *pgxpool.Pool) (*api.AppApi, error) {
+func NewAppApi(cfg config.AppConfig, awsCfg aws.Config, db *pgxpool.Pool) (*api.AppApi, func(), error) {
 	transactioner := repository.NewTransactioner(db)
-	settingRepository := repository.NewSettingRepository(db)
-	settingService := service.NewSettingService(transactioner, settingRepository)
+	replicas, cleanup, err := database.NewReplicas(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	manager := secrets.NewAWSSecretsManager(awsCfg)
+	settingKeyring := repository.NewSettingKeyring(cfg, db, manager)
+	settingRepository := repository.NewSettingRepository(db, replicas, settingKeyring)
+	settingRevisionRepository := repository.NewSettingRevisionRepository(db, replicas, settingKeyring)
+	settingChangeListener := repository.NewSettingChangeListener(db)
+	outboxRepository := repository.NewOutboxRepository(db)
+	policyService, err := service.NewPolicyService(cfg)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	settingService, cleanup2, err := service.NewSettingService(transactioner, settingRepository, settingRevisionRepository, settingKeyring, settingChangeListener, outboxRepository, policyService)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
 	settingHandler := handler.NewSettingHandler(settingService)
-	appApi := api.NewAppApi(cfg, settingHandler)
-	return appApi, nil
+	featureFlagService := service.NewFeatureFlagService(settingService)
+	featureFlagHandler := handler.NewFeatureFlagHandler(featureFlagService)
+	scheduledRunRepository := repository.NewScheduledRunRepository(db, replicas)
+	locker := repository.NewAdvisoryLocker(db)
+	schedulerScheduler, cleanup3, err := scheduler.NewScheduler(scheduledRunRepository, locker)
+	if err != nil {
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	store := repository.NewTokenStore(db)
+	keyring, err := security.NewKeyring(cfg, manager)
+	if err != nil {
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	securityManager := security.NewJwtSecurityManager(cfg, store, keyring)
+	schedulerService, err := service.NewSchedulerService(cfg, schedulerScheduler, scheduledRunRepository, settingService, securityManager)
+	if err != nil {
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	schedulerHandler := handler.NewSchedulerHandler(schedulerService)
+	authHandler := handler.NewAuthHandler(securityManager)
+	appApi := api.NewAppApi(cfg, securityManager, policyService, settingHandler, featureFlagHandler, schedulerHandler, authHandler)
+	return appApi, func() {
+		cleanup3()
+		cleanup2()
+		cleanup()
+	}, nil
 }
@@ -0,0 +1,25 @@
+package domain
+
+import "time"
+
+type (
+	// RefreshTokenInput defines the input for exchanging a refresh token for a new pair of tokens
+	RefreshTokenInput struct {
+		RefreshToken string `json:"refreshToken" validate:"required" example:"c2VjcmV0LXJlZnJlc2gtdG9rZW4"`
+	} // @name RefreshTokenInput
+
+	// LogoutInput defines the input for logging out.
+	// The refresh token, if given, is revoked along with every token issued from it.
+	LogoutInput struct {
+		RefreshToken string `json:"refreshToken,omitempty" example:"c2VjcmV0LXJlZnJlc2gtdG9rZW4"`
+	} // @name LogoutInput
+
+	// AuthTokens is an access token, along with the refresh token that gets a new pair once the access token expires.
+	// A refresh token can only be used once.
+	AuthTokens struct {
+		AccessToken        string    `json:"accessToken" example:"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9"`
+		AccessTokenExpiry  time.Time `json:"accessTokenExpiry" example:"2020-01-01T04:00:00+05:30"`
+		RefreshToken       string    `json:"refreshToken" example:"c2VjcmV0LXJlZnJlc2gtdG9rZW4"`
+		RefreshTokenExpiry time.Time `json:"refreshTokenExpiry" example:"2020-01-31T00:00:00+05:30"`
+	} // @name AuthTokens
+)
@@ -48,36 +48,60 @@ type (
 type (
 	FilterOp string // @name FilterOp
 
-	// Claims represents the claims in the JWT token
+	// Claims represents the claims in the JWT token, as verified by the auth middleware.
+	// OrganizationID is the organization the caller acts for, which is one of the OrganizationIDs they belong to.
 	Claims struct {
-		UserID uuid.UUID `json:"userId" swaggerignore:"true"`
-		Role   string    `json:"role" swaggerignore:"true"`
+		UserID          uuid.UUID   `json:"userId" swaggerignore:"true"`
+		OrganizationID  uuid.UUID   `json:"organizationId" swaggerignore:"true"`
+		OrganizationIDs []uuid.UUID `json:"organizationIds" swaggerignore:"true"`
+		Role            string      `json:"role" swaggerignore:"true"`
 	} // @name Claims
 
-	// TokenInfo represents the token information
-	TokenInfo struct {
-		UserID          uuid.UUID   `json:"-"`
-		OrganizationID  uuid.UUID   `json:"-"`
-		OrganizationIDs []uuid.UUID `json:"-"`
-		Role            string      `json:"-"`
-	} // @name TokenInfo
-
 	// SortKey defines the sort key for sorting
 	SortKey struct {
 		// Field represents a column for the entity you are sorting
 		Field string `json:"field" example:"name"`
 		// Direction represents the direction of the sort
-		Direction string `json:"direction" enums:"asc,desc" example:"asc"`
+		Direction string `json:"direction" validate:"omitempty,oneof=asc desc" enums:"asc,desc" example:"asc"`
 	} // @name SortKey
 
+	// Condition defines a condition that the entities of a query must meet
+	Condition struct {
+		// Field represents a column for the entity you are filtering
+		Field string `json:"field" validate:"required" example:"key"`
+		// Op represents the operator comparing the field with the values
+		Op FilterOp `json:"op" validate:"required,oneof=eq ne in like gt lt between is_null" example:"like"`
+		// Values represents the values to compare the field with.
+		// in takes one or more values, between takes two, is_null takes none, and every other operator takes one.
+		Values []string `json:"values,omitempty" example:"app.%"`
+	} // @name Condition
+
+	// QueryCriteria defines the conditions that the entities of a query must meet and the order they are returned in
+	QueryCriteria struct {
+		Conditions []Condition `json:"conditions,omitempty" validate:"dive"`
+		SortKeys   []SortKey   `json:"sortKeys,omitempty" validate:"dive"`
+	} // @name QueryCriteria
+
 	// QueryOptions defines the options for a query
 	QueryOptions struct {
 		Limit  int64 `json:"limit" example:"10"`
 		Offset int64 `json:"offset" example:"0"`
 
-		SortKeys []SortKey `json:"sortKeys"`
+		// Keyset paginates by keyset instead of offset, which doesn't count the entities
+		Keyset bool `json:"keyset,omitempty" example:"true"`
+		// Cursor selects the page next to the one it was handed out with, and implies keyset pagination
+		Cursor string `json:"cursor,omitempty" example:"eyJvIjoia2V5IEFTQyJ9"`
+
+		QueryCriteria
 	} // @name QueryOptions
 
+	// PageInfo describes the page of entities returned by a query.
+	// Total is only counted with offset pagination, and the cursors are only handed out with keyset pagination.
+	PageInfo struct {
+		Total      int64
+		NextCursor string
+		PrevCursor string
+	}
 )
 
 type (
@@ -94,6 +118,15 @@ type (
 		Size  int64 `json:"size" example:"10"`
 	} // @name PaginationResponse
 
+	// CursorPaginationResponse is the response type of pages fetched by keyset.
+	// The cursors are absent when there is no page before or after.
+	CursorPaginationResponse struct {
+		BaseResponse
+		Next string `json:"next,omitempty" example:"eyJvIjoia2V5IEFTQyJ9"`
+		Prev string `json:"prev,omitempty" example:"eyJvIjoia2V5IEFTQyJ9"`
+		Size int64  `json:"size" example:"10"`
+	} // @name CursorPaginationResponse
+
 	// ErrorResponse is the error response type
 	ErrorResponse struct {
 		Code    string `json:"code" example:"INTERNAL_SERVER_ERROR"`
@@ -110,9 +143,106 @@ type (
 		Commit(ctx context.Context) (err error)
 		// Rollback rolls back a transaction
 		Rollback(ctx context.Context, err error)
+		// WithinTransaction runs the function in a transaction, committing it if the function succeeds and rolling it back otherwise.
+		// When the context already carries a transaction, the function runs within a savepoint of it instead.
+		WithinTransaction(ctx context.Context, fn func(ctx context.Context) error, opts ...TxOptions) (err error)
 	}
+
+	// TxIsolationLevel is the isolation level of a transaction
+	TxIsolationLevel string
+
+	// TxOptions defines the options of a transaction.
+	// They only apply to the outermost transaction, since savepoints share the transaction they belong to.
+	TxOptions struct {
+		// IsolationLevel defaults to the isolation level of the database
+		IsolationLevel TxIsolationLevel
+		ReadOnly       bool
+		// MaxRetries is the number of times the transaction is retried when it fails to serialize. It defaults to 3, and negative values disable retries.
+		MaxRetries int
+	}
+)
+
+// Transaction isolation levels
+const (
+	TxIsolationLevelReadCommitted  TxIsolationLevel = "read committed"
+	TxIsolationLevelRepeatableRead TxIsolationLevel = "repeatable read"
+	TxIsolationLevelSerializable   TxIsolationLevel = "serializable"
 )
 
+type PrimaryReadsKeyType string
+
+const PrimaryReadsKey PrimaryReadsKeyType = "App-Primary-Reads"
+
+// ContextWithPrimaryReads returns a copy of the context whose reads go to the primary database rather than to a read replica,
+// so that they see the writes made just before, which the replicas may not have caught up with
+func ContextWithPrimaryReads(ctx context.Context) context.Context {
+	return context.WithValue(ctx, PrimaryReadsKey, true)
+}
+
+// PrimaryReadsFromContext checks if the reads made with the context must go to the primary database
+func PrimaryReadsFromContext(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	primary, _ := ctx.Value(PrimaryReadsKey).(bool)
+	return primary
+}
+
+type PlatformKeyType string
+
+const PlatformKey PlatformKeyType = "App-Platform"
+
+// ContextAsPlatform returns a copy of the context for the platform itself, e.g. background jobs & seeding, which acts for no organization
+// and sees the rows of all of them. It's only ever set by internal code, so that callers are confined to their organization by default.
+func ContextAsPlatform(ctx context.Context) context.Context {
+	return context.WithValue(ctx, PlatformKey, true)
+}
+
+// PlatformFromContext checks if the context is for the platform itself
+func PlatformFromContext(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	platform, _ := ctx.Value(PlatformKey).(bool)
+	return platform
+}
+
+type ClaimsKeyType string
+
+const ClaimsKey ClaimsKeyType = "App-Claims"
+
+// ContextWithClaims returns a copy of the context that carries the claims of the caller
+func ContextWithClaims(ctx context.Context, claims Claims) context.Context {
+	return context.WithValue(ctx, ClaimsKey, claims)
+}
+
+// ClaimsFromContext returns the claims of the caller carried by the context, if any
+func ClaimsFromContext(ctx context.Context) (result Claims, ok bool) {
+	if ctx == nil {
+		return result, false
+	}
+	result, ok = ctx.Value(ClaimsKey).(Claims)
+	return result, ok
+}
+
+// UserIDFromContext returns the ID of the user making the call, if the context carries the claims of one
+func UserIDFromContext(ctx context.Context) (result uuid.UUID, ok bool) {
+	claims, _ := ClaimsFromContext(ctx)
+	return claims.UserID, claims.UserID != uuid.Nil
+}
+
+// OrganizationIDFromContext returns the ID of the organization the caller acts for, if any
+func OrganizationIDFromContext(ctx context.Context) (result uuid.UUID, ok bool) {
+	claims, _ := ClaimsFromContext(ctx)
+	return claims.OrganizationID, claims.OrganizationID != uuid.Nil
+}
+
+// RoleFromContext returns the role of the caller, if any
+func RoleFromContext(ctx context.Context) (result string, ok bool) {
+	claims, _ := ClaimsFromContext(ctx)
+	return claims.Role, claims.Role != ""
+}
+
 // Value implements the driver.Valuer interface,
 func (j *JSONB) Value() (driver.Value, error) {
 	valueString, err := json.Marshal(j)
@@ -131,9 +261,21 @@ const (
 	MessageVALIDATIONFAILED   string = "Validation failed for some or all of the fields in the request"
 	MessageUNAUTHORIZEDACCESS string = "You are not authorized to access this resource"
 	MessageFORBIDDENACCESS    string = "You are forbidden from accessing this resource"
+	MessageVERSIONCONFLICT    string = "The record was changed by someone else since you last read it. Please reload it and try again"
 )
 
 const (
 	SortDirectionAsc  string = "asc"
 	SortDirectionDesc string = "desc"
 )
+
+const (
+	FilterOpEq      FilterOp = "eq"
+	FilterOpNe      FilterOp = "ne"
+	FilterOpIn      FilterOp = "in"
+	FilterOpLike    FilterOp = "like"
+	FilterOpGt      FilterOp = "gt"
+	FilterOpLt      FilterOp = "lt"
+	FilterOpBetween FilterOp = "between"
+	FilterOpIsNull  FilterOp = "is_null"
+)
@@ -59,6 +59,16 @@ func (e UserError) Error() string {
 	return e.Message
 }
 
+// ConflictError defines model for an error caused by a conflicting change, such as a stale version.
+type ConflictError struct {
+	Code    string `json:"code" example:"CONFLICT"`
+	Message string `json:"message" example:"The record was changed by someone else. Please reload it and try again"`
+}
+
+func (e ConflictError) Error() string {
+	return e.Message
+}
+
 type DataNotFoundError struct{}
 
 func (e DataNotFoundError) Error() string {
@@ -80,4 +90,6 @@ const (
 	ErrorCodeINTERNALSERVERERROR = "INTERNAL_SERVER_ERROR"
 	ErrorCodeUNAUTHORIZED        = "UNAUTHORIZED"
 	ErrorCodeFORBIDDENACCESS     = "FORBIDDEN_ACCESS"
+	ErrorCodeCONFLICT            = "CONFLICT"
+	ErrorCodePRECONDITIONFAILED  = "PRECONDITION_FAILED"
 )
@@ -0,0 +1,50 @@
+package domain
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/gofrs/uuid/v5"
+)
+
+type (
+	// FeatureFlag defines model for a feature flag.
+	// Feature flags are stored as JSON settings whose keys are the name of the flag prefixed with feature.,
+	// so they can be overridden for an organization or a user like any other setting.
+	FeatureFlag struct {
+		Name string `json:"name" example:"new_dashboard"`
+		FeatureFlagRules
+	} // @name FeatureFlag
+
+	// FeatureFlagRules defines model for the rules that decide who a feature flag is enabled for.
+	// A flag is enabled for callers whose role or organization is targeted, and for the given percentage of the other users.
+	// The kill switch disables the flag for everyone, regardless of the other rules.
+	FeatureFlagRules struct {
+		Description     string      `json:"description,omitempty" example:"The redesigned dashboard"`
+		KillSwitch      bool        `json:"killSwitch" example:"false"`
+		Percentage      int         `json:"percentage" validate:"min=0,max=100" example:"25"`
+		Roles           []string    `json:"roles,omitempty" example:"admin"`
+		OrganizationIDs []uuid.UUID `json:"organizationIds,omitempty" example:"550e8400-e29b-41d4-a716-446655440000"`
+	} // @name FeatureFlagRules
+)
+
+type (
+	// FeatureFlagService defines the feature flag service
+	FeatureFlagService interface {
+		// IsEnabled checks if a flag is enabled for the caller identified by the claims in the context.
+		// Flags that don't exist are disabled, and so are the flags whose global rules have the kill switch set, whatever their overrides.
+		IsEnabled(ctx context.Context, flag string) (result bool)
+		// Evaluate returns whether each flag is enabled for the caller identified by the claims in the context.
+		Evaluate(ctx context.Context) (result map[string]bool, err error)
+		// FindAll finds all the flags, as they apply globally.
+		FindAll(ctx context.Context) (result []FeatureFlag, err error)
+		// Save creates or replaces the global rules of a flag.
+		Save(ctx context.Context, name string, in FeatureFlagRules) (result FeatureFlag, err error)
+	}
+)
+
+// FeatureFlagKeyPrefix prefixes the keys of the settings holding feature flags
+const FeatureFlagKeyPrefix = "feature."
+
+// FeatureFlagNamePattern is the pattern that the names of feature flags must match
+var FeatureFlagNamePattern = regexp.MustCompile(`^[a-z0-9_\-]+$`)
@@ -0,0 +1,55 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// Statuses of a job
+const (
+	JobStatusPending   = "pending"
+	JobStatusRunning   = "running"
+	JobStatusSucceeded = "succeeded"
+	// JobStatusDead is the status of the jobs that failed on every attempt, and are left for someone to look into
+	JobStatusDead = "dead"
+)
+
+type (
+	// Job is a unit of work run in the background by one of the workers of any instance of the platform
+	Job struct {
+		Base
+		Name   string          `db:"name" json:"name" example:"send-email"`
+		Args   json.RawMessage `db:"args" json:"args" swaggertype:"object"`
+		Status string          `db:"status" json:"status" enums:"pending,running,succeeded,dead" example:"pending"`
+		// UniqueKey keeps other jobs with the same key from being enqueued while the job is pending or running
+		UniqueKey   *string    `db:"unique_key" json:"uniqueKey,omitempty" example:"send-email:550e8400-e29b-41d4-a716-446655440000"`
+		Attempts    int        `db:"attempts" json:"attempts" example:"1"`
+		MaxAttempts int        `db:"max_attempts" json:"maxAttempts" example:"5"`
+		LastError   *string    `db:"last_error" json:"lastError,omitempty" example:"connection refused"`
+		RunAt       time.Time  `db:"run_at" json:"runAt" example:"2020-01-01T00:00:00+05:30"`
+		LockedAt    *time.Time `db:"locked_at" json:"lockedAt,omitempty" example:"2020-01-01T00:00:00+05:30"`
+		FinishedAt  *time.Time `db:"finished_at" json:"finishedAt,omitempty" example:"2020-01-01T00:00:00+05:30"`
+		Audit
+	} // @name Job
+)
+
+type (
+	// JobRepository defines the job repository
+	JobRepository interface {
+		// Enqueue creates a job. When a pending or running job already holds the unique key of the job, that job is returned instead.
+		Enqueue(ctx context.Context, entity *Job) (err error)
+		// Dequeue claims up to limit pending jobs with the given names that are due to run, marking them as running.
+		// Jobs claimed by other workers are skipped, so that a job is only ever run by one worker at a time.
+		Dequeue(ctx context.Context, names []string, limit int) (result []Job, err error)
+		// Complete marks a running job as succeeded.
+		Complete(ctx context.Context, job Job) (err error)
+		// Retry records the failure of a running job, and puts it back in the queue to run again at runAt.
+		Retry(ctx context.Context, job Job, reason string, runAt time.Time) (err error)
+		// Kill records the failure of a running job, and marks it as dead so that it isn't run again.
+		Kill(ctx context.Context, job Job, reason string) (err error)
+		// Rescue puts the jobs that have been running since before lockedBefore back in the queue, since their workers went away.
+		// count is the number of jobs that were rescued.
+		Rescue(ctx context.Context, lockedBefore time.Time) (count int64, err error)
+	}
+)
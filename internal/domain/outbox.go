@@ -0,0 +1,56 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/gofrs/uuid/v5"
+)
+
+// Topics of the events published through the outbox
+const (
+	OutboxTopicSettingChanged = "setting.changed"
+)
+
+type (
+	// OutboxEvent is an event recorded in the same transaction as the change it describes, and published to other systems afterwards.
+	// Events are published at least once, so consumers should deduplicate them by their ID.
+	OutboxEvent struct {
+		Base
+		Topic       string          `db:"topic" json:"topic" example:"setting.changed"`
+		AggregateID uuid.UUID       `db:"aggregate_id" json:"aggregateId" example:"550e8400-e29b-41d4-a716-446655440000"`
+		Payload     json.RawMessage `db:"payload" json:"payload" swaggertype:"object"`
+		// Attempts is the number of times publishing the event failed
+		Attempts    int       `db:"attempts" json:"-"`
+		LastError   *string   `db:"last_error" json:"-"`
+		AvailableAt time.Time `db:"available_at" json:"-"`
+		// LockedAt is when a relay claimed the event to publish it, if it still holds on to it
+		LockedAt    *time.Time `db:"locked_at" json:"-"`
+		PublishedAt *time.Time `db:"published_at" json:"-"`
+		CreatedAt   time.Time  `db:"created_at" json:"createdAt" example:"2020-01-01T00:00:00+05:30"`
+	} // @name OutboxEvent
+)
+
+type (
+	// OutboxRepository defines the outbox repository
+	OutboxRepository interface {
+		// Add records events in the outbox. It should be given the transaction of the change that the events describe.
+		Add(ctx context.Context, events ...*OutboxEvent) (err error)
+		// Claim claims up to limit events that are due to be published, oldest first, so that other relays skip them while they are published.
+		// Events claimed before lockedBefore are claimed again, since the relay that claimed them is taken to have gone away.
+		// The events are claimed by a single statement, so that no row is locked while they are published.
+		Claim(ctx context.Context, limit int, lockedBefore time.Time) (result []OutboxEvent, err error)
+		// MarkPublished marks events as published.
+		MarkPublished(ctx context.Context, ids []uuid.UUID) (err error)
+		// MarkFailed records the failure to publish an event, and puts off publishing it again for the given delay.
+		// The claim on the event is released.
+		MarkFailed(ctx context.Context, id uuid.UUID, reason string, delay time.Duration) (err error)
+	}
+
+	// OutboxRelay publishes the events recorded in the outbox
+	OutboxRelay interface {
+		// Flush publishes the events that are due, and returns the number of events that were published.
+		Flush(ctx context.Context) (count int, err error)
+	}
+)
@@ -0,0 +1,40 @@
+package domain
+
+import "context"
+
+// Permission allows a caller to perform a set of operations, e.g. setting:read
+type Permission string // @name Permission
+
+// Permissions that roles can be granted
+const (
+	PermissionSettingRead          Permission = "setting:read"
+	PermissionSettingWrite         Permission = "setting:write"
+	PermissionSettingReadSensitive Permission = "setting:read_sensitive"
+	PermissionSettingRotateKey     Permission = "setting:rotate_key"
+	PermissionFeatureFlagRead      Permission = "feature_flag:read"
+	PermissionFeatureFlagWrite     Permission = "feature_flag:write"
+	PermissionSchedulerRead        Permission = "scheduler:read"
+)
+
+// PermissionDefinitions is the registry of the permissions, along with what they allow
+var PermissionDefinitions = map[Permission]string{
+	PermissionSettingRead:          "Find, filter, export & resolve settings, along with their definitions & revisions",
+	PermissionSettingWrite:         "Create, update, delete, import & roll back settings",
+	PermissionSettingReadSensitive: "See the values of sensitive settings, which are masked otherwise",
+	PermissionSettingRotateKey:     "Rotate the data key of sensitive settings",
+	PermissionFeatureFlagRead:      "List the feature flags along with their rules",
+	PermissionFeatureFlagWrite:     "Change the rules of feature flags",
+	PermissionSchedulerRead:        "List the scheduled tasks & their runs",
+}
+
+type (
+	// PolicyService tells which permissions the roles are granted
+	PolicyService interface {
+		// Permissions lists the permissions granted to the role
+		Permissions(role string) (result []Permission)
+		// Can checks if the role is granted all the permissions
+		Can(role string, permissions ...Permission) bool
+		// Authorize checks that the caller is granted all the permissions, and returns a forbidden access error if not
+		Authorize(ctx context.Context, permissions ...Permission) (err error)
+	}
+)
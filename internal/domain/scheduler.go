@@ -0,0 +1,79 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// Statuses of a run of a scheduled task
+const (
+	ScheduledRunStatusRunning   = "running"
+	ScheduledRunStatusSucceeded = "succeeded"
+	ScheduledRunStatusFailed    = "failed"
+)
+
+type (
+	// ScheduledRun is a run of a task by the scheduler
+	ScheduledRun struct {
+		Base
+		Task string `db:"task" json:"task" example:"setting.purge"`
+		// ScheduledAt is the time the run was due, which identifies it among the runs of the task
+		ScheduledAt time.Time  `db:"scheduled_at" json:"scheduledAt" example:"2020-01-01T00:00:00+05:30"`
+		Status      string     `db:"status" json:"status" enums:"running,succeeded,failed" example:"succeeded"`
+		Error       *string    `db:"error" json:"error,omitempty" example:"connection refused"`
+		Instance    string     `db:"instance" json:"instance" example:"api-7d9f8b6c4-x2x5l"`
+		StartedAt   time.Time  `db:"started_at" json:"startedAt" example:"2020-01-01T00:00:00+05:30"`
+		FinishedAt  *time.Time `db:"finished_at" json:"finishedAt,omitempty" example:"2020-01-01T00:00:01+05:30"`
+	} // @name ScheduledRun
+
+	// ScheduledTask describes a task registered with the scheduler
+	ScheduledTask struct {
+		Name     string        `json:"name" example:"setting.purge"`
+		Schedule string        `json:"schedule" example:"0 3 * * *"`
+		LastRun  *ScheduledRun `json:"lastRun,omitempty"`
+		NextRun  *time.Time    `json:"nextRun,omitempty" example:"2020-01-01T03:00:00+05:30"`
+	} // @name ScheduledTask
+
+	// FilterScheduledRunsByCriteriaInput defines the input for filtering the runs of scheduled tasks by criteria.
+	FilterScheduledRunsByCriteriaInput struct {
+		Task string `json:"task,omitempty" query:"task" example:"setting.purge"`
+	} // @name FilterScheduledRunsByCriteriaInput
+)
+
+type (
+	// ScheduledRunRepository defines the repository of the runs of scheduled tasks
+	ScheduledRunRepository interface {
+		// Start records the start of a run.
+		// started is false if the run was already started by another instance, in which case it must not be run again.
+		Start(ctx context.Context, entity *ScheduledRun) (started bool, err error)
+		// Finish records the outcome of a run.
+		Finish(ctx context.Context, entity *ScheduledRun) (err error)
+		// FindLatest finds the latest run of each of the tasks, by task.
+		FindLatest(ctx context.Context, tasks []string) (result map[string]ScheduledRun, err error)
+		// Filter filters the runs by criteria, latest first.
+		// limit and offset, or the cursor, specified through query options are used for pagination.
+		Filter(ctx context.Context, in FilterScheduledRunsByCriteriaInput, opts QueryOptions) (result []ScheduledRun, page PageInfo, err error)
+	}
+
+	// Locker hands out locks that are shared by all the instances of the platform
+	Locker interface {
+		// TryLock takes the lock with the key without waiting for it. ok is false if another instance holds it.
+		TryLock(ctx context.Context, key string) (lock Lock, ok bool, err error)
+	}
+
+	// Lock is a lock held by this instance
+	Lock interface {
+		// Held checks that the lock is still held, since it is lost along with the connection to the database.
+		Held(ctx context.Context) bool
+		// Release releases the lock.
+		Release()
+	}
+
+	// SchedulerService defines the scheduler service
+	SchedulerService interface {
+		// FindTasks finds the tasks registered with the scheduler, along with their last & next runs.
+		FindTasks(ctx context.Context) (result []ScheduledTask, err error)
+		// FindRuns finds the runs of the tasks by criteria, latest first.
+		FindRuns(ctx context.Context, in FilterScheduledRunsByCriteriaInput, opts QueryOptions) (result []ScheduledRun, page PageInfo, err error)
+	}
+)
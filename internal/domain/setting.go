@@ -2,6 +2,7 @@ package domain
 
 import (
 	"context"
+	"time"
 
 	"github.com/gofrs/uuid/v5"
 )
@@ -10,17 +11,106 @@ type (
 	// Setting defines model for Setting.
 	Setting struct {
 		Base
-		Key   string `db:"key" json:"key,omitempty" example:"app.name"`
-		Value string `db:"value" json:"value,omitempty" example:"App"`
+		Key     string     `db:"key" json:"key,omitempty" example:"app.name"`
+		Value   string     `db:"value" json:"value,omitempty" example:"App"`
+		Scope   string     `db:"scope" json:"scope,omitempty" enums:"global,organization,user" example:"global"`
+		ScopeID *uuid.UUID `db:"scope_id" json:"scopeId,omitempty" example:"550e8400-e29b-41d4-a716-446655440000"`
+		// OrganizationID is the organization that owns the setting. Global settings are owned by no organization, and shared by all of them.
+		OrganizationID *uuid.UUID `db:"organization_id" json:"organizationId,omitempty" example:"550e8400-e29b-41d4-a716-446655440000"`
+		// Sensitive settings are stored encrypted and their values are masked for callers who can't read them
+		Sensitive bool       `db:"sensitive" json:"sensitive" example:"false"`
+		DataKeyID *uuid.UUID `db:"data_key_id" json:"-" swaggerignore:"true"`
+		// Version is incremented every time the setting is changed
+		Version int64 `db:"version" json:"version" example:"1"`
 		Audit
 	} // @name Setting
 )
 
+type (
+	// SettingChangeEvent describes a change made to a setting by any instance of the platform.
+	SettingChangeEvent struct {
+		Operation string    `json:"operation" enums:"INSERT,UPDATE,DELETE" example:"UPDATE"`
+		ID        uuid.UUID `json:"id" example:"550e8400-e29b-41d4-a716-446655440000"`
+		Key       string    `json:"key" example:"app.name"`
+	} // @name SettingChangeEvent
+
+	// SettingChangedPayload is the payload of the events published to other systems when a setting is changed.
+	// The values of sensitive settings are left out.
+	SettingChangedPayload struct {
+		ID        uuid.UUID  `json:"id" example:"550e8400-e29b-41d4-a716-446655440000"`
+		Key       string     `json:"key" example:"app.name"`
+		Value     *string    `json:"value,omitempty" example:"App"`
+		Scope     string     `json:"scope" enums:"global,organization,user" example:"global"`
+		ScopeID   *uuid.UUID `json:"scopeId,omitempty" example:"550e8400-e29b-41d4-a716-446655440000"`
+		Sensitive bool       `json:"sensitive" example:"false"`
+		Version   int64      `json:"version" example:"1"`
+		Operation string     `json:"operation" enums:"create,update,delete,rollback" example:"update"`
+		ChangedBy *uuid.UUID `json:"changedBy,omitempty" example:"550e8400-e29b-41d4-a716-446655440000"`
+	} // @name SettingChangedPayload
+)
+
 type (
 	// FilterSettingsByCriteriaInput defines the input for filtering settings by criteria.
+	// Settings can be filtered & sorted by key, scope, scopeId, organizationId, sensitive, version, createdAt and updatedAt.
+	// The conditions & sort keys are applied along with the ones given in the query string.
 	FilterSettingsByCriteriaInput struct {
-		Keys []string `json:"keys,omitempty" example:"app.name"`
+		Keys    []string   `json:"keys,omitempty" example:"app.name"`
+		Scope   string     `json:"scope,omitempty" validate:"omitempty,oneof=global organization user" example:"global"`
+		ScopeID *uuid.UUID `json:"scopeId,omitempty" example:"550e8400-e29b-41d4-a716-446655440000"`
+		QueryCriteria
 	} // @name FilterSettingsByCriteriaInput
+
+	// CreateSettingInput defines the input for creating a setting.
+	// The setting applies to everyone unless it is scoped to an organization or a user.
+	// Settings declared as sensitive are always stored encrypted.
+	CreateSettingInput struct {
+		Key       string     `json:"key" validate:"required" example:"app.name"`
+		Value     string     `json:"value" example:"App"`
+		Scope     string     `json:"scope,omitempty" validate:"omitempty,oneof=global organization user" example:"organization"`
+		ScopeID   *uuid.UUID `json:"scopeId,omitempty" example:"550e8400-e29b-41d4-a716-446655440000"`
+		Sensitive bool       `json:"sensitive,omitempty" example:"false"`
+	} // @name CreateSettingInput
+
+	// CreateSettingsInput defines the input for creating multiple settings.
+	CreateSettingsInput struct {
+		Settings []CreateSettingInput `json:"settings" validate:"required,min=1,dive"`
+	} // @name CreateSettingsInput
+
+	// UpdateSettingInput defines the input for replacing a setting.
+	// When a version is given, the setting is only replaced if it hasn't been changed since that version.
+	UpdateSettingInput struct {
+		Key     string `json:"key" validate:"required" example:"app.name"`
+		Value   string `json:"value" example:"App"`
+		Version int64  `json:"version,omitempty" validate:"min=0" example:"1"`
+	} // @name UpdateSettingInput
+
+	// UpdateSettingsItemInput defines a single setting to be replaced as part of a bulk update.
+	// When a version is given, the setting is only replaced if it hasn't been changed since that version.
+	UpdateSettingsItemInput struct {
+		ID      uuid.UUID `json:"id" validate:"required" example:"550e8400-e29b-41d4-a716-446655440000"`
+		Key     string    `json:"key" validate:"required" example:"app.name"`
+		Value   string    `json:"value" example:"App"`
+		Version int64     `json:"version,omitempty" validate:"min=0" example:"1"`
+	} // @name UpdateSettingsItemInput
+
+	// UpdateSettingsInput defines the input for replacing multiple settings.
+	UpdateSettingsInput struct {
+		Settings []UpdateSettingsItemInput `json:"settings" validate:"required,min=1,dive"`
+	} // @name UpdateSettingsInput
+
+	// PatchSettingInput defines the input for partially updating a setting.
+	// Only the fields that are present in the request are updated.
+	// When a version is given, the setting is only updated if it hasn't been changed since that version.
+	PatchSettingInput struct {
+		Key     *string `json:"key,omitempty" validate:"omitempty,min=1" example:"app.name"`
+		Value   *string `json:"value,omitempty" example:"App"`
+		Version int64   `json:"version,omitempty" validate:"min=0" example:"1"`
+	} // @name PatchSettingInput
+
+	// DeleteSettingsInput defines the input for deleting multiple settings.
+	DeleteSettingsInput struct {
+		IDs []uuid.UUID `json:"ids" validate:"required,min=1" example:"550e8400-e29b-41d4-a716-446655440000"`
+	} // @name DeleteSettingsInput
 )
 
 type (
@@ -29,42 +119,162 @@ type (
 		// FindByID finds a setting by its ID.
 		FindByID(ctx context.Context, id uuid.UUID) (result Setting, err error)
 		// Filter filters settings by criteria.
-		// limit and offset specified through query options are used for pagination.
-		// total is the total number of entities in the database matching the criteria.
-		Filter(ctx context.Context, in FilterSettingsByCriteriaInput, opts QueryOptions) (result []Setting, total int64, err error)
+		// limit and offset, or the cursor, specified through query options are used for pagination.
+		// page holds the total number of entities in the database matching the criteria, or the cursors of the pages around them with keyset pagination.
+		Filter(ctx context.Context, in FilterSettingsByCriteriaInput, opts QueryOptions) (result []Setting, page PageInfo, err error)
 		// Create creates a setting.
 		Create(ctx context.Context, entity *Setting) (err error)
 		// CreateMultiple creates multiple settings.
 		CreateMultiple(ctx context.Context, entities []*Setting) (err error)
-		// Update updates a setting.
+		// CreateMissing creates the settings that don't exist yet with the same key & scope, leaving the existing ones untouched.
+		// The IDs of the entities that weren't created remain nil.
+		CreateMissing(ctx context.Context, entities []*Setting) (err error)
+		// Restore restores a deleted setting. It fails with a not found error if the setting has been purged since.
+		Restore(ctx context.Context, id uuid.UUID) (err error)
+		// Update updates a setting and increments its version.
+		// It fails with a conflict error if the version of the setting in the database no longer matches the version of the entity,
+		// and with a not found error if the setting doesn't exist.
 		Update(ctx context.Context, entity *Setting) (err error)
-		// UpdateMultiple updates multiple settings.
+		// UpdateMultiple updates multiple settings and increments their versions.
+		// It fails with a conflict error if the version of any of the settings in the database no longer matches the version of its entity.
 		UpdateMultiple(ctx context.Context, entities []*Setting) (err error)
-		// DeleteByID deletes a setting by its ID.
+		// DeleteByID deletes a setting by its ID. It fails with a not found error if the setting doesn't exist.
 		DeleteByID(ctx context.Context, id uuid.UUID) (err error)
-		// DeleteByIDs deletes settings by their IDs.
+		// DeleteByIDs deletes settings by their IDs. It fails with a not found error unless all the settings exist.
 		DeleteByIDs(ctx context.Context, ids []uuid.UUID) (err error)
+		// Reencrypt re-encrypts up to limit sensitive settings, including deleted ones,
+		// whose values are encrypted with a data key other than the active one.
+		// count is the number of settings that were re-encrypted.
+		Reencrypt(ctx context.Context, limit int) (count int64, err error)
+		// Purge permanently deletes the settings that were deleted before the given time, along with their revisions.
+		// count is the number of settings that were purged.
+		Purge(ctx context.Context, deletedBefore time.Time) (count int64, err error)
+	}
+
+	// SettingKeyring encrypts the values of sensitive settings using envelope encryption.
+	// Values are encrypted with a data key, which is itself stored encrypted with a master key.
+	SettingKeyring interface {
+		// Encrypt encrypts the value of a setting with the given data key, which should be the active one.
+		// The ciphertext is bound to the setting & the data key, so that it can't be decrypted as the value of another setting.
+		Encrypt(ctx context.Context, plaintext string, settingID, dataKeyID uuid.UUID) (ciphertext string, err error)
+		// Decrypt decrypts the value of a setting that was encrypted with the given data key.
+		Decrypt(ctx context.Context, ciphertext string, settingID, dataKeyID uuid.UUID) (plaintext string, err error)
+		// ActiveKeyID returns the ID of the data key that new values are encrypted with, as seen by the transaction carried by the context if any.
+		// A new data key is created when there is none, or when the active one isn't encrypted with the current master key.
+		// It returns uuid.Nil when no master key is configured.
+		ActiveKeyID(ctx context.Context) (result uuid.UUID, err error)
+		// Rotate retires the active data key in favour of a new one, encrypted with the current master key.
+		Rotate(ctx context.Context) (result uuid.UUID, err error)
+		// Prune deletes the retired data keys that no longer encrypt any value.
+		Prune(ctx context.Context) (count int64, err error)
+	}
+
+	// SettingChangeListener listens for changes made to settings by any instance of the platform
+	SettingChangeListener interface {
+		// Listen blocks and calls onChange for every change made to a setting, until the context is done.
+		// onConnect is called every time the listener (re)connects, since changes may have been missed in between.
+		Listen(ctx context.Context, onConnect func(), onChange func(event SettingChangeEvent)) (err error)
+	}
+
+	// SettingChangeNotifier lets other services react to changes made to settings
+	SettingChangeNotifier interface {
+		// Subscribe registers fn to be called after a setting is changed by any instance of the platform.
+		// The returned function removes the subscription.
+		Subscribe(fn func(event SettingChangeEvent)) (unsubscribe func())
 	}
 
-	// SettingService defines the setting service
+	// SettingService defines the setting service.
+	// Callers acting for an organization only see its settings along with the global ones, and can only change its own.
+	// Callers acting for no organization only see the global settings, which only the platform itself can change.
+	// The values of sensitive settings are masked in what's returned to callers whose role isn't granted setting:read_sensitive,
+	// except by the typed getters, which are meant for the platform itself.
 	SettingService interface {
+		SettingChangeNotifier
+
 		// FindByID finds a setting by its ID.
-		FindByID(id uuid.UUID) (result Setting, err error)
+		FindByID(ctx context.Context, id uuid.UUID) (result Setting, err error)
 		// Filter filters settings by criteria.
-		// limit and offset specified through query options are used for pagination.
-		// total is the total number of entities in the database matching the criteria.
-		Filter(in FilterSettingsByCriteriaInput, options QueryOptions) (result []Setting, total int64, err error)
+		// limit and offset, or the cursor, specified through query options are used for pagination.
+		// page holds the total number of entities in the database matching the criteria, or the cursors of the pages around them with keyset pagination.
+		Filter(ctx context.Context, in FilterSettingsByCriteriaInput, options QueryOptions) (result []Setting, page PageInfo, err error)
+		// Create creates a setting.
+		Create(ctx context.Context, in CreateSettingInput) (result Setting, err error)
+		// CreateMultiple creates multiple settings in a single transaction.
+		CreateMultiple(ctx context.Context, in CreateSettingsInput) (result []Setting, err error)
+		// Update replaces the key and value of a setting.
+		Update(ctx context.Context, id uuid.UUID, in UpdateSettingInput) (result Setting, err error)
+		// UpdateMultiple replaces the key and value of multiple settings in a single transaction.
+		UpdateMultiple(ctx context.Context, in UpdateSettingsInput) (result []Setting, err error)
+		// Patch updates only the fields of a setting that are present in the input.
+		// The masked value of a sensitive setting leaves its value unchanged, for Update & UpdateMultiple as well.
+		Patch(ctx context.Context, id uuid.UUID, in PatchSettingInput) (result Setting, err error)
+		// DeleteByID deletes a setting by its ID.
+		DeleteByID(ctx context.Context, id uuid.UUID) (err error)
+		// DeleteByIDs deletes settings by their IDs in a single transaction.
+		DeleteByIDs(ctx context.Context, in DeleteSettingsInput) (err error)
+		// Purge permanently deletes the settings that were deleted before the given time, along with their revisions.
+		Purge(ctx context.Context, deletedBefore time.Time) (count int64, err error)
+		// FindRevisions filters the revisions of settings by criteria, latest first.
+		// limit and offset, or the cursor, specified through query options are used for pagination.
+		// page holds the total number of revisions in the database matching the criteria, or the cursors of the pages around them with keyset pagination.
+		FindRevisions(ctx context.Context, in FilterSettingRevisionsByCriteriaInput, options QueryOptions) (result []SettingRevision, page PageInfo, err error)
+		// Rollback restores the key & value captured by a revision to the setting it was made to,
+		// restoring the setting if it has since been deleted. It fails with a conflict if the setting is changed concurrently.
+		Rollback(ctx context.Context, revisionID uuid.UUID) (result Setting, err error)
+		// Resolve finds the setting that applies to the caller identified by the claims in the context.
+		// Settings scoped to the user take precedence over the ones scoped to the organization,
+		// which in turn take precedence over global settings and the declared default.
+		Resolve(ctx context.Context, key string) (result Setting, err error)
+		// Export exports all the settings that aren't deleted.
+		Export(ctx context.Context) (result SettingExport, err error)
+		// Import makes the settings match the ones being imported in a single transaction.
+		// Settings that are not part of the import are only removed when pruning.
+		Import(ctx context.Context, in ImportSettingsInput) (result SettingImportResult, err error)
+		// RotateKey retires the data key that sensitive settings are encrypted with in favour of a new one.
+		// The values are re-encrypted with the new data key in the background.
+		RotateKey(ctx context.Context) (err error)
+		// Definitions returns the declared schema of every setting known to the platform.
+		Definitions() (result []SettingDefinition)
+		// GetString returns the value of a setting, falling back to its declared default when it isn't set.
+		GetString(key string) (result string, err error)
+		// GetInt returns the value of an int setting.
+		GetInt(key string) (result int64, err error)
+		// GetBool returns the value of a bool setting.
+		GetBool(key string) (result bool, err error)
+		// GetDuration returns the value of a duration setting.
+		GetDuration(key string) (result time.Duration, err error)
+		// GetJSON decodes the value of a JSON setting into v.
+		GetJSON(key string, v interface{}) (err error)
+		// GetList returns the items of a list setting.
+		GetList(key string) (result []string, err error)
 	}
 )
 
+const (
+	SettingChangeOperationInsert = "INSERT"
+	SettingChangeOperationUpdate = "UPDATE"
+	SettingChangeOperationDelete = "DELETE"
+)
+
+const (
+	SettingScopeGlobal       = "global"
+	SettingScopeOrganization = "organization"
+	SettingScopeUser         = "user"
+)
+
+// SettingMaskedValue replaces the value of sensitive settings for callers who can't read them
+const SettingMaskedValue = "********"
+
+// Masked returns the setting with its value masked if it's sensitive
+func (s Setting) Masked() Setting {
+	if s.Sensitive {
+		s.Value = SettingMaskedValue
+	}
+	return s
+}
+
 const (
 	SettingKeyAppName       = "app.name"
 	SettingTestPhoneNumbers = "test.phone_numbers"
 	SettingTestPhoneCode    = "test.phone_code"
 )
-
-var SettingDefaultValues = map[string]string{
-	SettingKeyAppName:       "App",
-	SettingTestPhoneNumbers: "+911234567890",
-	SettingTestPhoneCode:    "123456",
-}
@@ -0,0 +1,214 @@
+package domain
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type (
+	// SettingType defines the type of the value held by a setting
+	SettingType string // @name SettingType
+
+	// SettingConstraints defines the constraints that the value of a setting must satisfy
+	SettingConstraints struct {
+		// Min is the inclusive lower bound for int and duration settings, expressed in the setting's type
+		Min string `json:"min,omitempty" example:"1"`
+		// Max is the inclusive upper bound for int and duration settings, expressed in the setting's type
+		Max string `json:"max,omitempty" example:"10"`
+		// MinLength is the minimum length of a string setting, or the minimum number of items in a list setting
+		MinLength int `json:"minLength,omitempty" example:"1"`
+		// MaxLength is the maximum length of a string setting, or the maximum number of items in a list setting
+		MaxLength int `json:"maxLength,omitempty" example:"100"`
+		// Options are the allowed values of a string setting, or the allowed items of a list setting
+		Options []string `json:"options,omitempty" example:"light,dark"`
+		// Pattern is a regular expression that a string setting, or every item of a list setting, must match
+		Pattern string `json:"pattern,omitempty" example:"^\\d{6}$"`
+	} // @name SettingConstraints
+
+	// SettingDefinition declares the schema of a setting
+	SettingDefinition struct {
+		Key         string             `json:"key" example:"app.name"`
+		Type        SettingType        `json:"type" enums:"string,int,bool,duration,json,list" example:"string"`
+		Default     string             `json:"default" example:"App"`
+		Description string             `json:"description" example:"The name of the application"`
+		Constraints SettingConstraints `json:"constraints"`
+		// Sensitive settings are stored encrypted and their values are masked for callers who can't read them
+		Sensitive bool `json:"sensitive" example:"false"`
+	} // @name SettingDefinition
+)
+
+const (
+	SettingTypeString   SettingType = "string"
+	SettingTypeInt      SettingType = "int"
+	SettingTypeBool     SettingType = "bool"
+	SettingTypeDuration SettingType = "duration"
+	SettingTypeJSON     SettingType = "json"
+	SettingTypeList     SettingType = "list"
+)
+
+// SettingListSeparator separates the items of a list setting
+const SettingListSeparator = ","
+
+// SettingDefinitions is the registry of all the settings known to the platform
+var SettingDefinitions = map[string]SettingDefinition{
+	SettingKeyAppName: {
+		Key:         SettingKeyAppName,
+		Type:        SettingTypeString,
+		Default:     "App",
+		Description: "The name of the application",
+		Constraints: SettingConstraints{
+			MinLength: 1,
+		},
+	},
+	SettingTestPhoneNumbers: {
+		Key:         SettingTestPhoneNumbers,
+		Type:        SettingTypeList,
+		Default:     "+911234567890",
+		Description: "Phone numbers that accept the test OTP code instead of a real one",
+		Constraints: SettingConstraints{
+			Pattern: `^\+[1-9]\d{1,14}$`,
+		},
+	},
+	SettingTestPhoneCode: {
+		Key:         SettingTestPhoneCode,
+		Type:        SettingTypeString,
+		Default:     "123456",
+		Description: "The OTP code accepted for the test phone numbers",
+		Constraints: SettingConstraints{
+			Pattern: `^\d{6}$`,
+		},
+	},
+}
+
+// Validate checks that the value satisfies the type and constraints of the definition
+func (d SettingDefinition) Validate(value string) (err error) {
+	switch d.Type {
+	case SettingTypeString:
+		return d.validateText(value, len(value), []string{value})
+	case SettingTypeInt:
+		v, err := ParseSettingInt(value)
+		if err != nil {
+			return d.validationError("%s must be an integer", d.Key)
+		}
+		return d.validateBounds(func(bound string) (int, error) {
+			b, err := ParseSettingInt(bound)
+			return cmp.Compare(v, b), err
+		})
+	case SettingTypeBool:
+		_, err := ParseSettingBool(value)
+		if err != nil {
+			return d.validationError("%s must be a boolean", d.Key)
+		}
+	case SettingTypeDuration:
+		v, err := ParseSettingDuration(value)
+		if err != nil {
+			return d.validationError("%s must be a duration such as 30s or 1h", d.Key)
+		}
+		return d.validateBounds(func(bound string) (int, error) {
+			b, err := ParseSettingDuration(bound)
+			return cmp.Compare(v, b), err
+		})
+	case SettingTypeJSON:
+		if !json.Valid([]byte(value)) {
+			return d.validationError("%s must be a valid JSON document", d.Key)
+		}
+	case SettingTypeList:
+		items := ParseSettingList(value)
+		return d.validateText(value, len(items), items)
+	default:
+		return d.validationError("%s has an unsupported type %s", d.Key, d.Type)
+	}
+	return nil
+}
+
+// validateText validates the length, options and pattern constraints of string and list settings
+func (d SettingDefinition) validateText(value string, length int, items []string) (err error) {
+	c := d.Constraints
+	if c.MinLength > 0 && length < c.MinLength {
+		return d.validationError("%s must have a minimum length of %d", d.Key, c.MinLength)
+	}
+	if c.MaxLength > 0 && length > c.MaxLength {
+		return d.validationError("%s must not exceed a length of %d", d.Key, c.MaxLength)
+	}
+	var re *regexp.Regexp
+	if c.Pattern != "" {
+		re, err = regexp.Compile(c.Pattern)
+		if err != nil {
+			return err
+		}
+	}
+	for _, item := range items {
+		if len(c.Options) > 0 && !slices.Contains(c.Options, item) {
+			return d.validationError("%s must be one of %s", d.Key, strings.Join(c.Options, " "))
+		}
+		if re != nil && !re.MatchString(item) {
+			return d.validationError("%s must match the pattern %s", d.Key, c.Pattern)
+		}
+	}
+	return nil
+}
+
+// validateBounds validates the min and max constraints of int and duration settings
+func (d SettingDefinition) validateBounds(compareTo func(bound string) (int, error)) (err error) {
+	c := d.Constraints
+	if c.Min != "" {
+		r, err := compareTo(c.Min)
+		if err != nil {
+			return err
+		}
+		if r < 0 {
+			return d.validationError("%s must be at least %s", d.Key, c.Min)
+		}
+	}
+	if c.Max != "" {
+		r, err := compareTo(c.Max)
+		if err != nil {
+			return err
+		}
+		if r > 0 {
+			return d.validationError("%s must be at most %s", d.Key, c.Max)
+		}
+	}
+	return nil
+}
+
+func (d SettingDefinition) validationError(format string, args ...interface{}) error {
+	return ValidationError{
+		Code:    ErrorCodeVALIDATIONERROR,
+		Message: MessageVALIDATIONFAILED,
+		Fields:  []string{fmt.Sprintf(format, args...)},
+	}
+}
+
+// ParseSettingInt parses the value of an int setting
+func ParseSettingInt(value string) (int64, error) {
+	return strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+}
+
+// ParseSettingBool parses the value of a bool setting
+func ParseSettingBool(value string) (bool, error) {
+	return strconv.ParseBool(strings.TrimSpace(value))
+}
+
+// ParseSettingDuration parses the value of a duration setting
+func ParseSettingDuration(value string) (time.Duration, error) {
+	return time.ParseDuration(strings.TrimSpace(value))
+}
+
+// ParseSettingList parses the value of a list setting into its items
+func ParseSettingList(value string) []string {
+	result := make([]string, 0)
+	for _, item := range strings.Split(value, SettingListSeparator) {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
@@ -0,0 +1,73 @@
+package domain
+
+import (
+	"github.com/gofrs/uuid/v5"
+)
+
+type (
+	// SettingExport defines model for the settings of an environment,
+	// in the format they are exported from and imported into an environment.
+	SettingExport struct {
+		Settings []SettingExportItem `json:"settings" yaml:"settings" validate:"dive"`
+	} // @name SettingExport
+
+	// SettingExportItem defines model for a setting in an export.
+	// Settings are identified by their key and scope rather than their ID, since IDs differ between environments.
+	SettingExportItem struct {
+		Key     string     `json:"key" yaml:"key" validate:"required" example:"app.name"`
+		Value   string     `json:"value" yaml:"value" example:"App"`
+		Scope   string     `json:"scope,omitempty" yaml:"scope,omitempty" validate:"omitempty,oneof=global organization user" enums:"global,organization,user" example:"global"`
+		ScopeID *uuid.UUID `json:"scopeId,omitempty" yaml:"scopeId,omitempty" example:"550e8400-e29b-41d4-a716-446655440000"`
+		// The values of sensitive settings are masked for callers who can't read them.
+		// Masked values are left unchanged by an import.
+		Sensitive bool `json:"sensitive,omitempty" yaml:"sensitive,omitempty" example:"false"`
+	} // @name SettingExportItem
+
+	// SettingValueChange defines model for a setting whose value is changed by an import.
+	SettingValueChange struct {
+		SettingExportItem
+		PreviousValue string `json:"previousValue" example:"Old App"`
+	} // @name SettingValueChange
+
+	// SettingImportResult defines model for the outcome of an import.
+	// In dry-run mode, it describes the changes that would be made without making them.
+	SettingImportResult struct {
+		DryRun  bool                 `json:"dryRun" example:"true"`
+		Added   []SettingExportItem  `json:"added"`
+		Changed []SettingValueChange `json:"changed"`
+		Removed []SettingExportItem  `json:"removed"`
+	} // @name SettingImportResult
+)
+
+type (
+	// ImportSettingsInput defines the input for importing settings.
+	ImportSettingsInput struct {
+		SettingExport
+		// DryRun reports the changes without making them.
+		DryRun bool
+		// Prune removes the settings that are not part of the import.
+		Prune bool
+	}
+)
+
+// Masked returns the setting with its value masked if it's sensitive
+func (i SettingExportItem) Masked() SettingExportItem {
+	if i.Sensitive {
+		i.Value = SettingMaskedValue
+	}
+	return i
+}
+
+// Masked returns the change with its values masked if the setting is sensitive
+func (c SettingValueChange) Masked() SettingValueChange {
+	if c.Sensitive {
+		c.Value = SettingMaskedValue
+		c.PreviousValue = SettingMaskedValue
+	}
+	return c
+}
+
+const (
+	SettingExportFormatJSON = "json"
+	SettingExportFormatYAML = "yaml"
+)
@@ -0,0 +1,69 @@
+package domain
+
+import (
+	"context"
+	"time"
+
+	"github.com/gofrs/uuid/v5"
+)
+
+type (
+	// SettingRevision defines model for a revision of a Setting.
+	// A revision captures the state of a setting right after it was changed.
+	SettingRevision struct {
+		Base
+		SettingID uuid.UUID  `db:"setting_id" json:"settingId" example:"550e8400-e29b-41d4-a716-446655440000"`
+		Key       string     `db:"key" json:"key" example:"app.name"`
+		Value     string     `db:"value" json:"value" example:"App"`
+		Scope     string     `db:"scope" json:"scope" enums:"global,organization,user" example:"global"`
+		ScopeID   *uuid.UUID `db:"scope_id" json:"scopeId,omitempty" example:"550e8400-e29b-41d4-a716-446655440000"`
+		// OrganizationID is the organization that owns the setting, if any
+		OrganizationID *uuid.UUID `db:"organization_id" json:"organizationId,omitempty" example:"550e8400-e29b-41d4-a716-446655440000"`
+		Sensitive      bool       `db:"sensitive" json:"sensitive" example:"false"`
+		DataKeyID      *uuid.UUID `db:"data_key_id" json:"-" swaggerignore:"true"`
+		Operation      string     `db:"operation" json:"operation" enums:"create,update,delete,rollback" example:"update"`
+		ChangedBy      *uuid.UUID `db:"changed_by" json:"changedBy,omitempty" example:"550e8400-e29b-41d4-a716-446655440000"`
+		CreatedAt      time.Time  `db:"created_at" json:"createdAt" example:"2020-01-01T00:00:00+05:30"`
+	} // @name SettingRevision
+)
+
+type (
+	// FilterSettingRevisionsByCriteriaInput defines the input for filtering setting revisions by criteria.
+	FilterSettingRevisionsByCriteriaInput struct {
+		Key       string    `json:"key,omitempty" query:"key" example:"app.name"`
+		SettingID uuid.UUID `json:"settingId,omitempty" query:"settingId" example:"550e8400-e29b-41d4-a716-446655440000"`
+	} // @name FilterSettingRevisionsByCriteriaInput
+)
+
+type (
+	// SettingRevisionRepository defines the setting revision repository
+	SettingRevisionRepository interface {
+		// FindByID finds a setting revision by its ID.
+		FindByID(ctx context.Context, id uuid.UUID) (result SettingRevision, err error)
+		// Filter filters setting revisions by criteria, latest first.
+		// limit and offset, or the cursor, specified through query options are used for pagination.
+		// page holds the total number of entities in the database matching the criteria, or the cursors of the pages around them with keyset pagination.
+		Filter(ctx context.Context, in FilterSettingRevisionsByCriteriaInput, opts QueryOptions) (result []SettingRevision, page PageInfo, err error)
+		// CreateMultiple creates multiple setting revisions.
+		CreateMultiple(ctx context.Context, entities []*SettingRevision) (err error)
+		// Reencrypt re-encrypts up to limit revisions of sensitive settings
+		// whose values are encrypted with a data key other than the active one.
+		// count is the number of revisions that were re-encrypted.
+		Reencrypt(ctx context.Context, limit int) (count int64, err error)
+	}
+)
+
+// Masked returns the revision with its value masked if the setting is sensitive
+func (r SettingRevision) Masked() SettingRevision {
+	if r.Sensitive {
+		r.Value = SettingMaskedValue
+	}
+	return r
+}
+
+const (
+	SettingRevisionOperationCreate   = "create"
+	SettingRevisionOperationUpdate   = "update"
+	SettingRevisionOperationDelete   = "delete"
+	SettingRevisionOperationRollback = "rollback"
+)
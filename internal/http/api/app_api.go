@@ -1,17 +1,23 @@
 package api
 
 import (
-	echojwt "github.com/labstack/echo-jwt/v4"
 	"github.com/labstack/echo/v4"
 
+	"github.com/Intiqo/app-platform/internal/domain"
 	"github.com/Intiqo/app-platform/internal/http/handler"
 	"github.com/Intiqo/app-platform/internal/pkg/config"
+	"github.com/Intiqo/app-platform/internal/pkg/security"
 )
 
 type AppApi struct {
 	cfg config.AppConfig
+	sm  security.Manager
+	p   domain.PolicyService
 
-	SettingHandler handler.SettingHandler
+	SettingHandler     handler.SettingHandler
+	FeatureFlagHandler handler.FeatureFlagHandler
+	SchedulerHandler   handler.SchedulerHandler
+	AuthHandler        handler.AuthHandler
 }
 
 // NewAppApi initializes all the routes for the application.
@@ -32,24 +38,69 @@ type AppApi struct {
 //	@name						Authorization
 func NewAppApi(
 	cfg config.AppConfig,
+	sm security.Manager,
+	p domain.PolicyService,
 
 	sh handler.SettingHandler,
+	ffh handler.FeatureFlagHandler,
+	sch handler.SchedulerHandler,
+	ah handler.AuthHandler,
 ) *AppApi {
 	return &AppApi{
 		cfg: cfg,
+		sm:  sm,
+		p:   p,
 
-		SettingHandler: sh,
+		SettingHandler:     sh,
+		FeatureFlagHandler: ffh,
+		SchedulerHandler:   sch,
+		AuthHandler:        ah,
 	}
 }
 
 // SetupRoutes initializes all the routes for the application.
 func (t AppApi) SetupRoutes(e *echo.Echo) {
+	// The public keys of the auth tokens are served where verifiers expect them
+	e.GET("/.well-known/jwks.json", t.AuthHandler.JWKS)
+
 	g := e.Group("/api/v1")
 
-	auth := echojwt.JWT([]byte(t.cfg.AuthSecret))
+	auth := RequireAuth(t.sm)
+
+	authApi := g.Group("/auth")
+	authApi.POST("/token/refresh", t.AuthHandler.Refresh)
+	authApi.POST("/logout", t.AuthHandler.Logout, auth)
+	authApi.POST("/revoke", t.AuthHandler.Revoke, auth)
 
+	read := RequirePermission(t.p, domain.PermissionSettingRead)
+	write := RequirePermission(t.p, domain.PermissionSettingWrite)
 	settingApi := g.Group("/setting")
 	settingApi.Use(auth)
-	settingApi.GET("/:id", t.SettingHandler.FindByID)
-	settingApi.POST("/filter", t.SettingHandler.Filter)
+	settingApi.POST("", t.SettingHandler.Create, write)
+	settingApi.POST("/bulk", t.SettingHandler.CreateMultiple, write)
+	settingApi.PUT("/bulk", t.SettingHandler.UpdateMultiple, write)
+	settingApi.DELETE("/bulk", t.SettingHandler.DeleteByIDs, write)
+	settingApi.POST("/filter", t.SettingHandler.Filter, read)
+	settingApi.GET("/export", t.SettingHandler.Export, read)
+	settingApi.POST("/import", t.SettingHandler.Import, write)
+	settingApi.GET("/definition", t.SettingHandler.Definitions, read)
+	settingApi.GET("/revision", t.SettingHandler.FindRevisions, read)
+	settingApi.POST("/revision/:id/rollback", t.SettingHandler.Rollback, write)
+	settingApi.GET("/resolve/:key", t.SettingHandler.Resolve, read)
+	settingApi.POST("/key/rotate", t.SettingHandler.RotateKey, RequirePermission(t.p, domain.PermissionSettingRotateKey))
+	settingApi.GET("/:id", t.SettingHandler.FindByID, read)
+	settingApi.PUT("/:id", t.SettingHandler.Update, write)
+	settingApi.PATCH("/:id", t.SettingHandler.Patch, write)
+	settingApi.DELETE("/:id", t.SettingHandler.DeleteByID, write)
+
+	featureFlagApi := g.Group("/feature-flag")
+	featureFlagApi.Use(auth)
+	featureFlagApi.GET("", t.FeatureFlagHandler.FindAll, RequirePermission(t.p, domain.PermissionFeatureFlagRead))
+	featureFlagApi.GET("/evaluate", t.FeatureFlagHandler.Evaluate)
+	featureFlagApi.PUT("/:name", t.FeatureFlagHandler.Save, RequirePermission(t.p, domain.PermissionFeatureFlagWrite))
+
+	adminApi := g.Group("/admin")
+	adminApi.Use(auth)
+	adminApi.GET("/scheduler/task", t.SchedulerHandler.FindTasks, RequirePermission(t.p, domain.PermissionSchedulerRead))
+	adminApi.GET("/scheduler/run", t.SchedulerHandler.FindRuns, RequirePermission(t.p, domain.PermissionSchedulerRead))
 }
@@ -1,17 +1,20 @@
 package api
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/jackc/pgx/v5/pgconn"
+	echojwt "github.com/labstack/echo-jwt/v4"
 	"github.com/labstack/echo/v4"
 	echomiddleware "github.com/labstack/echo/v4/middleware"
 
 	"github.com/Intiqo/app-platform/internal/domain"
 	"github.com/Intiqo/app-platform/internal/http/swagger"
 	"github.com/Intiqo/app-platform/internal/http/transport"
+	"github.com/Intiqo/app-platform/internal/pkg/security"
 )
 
 // SetupMiddleware sets up middleware for the echo server
@@ -44,6 +47,54 @@ func (t AppApi) SetupMiddleware(e *echo.Echo) {
 	)
 }
 
+// RequireAuth verifies the auth token of the request with the security manager, which parses its claims into the typed TokenMetadata.
+// The metadata is kept in the context, where transport.GetClaimsForContext reads it, so echojwt's own parsing & claims aren't used.
+// Requests with a missing, malformed, expired or revoked token get an unauthorized error.
+func RequireAuth(sm security.Manager) echo.MiddlewareFunc {
+	return echojwt.WithConfig(echojwt.Config{
+		ParseTokenFunc: func(c echo.Context, auth string) (interface{}, error) {
+			return sm.VerifyAuthToken(c.Request().Context(), auth)
+		},
+		ErrorHandler: func(c echo.Context, err error) error {
+			// Other errors, such as a failure to check the denylist, aren't the caller's fault
+			var extraction *echojwt.TokenExtractionError
+			if errors.Is(err, security.ErrInvalidToken) || errors.As(err, &extraction) {
+				return domain.UnauthorizedError{}
+			}
+			return err
+		},
+	})
+}
+
+// RequireFeature gates routes behind a feature flag.
+// Callers for whom the flag isn't enabled get a not found error, as if the routes didn't exist.
+// It must be used after the auth middleware, since flags are evaluated against the claims of the caller.
+func RequireFeature(f domain.FeatureFlagService, flag string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if !f.IsEnabled(transport.NewContext(c), flag) {
+				return echo.ErrNotFound
+			}
+			return next(c)
+		}
+	}
+}
+
+// RequirePermission restricts routes to the callers whose role is granted all the permissions.
+// Other callers get a forbidden access error. It must be used after the auth middleware.
+// The permissions of a route are documented with the x-permissions attribute of its swagger annotations.
+func RequirePermission(p domain.PolicyService, permissions ...domain.Permission) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			err := p.Authorize(transport.NewContext(c), permissions...)
+			if err != nil {
+				return err
+			}
+			return next(c)
+		}
+	}
+}
+
 // errorMiddleware absorbs and processes all errors
 func errorMiddleware(err error, c echo.Context) {
 	switch err.(type) {
@@ -64,6 +115,11 @@ func errorMiddleware(err error, c echo.Context) {
 			_ = c.JSON(err.Code, domain.NotFoundError{})
 		case http.StatusBadRequest:
 			_ = c.JSON(err.Code, domain.InvalidRequestError{Message: err.Message.(string)})
+		case http.StatusPreconditionFailed:
+			_ = c.JSON(err.Code, domain.ConflictError{
+				Code:    domain.ErrorCodePRECONDITIONFAILED,
+				Message: err.Message.(string),
+			})
 		default:
 			_ = c.JSON(err.Code, domain.SystemError{Code: domain.ErrorCodeINTERNALSERVERERROR, Message: err.Message.(string)})
 		}
@@ -113,6 +169,9 @@ func errorMiddleware(err error, c echo.Context) {
 		}
 		_ = c.JSON(http.StatusBadRequest, ve)
 
+	case domain.ValidationError:
+		_ = c.JSON(http.StatusBadRequest, err)
+
 	case *pgconn.PgError:
 		res := domain.SystemError{
 			Code:    domain.ErrorCodeINTERNALSERVERERROR,
@@ -134,6 +193,9 @@ func errorMiddleware(err error, c echo.Context) {
 		}
 		_ = c.JSON(http.StatusBadRequest, res)
 
+	case domain.ConflictError:
+		_ = c.JSON(http.StatusConflict, err)
+
 	case domain.UnauthorizedError:
 		res := domain.UnauthorizedError{
 			Code:    domain.ErrorCodeUNAUTHORIZED,
@@ -0,0 +1,157 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/Intiqo/app-platform/internal/domain"
+	"github.com/Intiqo/app-platform/internal/http/transport"
+	"github.com/Intiqo/app-platform/internal/pkg/security"
+)
+
+// AuthHandler represents a handler for the auth tokens
+type AuthHandler struct {
+	sm security.Manager
+}
+
+// NewAuthHandler creates a new instance of the auth handler
+func NewAuthHandler(sm security.Manager) AuthHandler {
+	return AuthHandler{
+		sm: sm,
+	}
+}
+
+// Refresh exchanges a refresh token for a new pair of tokens
+//
+//	@Summary		Refresh tokens
+//	@Description	Exchange a refresh token for a new access token & refresh token. A refresh token can only be used once. Using it again revokes every token issued from it.
+//	@Tags			Auth
+//	@ID				refreshTokens
+//	@Accept			json
+//	@Produce		json
+//	@Param			in	body		domain.RefreshTokenInput	true	"Refresh Token"
+//	@Success		200	{object}	domain.BaseResponse{data=domain.AuthTokens}
+//	@Failure		400	{object}	domain.ErrorResponse
+//	@Failure		401	{object}	domain.ErrorResponse
+//	@Failure		500	{object}	domain.ErrorResponse
+//	@Router			/auth/token/refresh [post]
+func (c AuthHandler) Refresh(ctx echo.Context) (err error) {
+	// Parse the input from the request body
+	var in domain.RefreshTokenInput
+	err = transport.DecodeAndValidateRequestBody(ctx, &in)
+	if err != nil {
+		return err
+	}
+
+	// Refresh the tokens
+	tokens, err := c.sm.RefreshTokens(ctx.Request().Context(), in.RefreshToken)
+	if err != nil {
+		return authError(err)
+	}
+
+	// Return the result
+	result := domain.AuthTokens{
+		AccessToken:        tokens.AuthToken,
+		AccessTokenExpiry:  tokens.AuthTokenExpiry,
+		RefreshToken:       tokens.RefreshToken,
+		RefreshTokenExpiry: tokens.RefreshTokenExpiry,
+	}
+	return transport.SendResponse(ctx, http.StatusOK, result)
+}
+
+// Logout revokes the access token of the caller
+//
+//	@Summary		Logout
+//	@Description	Revoke the access token the request is made with. The refresh token, if given, is revoked along with every token issued from it.
+//	@Tags			Auth
+//	@ID				logout
+//	@Accept			json
+//	@Produce		json
+//	@Security		JWT
+//	@Param			in	body	domain.LogoutInput	false	"Refresh Token"
+//	@Success		204
+//	@Failure		400	{object}	domain.ErrorResponse
+//	@Failure		401	{object}	domain.ErrorResponse
+//	@Failure		500	{object}	domain.ErrorResponse
+//	@Router			/auth/logout [post]
+func (c AuthHandler) Logout(ctx echo.Context) (err error) {
+	// Parse the input from the request body
+	var in domain.LogoutInput
+	err = transport.DecodeAndValidateRequestBody(ctx, &in)
+	if err != nil {
+		return err
+	}
+
+	// Revoke the refresh token
+	metadata, ok := transport.GetTokenMetadata(ctx)
+	if !ok {
+		return domain.UnauthorizedError{}
+	}
+	if in.RefreshToken != "" {
+		err = c.sm.RevokeRefreshToken(ctx.Request().Context(), metadata.UserID, in.RefreshToken)
+		if err != nil {
+			return authError(err)
+		}
+	}
+
+	// Revoke the access token
+	err = c.sm.RevokeAuthToken(ctx.Request().Context(), metadata)
+	if err != nil {
+		return authError(err)
+	}
+
+	// Return the result
+	return transport.SendResponse(ctx, http.StatusNoContent, nil)
+}
+
+// Revoke revokes all the tokens of the caller
+//
+//	@Summary		Revoke all tokens
+//	@Description	Revoke every refresh token of the caller, along with the access tokens issued with them & the access token the request is made with. Logs the caller out everywhere.
+//	@Tags			Auth
+//	@ID				revokeTokens
+//	@Accept			json
+//	@Produce		json
+//	@Security		JWT
+//	@Success		204
+//	@Failure		401	{object}	domain.ErrorResponse
+//	@Failure		500	{object}	domain.ErrorResponse
+//	@Router			/auth/revoke [post]
+func (c AuthHandler) Revoke(ctx echo.Context) (err error) {
+	// Revoke the refresh tokens of the caller
+	metadata, ok := transport.GetTokenMetadata(ctx)
+	if !ok {
+		return domain.UnauthorizedError{}
+	}
+	err = c.sm.RevokeUserTokens(ctx.Request().Context(), metadata.UserID)
+	if err != nil {
+		return err
+	}
+
+	// Revoke the access token
+	err = c.sm.RevokeAuthToken(ctx.Request().Context(), metadata)
+	if err != nil {
+		return authError(err)
+	}
+
+	// Return the result
+	return transport.SendResponse(ctx, http.StatusNoContent, nil)
+}
+
+// JWKS returns the public keys that auth tokens are verified with, for downstream verifiers.
+// It is served at /.well-known/jwks.json, outside of the API, in the JSON Web Key Set format.
+func (c AuthHandler) JWKS(ctx echo.Context) (err error) {
+	// Let verifiers cache the keys for a while. New keys are published well before they start signing.
+	ctx.Response().Header().Set("Cache-Control", "public, max-age=300")
+	return ctx.JSON(http.StatusOK, c.sm.JWKS())
+}
+
+// authError turns the errors of invalid tokens into unauthorized errors
+func authError(err error) error {
+	if errors.Is(err, security.ErrInvalidToken) || errors.Is(err, security.ErrTokenReused) {
+		return domain.UnauthorizedError{}
+	}
+	return err
+}
@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/Intiqo/app-platform/internal/domain"
+	"github.com/Intiqo/app-platform/internal/http/transport"
+)
+
+// FeatureFlagHandler represents a handler for feature flags
+type FeatureFlagHandler struct {
+	f domain.FeatureFlagService
+}
+
+// NewFeatureFlagHandler creates a new instance of the feature flag handler
+func NewFeatureFlagHandler(f domain.FeatureFlagService) FeatureFlagHandler {
+	return FeatureFlagHandler{
+		f: f,
+	}
+}
+
+// FindAll finds all the feature flags
+//
+//	@Summary		Find all feature flags
+//	@Description	Find all the feature flags, with their global rules
+//	@Tags			Feature Flag
+//	@ID				findAllFeatureFlags
+//	@Accept			json
+//	@Produce		json
+//	@Security		JWT
+//	@x-permissions	["feature_flag:read"]
+//	@Success		200	{object}	domain.BaseResponse{data=[]domain.FeatureFlag}
+//	@Failure		401	{object}	domain.ErrorResponse
+//	@Failure		403	{object}	domain.ErrorResponse
+//	@Failure		500	{object}	domain.ErrorResponse
+//	@Router			/feature-flag [get]
+func (c FeatureFlagHandler) FindAll(ctx echo.Context) (err error) {
+	// Find the flags
+	result, err := c.f.FindAll(transport.NewContext(ctx))
+	if err != nil {
+		return err
+	}
+
+	// Return the result
+	return transport.SendResponse(ctx, http.StatusOK, result)
+}
+
+// Evaluate evaluates all the feature flags for the caller
+//
+//	@Summary		Evaluate feature flags
+//	@Description	Find out which feature flags are enabled for the caller, taking the overrides for their organization & user into account
+//	@Tags			Feature Flag
+//	@ID				evaluateFeatureFlags
+//	@Accept			json
+//	@Produce		json
+//	@Security		JWT
+//	@Success		200	{object}	domain.BaseResponse{data=map[string]bool}
+//	@Failure		401	{object}	domain.ErrorResponse
+//	@Failure		403	{object}	domain.ErrorResponse
+//	@Failure		500	{object}	domain.ErrorResponse
+//	@Router			/feature-flag/evaluate [get]
+func (c FeatureFlagHandler) Evaluate(ctx echo.Context) (err error) {
+	// Evaluate the flags
+	result, err := c.f.Evaluate(transport.NewContext(ctx))
+	if err != nil {
+		return err
+	}
+
+	// Return the result
+	return transport.SendResponse(ctx, http.StatusOK, result)
+}
+
+// Save creates or replaces a feature flag
+//
+//	@Summary		Save a feature flag
+//	@Description	Create or replace the global rules of a feature flag. Rules can be overridden for an organization or a user through the setting with the key feature.{name}.
+//	@Tags			Feature Flag
+//	@ID				saveFeatureFlag
+//	@Accept			json
+//	@Produce		json
+//	@Security		JWT
+//	@x-permissions	["feature_flag:write"]
+//	@Param			name	path		string					true	"Feature Flag Name"
+//	@Param			in		body		domain.FeatureFlagRules	true	"Input"
+//	@Success		200		{object}	domain.BaseResponse{data=domain.FeatureFlag}
+//	@Failure		400		{object}	domain.ErrorResponse
+//	@Failure		401		{object}	domain.ErrorResponse
+//	@Failure		403		{object}	domain.ErrorResponse
+//	@Failure		500		{object}	domain.ErrorResponse
+//	@Router			/feature-flag/{name} [put]
+func (c FeatureFlagHandler) Save(ctx echo.Context) (err error) {
+	// Parse the input from the request body
+	var in domain.FeatureFlagRules
+	err = transport.DecodeAndValidateRequestBody(ctx, &in)
+	if err != nil {
+		return err
+	}
+
+	// Save the flag
+	result, err := c.f.Save(transport.NewContext(ctx), ctx.Param("name"), in)
+	if err != nil {
+		return err
+	}
+
+	// Return the result
+	return transport.SendResponse(ctx, http.StatusOK, result)
+}
@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/Intiqo/app-platform/internal/domain"
+	"github.com/Intiqo/app-platform/internal/http/transport"
+)
+
+// SchedulerHandler represents a handler for the scheduler
+type SchedulerHandler struct {
+	s domain.SchedulerService
+}
+
+// NewSchedulerHandler creates a new instance of the scheduler handler
+func NewSchedulerHandler(s domain.SchedulerService) SchedulerHandler {
+	return SchedulerHandler{
+		s: s,
+	}
+}
+
+// FindTasks finds the scheduled tasks
+//
+//	@Summary		Find scheduled tasks
+//	@Description	Find the tasks run on a schedule, along with their last & next runs
+//	@Tags			Scheduler
+//	@ID				findScheduledTasks
+//	@Accept			json
+//	@Produce		json
+//	@Security		JWT
+//	@x-permissions	["scheduler:read"]
+//	@Success		200	{object}	domain.BaseResponse{data=[]domain.ScheduledTask}
+//	@Failure		401	{object}	domain.ErrorResponse
+//	@Failure		403	{object}	domain.ErrorResponse
+//	@Failure		500	{object}	domain.ErrorResponse
+//	@Router			/admin/scheduler/task [get]
+func (c SchedulerHandler) FindTasks(ctx echo.Context) (err error) {
+	// Find the tasks
+	result, err := c.s.FindTasks(transport.NewContext(ctx))
+	if err != nil {
+		return err
+	}
+
+	// Return the result
+	return transport.SendResponse(ctx, http.StatusOK, result)
+}
+
+// FindRuns lists the history of the runs of scheduled tasks
+//
+//	@Summary		List runs of scheduled tasks
+//	@Description	List the runs of the scheduled tasks, latest first. Supports pagination and returns the number of records as total. Pages can also be fetched by cursor, in which case a CursorPaginationResponse is returned without the total.
+//	@Tags			Scheduler
+//	@ID				findScheduledRuns
+//	@Accept			json
+//	@Produce		json
+//	@Security		JWT
+//	@x-permissions	["scheduler:read"]
+//	@Param			task	query		string	false	"Task Name"
+//	@Param			page	query		number	false	"Page Index"
+//	@Param			size	query		number	false	"Page Size"
+//	@Param			sort	query		string	false	"Sort Keys, as field:direction separated by commas"			example(startedAt:desc)
+//	@Param			filter	query		string	false	"Condition, as field:op:values with values separated by |"	example(status:eq:failed)
+//	@Param			cursor	query		string	false	"Cursor, which switches to keyset pagination. Empty for the first page."
+//	@Success		200		{object}	domain.PaginationResponse{data=[]domain.ScheduledRun}
+//	@Failure		400		{object}	domain.ErrorResponse
+//	@Failure		401		{object}	domain.ErrorResponse
+//	@Failure		403		{object}	domain.ErrorResponse
+//	@Failure		500		{object}	domain.ErrorResponse
+//	@Router			/admin/scheduler/run [get]
+func (c SchedulerHandler) FindRuns(ctx echo.Context) (err error) {
+	// Parse the input from the query parameters
+	var in domain.FilterScheduledRunsByCriteriaInput
+	err = transport.DecodeAndValidateRequestBody(ctx, &in)
+	if err != nil {
+		return err
+	}
+
+	// Decode the query options
+	opts, err := transport.DecodeQueryOptions(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Find the runs
+	result, page, err := c.s.FindRuns(transport.NewContext(ctx), in, opts)
+	if err != nil {
+		return err
+	}
+
+	// Return the result
+	if opts.Keyset {
+		return transport.SendCursorPaginationResponse(ctx, http.StatusOK, result, page)
+	}
+	return transport.SendPaginationResponse(ctx, http.StatusOK, result, page.Total)
+}
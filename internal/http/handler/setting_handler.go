@@ -1,7 +1,9 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/gofrs/uuid/v5"
 	"github.com/labstack/echo/v4"
@@ -31,8 +33,10 @@ func NewSettingHandler(s domain.SettingService) SettingHandler {
 //	@Accept			json
 //	@Produce		json
 //	@Security		JWT
+//	@x-permissions	["setting:read"]
 //	@Param			id	path		string	true	"Setting ID"
 //	@Success		200	{object}	domain.BaseResponse{data=domain.Setting}
+//	@Header			200	{string}	ETag	"The version of the setting"
 //	@Failure		400	{object}	domain.ErrorResponse
 //	@Failure		401	{object}	domain.ErrorResponse
 //	@Failure		403	{object}	domain.ErrorResponse
@@ -46,26 +50,31 @@ func (c SettingHandler) FindByID(ctx echo.Context) (err error) {
 	}
 
 	// Find the setting by ID
-	result, err := c.s.FindByID(id)
+	result, err := c.s.FindByID(transport.NewContext(ctx), id)
 	if err != nil {
 		return err
 	}
 
-	// Return the result
+	// Return the result, along with its version
+	transport.SetETag(ctx, result.Version)
 	return transport.SendResponse(ctx, http.StatusOK, result)
 }
 
 // Filter filters settings by criteria
 //
 //	@Summary		Filter settings by criteria
-//	@Description	Filter settings by criteria. Supports pagination and returns the number of records as total.
+//	@Description	Filter settings by criteria. Supports pagination and returns the number of records as total. Pages can also be fetched by cursor, in which case a CursorPaginationResponse is returned without the total.
 //	@Tags			Setting
 //	@ID				filterSettingsByCriteria
 //	@Accept			json
 //	@Produce		json
 //	@Security		JWT
+//	@x-permissions	["setting:read"]
 //	@Param			page	query		number									false	"Page Index"
 //	@Param			size	query		number									false	"Page Size"
+//	@Param			sort	query		string									false	"Sort Keys, as field:direction separated by commas"			example(updatedAt:desc)
+//	@Param			filter	query		string									false	"Condition, as field:op:values with values separated by |"	example(key:like:app.%)
+//	@Param			cursor	query		string									false	"Cursor, which switches to keyset pagination. Empty for the first page."
 //	@Param			in		body		domain.FilterSettingsByCriteriaInput	true	"Input"
 //	@Success		200		{object}	domain.PaginationResponse{data=[]domain.Setting}
 //	@Failure		400		{object}	domain.ErrorResponse
@@ -81,15 +90,549 @@ func (c SettingHandler) Filter(ctx echo.Context) (err error) {
 		return err
 	}
 
-	// Decode the query options
-	opts := transport.DecodeQueryOptions(ctx)
+	// Decode the query options, along with the criteria given in the body
+	opts, err := transport.DecodeQueryOptions(ctx, in.QueryCriteria)
+	if err != nil {
+		return err
+	}
 
 	// Filter the settings
-	result, total, err := c.s.Filter(in, opts)
+	result, page, err := c.s.Filter(transport.NewContext(ctx), in, opts)
+	if err != nil {
+		return err
+	}
+
+	// Return the result
+	if opts.Keyset {
+		return transport.SendCursorPaginationResponse(ctx, http.StatusOK, result, page)
+	}
+	return transport.SendPaginationResponse(ctx, http.StatusOK, result, page.Total)
+}
+
+// FindRevisions lists the history of changes made to settings
+//
+//	@Summary		List setting revisions
+//	@Description	List the revisions of a setting, latest first. Supports pagination and returns the number of records as total. Pages can also be fetched by cursor, in which case a CursorPaginationResponse is returned without the total.
+//	@Tags			Setting
+//	@ID				findSettingRevisions
+//	@Accept			json
+//	@Produce		json
+//	@Security		JWT
+//	@x-permissions	["setting:read"]
+//	@Param			key			query		string	false	"Setting Key"
+//	@Param			settingId	query		string	false	"Setting ID"
+//	@Param			page		query		number	false	"Page Index"
+//	@Param			size		query		number	false	"Page Size"
+//	@Param			sort		query		string	false	"Sort Keys, as field:direction separated by commas"			example(createdAt:asc)
+//	@Param			filter		query		string	false	"Condition, as field:op:values with values separated by |"	example(operation:in:create|update)
+//	@Param			cursor		query		string	false	"Cursor, which switches to keyset pagination. Empty for the first page."
+//	@Success		200			{object}	domain.PaginationResponse{data=[]domain.SettingRevision}
+//	@Failure		400			{object}	domain.ErrorResponse
+//	@Failure		401			{object}	domain.ErrorResponse
+//	@Failure		403			{object}	domain.ErrorResponse
+//	@Failure		500			{object}	domain.ErrorResponse
+//	@Router			/setting/revision [get]
+func (c SettingHandler) FindRevisions(ctx echo.Context) (err error) {
+	// Parse the input from the query parameters
+	var in domain.FilterSettingRevisionsByCriteriaInput
+	err = transport.DecodeAndValidateRequestBody(ctx, &in)
+	if err != nil {
+		return err
+	}
+
+	// Decode the query options
+	opts, err := transport.DecodeQueryOptions(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Find the revisions
+	result, page, err := c.s.FindRevisions(transport.NewContext(ctx), in, opts)
+	if err != nil {
+		return err
+	}
+
+	// Return the result
+	if opts.Keyset {
+		return transport.SendCursorPaginationResponse(ctx, http.StatusOK, result, page)
+	}
+	return transport.SendPaginationResponse(ctx, http.StatusOK, result, page.Total)
+}
+
+// Rollback restores a setting to a revision
+//
+//	@Summary		Roll back a setting to a revision
+//	@Description	Restore the key & value captured by a revision to the setting it was made to. The setting is restored if it has since been deleted. Fails with a conflict if the setting is changed concurrently.
+//	@Tags			Setting
+//	@ID				rollbackSetting
+//	@Accept			json
+//	@Produce		json
+//	@Security		JWT
+//	@x-permissions	["setting:write"]
+//	@Param			id	path		string	true	"Setting Revision ID"
+//	@Success		200	{object}	domain.BaseResponse{data=domain.Setting}
+//	@Failure		400	{object}	domain.ErrorResponse
+//	@Failure		401	{object}	domain.ErrorResponse
+//	@Failure		403	{object}	domain.ErrorResponse
+//	@Failure		409	{object}	domain.ErrorResponse
+//	@Failure		500	{object}	domain.ErrorResponse
+//	@Router			/setting/revision/{id}/rollback [post]
+func (c SettingHandler) Rollback(ctx echo.Context) (err error) {
+	// Parse the ID from the path parameter
+	id, err := uuid.FromString(ctx.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	// Roll back the setting
+	result, err := c.s.Rollback(transport.NewContext(ctx), id)
+	if err != nil {
+		return err
+	}
+
+	// Return the result
+	return transport.SendResponse(ctx, http.StatusOK, result)
+}
+
+// Definitions lists the declared schema of all settings
+//
+//	@Summary		List setting definitions
+//	@Description	List the type, default value, constraints and description of every setting known to the platform
+//	@Tags			Setting
+//	@ID				findSettingDefinitions
+//	@Accept			json
+//	@Produce		json
+//	@Security		JWT
+//	@x-permissions	["setting:read"]
+//	@Success		200	{object}	domain.BaseResponse{data=[]domain.SettingDefinition}
+//	@Failure		401	{object}	domain.ErrorResponse
+//	@Failure		403	{object}	domain.ErrorResponse
+//	@Failure		500	{object}	domain.ErrorResponse
+//	@Router			/setting/definition [get]
+func (c SettingHandler) Definitions(ctx echo.Context) (err error) {
+	// Find the definitions
+	result := c.s.Definitions()
+
+	// Return the result
+	return transport.SendResponse(ctx, http.StatusOK, result)
+}
+
+// Resolve resolves a setting for the caller
+//
+//	@Summary		Resolve a setting
+//	@Description	Resolve the value of a setting for the caller. A user override takes precedence over an organization override, which takes precedence over the global setting and then the declared default.
+//	@Tags			Setting
+//	@ID				resolveSetting
+//	@Accept			json
+//	@Produce		json
+//	@Security		JWT
+//	@x-permissions	["setting:read"]
+//	@Param			key	path		string	true	"Setting Key"
+//	@Success		200	{object}	domain.BaseResponse{data=domain.Setting}
+//	@Failure		400	{object}	domain.ErrorResponse
+//	@Failure		401	{object}	domain.ErrorResponse
+//	@Failure		403	{object}	domain.ErrorResponse
+//	@Failure		500	{object}	domain.ErrorResponse
+//	@Router			/setting/resolve/{key} [get]
+func (c SettingHandler) Resolve(ctx echo.Context) (err error) {
+	// Resolve the setting for the caller
+	result, err := c.s.Resolve(transport.NewContext(ctx), ctx.Param("key"))
+	if err != nil {
+		return err
+	}
+
+	// Return the result
+	return transport.SendResponse(ctx, http.StatusOK, result)
+}
+
+// Export exports all the settings
+//
+//	@Summary		Export settings
+//	@Description	Export all the settings that aren't deleted as a JSON or YAML document, which can be imported into another environment
+//	@Tags			Setting
+//	@ID				exportSettings
+//	@Accept			json
+//	@Produce		json,application/yaml
+//	@Security		JWT
+//	@x-permissions	["setting:read"]
+//	@Param			format	query		string	false	"Document Format"	Enums(json, yaml)
+//	@Success		200		{object}	domain.SettingExport
+//	@Failure		400		{object}	domain.ErrorResponse
+//	@Failure		401		{object}	domain.ErrorResponse
+//	@Failure		403		{object}	domain.ErrorResponse
+//	@Failure		500		{object}	domain.ErrorResponse
+//	@Router			/setting/export [get]
+func (c SettingHandler) Export(ctx echo.Context) (err error) {
+	// Export the settings
+	result, err := c.s.Export(transport.NewContext(ctx))
+	if err != nil {
+		return err
+	}
+
+	// Return the result
+	return transport.SendDocument(ctx, "settings", result)
+}
+
+// Import imports settings
+//
+//	@Summary		Import settings
+//	@Description	Import settings from a JSON or YAML document in the format produced by the export. Settings are matched by key and scope.
+//	@Description	Settings that are not part of the document are only removed when pruning. In dry-run mode, the changes are reported without being made.
+//	@Tags			Setting
+//	@ID				importSettings
+//	@Accept			json,application/yaml
+//	@Produce		json
+//	@Security		JWT
+//	@x-permissions	["setting:write"]
+//	@Param			dryRun	query		boolean					false	"Report the changes without making them"
+//	@Param			prune	query		boolean					false	"Remove the settings that are not part of the document"
+//	@Param			in		body		domain.SettingExport	true	"Input"
+//	@Success		200		{object}	domain.BaseResponse{data=domain.SettingImportResult}
+//	@Failure		400		{object}	domain.ErrorResponse
+//	@Failure		401		{object}	domain.ErrorResponse
+//	@Failure		403		{object}	domain.ErrorResponse
+//	@Failure		500		{object}	domain.ErrorResponse
+//	@Router			/setting/import [post]
+func (c SettingHandler) Import(ctx echo.Context) (err error) {
+	// Parse the input from the request body & query parameters
+	var in domain.ImportSettingsInput
+	err = transport.DecodeAndValidateDocument(ctx, &in.SettingExport)
+	if err != nil {
+		return err
+	}
+	in.DryRun, _ = strconv.ParseBool(ctx.QueryParam("dryRun"))
+	in.Prune, _ = strconv.ParseBool(ctx.QueryParam("prune"))
+
+	// Import the settings
+	result, err := c.s.Import(transport.NewContext(ctx), in)
+	if err != nil {
+		return err
+	}
+
+	// Return the result
+	return transport.SendResponse(ctx, http.StatusOK, result)
+}
+
+// RotateKey rotates the data key of sensitive settings
+//
+//	@Summary		Rotate the data key of sensitive settings
+//	@Description	Retire the data key that sensitive settings are encrypted with in favour of a new one. The values are re-encrypted with the new data key in the background.
+//	@Tags			Setting
+//	@ID				rotateSettingKey
+//	@Accept			json
+//	@Produce		json
+//	@Security		JWT
+//	@x-permissions	["setting:rotate_key"]
+//	@Success		204
+//	@Failure		400	{object}	domain.ErrorResponse
+//	@Failure		401	{object}	domain.ErrorResponse
+//	@Failure		403	{object}	domain.ErrorResponse
+//	@Failure		500	{object}	domain.ErrorResponse
+//	@Router			/setting/key/rotate [post]
+func (c SettingHandler) RotateKey(ctx echo.Context) (err error) {
+	// Rotate the data key
+	err = c.s.RotateKey(transport.NewContext(ctx))
+	if err != nil {
+		return err
+	}
+
+	// Return the result
+	return transport.SendResponse(ctx, http.StatusNoContent, nil)
+}
+
+// Create creates a setting
+//
+//	@Summary		Create a setting
+//	@Description	Create a setting. The key must not be in use by another setting in the same scope.
+//	@Tags			Setting
+//	@ID				createSetting
+//	@Accept			json
+//	@Produce		json
+//	@Security		JWT
+//	@x-permissions	["setting:write"]
+//	@Param			in	body		domain.CreateSettingInput	true	"Input"
+//	@Success		201	{object}	domain.BaseResponse{data=domain.Setting}
+//	@Failure		400	{object}	domain.ErrorResponse
+//	@Failure		401	{object}	domain.ErrorResponse
+//	@Failure		403	{object}	domain.ErrorResponse
+//	@Failure		500	{object}	domain.ErrorResponse
+//	@Router			/setting [post]
+func (c SettingHandler) Create(ctx echo.Context) (err error) {
+	// Parse the input from the request body
+	var in domain.CreateSettingInput
+	err = transport.DecodeAndValidateRequestBody(ctx, &in)
+	if err != nil {
+		return err
+	}
+
+	// Create the setting
+	result, err := c.s.Create(transport.NewContext(ctx), in)
+	if err != nil {
+		return err
+	}
+
+	// Return the result, along with its version
+	transport.SetETag(ctx, result.Version)
+	return transport.SendResponse(ctx, http.StatusCreated, result)
+}
+
+// CreateMultiple creates multiple settings
+//
+//	@Summary		Create multiple settings
+//	@Description	Create multiple settings at once. Either all or none of the settings are created.
+//	@Tags			Setting
+//	@ID				createSettings
+//	@Accept			json
+//	@Produce		json
+//	@Security		JWT
+//	@x-permissions	["setting:write"]
+//	@Param			in	body		domain.CreateSettingsInput	true	"Input"
+//	@Success		201	{object}	domain.BaseResponse{data=[]domain.Setting}
+//	@Failure		400	{object}	domain.ErrorResponse
+//	@Failure		401	{object}	domain.ErrorResponse
+//	@Failure		403	{object}	domain.ErrorResponse
+//	@Failure		500	{object}	domain.ErrorResponse
+//	@Router			/setting/bulk [post]
+func (c SettingHandler) CreateMultiple(ctx echo.Context) (err error) {
+	// Parse the input from the request body
+	var in domain.CreateSettingsInput
+	err = transport.DecodeAndValidateRequestBody(ctx, &in)
+	if err != nil {
+		return err
+	}
+
+	// Create the settings
+	result, err := c.s.CreateMultiple(transport.NewContext(ctx), in)
+	if err != nil {
+		return err
+	}
+
+	// Return the result
+	return transport.SendResponse(ctx, http.StatusCreated, result)
+}
+
+// Update replaces a setting
+//
+//	@Summary		Update a setting
+//	@Description	Replace the key and value of a setting
+//	@Tags			Setting
+//	@ID				updateSetting
+//	@Accept			json
+//	@Produce		json
+//	@Security		JWT
+//	@x-permissions	["setting:write"]
+//	@Param			id			path		string						true	"Setting ID"
+//	@Param			If-Match	header		string						false	"ETag of the version of the setting the caller expects"
+//	@Param			in			body		domain.UpdateSettingInput	true	"Input"
+//	@Success		200			{object}	domain.BaseResponse{data=domain.Setting}
+//	@Header			200			{string}	ETag	"The version of the setting"
+//	@Failure		400			{object}	domain.ErrorResponse
+//	@Failure		401			{object}	domain.ErrorResponse
+//	@Failure		403			{object}	domain.ErrorResponse
+//	@Failure		409			{object}	domain.ErrorResponse
+//	@Failure		412			{object}	domain.ErrorResponse
+//	@Failure		500			{object}	domain.ErrorResponse
+//	@Router			/setting/{id} [put]
+func (c SettingHandler) Update(ctx echo.Context) (err error) {
+	// Parse the ID from the path parameter
+	id, err := uuid.FromString(ctx.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	// Parse the input from the request body
+	var in domain.UpdateSettingInput
+	err = transport.DecodeAndValidateRequestBody(ctx, &in)
+	if err != nil {
+		return err
+	}
+
+	// Parse the version the caller expects from the If-Match header, which takes precedence over the one in the body
+	version, conditional, err := transport.DecodeIfMatch(ctx)
+	if err != nil {
+		return err
+	}
+	if conditional {
+		in.Version = version
+	}
+
+	// Update the setting
+	result, err := c.s.Update(transport.NewContext(ctx), id, in)
+	if err != nil {
+		return preconditionFailed(err, conditional)
+	}
+
+	// Return the result, along with its new version
+	transport.SetETag(ctx, result.Version)
+	return transport.SendResponse(ctx, http.StatusOK, result)
+}
+
+// UpdateMultiple replaces multiple settings
+//
+//	@Summary		Update multiple settings
+//	@Description	Replace the key and value of multiple settings at once. Either all or none of the settings are updated.
+//	@Tags			Setting
+//	@ID				updateSettings
+//	@Accept			json
+//	@Produce		json
+//	@Security		JWT
+//	@x-permissions	["setting:write"]
+//	@Param			in	body		domain.UpdateSettingsInput	true	"Input"
+//	@Success		200	{object}	domain.BaseResponse{data=[]domain.Setting}
+//	@Failure		400	{object}	domain.ErrorResponse
+//	@Failure		401	{object}	domain.ErrorResponse
+//	@Failure		403	{object}	domain.ErrorResponse
+//	@Failure		409	{object}	domain.ErrorResponse
+//	@Failure		500	{object}	domain.ErrorResponse
+//	@Router			/setting/bulk [put]
+func (c SettingHandler) UpdateMultiple(ctx echo.Context) (err error) {
+	// Parse the input from the request body
+	var in domain.UpdateSettingsInput
+	err = transport.DecodeAndValidateRequestBody(ctx, &in)
+	if err != nil {
+		return err
+	}
+
+	// Update the settings
+	result, err := c.s.UpdateMultiple(transport.NewContext(ctx), in)
 	if err != nil {
 		return err
 	}
 
 	// Return the result
-	return transport.SendPaginationResponse(ctx, http.StatusOK, result, total)
+	return transport.SendResponse(ctx, http.StatusOK, result)
+}
+
+// Patch partially updates a setting
+//
+//	@Summary		Patch a setting
+//	@Description	Update only the fields of a setting that are present in the request
+//	@Tags			Setting
+//	@ID				patchSetting
+//	@Accept			json
+//	@Produce		json
+//	@Security		JWT
+//	@x-permissions	["setting:write"]
+//	@Param			id			path		string						true	"Setting ID"
+//	@Param			If-Match	header		string						false	"ETag of the version of the setting the caller expects"
+//	@Param			in			body		domain.PatchSettingInput	true	"Input"
+//	@Success		200			{object}	domain.BaseResponse{data=domain.Setting}
+//	@Header			200			{string}	ETag	"The version of the setting"
+//	@Failure		400			{object}	domain.ErrorResponse
+//	@Failure		401			{object}	domain.ErrorResponse
+//	@Failure		403			{object}	domain.ErrorResponse
+//	@Failure		409			{object}	domain.ErrorResponse
+//	@Failure		412			{object}	domain.ErrorResponse
+//	@Failure		500			{object}	domain.ErrorResponse
+//	@Router			/setting/{id} [patch]
+func (c SettingHandler) Patch(ctx echo.Context) (err error) {
+	// Parse the ID from the path parameter
+	id, err := uuid.FromString(ctx.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	// Parse the input from the request body
+	var in domain.PatchSettingInput
+	err = transport.DecodeAndValidateRequestBody(ctx, &in)
+	if err != nil {
+		return err
+	}
+
+	// Parse the version the caller expects from the If-Match header, which takes precedence over the one in the body
+	version, conditional, err := transport.DecodeIfMatch(ctx)
+	if err != nil {
+		return err
+	}
+	if conditional {
+		in.Version = version
+	}
+
+	// Patch the setting
+	result, err := c.s.Patch(transport.NewContext(ctx), id, in)
+	if err != nil {
+		return preconditionFailed(err, conditional)
+	}
+
+	// Return the result, along with its new version
+	transport.SetETag(ctx, result.Version)
+	return transport.SendResponse(ctx, http.StatusOK, result)
+}
+
+// DeleteByID deletes a setting by ID
+//
+//	@Summary		Delete a setting by id
+//	@Description	Delete a setting by id
+//	@Tags			Setting
+//	@ID				deleteSettingByID
+//	@Accept			json
+//	@Produce		json
+//	@Security		JWT
+//	@x-permissions	["setting:write"]
+//	@Param			id	path	string	true	"Setting ID"
+//	@Success		204
+//	@Failure		400	{object}	domain.ErrorResponse
+//	@Failure		401	{object}	domain.ErrorResponse
+//	@Failure		403	{object}	domain.ErrorResponse
+//	@Failure		500	{object}	domain.ErrorResponse
+//	@Router			/setting/{id} [delete]
+func (c SettingHandler) DeleteByID(ctx echo.Context) (err error) {
+	// Parse the ID from the path parameter
+	id, err := uuid.FromString(ctx.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	// Delete the setting
+	err = c.s.DeleteByID(transport.NewContext(ctx), id)
+	if err != nil {
+		return err
+	}
+
+	// Return the result
+	return transport.SendResponse(ctx, http.StatusNoContent, nil)
+}
+
+// DeleteByIDs deletes multiple settings by their IDs
+//
+//	@Summary		Delete multiple settings
+//	@Description	Delete multiple settings by their ids. Either all or none of the settings are deleted.
+//	@Tags			Setting
+//	@ID				deleteSettingsByIDs
+//	@Accept			json
+//	@Produce		json
+//	@Security		JWT
+//	@x-permissions	["setting:write"]
+//	@Param			in	body	domain.DeleteSettingsInput	true	"Input"
+//	@Success		204
+//	@Failure		400	{object}	domain.ErrorResponse
+//	@Failure		401	{object}	domain.ErrorResponse
+//	@Failure		403	{object}	domain.ErrorResponse
+//	@Failure		500	{object}	domain.ErrorResponse
+//	@Router			/setting/bulk [delete]
+func (c SettingHandler) DeleteByIDs(ctx echo.Context) (err error) {
+	// Parse the input from the request body
+	var in domain.DeleteSettingsInput
+	err = transport.DecodeAndValidateRequestBody(ctx, &in)
+	if err != nil {
+		return err
+	}
+
+	// Delete the settings
+	err = c.s.DeleteByIDs(transport.NewContext(ctx), in)
+	if err != nil {
+		return err
+	}
+
+	// Return the result
+	return transport.SendResponse(ctx, http.StatusNoContent, nil)
+}
+
+// preconditionFailed reports a version conflict as a failed precondition when the caller made the request conditional through the If-Match header
+func preconditionFailed(err error, conditional bool) error {
+	var ce domain.ConflictError
+	if conditional && errors.As(err, &ce) {
+		return echo.NewHTTPError(http.StatusPreconditionFailed, ce.Message)
+	}
+	return err
 }
@@ -20,14 +20,14 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/setting/filter": {
-            "post": {
+        "/admin/scheduler/run": {
+            "get": {
                 "security": [
                     {
                         "JWT": []
                     }
                 ],
-                "description": "Filter settings by criteria. Supports pagination and returns the number of records as total.",
+                "description": "List the runs of the scheduled tasks, latest first. Supports pagination and returns the number of records as total. Pages can also be fetched by cursor, in which case a CursorPaginationResponse is returned without the total.",
                 "consumes": [
                     "application/json"
                 ],
@@ -35,11 +35,17 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Setting"
+                    "Scheduler"
                 ],
-                "summary": "Filter settings by criteria",
-                "operationId": "filterSettingsByCriteria",
+                "summary": "List runs of scheduled tasks",
+                "operationId": "findScheduledRuns",
                 "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Task Name",
+                        "name": "task",
+                        "in": "query"
+                    },
                     {
                         "type": "number",
                         "description": "Page Index",
@@ -53,13 +59,24 @@ const docTemplate = `{
                         "in": "query"
                     },
                     {
-                        "description": "Input",
-                        "name": "in",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/FilterSettingsByCriteriaInput"
-                        }
+                        "type": "string",
+                        "example": "startedAt:desc",
+                        "description": "Sort Keys, as field:direction separated by commas",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "status:eq:failed",
+                        "description": "Condition, as field:op:values with values separated by |",
+                        "name": "filter",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Cursor, which switches to keyset pagination. Empty for the first page.",
+                        "name": "cursor",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -76,7 +93,7 @@ const docTemplate = `{
                                         "data": {
                                             "type": "array",
                                             "items": {
-                                                "$ref": "#/definitions/Setting"
+                                                "$ref": "#/definitions/ScheduledRun"
                                             }
                                         }
                                     }
@@ -108,17 +125,20 @@ const docTemplate = `{
                             "$ref": "#/definitions/ErrorResponse"
                         }
                     }
-                }
+                },
+                "x-permissions": [
+                    "scheduler:read"
+                ]
             }
         },
-        "/setting/{id}": {
+        "/admin/scheduler/task": {
             "get": {
                 "security": [
                     {
                         "JWT": []
                     }
                 ],
-                "description": "Find a setting by id",
+                "description": "Find the tasks run on a schedule, along with their last \u0026 next runs",
                 "consumes": [
                     "application/json"
                 ],
@@ -126,17 +146,171 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Setting"
+                    "Scheduler"
                 ],
-                "summary": "Find a setting by id",
-                "operationId": "findSettingByID",
+                "summary": "Find scheduled tasks",
+                "operationId": "findScheduledTasks",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/BaseResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/ScheduledTask"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                },
+                "x-permissions": [
+                    "scheduler:read"
+                ]
+            }
+        },
+        "/auth/logout": {
+            "post": {
+                "security": [
+                    {
+                        "JWT": []
+                    }
+                ],
+                "description": "Revoke the access token the request is made with. The refresh token, if given, is revoked along with every token issued from it.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "Logout",
+                "operationId": "logout",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Setting ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "description": "Refresh Token",
+                        "name": "in",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/LogoutInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/revoke": {
+            "post": {
+                "security": [
+                    {
+                        "JWT": []
+                    }
+                ],
+                "description": "Revoke every refresh token of the caller, along with the access tokens issued with them \u0026 the access token the request is made with. Logs the caller out everywhere.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "Revoke all tokens",
+                "operationId": "revokeTokens",
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/token/refresh": {
+            "post": {
+                "description": "Exchange a refresh token for a new access token \u0026 refresh token. A refresh token can only be used once. Using it again revokes every token issued from it.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "Refresh tokens",
+                "operationId": "refreshTokens",
+                "parameters": [
+                    {
+                        "description": "Refresh Token",
+                        "name": "in",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/RefreshTokenInput"
+                        }
                     }
                 ],
                 "responses": {
@@ -151,7 +325,7 @@ const docTemplate = `{
                                     "type": "object",
                                     "properties": {
                                         "data": {
-                                            "$ref": "#/definitions/Setting"
+                                            "$ref": "#/definitions/AuthTokens"
                                         }
                                     }
                                 }
@@ -170,6 +344,62 @@ const docTemplate = `{
                             "$ref": "#/definitions/ErrorResponse"
                         }
                     },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/feature-flag": {
+            "get": {
+                "security": [
+                    {
+                        "JWT": []
+                    }
+                ],
+                "description": "Find all the feature flags, with their global rules",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Feature Flag"
+                ],
+                "summary": "Find all feature flags",
+                "operationId": "findAllFeatureFlags",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/BaseResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/FeatureFlag"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
                     "403": {
                         "description": "Forbidden",
                         "schema": {
@@ -182,42 +412,1796 @@ const docTemplate = `{
                             "$ref": "#/definitions/ErrorResponse"
                         }
                     }
-                }
-            }
-        }
-    },
-    "definitions": {
-        "BaseResponse": {
-            "type": "object",
-            "properties": {
-                "data": {}
-            }
-        },
-        "ErrorResponse": {
-            "type": "object",
-            "properties": {
-                "code": {
-                    "type": "string",
-                    "example": "INTERNAL_SERVER_ERROR"
                 },
-                "message": {
-                    "type": "string",
-                    "example": "Internal Server Error"
-                }
+                "x-permissions": [
+                    "feature_flag:read"
+                ]
             }
         },
-        "FilterSettingsByCriteriaInput": {
-            "type": "object",
-            "properties": {
-                "keys": {
-                    "type": "array",
-                    "items": {
-                        "type": "string"
-                    },
-                    "example": [
-                        "app.name"
-                    ]
-                }
+        "/feature-flag/evaluate": {
+            "get": {
+                "security": [
+                    {
+                        "JWT": []
+                    }
+                ],
+                "description": "Find out which feature flags are enabled for the caller, taking the overrides for their organization \u0026 user into account",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Feature Flag"
+                ],
+                "summary": "Evaluate feature flags",
+                "operationId": "evaluateFeatureFlags",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/BaseResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "object",
+                                            "additionalProperties": {
+                                                "type": "boolean"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/feature-flag/{name}": {
+            "put": {
+                "security": [
+                    {
+                        "JWT": []
+                    }
+                ],
+                "description": "Create or replace the global rules of a feature flag. Rules can be overridden for an organization or a user through the setting with the key feature.{name}.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Feature Flag"
+                ],
+                "summary": "Save a feature flag",
+                "operationId": "saveFeatureFlag",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Feature Flag Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Input",
+                        "name": "in",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/FeatureFlagRules"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/BaseResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/FeatureFlag"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                },
+                "x-permissions": [
+                    "feature_flag:write"
+                ]
+            }
+        },
+        "/setting": {
+            "post": {
+                "security": [
+                    {
+                        "JWT": []
+                    }
+                ],
+                "description": "Create a setting. The key must not be in use by another setting in the same scope.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Setting"
+                ],
+                "summary": "Create a setting",
+                "operationId": "createSetting",
+                "parameters": [
+                    {
+                        "description": "Input",
+                        "name": "in",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/CreateSettingInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/BaseResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/Setting"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                },
+                "x-permissions": [
+                    "setting:write"
+                ]
+            }
+        },
+        "/setting/bulk": {
+            "put": {
+                "security": [
+                    {
+                        "JWT": []
+                    }
+                ],
+                "description": "Replace the key and value of multiple settings at once. Either all or none of the settings are updated.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Setting"
+                ],
+                "summary": "Update multiple settings",
+                "operationId": "updateSettings",
+                "parameters": [
+                    {
+                        "description": "Input",
+                        "name": "in",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/UpdateSettingsInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/BaseResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/Setting"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                },
+                "x-permissions": [
+                    "setting:write"
+                ]
+            },
+            "post": {
+                "security": [
+                    {
+                        "JWT": []
+                    }
+                ],
+                "description": "Create multiple settings at once. Either all or none of the settings are created.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Setting"
+                ],
+                "summary": "Create multiple settings",
+                "operationId": "createSettings",
+                "parameters": [
+                    {
+                        "description": "Input",
+                        "name": "in",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/CreateSettingsInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/BaseResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/Setting"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                },
+                "x-permissions": [
+                    "setting:write"
+                ]
+            },
+            "delete": {
+                "security": [
+                    {
+                        "JWT": []
+                    }
+                ],
+                "description": "Delete multiple settings by their ids. Either all or none of the settings are deleted.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Setting"
+                ],
+                "summary": "Delete multiple settings",
+                "operationId": "deleteSettingsByIDs",
+                "parameters": [
+                    {
+                        "description": "Input",
+                        "name": "in",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/DeleteSettingsInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                },
+                "x-permissions": [
+                    "setting:write"
+                ]
+            }
+        },
+        "/setting/definition": {
+            "get": {
+                "security": [
+                    {
+                        "JWT": []
+                    }
+                ],
+                "description": "List the type, default value, constraints and description of every setting known to the platform",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Setting"
+                ],
+                "summary": "List setting definitions",
+                "operationId": "findSettingDefinitions",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/BaseResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/SettingDefinition"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                },
+                "x-permissions": [
+                    "setting:read"
+                ]
+            }
+        },
+        "/setting/export": {
+            "get": {
+                "security": [
+                    {
+                        "JWT": []
+                    }
+                ],
+                "description": "Export all the settings that aren't deleted as a JSON or YAML document, which can be imported into another environment",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/yaml"
+                ],
+                "tags": [
+                    "Setting"
+                ],
+                "summary": "Export settings",
+                "operationId": "exportSettings",
+                "parameters": [
+                    {
+                        "enum": [
+                            "json",
+                            "yaml"
+                        ],
+                        "type": "string",
+                        "description": "Document Format",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/SettingExport"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                },
+                "x-permissions": [
+                    "setting:read"
+                ]
+            }
+        },
+        "/setting/filter": {
+            "post": {
+                "security": [
+                    {
+                        "JWT": []
+                    }
+                ],
+                "description": "Filter settings by criteria. Supports pagination and returns the number of records as total. Pages can also be fetched by cursor, in which case a CursorPaginationResponse is returned without the total.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Setting"
+                ],
+                "summary": "Filter settings by criteria",
+                "operationId": "filterSettingsByCriteria",
+                "parameters": [
+                    {
+                        "type": "number",
+                        "description": "Page Index",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Page Size",
+                        "name": "size",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "updatedAt:desc",
+                        "description": "Sort Keys, as field:direction separated by commas",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "key:like:app.%",
+                        "description": "Condition, as field:op:values with values separated by |",
+                        "name": "filter",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Cursor, which switches to keyset pagination. Empty for the first page.",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Input",
+                        "name": "in",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/FilterSettingsByCriteriaInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/PaginationResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/Setting"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                },
+                "x-permissions": [
+                    "setting:read"
+                ]
+            }
+        },
+        "/setting/import": {
+            "post": {
+                "security": [
+                    {
+                        "JWT": []
+                    }
+                ],
+                "description": "Import settings from a JSON or YAML document in the format produced by the export. Settings are matched by key and scope.\nSettings that are not part of the document are only removed when pruning. In dry-run mode, the changes are reported without being made.",
+                "consumes": [
+                    "application/json",
+                    "application/yaml"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Setting"
+                ],
+                "summary": "Import settings",
+                "operationId": "importSettings",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "description": "Report the changes without making them",
+                        "name": "dryRun",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Remove the settings that are not part of the document",
+                        "name": "prune",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Input",
+                        "name": "in",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/SettingExport"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/BaseResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/SettingImportResult"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                },
+                "x-permissions": [
+                    "setting:write"
+                ]
+            }
+        },
+        "/setting/key/rotate": {
+            "post": {
+                "security": [
+                    {
+                        "JWT": []
+                    }
+                ],
+                "description": "Retire the data key that sensitive settings are encrypted with in favour of a new one. The values are re-encrypted with the new data key in the background.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Setting"
+                ],
+                "summary": "Rotate the data key of sensitive settings",
+                "operationId": "rotateSettingKey",
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                },
+                "x-permissions": [
+                    "setting:rotate_key"
+                ]
+            }
+        },
+        "/setting/resolve/{key}": {
+            "get": {
+                "security": [
+                    {
+                        "JWT": []
+                    }
+                ],
+                "description": "Resolve the value of a setting for the caller. A user override takes precedence over an organization override, which takes precedence over the global setting and then the declared default.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Setting"
+                ],
+                "summary": "Resolve a setting",
+                "operationId": "resolveSetting",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Setting Key",
+                        "name": "key",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/BaseResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/Setting"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                },
+                "x-permissions": [
+                    "setting:read"
+                ]
+            }
+        },
+        "/setting/revision": {
+            "get": {
+                "security": [
+                    {
+                        "JWT": []
+                    }
+                ],
+                "description": "List the revisions of a setting, latest first. Supports pagination and returns the number of records as total. Pages can also be fetched by cursor, in which case a CursorPaginationResponse is returned without the total.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Setting"
+                ],
+                "summary": "List setting revisions",
+                "operationId": "findSettingRevisions",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Setting Key",
+                        "name": "key",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Setting ID",
+                        "name": "settingId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Page Index",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Page Size",
+                        "name": "size",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "createdAt:asc",
+                        "description": "Sort Keys, as field:direction separated by commas",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "operation:in:create|update",
+                        "description": "Condition, as field:op:values with values separated by |",
+                        "name": "filter",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Cursor, which switches to keyset pagination. Empty for the first page.",
+                        "name": "cursor",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/PaginationResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/SettingRevision"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                },
+                "x-permissions": [
+                    "setting:read"
+                ]
+            }
+        },
+        "/setting/revision/{id}/rollback": {
+            "post": {
+                "security": [
+                    {
+                        "JWT": []
+                    }
+                ],
+                "description": "Restore the key \u0026 value captured by a revision to the setting it was made to. The setting is restored if it has since been deleted. Fails with a conflict if the setting is changed concurrently.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Setting"
+                ],
+                "summary": "Roll back a setting to a revision",
+                "operationId": "rollbackSetting",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Setting Revision ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/BaseResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/Setting"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                },
+                "x-permissions": [
+                    "setting:write"
+                ]
+            }
+        },
+        "/setting/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "JWT": []
+                    }
+                ],
+                "description": "Find a setting by id",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Setting"
+                ],
+                "summary": "Find a setting by id",
+                "operationId": "findSettingByID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Setting ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/BaseResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/Setting"
+                                        }
+                                    }
+                                }
+                            ]
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "The version of the setting"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                },
+                "x-permissions": [
+                    "setting:read"
+                ]
+            },
+            "put": {
+                "security": [
+                    {
+                        "JWT": []
+                    }
+                ],
+                "description": "Replace the key and value of a setting",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Setting"
+                ],
+                "summary": "Update a setting",
+                "operationId": "updateSetting",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Setting ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the version of the setting the caller expects",
+                        "name": "If-Match",
+                        "in": "header"
+                    },
+                    {
+                        "description": "Input",
+                        "name": "in",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/UpdateSettingInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/BaseResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/Setting"
+                                        }
+                                    }
+                                }
+                            ]
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "The version of the setting"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                },
+                "x-permissions": [
+                    "setting:write"
+                ]
+            },
+            "delete": {
+                "security": [
+                    {
+                        "JWT": []
+                    }
+                ],
+                "description": "Delete a setting by id",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Setting"
+                ],
+                "summary": "Delete a setting by id",
+                "operationId": "deleteSettingByID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Setting ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                },
+                "x-permissions": [
+                    "setting:write"
+                ]
+            },
+            "patch": {
+                "security": [
+                    {
+                        "JWT": []
+                    }
+                ],
+                "description": "Update only the fields of a setting that are present in the request",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Setting"
+                ],
+                "summary": "Patch a setting",
+                "operationId": "patchSetting",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Setting ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the version of the setting the caller expects",
+                        "name": "If-Match",
+                        "in": "header"
+                    },
+                    {
+                        "description": "Input",
+                        "name": "in",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/PatchSettingInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/BaseResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/Setting"
+                                        }
+                                    }
+                                }
+                            ]
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "The version of the setting"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                },
+                "x-permissions": [
+                    "setting:write"
+                ]
+            }
+        }
+    },
+    "definitions": {
+        "AuthTokens": {
+            "type": "object",
+            "properties": {
+                "accessToken": {
+                    "type": "string",
+                    "example": "eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9"
+                },
+                "accessTokenExpiry": {
+                    "type": "string",
+                    "example": "2020-01-01T04:00:00+05:30"
+                },
+                "refreshToken": {
+                    "type": "string",
+                    "example": "c2VjcmV0LXJlZnJlc2gtdG9rZW4"
+                },
+                "refreshTokenExpiry": {
+                    "type": "string",
+                    "example": "2020-01-31T00:00:00+05:30"
+                }
+            }
+        },
+        "BaseResponse": {
+            "type": "object",
+            "properties": {
+                "data": {}
+            }
+        },
+        "Condition": {
+            "type": "object",
+            "required": [
+                "field",
+                "op"
+            ],
+            "properties": {
+                "field": {
+                    "description": "Field represents a column for the entity you are filtering",
+                    "type": "string",
+                    "example": "key"
+                },
+                "op": {
+                    "description": "Op represents the operator comparing the field with the values",
+                    "enum": [
+                        "eq",
+                        "ne",
+                        "in",
+                        "like",
+                        "gt",
+                        "lt",
+                        "between",
+                        "is_null"
+                    ],
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/FilterOp"
+                        }
+                    ],
+                    "example": "like"
+                },
+                "values": {
+                    "description": "Values represents the values to compare the field with.\nin takes one or more values, between takes two, is_null takes none, and every other operator takes one.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "app.%"
+                    ]
+                }
+            }
+        },
+        "CreateSettingInput": {
+            "type": "object",
+            "required": [
+                "key"
+            ],
+            "properties": {
+                "key": {
+                    "type": "string",
+                    "example": "app.name"
+                },
+                "scope": {
+                    "type": "string",
+                    "enum": [
+                        "global",
+                        "organization",
+                        "user"
+                    ],
+                    "example": "organization"
+                },
+                "scopeId": {
+                    "type": "string",
+                    "example": "550e8400-e29b-41d4-a716-446655440000"
+                },
+                "sensitive": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "value": {
+                    "type": "string",
+                    "example": "App"
+                }
+            }
+        },
+        "CreateSettingsInput": {
+            "type": "object",
+            "required": [
+                "settings"
+            ],
+            "properties": {
+                "settings": {
+                    "type": "array",
+                    "minItems": 1,
+                    "items": {
+                        "$ref": "#/definitions/CreateSettingInput"
+                    }
+                }
+            }
+        },
+        "DeleteSettingsInput": {
+            "type": "object",
+            "required": [
+                "ids"
+            ],
+            "properties": {
+                "ids": {
+                    "type": "array",
+                    "minItems": 1,
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "550e8400-e29b-41d4-a716-446655440000"
+                    ]
+                }
+            }
+        },
+        "ErrorResponse": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string",
+                    "example": "INTERNAL_SERVER_ERROR"
+                },
+                "message": {
+                    "type": "string",
+                    "example": "Internal Server Error"
+                }
+            }
+        },
+        "FeatureFlag": {
+            "type": "object",
+            "properties": {
+                "description": {
+                    "type": "string",
+                    "example": "The redesigned dashboard"
+                },
+                "killSwitch": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "name": {
+                    "type": "string",
+                    "example": "new_dashboard"
+                },
+                "organizationIds": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "550e8400-e29b-41d4-a716-446655440000"
+                    ]
+                },
+                "percentage": {
+                    "type": "integer",
+                    "maximum": 100,
+                    "minimum": 0,
+                    "example": 25
+                },
+                "roles": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "admin"
+                    ]
+                }
+            }
+        },
+        "FeatureFlagRules": {
+            "type": "object",
+            "properties": {
+                "description": {
+                    "type": "string",
+                    "example": "The redesigned dashboard"
+                },
+                "killSwitch": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "organizationIds": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "550e8400-e29b-41d4-a716-446655440000"
+                    ]
+                },
+                "percentage": {
+                    "type": "integer",
+                    "maximum": 100,
+                    "minimum": 0,
+                    "example": 25
+                },
+                "roles": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "admin"
+                    ]
+                }
+            }
+        },
+        "FilterOp": {
+            "type": "string",
+            "enum": [
+                "eq",
+                "ne",
+                "in",
+                "like",
+                "gt",
+                "lt",
+                "between",
+                "is_null"
+            ],
+            "x-enum-varnames": [
+                "FilterOpEq",
+                "FilterOpNe",
+                "FilterOpIn",
+                "FilterOpLike",
+                "FilterOpGt",
+                "FilterOpLt",
+                "FilterOpBetween",
+                "FilterOpIsNull"
+            ]
+        },
+        "FilterSettingsByCriteriaInput": {
+            "type": "object",
+            "properties": {
+                "conditions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/Condition"
+                    }
+                },
+                "keys": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "app.name"
+                    ]
+                },
+                "scope": {
+                    "type": "string",
+                    "enum": [
+                        "global",
+                        "organization",
+                        "user"
+                    ],
+                    "example": "global"
+                },
+                "scopeId": {
+                    "type": "string",
+                    "example": "550e8400-e29b-41d4-a716-446655440000"
+                },
+                "sortKeys": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/SortKey"
+                    }
+                }
+            }
+        },
+        "LogoutInput": {
+            "type": "object",
+            "properties": {
+                "refreshToken": {
+                    "type": "string",
+                    "example": "c2VjcmV0LXJlZnJlc2gtdG9rZW4"
+                }
             }
         },
         "PaginationResponse": {
@@ -238,8 +2222,478 @@ const docTemplate = `{
                 }
             }
         },
+        "PatchSettingInput": {
+            "type": "object",
+            "properties": {
+                "key": {
+                    "type": "string",
+                    "minLength": 1,
+                    "example": "app.name"
+                },
+                "value": {
+                    "type": "string",
+                    "example": "App"
+                },
+                "version": {
+                    "type": "integer",
+                    "minimum": 0,
+                    "example": 1
+                }
+            }
+        },
+        "RefreshTokenInput": {
+            "type": "object",
+            "required": [
+                "refreshToken"
+            ],
+            "properties": {
+                "refreshToken": {
+                    "type": "string",
+                    "example": "c2VjcmV0LXJlZnJlc2gtdG9rZW4"
+                }
+            }
+        },
+        "ScheduledRun": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string",
+                    "example": "connection refused"
+                },
+                "finishedAt": {
+                    "type": "string",
+                    "example": "2020-01-01T00:00:01+05:30"
+                },
+                "id": {
+                    "type": "string",
+                    "example": "550e8400-e29b-41d4-a716-446655440000"
+                },
+                "instance": {
+                    "type": "string",
+                    "example": "api-7d9f8b6c4-x2x5l"
+                },
+                "scheduledAt": {
+                    "description": "ScheduledAt is the time the run was due, which identifies it among the runs of the task",
+                    "type": "string",
+                    "example": "2020-01-01T00:00:00+05:30"
+                },
+                "startedAt": {
+                    "type": "string",
+                    "example": "2020-01-01T00:00:00+05:30"
+                },
+                "status": {
+                    "type": "string",
+                    "enum": [
+                        "running",
+                        "succeeded",
+                        "failed"
+                    ],
+                    "example": "succeeded"
+                },
+                "task": {
+                    "type": "string",
+                    "example": "setting.purge"
+                }
+            }
+        },
+        "ScheduledTask": {
+            "type": "object",
+            "properties": {
+                "lastRun": {
+                    "$ref": "#/definitions/ScheduledRun"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "setting.purge"
+                },
+                "nextRun": {
+                    "type": "string",
+                    "example": "2020-01-01T03:00:00+05:30"
+                },
+                "schedule": {
+                    "type": "string",
+                    "example": "0 3 * * *"
+                }
+            }
+        },
         "Setting": {
             "type": "object",
+            "properties": {
+                "id": {
+                    "type": "string",
+                    "example": "550e8400-e29b-41d4-a716-446655440000"
+                },
+                "key": {
+                    "type": "string",
+                    "example": "app.name"
+                },
+                "organizationId": {
+                    "description": "OrganizationID is the organization that owns the setting. Settings owned by no organization are shared by all of them.",
+                    "type": "string",
+                    "example": "550e8400-e29b-41d4-a716-446655440000"
+                },
+                "scope": {
+                    "type": "string",
+                    "enum": [
+                        "global",
+                        "organization",
+                        "user"
+                    ],
+                    "example": "global"
+                },
+                "scopeId": {
+                    "type": "string",
+                    "example": "550e8400-e29b-41d4-a716-446655440000"
+                },
+                "sensitive": {
+                    "description": "Sensitive settings are stored encrypted and their values are masked for callers who can't read them",
+                    "type": "boolean",
+                    "example": false
+                },
+                "value": {
+                    "type": "string",
+                    "example": "App"
+                },
+                "version": {
+                    "description": "Version is incremented every time the setting is changed",
+                    "type": "integer",
+                    "example": 1
+                }
+            }
+        },
+        "SettingConstraints": {
+            "type": "object",
+            "properties": {
+                "max": {
+                    "description": "Max is the inclusive upper bound for int and duration settings, expressed in the setting's type",
+                    "type": "string",
+                    "example": "10"
+                },
+                "maxLength": {
+                    "description": "MaxLength is the maximum length of a string setting, or the maximum number of items in a list setting",
+                    "type": "integer",
+                    "example": 100
+                },
+                "min": {
+                    "description": "Min is the inclusive lower bound for int and duration settings, expressed in the setting's type",
+                    "type": "string",
+                    "example": "1"
+                },
+                "minLength": {
+                    "description": "MinLength is the minimum length of a string setting, or the minimum number of items in a list setting",
+                    "type": "integer",
+                    "example": 1
+                },
+                "options": {
+                    "description": "Options are the allowed values of a string setting, or the allowed items of a list setting",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "light",
+                        "dark"
+                    ]
+                },
+                "pattern": {
+                    "description": "Pattern is a regular expression that a string setting, or every item of a list setting, must match",
+                    "type": "string",
+                    "example": "^\\d{6}$"
+                }
+            }
+        },
+        "SettingDefinition": {
+            "type": "object",
+            "properties": {
+                "constraints": {
+                    "$ref": "#/definitions/SettingConstraints"
+                },
+                "default": {
+                    "type": "string",
+                    "example": "App"
+                },
+                "description": {
+                    "type": "string",
+                    "example": "The name of the application"
+                },
+                "key": {
+                    "type": "string",
+                    "example": "app.name"
+                },
+                "sensitive": {
+                    "description": "Sensitive settings are stored encrypted and their values are masked for callers who can't read them",
+                    "type": "boolean",
+                    "example": false
+                },
+                "type": {
+                    "enum": [
+                        "string",
+                        "int",
+                        "bool",
+                        "duration",
+                        "json",
+                        "list"
+                    ],
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/SettingType"
+                        }
+                    ],
+                    "example": "string"
+                }
+            }
+        },
+        "SettingExport": {
+            "type": "object",
+            "properties": {
+                "settings": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/SettingExportItem"
+                    }
+                }
+            }
+        },
+        "SettingExportItem": {
+            "type": "object",
+            "required": [
+                "key"
+            ],
+            "properties": {
+                "key": {
+                    "type": "string",
+                    "example": "app.name"
+                },
+                "scope": {
+                    "type": "string",
+                    "enum": [
+                        "global",
+                        "organization",
+                        "user"
+                    ],
+                    "example": "global"
+                },
+                "scopeId": {
+                    "type": "string",
+                    "example": "550e8400-e29b-41d4-a716-446655440000"
+                },
+                "sensitive": {
+                    "description": "The values of sensitive settings are masked for callers who can't read them.\nMasked values are left unchanged by an import.",
+                    "type": "boolean",
+                    "example": false
+                },
+                "value": {
+                    "type": "string",
+                    "example": "App"
+                }
+            }
+        },
+        "SettingImportResult": {
+            "type": "object",
+            "properties": {
+                "added": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/SettingExportItem"
+                    }
+                },
+                "changed": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/SettingValueChange"
+                    }
+                },
+                "dryRun": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "removed": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/SettingExportItem"
+                    }
+                }
+            }
+        },
+        "SettingRevision": {
+            "type": "object",
+            "properties": {
+                "changedBy": {
+                    "type": "string",
+                    "example": "550e8400-e29b-41d4-a716-446655440000"
+                },
+                "createdAt": {
+                    "type": "string",
+                    "example": "2020-01-01T00:00:00+05:30"
+                },
+                "id": {
+                    "type": "string",
+                    "example": "550e8400-e29b-41d4-a716-446655440000"
+                },
+                "key": {
+                    "type": "string",
+                    "example": "app.name"
+                },
+                "operation": {
+                    "type": "string",
+                    "enum": [
+                        "create",
+                        "update",
+                        "delete",
+                        "rollback"
+                    ],
+                    "example": "update"
+                },
+                "organizationId": {
+                    "description": "OrganizationID is the organization that owns the setting, if any",
+                    "type": "string",
+                    "example": "550e8400-e29b-41d4-a716-446655440000"
+                },
+                "scope": {
+                    "type": "string",
+                    "enum": [
+                        "global",
+                        "organization",
+                        "user"
+                    ],
+                    "example": "global"
+                },
+                "scopeId": {
+                    "type": "string",
+                    "example": "550e8400-e29b-41d4-a716-446655440000"
+                },
+                "sensitive": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "settingId": {
+                    "type": "string",
+                    "example": "550e8400-e29b-41d4-a716-446655440000"
+                },
+                "value": {
+                    "type": "string",
+                    "example": "App"
+                }
+            }
+        },
+        "SettingType": {
+            "type": "string",
+            "enum": [
+                "string",
+                "int",
+                "bool",
+                "duration",
+                "json",
+                "list"
+            ],
+            "x-enum-varnames": [
+                "SettingTypeString",
+                "SettingTypeInt",
+                "SettingTypeBool",
+                "SettingTypeDuration",
+                "SettingTypeJSON",
+                "SettingTypeList"
+            ]
+        },
+        "SettingValueChange": {
+            "type": "object",
+            "required": [
+                "key"
+            ],
+            "properties": {
+                "key": {
+                    "type": "string",
+                    "example": "app.name"
+                },
+                "previousValue": {
+                    "type": "string",
+                    "example": "Old App"
+                },
+                "scope": {
+                    "type": "string",
+                    "enum": [
+                        "global",
+                        "organization",
+                        "user"
+                    ],
+                    "example": "global"
+                },
+                "scopeId": {
+                    "type": "string",
+                    "example": "550e8400-e29b-41d4-a716-446655440000"
+                },
+                "sensitive": {
+                    "description": "The values of sensitive settings are masked for callers who can't read them.\nMasked values are left unchanged by an import.",
+                    "type": "boolean",
+                    "example": false
+                },
+                "value": {
+                    "type": "string",
+                    "example": "App"
+                }
+            }
+        },
+        "SortKey": {
+            "type": "object",
+            "properties": {
+                "direction": {
+                    "description": "Direction represents the direction of the sort",
+                    "type": "string",
+                    "enum": [
+                        "asc",
+                        "desc"
+                    ],
+                    "example": "asc"
+                },
+                "field": {
+                    "description": "Field represents a column for the entity you are sorting",
+                    "type": "string",
+                    "example": "name"
+                }
+            }
+        },
+        "UpdateSettingInput": {
+            "type": "object",
+            "required": [
+                "key"
+            ],
+            "properties": {
+                "key": {
+                    "type": "string",
+                    "example": "app.name"
+                },
+                "value": {
+                    "type": "string",
+                    "example": "App"
+                },
+                "version": {
+                    "type": "integer",
+                    "minimum": 0,
+                    "example": 1
+                }
+            }
+        },
+        "UpdateSettingsInput": {
+            "type": "object",
+            "required": [
+                "settings"
+            ],
+            "properties": {
+                "settings": {
+                    "type": "array",
+                    "minItems": 1,
+                    "items": {
+                        "$ref": "#/definitions/UpdateSettingsItemInput"
+                    }
+                }
+            }
+        },
+        "UpdateSettingsItemInput": {
+            "type": "object",
+            "required": [
+                "id",
+                "key"
+            ],
             "properties": {
                 "id": {
                     "type": "string",
@@ -252,6 +2706,11 @@ const docTemplate = `{
                 "value": {
                     "type": "string",
                     "example": "App"
+                },
+                "version": {
+                    "type": "integer",
+                    "minimum": 0,
+                    "example": 1
                 }
             }
         }
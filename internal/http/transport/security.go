@@ -1,29 +1,41 @@
 package transport
 
 import (
-	"github.com/gofrs/uuid/v5"
-	"github.com/golang-jwt/jwt/v5"
+	"context"
+
 	"github.com/labstack/echo/v4"
 
 	"github.com/Intiqo/app-platform/internal/domain"
+	"github.com/Intiqo/app-platform/internal/pkg/security"
 )
 
+// GetTokenMetadata returns the metadata of the auth token verified by the auth middleware, if any
+func GetTokenMetadata(ctx echo.Context) (result security.TokenMetadata, ok bool) {
+	result, ok = ctx.Get("user").(security.TokenMetadata)
+	return result, ok
+}
+
+// GetClaimsForContext returns the claims of the auth token verified by the auth middleware.
+// Services get them from the context returned by NewContext instead.
 func GetClaimsForContext(ctx echo.Context) (result domain.Claims) {
-	// Get the user from the context
-	token := ctx.Get("user")
-
-	// Get the user ID and role from the token
-	if token != nil {
-		u := token.(*jwt.Token)
-		jwtClaims := u.Claims.(jwt.MapClaims)
-		if jwtClaims["user_id"] != nil && jwtClaims["user_id"].(string) != "" {
-			result.UserID = uuid.Must(uuid.FromString(jwtClaims["user_id"].(string)))
-		}
-		if jwtClaims["role"] != nil && jwtClaims["role"].(string) != "" {
-			result.Role = jwtClaims["role"].(string)
+	// Get the metadata of the token from the context
+	metadata, ok := GetTokenMetadata(ctx)
+
+	// Get the user, organizations and role from the token
+	if ok {
+		result = domain.Claims{
+			UserID:          metadata.UserID,
+			OrganizationID:  metadata.OrganizationID,
+			OrganizationIDs: metadata.OrganizationIDs,
+			Role:            metadata.Role,
 		}
 	}
 
 	// Return the result
 	return result
 }
+
+// NewContext returns the context of the request, carrying the claims of the caller
+func NewContext(ctx echo.Context) context.Context {
+	return domain.ContextWithClaims(ctx.Request().Context(), GetClaimsForContext(ctx))
+}
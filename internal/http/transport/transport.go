@@ -1,19 +1,29 @@
 package transport
 
 import (
+	"encoding/json"
+	"fmt"
+	"mime"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/labstack/echo/v4"
+	"gopkg.in/yaml.v3"
 
 	"github.com/Intiqo/app-platform/internal/domain"
 )
 
 const PageMax = 100
 
-// DecodeQueryOptions decodes the query options
-func DecodeQueryOptions(ctx echo.Context) (result domain.QueryOptions) {
+// DecodeQueryOptions decodes the query options.
+// Pages are selected by page & size, unless the cursor param is present, in which case they are fetched by keyset.
+// The first page is fetched with an empty cursor, and the following ones with the cursors handed out with each page.
+// The query string can give sort keys as sort=field:direction, separated by commas,
+// and conditions as filter=field:op:values, with the values of in & between separated by |. The filter param can be repeated.
+// The criteria given in the body of a request, if any, are applied along with the ones given in the query string.
+func DecodeQueryOptions(ctx echo.Context, criteria ...domain.QueryCriteria) (result domain.QueryOptions, err error) {
 	// Get the limit and offset
 	limit, offset := GetLimitAndOffset(ctx)
 
@@ -24,9 +34,39 @@ func DecodeQueryOptions(ctx echo.Context) (result domain.QueryOptions) {
 	if offset != 0 {
 		result.Offset = offset
 	}
+	result.Keyset = ctx.QueryParams().Has("cursor")
+	result.Cursor = ctx.QueryParam("cursor")
+
+	// Parse the sort keys
+	for _, v := range strings.Split(ctx.QueryParam("sort"), ",") {
+		if v = strings.TrimSpace(v); v == "" {
+			continue
+		}
+		field, direction, _ := strings.Cut(v, ":")
+		result.SortKeys = append(result.SortKeys, domain.SortKey{Field: field, Direction: direction})
+	}
+
+	// Parse the conditions
+	for _, v := range ctx.QueryParams()["filter"] {
+		parts := strings.SplitN(v, ":", 3)
+		if len(parts) < 2 {
+			return result, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("The filter %s must be given as field:op:values", v))
+		}
+		condition := domain.Condition{Field: parts[0], Op: domain.FilterOp(parts[1])}
+		if len(parts) == 3 {
+			condition.Values = strings.Split(parts[2], "|")
+		}
+		result.Conditions = append(result.Conditions, condition)
+	}
+
+	// Add the criteria given in the body
+	for _, v := range criteria {
+		result.Conditions = append(result.Conditions, v.Conditions...)
+		result.SortKeys = append(result.SortKeys, v.SortKeys...)
+	}
 
 	// Return the result
-	return result
+	return result, nil
 }
 
 // DecodeAndValidateRequestBody decodes and validates the request body
@@ -47,6 +87,29 @@ func DecodeAndValidateRequestBody(ctx echo.Context, t interface{}) (err error) {
 	return nil
 }
 
+// DecodeAndValidateDocument decodes and validates a request body that is sent either as JSON or as YAML,
+// depending on its content type
+func DecodeAndValidateDocument(ctx echo.Context, t interface{}) (err error) {
+	// Bind the request body, which echo only supports as JSON
+	mediaType, _, _ := mime.ParseMediaType(ctx.Request().Header.Get(echo.HeaderContentType))
+	if !isYAML(mediaType) {
+		return DecodeAndValidateRequestBody(ctx, t)
+	}
+	err = yaml.NewDecoder(ctx.Request().Body).Decode(t)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	// Validate the request body
+	err = ctx.Validate(t)
+	if err != nil {
+		return err
+	}
+
+	// Return the result
+	return nil
+}
+
 // GetLimitAndOffset gets the limit and offset from the query params
 func GetLimitAndOffset(ctx echo.Context) (int64, int64) {
 	p := ctx.QueryParam("page")
@@ -119,6 +182,102 @@ func SendPaginationResponse(ctx echo.Context, status int, data interface{}, tota
 	return ctx.JSON(status, finalResult)
 }
 
+// SendCursorPaginationResponse sends a page fetched by keyset, along with the cursors of the pages around it
+func SendCursorPaginationResponse(ctx echo.Context, status int, data interface{}, page domain.PageInfo) error {
+	// Get the size
+	s, _ := strconv.Atoi(ctx.QueryParam("size"))
+	size := int64(s)
+	if size <= 0 {
+		size = PageMax
+	}
+
+	// Create the final response
+	finalResult := domain.CursorPaginationResponse{
+		BaseResponse: domain.BaseResponse{
+			Data: data,
+		},
+		Next: page.NextCursor,
+		Prev: page.PrevCursor,
+		Size: size,
+	}
+
+	// Return the result
+	return ctx.JSON(status, finalResult)
+}
+
+// SendDocument sends data as a downloadable document in the format given by the format query param.
+// The format can be either json or yaml, and defaults to json.
+func SendDocument(ctx echo.Context, name string, data interface{}) error {
+	// Encode the document
+	format := ctx.QueryParam("format")
+	var contentType string
+	var b []byte
+	var err error
+	switch format {
+	case "", domain.SettingExportFormatJSON:
+		format = domain.SettingExportFormatJSON
+		contentType = echo.MIMEApplicationJSON
+		b, err = json.MarshalIndent(data, "", "  ")
+	case domain.SettingExportFormatYAML:
+		contentType = MIMEApplicationYAML
+		b, err = yaml.Marshal(data)
+	default:
+		return domain.UserError{
+			Code:    domain.ErrorCodeINVALIDREQUEST,
+			Message: fmt.Sprintf("The format %s is not supported", format),
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	// Return the document as an attachment
+	ctx.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", name+"."+format))
+	return ctx.Blob(http.StatusOK, contentType, b)
+}
+
+// SetETag sets the ETag header of the response to the given version of the entity
+func SetETag(ctx echo.Context, version int64) {
+	ctx.Response().Header().Set(HeaderETag, strconv.Quote(strconv.FormatInt(version, 10)))
+}
+
+// DecodeIfMatch decodes the version of the entity the caller expects from the If-Match header.
+// ok is false when the header is absent or is a wildcard, in which case any version is acceptable.
+// ETags that aren't versions of the entity can never match, so they fail with a precondition failed error.
+func DecodeIfMatch(ctx echo.Context) (version int64, ok bool, err error) {
+	h := strings.TrimSpace(ctx.Request().Header.Get(HeaderIfMatch))
+	if h == "" || h == "*" {
+		return 0, false, nil
+	}
+	v, err := strconv.Unquote(h)
+	if err == nil {
+		version, err = strconv.ParseInt(v, 10, 64)
+	}
+	if err != nil || version <= 0 {
+		return 0, false, echo.NewHTTPError(http.StatusPreconditionFailed, domain.MessageVERSIONCONFLICT)
+	}
+	return version, true, nil
+}
+
+const (
+	// HeaderETag is the header carrying the version of the entity in a response
+	HeaderETag = "ETag"
+	// HeaderIfMatch is the header carrying the ETag of the entity that a conditional request expects
+	HeaderIfMatch = "If-Match"
+)
+
+// MIMEApplicationYAML is the media type of YAML documents
+const MIMEApplicationYAML = "application/yaml"
+
+// isYAML checks if the media type is one of the media types used for YAML documents
+func isYAML(mediaType string) bool {
+	switch mediaType {
+	case MIMEApplicationYAML, "application/x-yaml", "text/yaml", "text/x-yaml":
+		return true
+	}
+	return false
+}
+
 // CustomValidator custom validator for echo
 type CustomValidator struct {
 	Validator *validator.Validate
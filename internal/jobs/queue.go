@@ -0,0 +1,316 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/Intiqo/app-platform/internal/domain"
+	"github.com/Intiqo/app-platform/internal/pkg/config"
+)
+
+// pollPeriod is how often the queue is looked up for jobs that are due
+const pollPeriod = time.Second
+
+// rescuePeriod is how often the jobs whose workers went away are put back in the queue
+const rescuePeriod = time.Minute
+
+// lockTimeout is the time a job may run for before it is considered abandoned by its worker, and run again
+const lockTimeout = 30 * time.Minute
+
+// maxBackoff caps the time to wait before a failed job is run again
+const maxBackoff = time.Hour
+
+// defaultWorkers is the number of jobs run at the same time, unless configured through JOB_WORKERS
+const defaultWorkers = 4
+
+// defaultMaxAttempts is the number of times a job is run before it is marked as dead, unless given when it is enqueued
+const defaultMaxAttempts = 5
+
+// Handler runs a job with the arguments it was enqueued with
+type Handler func(ctx context.Context, args json.RawMessage) (err error)
+
+// Options are the options of a job given when it is enqueued
+type Options struct {
+	// RunAt is the time the job is due to run, right away by default
+	RunAt time.Time
+	// MaxAttempts is the number of times the job is run before it is marked as dead, 5 by default
+	MaxAttempts int
+	// UniqueKey keeps other jobs with the same key from being enqueued while the job is pending or running
+	UniqueKey string
+}
+
+// Queue runs the jobs stored in the database with a pool of workers.
+// Jobs can be enqueued by any instance, and are run by one of the instances that have a handler registered for them.
+type Queue struct {
+	r       domain.JobRepository
+	workers int
+
+	mu       sync.RWMutex
+	handlers map[string]Handler
+
+	// wake wakes up the dispatcher when a job is enqueued by this instance
+	wake chan struct{}
+
+	// cancel stops the dispatcher, and runCancel cancels the context of the jobs that are running
+	cancel    context.CancelFunc
+	runCancel context.CancelFunc
+	done      chan struct{}
+	running   sync.WaitGroup
+}
+
+// NewQueue creates a new queue, which runs JOB_WORKERS jobs at the same time once started
+func NewQueue(cfg config.AppConfig, r domain.JobRepository) *Queue {
+	workers := cfg.JobWorkers
+	if workers <= 0 {
+		workers = defaultWorkers
+	}
+	return &Queue{
+		r:        r,
+		workers:  workers,
+		handlers: make(map[string]Handler),
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// Handle registers the handler of the jobs with the name, replacing any that was registered before
+func (q *Queue) Handle(name string, h Handler) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.handlers[name] = h
+}
+
+// Register registers the handler of the jobs with the name, decoding their arguments into T
+func Register[T any](q *Queue, name string, fn func(ctx context.Context, args T) error) {
+	q.Handle(name, func(ctx context.Context, raw json.RawMessage) error {
+		var args T
+		err := json.Unmarshal(raw, &args)
+		if err != nil {
+			return fmt.Errorf("failed to decode the arguments of the job %s: %w", name, err)
+		}
+		return fn(ctx, args)
+	})
+}
+
+// Enqueue enqueues a job with the arguments, encoded as JSON.
+// The job is created in the transaction carried by the context, if any, so that it only runs if the transaction is committed.
+// When a pending or running job already holds the unique key, that job is returned instead.
+func (q *Queue) Enqueue(ctx context.Context, name string, args any, opts ...Options) (result domain.Job, err error) {
+	var o Options
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+
+	// Build the job
+	b, err := json.Marshal(args)
+	if err != nil {
+		return result, err
+	}
+	result = domain.Job{
+		Name:        name,
+		Args:        b,
+		MaxAttempts: o.MaxAttempts,
+		RunAt:       o.RunAt,
+	}
+	if result.MaxAttempts <= 0 {
+		result.MaxAttempts = defaultMaxAttempts
+	}
+	if result.RunAt.IsZero() {
+		result.RunAt = time.Now()
+	}
+	if o.UniqueKey != "" {
+		result.UniqueKey = &o.UniqueKey
+	}
+
+	// Enqueue the job
+	err = q.r.Enqueue(ctx, &result)
+	if err != nil {
+		return result, err
+	}
+
+	// Let the dispatcher know, in case the job is due
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return result, nil
+}
+
+// Start starts running the jobs that are due with the registered handlers, until Shutdown is called
+func (q *Queue) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	runCtx, runCancel := context.WithCancel(context.Background())
+	q.cancel = cancel
+	q.runCancel = runCancel
+	q.done = make(chan struct{})
+	go func() {
+		defer close(q.done)
+		q.dispatch(ctx, runCtx)
+	}()
+}
+
+// Shutdown stops running new jobs and waits for the running ones to finish.
+// If the context is done first, the running jobs are cancelled, and are run again later since they didn't finish.
+func (q *Queue) Shutdown(ctx context.Context) (err error) {
+	if q.cancel == nil {
+		return nil
+	}
+	q.cancel()
+	<-q.done
+
+	// Wait for the running jobs to finish
+	finished := make(chan struct{})
+	go func() {
+		q.running.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+	case <-ctx.Done():
+		q.runCancel()
+		<-finished
+		err = ctx.Err()
+	}
+	q.runCancel()
+	return err
+}
+
+// dispatch claims the jobs that are due whenever a worker is free, and runs them in the background
+func (q *Queue) dispatch(ctx context.Context, runCtx context.Context) {
+	ticker := time.NewTicker(pollPeriod)
+	defer ticker.Stop()
+	rescueTicker := time.NewTicker(rescuePeriod)
+	defer rescueTicker.Stop()
+
+	// free holds a token for every worker that is free
+	free := make(chan struct{}, q.workers)
+	for range q.workers {
+		free <- struct{}{}
+	}
+
+	q.rescue(ctx)
+	for {
+		// Claim as many jobs as there are free workers
+		claimed := q.claim(ctx, len(free))
+		for _, job := range claimed {
+			<-free
+			q.running.Add(1)
+			go func() {
+				defer func() {
+					free <- struct{}{}
+					q.running.Done()
+				}()
+				q.run(runCtx, job)
+			}()
+		}
+
+		// Look for more jobs right away if a worker is still free & jobs were found, and wait otherwise
+		if len(claimed) > 0 && len(free) > 0 {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-q.wake:
+		case <-rescueTicker.C:
+			q.rescue(ctx)
+		}
+	}
+}
+
+// claim claims up to limit jobs that are due, with the names that handlers are registered for
+func (q *Queue) claim(ctx context.Context, limit int) (result []domain.Job) {
+	q.mu.RLock()
+	names := make([]string, 0, len(q.handlers))
+	for k := range q.handlers {
+		names = append(names, k)
+	}
+	q.mu.RUnlock()
+	if limit == 0 || len(names) == 0 {
+		return nil
+	}
+
+	result, err := q.r.Dequeue(ctx, names, limit)
+	if err != nil && ctx.Err() == nil {
+		slog.Error("failed to dequeue jobs", "error", err)
+	}
+	return result
+}
+
+// rescue puts the jobs whose workers went away back in the queue
+func (q *Queue) rescue(ctx context.Context) {
+	count, err := q.r.Rescue(ctx, time.Now().Add(-lockTimeout))
+	if err != nil {
+		if ctx.Err() == nil {
+			slog.Error("failed to rescue abandoned jobs", "error", err)
+		}
+		return
+	}
+	if count > 0 {
+		slog.Warn("rescued abandoned jobs", "count", count)
+	}
+}
+
+// run runs a job with its handler, and records the outcome.
+// Failed jobs are run again with an exponential backoff, until they run out of attempts and are marked as dead.
+func (q *Queue) run(ctx context.Context, job domain.Job) {
+	q.mu.RLock()
+	h, ok := q.handlers[job.Name]
+	q.mu.RUnlock()
+
+	// Run the job, within the time it is allowed to hold on to its lock
+	err := errors.New("no handler is registered for the job")
+	if ok {
+		runCtx, cancel := context.WithTimeout(ctx, lockTimeout)
+		err = safeRun(runCtx, h, job.Args)
+		cancel()
+	}
+
+	// Record the outcome, even if the job was cancelled
+	interrupted := ctx.Err() != nil
+	ctx = context.WithoutCancel(ctx)
+	switch {
+	case err == nil:
+		err = q.r.Complete(ctx, job)
+	case interrupted:
+		// The job was cut short by the shutdown, rather than failing on its own
+		slog.Warn("job interrupted by shutdown", "id", job.ID, "name", job.Name)
+		err = q.r.Retry(ctx, job, err.Error(), time.Now())
+	case job.Attempts >= job.MaxAttempts:
+		slog.Error("job failed on its last attempt", "id", job.ID, "name", job.Name, "attempts", job.Attempts, "error", err)
+		err = q.r.Kill(ctx, job, err.Error())
+	default:
+		slog.Warn("job failed", "id", job.ID, "name", job.Name, "attempts", job.Attempts, "error", err)
+		err = q.r.Retry(ctx, job, err.Error(), time.Now().Add(backoff(job.Attempts)))
+	}
+	if err != nil {
+		slog.Error("failed to record the outcome of a job", "id", job.ID, "name", job.Name, "error", err)
+	}
+}
+
+// safeRun runs the handler, turning a panic into an error
+func safeRun(ctx context.Context, h Handler, args json.RawMessage) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("job panicked: %v\n%s", p, debug.Stack())
+		}
+	}()
+	return h(ctx, args)
+}
+
+// backoff is the time to wait before running a job again, doubling with every failed attempt, with some jitter
+// so that jobs that failed together don't run again together
+func backoff(attempts int) time.Duration {
+	d := maxBackoff
+	if attempts < 12 {
+		d = min(time.Second<<attempts, maxBackoff)
+	}
+	return d/2 + rand.N(d/2+1)
+}
@@ -0,0 +1,213 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid/v5"
+
+	"github.com/Intiqo/app-platform/internal/domain"
+	"github.com/Intiqo/app-platform/internal/pkg/config"
+)
+
+// memoryJobRepository keeps the jobs in memory, the way the database would
+type memoryJobRepository struct {
+	mu   sync.Mutex
+	jobs map[uuid.UUID]*domain.Job
+}
+
+func newMemoryJobRepository() *memoryJobRepository {
+	return &memoryJobRepository{jobs: make(map[uuid.UUID]*domain.Job)}
+}
+
+func (r *memoryJobRepository) Enqueue(ctx context.Context, entity *domain.Job) (err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, v := range r.jobs {
+		active := v.Status == domain.JobStatusPending || v.Status == domain.JobStatusRunning
+		if active && entity.UniqueKey != nil && v.UniqueKey != nil && *v.UniqueKey == *entity.UniqueKey {
+			*entity = *v
+			return nil
+		}
+	}
+	entity.ID = uuid.Must(uuid.NewV4())
+	entity.Status = domain.JobStatusPending
+	job := *entity
+	r.jobs[job.ID] = &job
+	return nil
+}
+
+func (r *memoryJobRepository) Dequeue(ctx context.Context, names []string, limit int) (result []domain.Job, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for _, v := range r.jobs {
+		if len(result) == limit {
+			break
+		}
+		if v.Status != domain.JobStatusPending || v.RunAt.After(now) || !slices.Contains(names, v.Name) {
+			continue
+		}
+		v.Status = domain.JobStatusRunning
+		v.Attempts++
+		v.LockedAt = &now
+		result = append(result, *v)
+	}
+	return result, nil
+}
+
+func (r *memoryJobRepository) Complete(ctx context.Context, job domain.Job) (err error) {
+	return r.finish(job, func(v *domain.Job) { v.Status = domain.JobStatusSucceeded })
+}
+
+func (r *memoryJobRepository) Retry(ctx context.Context, job domain.Job, reason string, runAt time.Time) (err error) {
+	return r.finish(job, func(v *domain.Job) {
+		v.Status = domain.JobStatusPending
+		v.LastError = &reason
+		v.RunAt = runAt
+	})
+}
+
+func (r *memoryJobRepository) Kill(ctx context.Context, job domain.Job, reason string) (err error) {
+	return r.finish(job, func(v *domain.Job) {
+		v.Status = domain.JobStatusDead
+		v.LastError = &reason
+	})
+}
+
+func (r *memoryJobRepository) Rescue(ctx context.Context, lockedBefore time.Time) (count int64, err error) {
+	return 0, nil
+}
+
+func (r *memoryJobRepository) finish(job domain.Job, fn func(v *domain.Job)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fn(r.jobs[job.ID])
+	return nil
+}
+
+// find finds a job once it meets the condition, failing the test if it doesn't in time
+func (r *memoryJobRepository) find(t *testing.T, id uuid.UUID, cond func(v domain.Job) bool) domain.Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		r.mu.Lock()
+		v := *r.jobs[id]
+		r.mu.Unlock()
+		if cond(v) {
+			return v
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("Job %s didn't reach the expected state in time", id)
+	return domain.Job{}
+}
+
+type greetArgs struct {
+	Name string `json:"name"`
+}
+
+func TestQueue(t *testing.T) {
+	t.Run("success - run a job with typed arguments", func(t *testing.T) {
+		r := newMemoryJobRepository()
+		q := NewQueue(config.AppConfig{}, r)
+		got := make(chan string, 1)
+		Register(q, "greet", func(ctx context.Context, args greetArgs) error {
+			got <- args.Name
+			return nil
+		})
+		q.Start()
+		defer func() { _ = q.Shutdown(context.Background()) }()
+
+		job, err := q.Enqueue(context.Background(), "greet", greetArgs{Name: "world"})
+		if err != nil {
+			t.Fatalf("Error enqueuing the job: %v", err)
+		}
+		select {
+		case name := <-got:
+			if name != "world" {
+				t.Errorf("Expected the job to be run with world, got %s", name)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Expected the job to run")
+		}
+		r.find(t, job.ID, func(v domain.Job) bool { return v.Status == domain.JobStatusSucceeded })
+	})
+
+	t.Run("success - deduplicate by unique key", func(t *testing.T) {
+		r := newMemoryJobRepository()
+		q := NewQueue(config.AppConfig{}, r)
+		first, err := q.Enqueue(context.Background(), "greet", greetArgs{Name: "a"}, Options{UniqueKey: "greet:a"})
+		if err != nil {
+			t.Fatalf("Error enqueuing the job: %v", err)
+		}
+		second, err := q.Enqueue(context.Background(), "greet", greetArgs{Name: "a"}, Options{UniqueKey: "greet:a"})
+		if err != nil {
+			t.Fatalf("Error enqueuing the job: %v", err)
+		}
+		if first.ID != second.ID {
+			t.Errorf("Expected the job holding the unique key to be returned, got %s and %s", first.ID, second.ID)
+		}
+	})
+
+	t.Run("success - retry a failed job, and mark it as dead once it runs out of attempts", func(t *testing.T) {
+		r := newMemoryJobRepository()
+		q := NewQueue(config.AppConfig{}, r)
+		q.Handle("fail", func(ctx context.Context, args json.RawMessage) error {
+			return errors.New("boom")
+		})
+		q.Handle("panic", func(ctx context.Context, args json.RawMessage) error {
+			panic("boom")
+		})
+		q.Start()
+		defer func() { _ = q.Shutdown(context.Background()) }()
+
+		retried, err := q.Enqueue(context.Background(), "fail", nil, Options{MaxAttempts: 2})
+		if err != nil {
+			t.Fatalf("Error enqueuing the job: %v", err)
+		}
+		v := r.find(t, retried.ID, func(v domain.Job) bool { return v.LastError != nil })
+		if v.Status != domain.JobStatusPending || !v.RunAt.After(time.Now()) {
+			t.Errorf("Expected the job to be put back in the queue for later, got %s at %s", v.Status, v.RunAt)
+		}
+
+		dead, err := q.Enqueue(context.Background(), "panic", nil, Options{MaxAttempts: 1})
+		if err != nil {
+			t.Fatalf("Error enqueuing the job: %v", err)
+		}
+		r.find(t, dead.ID, func(v domain.Job) bool { return v.Status == domain.JobStatusDead })
+	})
+
+	t.Run("success - interrupt the running jobs that don't finish before the shutdown", func(t *testing.T) {
+		r := newMemoryJobRepository()
+		q := NewQueue(config.AppConfig{}, r)
+		started := make(chan struct{})
+		q.Handle("block", func(ctx context.Context, args json.RawMessage) error {
+			close(started)
+			<-ctx.Done()
+			return ctx.Err()
+		})
+		q.Start()
+
+		job, err := q.Enqueue(context.Background(), "block", nil, Options{MaxAttempts: 1})
+		if err != nil {
+			t.Fatalf("Error enqueuing the job: %v", err)
+		}
+		<-started
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		err = q.Shutdown(ctx)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected the shutdown to run out of time, got %v", err)
+		}
+		v := r.find(t, job.ID, func(v domain.Job) bool { return v.Status != domain.JobStatusRunning })
+		if v.Status != domain.JobStatusPending {
+			t.Errorf("Expected the interrupted job to be put back in the queue, got %s", v.Status)
+		}
+	})
+}
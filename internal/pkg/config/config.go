@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 
@@ -15,6 +16,8 @@ const SourceKey = "CONFIG_SOURCE"
 const SourceEnv = "ENVIRONMENT"
 const SourceAWSSecretsManager = "AWS_SECRETS_MANAGER"
 
+const ConfigFileKey = "CONFIG_FILE"
+
 const AwsProfileKey = "AWS_PROFILE"
 const AwsConfigSecretsNameKey = "AWS_CONFIG_SECRETS_NAME"
 
@@ -33,14 +36,42 @@ type AppConfig struct {
 	AuthSecret       string `mapstructure:"AUTH_SECRET"`
 	AuthExpiryPeriod int    `mapstructure:"AUTH_EXPIRY_PERIOD"`
 
+	AuthRefreshExpiryPeriod int           `mapstructure:"AUTH_REFRESH_EXPIRY_PERIOD"`
+	AuthSigningKeys         string        `mapstructure:"AUTH_SIGNING_KEYS"`
+	AuthKeyOverlap          time.Duration `mapstructure:"AUTH_KEY_OVERLAP"`
+
 	DatabaseHost     string `mapstructure:"DB_HOST"`
 	DatabasePort     string `mapstructure:"DB_PORT"`
 	DatabaseUsername string `mapstructure:"DB_USERNAME"`
 	DatabasePassword string `mapstructure:"DB_PASSWORD"`
 	DatabaseName     string `mapstructure:"DB_DATABASE_NAME"`
 
+	DatabaseSslMode           string        `mapstructure:"DB_SSL_MODE"`
+	DatabaseSslRootCert       string        `mapstructure:"DB_SSL_ROOT_CERT"`
+	DatabaseMaxConns          int           `mapstructure:"DB_MAX_CONNS"`
+	DatabaseMinConns          int           `mapstructure:"DB_MIN_CONNS"`
+	DatabaseMaxConnLifetime   time.Duration `mapstructure:"DB_MAX_CONN_LIFETIME"`
+	DatabaseMaxConnIdleTime   time.Duration `mapstructure:"DB_MAX_CONN_IDLE_TIME"`
+	DatabaseHealthCheckPeriod time.Duration `mapstructure:"DB_HEALTH_CHECK_PERIOD"`
+	DatabaseStatementTimeout  time.Duration `mapstructure:"DB_STATEMENT_TIMEOUT"`
+	DatabaseApplicationName   string        `mapstructure:"DB_APPLICATION_NAME"`
+	DatabaseReplicaUrls       string        `mapstructure:"DB_REPLICA_URLS"`
+
+	DatabaseMigrateOnStart bool `mapstructure:"DB_MIGRATE_ON_START"`
+
 	RequestBodySizeLimit string `mapstructure:"REQUEST_BODY_SIZE_LIMIT"`
 
+	SettingMasterKeyName  string `mapstructure:"SETTING_MASTER_KEY_NAME"`
+	SettingPurgeAfterDays int    `mapstructure:"SETTING_PURGE_AFTER_DAYS"`
+
+	RbacRolePermissions string `mapstructure:"RBAC_ROLE_PERMISSIONS"`
+
+	OutboxPublisher     string `mapstructure:"OUTBOX_PUBLISHER"`
+	OutboxWebhookUrl    string `mapstructure:"OUTBOX_WEBHOOK_URL"`
+	OutboxWebhookSecret string `mapstructure:"OUTBOX_WEBHOOK_SECRET"`
+
+	JobWorkers int `mapstructure:"JOB_WORKERS"`
+
 	SwaggerHostUrl    string `mapstructure:"SWAGGER_HOST_URL"`
 	SwaggerHostScheme string `mapstructure:"SWAGGER_HOST_SCHEME"`
 	SwaggerUsername   string `mapstructure:"SWAGGER_USERNAME"`
@@ -0,0 +1,20 @@
+package publisher
+
+import (
+	"context"
+	"log/slog"
+)
+
+// logPublisher logs the messages instead of publishing them, for local use
+type logPublisher struct{}
+
+// NewLogPublisher creates a new log publisher
+func NewLogPublisher() Publisher {
+	return logPublisher{}
+}
+
+// Publish logs the message
+func (p logPublisher) Publish(ctx context.Context, msg Message) (err error) {
+	slog.InfoContext(ctx, "published message", "id", msg.ID, "topic", msg.Topic, "aggregateId", msg.AggregateID, "payload", string(msg.Payload))
+	return nil
+}
@@ -0,0 +1,44 @@
+package publisher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gofrs/uuid/v5"
+
+	"github.com/Intiqo/app-platform/internal/pkg/config"
+)
+
+// Kinds of publishers that can be configured through OUTBOX_PUBLISHER
+const (
+	KindLog     = "log"
+	KindWebhook = "webhook"
+)
+
+// Message is a message published to other systems
+type Message struct {
+	ID          uuid.UUID       `json:"id"`
+	Topic       string          `json:"topic"`
+	AggregateID uuid.UUID       `json:"aggregateId"`
+	Payload     json.RawMessage `json:"payload"`
+	CreatedAt   time.Time       `json:"createdAt"`
+}
+
+// Publisher defines the interface for a publisher
+type Publisher interface {
+	// Publish publishes a message. Messages may be published more than once, so consumers should deduplicate them by their ID.
+	Publish(ctx context.Context, msg Message) (err error)
+}
+
+// NewPublisher creates the publisher configured through OUTBOX_PUBLISHER, logging the messages when none is configured
+func NewPublisher(cfg config.AppConfig) (Publisher, error) {
+	switch cfg.OutboxPublisher {
+	case "", KindLog:
+		return NewLogPublisher(), nil
+	case KindWebhook:
+		return NewWebhookPublisher(cfg)
+	}
+	return nil, fmt.Errorf("unknown outbox publisher %q, expected one of: %s, %s", cfg.OutboxPublisher, KindLog, KindWebhook)
+}
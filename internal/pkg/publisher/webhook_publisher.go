@@ -0,0 +1,81 @@
+package publisher
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/Intiqo/app-platform/internal/pkg/config"
+)
+
+// Headers sent along with the messages posted to the webhook
+const (
+	HeaderMessageID    = "X-Message-Id"
+	HeaderMessageTopic = "X-Message-Topic"
+	// HeaderSignature holds the hex encoded HMAC-SHA256 of the body, keyed with OUTBOX_WEBHOOK_SECRET
+	HeaderSignature = "X-Signature-256"
+)
+
+// webhookTimeout is the time the webhook is given to accept a message
+const webhookTimeout = 10 * time.Second
+
+// webhookPublisher posts the messages to a webhook as JSON
+type webhookPublisher struct {
+	url    string
+	secret []byte
+	client *http.Client
+}
+
+// NewWebhookPublisher creates a new webhook publisher posting to OUTBOX_WEBHOOK_URL
+func NewWebhookPublisher(cfg config.AppConfig) (Publisher, error) {
+	if cfg.OutboxWebhookUrl == "" {
+		return nil, errors.New("OUTBOX_WEBHOOK_URL is required by the webhook publisher")
+	}
+	return &webhookPublisher{
+		url:    cfg.OutboxWebhookUrl,
+		secret: []byte(cfg.OutboxWebhookSecret),
+		client: &http.Client{Timeout: webhookTimeout},
+	}, nil
+}
+
+// Publish posts the message to the webhook, which must respond with a 2xx status for the message to be considered published
+func (p *webhookPublisher) Publish(ctx context.Context, msg Message) (err error) {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	// Build the request
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderMessageID, msg.ID.String())
+	req.Header.Set(HeaderMessageTopic, msg.Topic)
+	if len(p.secret) > 0 {
+		mac := hmac.New(sha256.New, p.secret)
+		mac.Write(body)
+		req.Header.Set(HeaderSignature, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	// Send the request
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package publisher
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid/v5"
+
+	"github.com/Intiqo/app-platform/internal/pkg/config"
+)
+
+func TestWebhookPublisher(t *testing.T) {
+	msg := Message{
+		ID:          uuid.Must(uuid.NewV4()),
+		Topic:       "setting.changed",
+		AggregateID: uuid.Must(uuid.NewV4()),
+		Payload:     json.RawMessage(`{"key":"app.name"}`),
+		CreatedAt:   time.Now().UTC(),
+	}
+
+	t.Run("success - post the signed message", func(t *testing.T) {
+		var got Message
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			mac := hmac.New(sha256.New, []byte("secret"))
+			mac.Write(body)
+			if r.Header.Get(HeaderSignature) != "sha256="+hex.EncodeToString(mac.Sum(nil)) {
+				t.Errorf("Expected the body to be signed, got signature %q", r.Header.Get(HeaderSignature))
+			}
+			if r.Header.Get(HeaderMessageID) != msg.ID.String() {
+				t.Errorf("Expected message id %s, got %s", msg.ID, r.Header.Get(HeaderMessageID))
+			}
+			_ = json.Unmarshal(body, &got)
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer srv.Close()
+
+		p, err := NewWebhookPublisher(config.AppConfig{OutboxWebhookUrl: srv.URL, OutboxWebhookSecret: "secret"})
+		if err != nil {
+			t.Fatalf("Error creating the publisher: %v", err)
+		}
+		err = p.Publish(context.Background(), msg)
+		if err != nil {
+			t.Fatalf("Error publishing the message: %v", err)
+		}
+		if got.ID != msg.ID || got.Topic != msg.Topic || string(got.Payload) != string(msg.Payload) {
+			t.Errorf("Expected message %+v, got %+v", msg, got)
+		}
+	})
+
+	t.Run("failure - webhook rejects the message", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer srv.Close()
+
+		p, err := NewWebhookPublisher(config.AppConfig{OutboxWebhookUrl: srv.URL})
+		if err != nil {
+			t.Fatalf("Error creating the publisher: %v", err)
+		}
+		err = p.Publish(context.Background(), msg)
+		if err == nil {
+			t.Errorf("Expected an error when the webhook responds with a server error")
+		}
+	})
+
+	t.Run("failure - no url", func(t *testing.T) {
+		_, err := NewPublisher(config.AppConfig{OutboxPublisher: KindWebhook})
+		if err == nil {
+			t.Errorf("Expected an error when the webhook url is missing")
+		}
+	})
+}
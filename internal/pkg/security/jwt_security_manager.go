@@ -1,8 +1,16 @@
 package security
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"time"
 
+	"github.com/gofrs/uuid/v5"
 	"github.com/golang-jwt/jwt/v5"
 
 	"github.com/Intiqo/app-platform/internal/pkg/config"
@@ -10,9 +18,17 @@ import (
 
 const issuer = "App-Server"
 
+// defaultRefreshExpiryPeriod is the number of hours refresh tokens are valid for, unless configured through AUTH_REFRESH_EXPIRY_PERIOD
+const defaultRefreshExpiryPeriod = 30 * 24
+
+// refreshTokenSize is the number of random bytes in a refresh token
+const refreshTokenSize = 32
+
 // jwtSecurityManager represents the JWT security manager
 type jwtSecurityManager struct {
-	cfg config.AppConfig
+	cfg   config.AppConfig
+	store Store
+	keys  *Keyring
 }
 
 // authClaims represents the claims in the auth token
@@ -21,28 +37,244 @@ type authClaims struct {
 	jwt.RegisteredClaims
 }
 
-// NewJwtSecurityManager creates a new JWT security manager
-func NewJwtSecurityManager(cfg config.AppConfig) Manager {
+// NewJwtSecurityManager creates a new JWT security manager.
+// Refresh tokens & revoked auth tokens are kept in the store.
+// Auth tokens are signed with the keys of the keyring, or with HS256 & the shared AUTH_SECRET when it has none.
+func NewJwtSecurityManager(cfg config.AppConfig, store Store, keys *Keyring) Manager {
 	return &jwtSecurityManager{
-		cfg: cfg,
+		cfg:   cfg,
+		store: store,
+		keys:  keys,
 	}
 }
 
 // GenerateAuthToken generates an auth token for a user.
 func (s jwtSecurityManager) GenerateAuthToken(metadata TokenMetadata) (token string, err error) {
+	token, _, _, err = s.generateAuthToken(metadata)
+	return token, err
+}
+
+// generateAuthToken generates an auth token for a user, along with its ID & expiry
+func (s jwtSecurityManager) generateAuthToken(metadata TokenMetadata) (token string, id string, expiry time.Time, err error) {
+	err = metadata.Validate()
+	if err != nil {
+		return "", "", expiry, err
+	}
+
+	now := time.Now()
+	id = uuid.Must(uuid.NewV4()).String()
+	expiry = now.Add(time.Hour * time.Duration(s.cfg.AuthExpiryPeriod))
 	claims := &authClaims{
 		TokenMetadata: metadata,
 		RegisteredClaims: jwt.RegisteredClaims{
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * time.Duration(s.cfg.AuthExpiryPeriod))),
+			ID:        id,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiry),
 			Issuer:    issuer,
 		},
 	}
 
-	t := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	token, err = t.SignedString([]byte(s.cfg.AuthSecret))
+	// Sign with the key whose turn it is, identified by its kid
+	var t *jwt.Token
+	var key interface{} = []byte(s.cfg.AuthSecret)
+	if s.keys.Empty() {
+		t = jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	} else {
+		k := s.keys.Signing(now)
+		if k == nil {
+			return "", "", expiry, errors.New("no signing key is active")
+		}
+		t = jwt.NewWithClaims(k.Method, claims)
+		t.Header["kid"] = k.ID
+		key = k.private
+	}
+	token, err = t.SignedString(key)
+	if err != nil {
+		return "", "", expiry, err
+	}
+	return token, id, expiry, nil
+}
+
+func (s jwtSecurityManager) VerifyAuthToken(ctx context.Context, token string) (result TokenMetadata, err error) {
+	// Parse the token, checking its signature, issuer & expiry
+	claims := &authClaims{}
+	_, err = jwt.ParseWithClaims(token, claims, s.verificationKey, jwt.WithValidMethods(s.methods()), jwt.WithIssuer(issuer), jwt.WithExpirationRequired())
+	if err != nil {
+		return result, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+	err = claims.TokenMetadata.Validate()
+	if err != nil {
+		return result, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+
+	// Reject the revoked tokens
+	if claims.ID != "" {
+		denied, err := s.store.IsAuthTokenDenied(ctx, claims.ID)
+		if err != nil {
+			return result, err
+		}
+		if denied {
+			return result, fmt.Errorf("%w: the token was revoked", ErrInvalidToken)
+		}
+	}
+
+	result = claims.TokenMetadata
+	result.TokenID = claims.ID
+	result.Expiry = claims.ExpiresAt.Time
+	return result, nil
+}
+
+// verificationKey finds the key that a token is verified with, from its kid
+func (s jwtSecurityManager) verificationKey(t *jwt.Token) (interface{}, error) {
+	if s.keys.Empty() {
+		return []byte(s.cfg.AuthSecret), nil
+	}
+	id, _ := t.Header["kid"].(string)
+	k := s.keys.Verifying(id, time.Now())
+	if k == nil {
+		return nil, fmt.Errorf("unknown signing key %q", id)
+	}
+	if k.Method.Alg() != t.Method.Alg() {
+		return nil, fmt.Errorf("the signing key %s doesn't sign with %s", id, t.Method.Alg())
+	}
+	return k.private.Public(), nil
+}
+
+// methods returns the algorithms that tokens are accepted with
+func (s jwtSecurityManager) methods() []string {
+	if s.keys.Empty() {
+		return []string{jwt.SigningMethodHS256.Alg()}
+	}
+	return s.keys.Methods()
+}
+
+func (s jwtSecurityManager) JWKS() JWKSet {
+	return s.keys.JWKS(time.Now())
+}
+
+func (s jwtSecurityManager) IssueTokens(ctx context.Context, metadata TokenMetadata) (result TokenPair, err error) {
+	return s.issueTokens(ctx, metadata, uuid.Must(uuid.NewV4()), func(next *RefreshToken) (bool, error) {
+		return true, s.store.CreateRefreshToken(ctx, next)
+	})
+}
+
+func (s jwtSecurityManager) RefreshTokens(ctx context.Context, refreshToken string) (result TokenPair, err error) {
+	// Find the refresh token
+	used, ok, err := s.store.FindRefreshToken(ctx, hashToken(refreshToken))
+	if err != nil {
+		return result, err
+	}
+	if !ok || used.RevokedAt != nil || !used.ExpiresAt.After(time.Now()) {
+		return result, ErrInvalidToken
+	}
+	if used.UsedAt != nil {
+		return result, s.reused(ctx, used)
+	}
+
+	// Replace it with a new one in the same family, unless it was used in the meantime
+	result, err = s.issueTokens(ctx, used.Metadata, used.FamilyID, func(next *RefreshToken) (bool, error) {
+		return s.store.RotateRefreshToken(ctx, used, next)
+	})
+	if errors.Is(err, ErrTokenReused) {
+		return result, s.reused(ctx, used)
+	}
+	return result, err
+}
+
+// issueTokens issues a pair of tokens, storing the refresh token in the family with the function given.
+// The function reports that the refresh token was reused when it fails to store the new one.
+func (s jwtSecurityManager) issueTokens(ctx context.Context, metadata TokenMetadata, familyID uuid.UUID, store func(next *RefreshToken) (bool, error)) (result TokenPair, err error) {
+	metadata.TokenID, metadata.Expiry = "", time.Time{}
+
+	// Generate the tokens
+	authToken, authTokenID, authTokenExpiry, err := s.generateAuthToken(metadata)
+	if err != nil {
+		return result, err
+	}
+	refreshToken, err := generateRefreshToken()
+	if err != nil {
+		return result, err
+	}
+	period := s.cfg.AuthRefreshExpiryPeriod
+	if period <= 0 {
+		period = defaultRefreshExpiryPeriod
+	}
+
+	// Store the refresh token
+	next := &RefreshToken{
+		FamilyID:        familyID,
+		UserID:          metadata.UserID,
+		Hash:            hashToken(refreshToken),
+		Metadata:        metadata,
+		AuthTokenID:     authTokenID,
+		AuthTokenExpiry: authTokenExpiry,
+		ExpiresAt:       time.Now().Add(time.Hour * time.Duration(period)),
+	}
+	ok, err := store(next)
+	if err != nil {
+		return result, err
+	}
+	if !ok {
+		return result, ErrTokenReused
+	}
+
+	return TokenPair{
+		AuthToken:          authToken,
+		AuthTokenExpiry:    authTokenExpiry,
+		RefreshToken:       refreshToken,
+		RefreshTokenExpiry: next.ExpiresAt,
+	}, nil
+}
+
+// reused revokes the family of a refresh token that was used more than once
+func (s jwtSecurityManager) reused(ctx context.Context, token RefreshToken) error {
+	err := s.store.RevokeRefreshTokens(ctx, token.FamilyID)
+	if err != nil {
+		return err
+	}
+	return ErrTokenReused
+}
+
+func (s jwtSecurityManager) RevokeAuthToken(ctx context.Context, metadata TokenMetadata) (err error) {
+	if metadata.TokenID == "" {
+		return ErrInvalidToken
+	}
+	return s.store.DenyAuthToken(ctx, metadata.TokenID, metadata.Expiry)
+}
+
+func (s jwtSecurityManager) RevokeRefreshToken(ctx context.Context, userID uuid.UUID, refreshToken string) (err error) {
+	// Find the refresh token, which only its user can revoke
+	token, ok, err := s.store.FindRefreshToken(ctx, hashToken(refreshToken))
+	if err != nil {
+		return err
+	}
+	if !ok || token.UserID != userID {
+		return ErrInvalidToken
+	}
+	return s.store.RevokeRefreshTokens(ctx, token.FamilyID)
+}
+
+func (s jwtSecurityManager) RevokeUserTokens(ctx context.Context, userID uuid.UUID) (err error) {
+	return s.store.RevokeUserRefreshTokens(ctx, userID)
+}
+
+func (s jwtSecurityManager) PurgeExpiredTokens(ctx context.Context) (count int64, err error) {
+	return s.store.PurgeExpiredTokens(ctx, time.Now())
+}
+
+// generateRefreshToken generates a random, opaque refresh token
+func generateRefreshToken() (string, error) {
+	b := make([]byte, refreshTokenSize)
+	_, err := rand.Read(b)
 	if err != nil {
 		return "", err
 	}
-	return token, nil
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken hashes a refresh token for storage, so that stored tokens can't be used if they leak.
+// Refresh tokens are random enough for a plain hash to be safe.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
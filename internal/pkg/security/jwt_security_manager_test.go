@@ -0,0 +1,265 @@
+package security
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid/v5"
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/Intiqo/app-platform/internal/pkg/config"
+)
+
+// memoryStore keeps the tokens in memory
+type memoryStore struct {
+	mu     sync.Mutex
+	tokens map[string]*RefreshToken
+	denied map[string]time.Time
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{tokens: map[string]*RefreshToken{}, denied: map[string]time.Time{}}
+}
+
+func (m *memoryStore) CreateRefreshToken(ctx context.Context, token *RefreshToken) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	token.ID = uuid.Must(uuid.NewV4())
+	v := *token
+	m.tokens[token.Hash] = &v
+	return nil
+}
+
+func (m *memoryStore) FindRefreshToken(ctx context.Context, hash string) (result RefreshToken, ok bool, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, ok := m.tokens[hash]
+	if !ok {
+		return result, false, nil
+	}
+	return *v, true, nil
+}
+
+func (m *memoryStore) RotateRefreshToken(ctx context.Context, used RefreshToken, next *RefreshToken) (ok bool, err error) {
+	m.mu.Lock()
+	v := m.tokens[used.Hash]
+	if v.UsedAt != nil || v.RevokedAt != nil {
+		m.mu.Unlock()
+		return false, nil
+	}
+	now := time.Now()
+	v.UsedAt = &now
+	m.mu.Unlock()
+	return true, m.CreateRefreshToken(ctx, next)
+}
+
+func (m *memoryStore) RevokeRefreshTokens(ctx context.Context, familyID uuid.UUID) (err error) {
+	return m.revoke(func(v *RefreshToken) bool { return v.FamilyID == familyID })
+}
+
+func (m *memoryStore) RevokeUserRefreshTokens(ctx context.Context, userID uuid.UUID) (err error) {
+	return m.revoke(func(v *RefreshToken) bool { return v.UserID == userID })
+}
+
+func (m *memoryStore) revoke(match func(v *RefreshToken) bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for _, v := range m.tokens {
+		if match(v) {
+			v.RevokedAt = &now
+			m.denied[v.AuthTokenID] = v.AuthTokenExpiry
+		}
+	}
+	return nil
+}
+
+func (m *memoryStore) DenyAuthToken(ctx context.Context, tokenID string, expiry time.Time) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.denied[tokenID] = expiry
+	return nil
+}
+
+func (m *memoryStore) IsAuthTokenDenied(ctx context.Context, tokenID string) (denied bool, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, denied = m.denied[tokenID]
+	return denied, nil
+}
+
+func (m *memoryStore) PurgeExpiredTokens(ctx context.Context, before time.Time) (count int64, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for k, v := range m.tokens {
+		if v.ExpiresAt.Before(before) {
+			delete(m.tokens, k)
+			count++
+		}
+	}
+	for k, v := range m.denied {
+		if v.Before(before) {
+			delete(m.denied, k)
+			count++
+		}
+	}
+	return count, nil
+}
+
+func TestJwtSecurityManager(t *testing.T) {
+	ctx := context.Background()
+	cfg := config.AppConfig{AuthSecret: "secret", AuthExpiryPeriod: 1}
+	metadata := TokenMetadata{UserID: uuid.Must(uuid.NewV4()), OrganizationID: uuid.Must(uuid.NewV4()), Role: "admin"}
+
+	t.Run("success - verify an auth token", func(t *testing.T) {
+		sm := NewJwtSecurityManager(cfg, newMemoryStore(), nil)
+		token, err := sm.GenerateAuthToken(metadata)
+		if err != nil {
+			t.Fatalf("Error generating the auth token: %v", err)
+		}
+		result, err := sm.VerifyAuthToken(ctx, token)
+		if err != nil {
+			t.Fatalf("Error verifying the auth token: %v", err)
+		}
+		if result.UserID != metadata.UserID || result.OrganizationID != metadata.OrganizationID || result.Role != metadata.Role {
+			t.Errorf("Expected the metadata %+v, got %+v", metadata, result)
+		}
+		if result.TokenID == "" || result.Expiry.IsZero() {
+			t.Errorf("Expected the ID & expiry of the token")
+		}
+	})
+
+	t.Run("failure - verify a forged, expired or unsigned auth token", func(t *testing.T) {
+		sm := NewJwtSecurityManager(cfg, newMemoryStore(), nil)
+		forged, err := NewJwtSecurityManager(config.AppConfig{AuthSecret: "other", AuthExpiryPeriod: 1}, newMemoryStore(), nil).GenerateAuthToken(metadata)
+		if err != nil {
+			t.Fatalf("Error generating the auth token: %v", err)
+		}
+		expired, err := NewJwtSecurityManager(config.AppConfig{AuthSecret: "secret", AuthExpiryPeriod: -1}, newMemoryStore(), nil).GenerateAuthToken(metadata)
+		if err != nil {
+			t.Fatalf("Error generating the auth token: %v", err)
+		}
+		unsigned, err := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.MapClaims{"user_id": metadata.UserID.String(), "iss": issuer}).SignedString(jwt.UnsafeAllowNoneSignatureType)
+		if err != nil {
+			t.Fatalf("Error generating the auth token: %v", err)
+		}
+		for _, token := range []string{forged, expired, unsigned, "malformed"} {
+			_, err = sm.VerifyAuthToken(ctx, token)
+			if !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("Expected the token to be invalid, got %v", err)
+			}
+		}
+	})
+
+	t.Run("failure - verify an auth token with malformed claims", func(t *testing.T) {
+		sm := NewJwtSecurityManager(cfg, newMemoryStore(), nil)
+		exp := time.Now().Add(time.Hour).Unix()
+		for _, claims := range []jwt.MapClaims{
+			{"user_id": "not-a-uuid"},
+			{"user_id": metadata.UserID.String(), "organization_id": 42},
+			{"organization_id": metadata.OrganizationID.String()},
+			{"user_id": metadata.UserID.String(), "organization_id": metadata.OrganizationID.String(), "organization_ids": []string{uuid.Must(uuid.NewV4()).String()}},
+		} {
+			claims["iss"], claims["exp"] = issuer, exp
+			token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(cfg.AuthSecret))
+			if err != nil {
+				t.Fatalf("Error generating the auth token: %v", err)
+			}
+			_, err = sm.VerifyAuthToken(ctx, token)
+			if !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("Expected the claims %v to be invalid, got %v", claims, err)
+			}
+		}
+
+		// Tokens are only issued for valid metadata
+		_, err := sm.GenerateAuthToken(TokenMetadata{Role: "admin"})
+		if err == nil {
+			t.Errorf("Expected an error generating a token without a user")
+		}
+	})
+
+	t.Run("success - rotate a refresh token", func(t *testing.T) {
+		sm := NewJwtSecurityManager(cfg, newMemoryStore(), nil)
+		issued, err := sm.IssueTokens(ctx, metadata)
+		if err != nil {
+			t.Fatalf("Error issuing the tokens: %v", err)
+		}
+		refreshed, err := sm.RefreshTokens(ctx, issued.RefreshToken)
+		if err != nil {
+			t.Fatalf("Error refreshing the tokens: %v", err)
+		}
+		if refreshed.RefreshToken == issued.RefreshToken || refreshed.AuthToken == issued.AuthToken {
+			t.Errorf("Expected new tokens")
+		}
+		result, err := sm.VerifyAuthToken(ctx, refreshed.AuthToken)
+		if err != nil {
+			t.Fatalf("Error verifying the auth token: %v", err)
+		}
+		if result.UserID != metadata.UserID {
+			t.Errorf("Expected the refreshed token to be issued for the user")
+		}
+	})
+
+	t.Run("failure - reuse a refresh token", func(t *testing.T) {
+		sm := NewJwtSecurityManager(cfg, newMemoryStore(), nil)
+		issued, err := sm.IssueTokens(ctx, metadata)
+		if err != nil {
+			t.Fatalf("Error issuing the tokens: %v", err)
+		}
+		refreshed, err := sm.RefreshTokens(ctx, issued.RefreshToken)
+		if err != nil {
+			t.Fatalf("Error refreshing the tokens: %v", err)
+		}
+
+		// Reusing the first refresh token revokes every token issued from it
+		_, err = sm.RefreshTokens(ctx, issued.RefreshToken)
+		if !errors.Is(err, ErrTokenReused) {
+			t.Fatalf("Expected the refresh token to be reused, got %v", err)
+		}
+		_, err = sm.RefreshTokens(ctx, refreshed.RefreshToken)
+		if !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("Expected the rotated refresh token to be revoked, got %v", err)
+		}
+		_, err = sm.VerifyAuthToken(ctx, refreshed.AuthToken)
+		if !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("Expected the rotated auth token to be revoked, got %v", err)
+		}
+	})
+
+	t.Run("success - revoke the tokens", func(t *testing.T) {
+		sm := NewJwtSecurityManager(cfg, newMemoryStore(), nil)
+		issued, err := sm.IssueTokens(ctx, metadata)
+		if err != nil {
+			t.Fatalf("Error issuing the tokens: %v", err)
+		}
+		verified, err := sm.VerifyAuthToken(ctx, issued.AuthToken)
+		if err != nil {
+			t.Fatalf("Error verifying the auth token: %v", err)
+		}
+		err = sm.RevokeAuthToken(ctx, verified)
+		if err != nil {
+			t.Fatalf("Error revoking the auth token: %v", err)
+		}
+		_, err = sm.VerifyAuthToken(ctx, issued.AuthToken)
+		if !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("Expected the auth token to be revoked, got %v", err)
+		}
+
+		// Only the user of a refresh token can revoke it
+		err = sm.RevokeRefreshToken(ctx, uuid.Must(uuid.NewV4()), issued.RefreshToken)
+		if !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("Expected another user not to revoke the refresh token, got %v", err)
+		}
+		err = sm.RevokeUserTokens(ctx, metadata.UserID)
+		if err != nil {
+			t.Fatalf("Error revoking the tokens of the user: %v", err)
+		}
+		_, err = sm.RefreshTokens(ctx, issued.RefreshToken)
+		if !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("Expected the refresh token to be revoked, got %v", err)
+		}
+	})
+}
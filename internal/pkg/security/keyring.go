@@ -0,0 +1,294 @@
+package security
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/Intiqo/app-platform/internal/pkg/config"
+	"github.com/Intiqo/app-platform/internal/pkg/secrets"
+)
+
+// secretSourcePrefix marks the key sources that are secrets in the secrets manager, rather than PEM files
+const secretSourcePrefix = "secret:"
+
+// minRSAKeySize is the minimum size of RSA signing keys, in bits
+const minRSAKeySize = 2048
+
+// SigningKey is a private key that signs the auth tokens for a period of time
+type SigningKey struct {
+	// ID is the kid that identifies the key in the header of the tokens it signs
+	ID     string
+	Method jwt.SigningMethod
+	// Start is the time the key starts signing at. The zero time means that it signs from the start.
+	Start time.Time
+	// End is the time the next key takes over at. The zero time means that no key takes over.
+	End time.Time
+
+	private crypto.Signer
+}
+
+// JWK is the public part of a signing key, as a JSON Web Key
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	Curve     string `json:"crv,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	X         string `json:"x,omitempty"`
+	Y         string `json:"y,omitempty"`
+}
+
+// JWKSet is the set of the public keys that auth tokens are verified with, as published to downstream verifiers
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// Keyring holds the keys that sign the auth tokens, each for its own period of time.
+// Keys are published & accepted for an overlap window before they start signing & after the next key takes over,
+// so that verifiers learn of them in time, and the tokens they signed stay valid until they expire.
+// A nil or empty Keyring has no key, so that tokens are signed with the shared secret instead.
+type Keyring struct {
+	keys    []*SigningKey
+	overlap time.Duration
+}
+
+// NewKeyring loads the signing keys in AUTH_SIGNING_KEYS, given as kid=source pairs separated by commas.
+// Sources are PEM files, or secrets in the secrets manager as secret:name, and can be followed by @ and the time the key starts signing at, in RFC 3339.
+// The key type sets the algorithm: RSA keys sign with RS256, P-256 keys with ES256, and Ed25519 keys with EdDSA.
+func NewKeyring(cfg config.AppConfig, sm secrets.Manager) (*Keyring, error) {
+	// Keep the keys for at least as long as the tokens they sign are valid
+	k := &Keyring{overlap: cfg.AuthKeyOverlap}
+	if lifetime := time.Hour * time.Duration(cfg.AuthExpiryPeriod); k.overlap < lifetime {
+		k.overlap = lifetime
+	}
+
+	// Load the keys
+	for _, v := range strings.Split(cfg.AuthSigningKeys, ",") {
+		if v = strings.TrimSpace(v); v == "" {
+			continue
+		}
+		key, err := loadSigningKey(v, sm)
+		if err != nil {
+			return nil, err
+		}
+		k.keys = append(k.keys, key)
+	}
+	if len(k.keys) == 0 {
+		return k, nil
+	}
+
+	// Order the keys by the time they start signing at, with each key signing until the next one takes over
+	slices.SortFunc(k.keys, func(a, b *SigningKey) int {
+		return a.Start.Compare(b.Start)
+	})
+	ids := map[string]bool{}
+	for i, v := range k.keys {
+		if ids[v.ID] {
+			return nil, fmt.Errorf("the signing key %s is configured more than once", v.ID)
+		}
+		ids[v.ID] = true
+		if i > 0 && v.Start.Equal(k.keys[i-1].Start) {
+			return nil, fmt.Errorf("the signing keys %s & %s start signing at the same time", k.keys[i-1].ID, v.ID)
+		}
+		if i > 0 {
+			k.keys[i-1].End = v.Start
+		}
+	}
+	if k.Signing(time.Now()) == nil {
+		return nil, fmt.Errorf("none of the signing keys has started signing, the first one starts at %s", k.keys[0].Start.Format(time.RFC3339))
+	}
+	return k, nil
+}
+
+// Empty checks if the keyring has no key
+func (k *Keyring) Empty() bool {
+	return k == nil || len(k.keys) == 0
+}
+
+// Signing returns the key that signs the tokens at the time given, if any
+func (k *Keyring) Signing(now time.Time) *SigningKey {
+	if k.Empty() {
+		return nil
+	}
+	for i := len(k.keys) - 1; i >= 0; i-- {
+		if !k.keys[i].Start.After(now) {
+			return k.keys[i]
+		}
+	}
+	return nil
+}
+
+// Verifying returns the key with the ID given, if it is published at the time given
+func (k *Keyring) Verifying(id string, now time.Time) *SigningKey {
+	if k.Empty() {
+		return nil
+	}
+	for _, v := range k.keys {
+		if v.ID == id && k.published(v, now) {
+			return v
+		}
+	}
+	return nil
+}
+
+// Methods returns the algorithms of the keys, which are the only ones tokens are accepted with
+func (k *Keyring) Methods() (result []string) {
+	if k.Empty() {
+		return result
+	}
+	for _, v := range k.keys {
+		if !slices.Contains(result, v.Method.Alg()) {
+			result = append(result, v.Method.Alg())
+		}
+	}
+	return result
+}
+
+// JWKS returns the public keys published at the time given
+func (k *Keyring) JWKS(now time.Time) JWKSet {
+	result := JWKSet{Keys: []JWK{}}
+	if k.Empty() {
+		return result
+	}
+	for _, v := range k.keys {
+		if k.published(v, now) {
+			result.Keys = append(result.Keys, v.jwk())
+		}
+	}
+	return result
+}
+
+// published checks if a key is published at the time given, which is from the overlap before it starts signing until the overlap after the next key takes over
+func (k *Keyring) published(key *SigningKey, now time.Time) bool {
+	if !key.Start.IsZero() && now.Before(key.Start.Add(-k.overlap)) {
+		return false
+	}
+	if !key.End.IsZero() && now.After(key.End.Add(k.overlap)) {
+		return false
+	}
+	return true
+}
+
+// jwk returns the public part of the key, as a JSON Web Key
+func (key *SigningKey) jwk() JWK {
+	result := JWK{KeyID: key.ID, Use: "sig", Algorithm: key.Method.Alg()}
+	switch pub := key.private.Public().(type) {
+	case *rsa.PublicKey:
+		result.KeyType = "RSA"
+		result.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+		result.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		// The uncompressed point is 0x04 followed by the coordinates, each the size of the curve
+		point, _ := pub.ECDH()
+		b := point.Bytes()[1:]
+		result.KeyType = "EC"
+		result.Curve = "P-256"
+		result.X = base64.RawURLEncoding.EncodeToString(b[:len(b)/2])
+		result.Y = base64.RawURLEncoding.EncodeToString(b[len(b)/2:])
+	case ed25519.PublicKey:
+		result.KeyType = "OKP"
+		result.Curve = "Ed25519"
+		result.X = base64.RawURLEncoding.EncodeToString(pub)
+	}
+	return result
+}
+
+// loadSigningKey loads a signing key given as kid=source, optionally followed by @ and the time it starts signing at
+func loadSigningKey(v string, sm secrets.Manager) (result *SigningKey, err error) {
+	id, source, ok := strings.Cut(v, "=")
+	id, source = strings.TrimSpace(id), strings.TrimSpace(source)
+	if !ok || id == "" || source == "" {
+		return nil, fmt.Errorf("the signing key %s must be given as kid=source", v)
+	}
+	result = &SigningKey{ID: id}
+
+	// Read the start time, unless the @ is part of the source
+	if i := strings.LastIndex(source, "@"); i >= 0 {
+		start, err := time.Parse(time.RFC3339, source[i+1:])
+		if err == nil {
+			result.Start, source = start, source[:i]
+		}
+	}
+
+	// Read the key
+	var b []byte
+	if name, ok := strings.CutPrefix(source, secretSourcePrefix); ok {
+		secret, err := sm.GetSecret(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the signing key %s from the secrets manager: %w", id, err)
+		}
+		b = []byte(secret)
+	} else {
+		b, err = os.ReadFile(source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the signing key %s: %w", id, err)
+		}
+	}
+	result.private, result.Method, err = parseSigningKey(b)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the signing key %s: %w", id, err)
+	}
+	return result, nil
+}
+
+// parseSigningKey parses a PEM encoded private key, in PKCS #8, PKCS #1 or SEC 1 form, and finds the algorithm it signs with
+func parseSigningKey(b []byte) (key crypto.Signer, method jwt.SigningMethod, err error) {
+	// Find the private key, skipping blocks such as the EC parameters
+	var parsed any
+	for {
+		var block *pem.Block
+		block, b = pem.Decode(b)
+		if block == nil {
+			return nil, nil, errors.New("no private key was found in the PEM data")
+		}
+		switch block.Type {
+		case "PRIVATE KEY":
+			parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		case "RSA PRIVATE KEY":
+			parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+		case "EC PRIVATE KEY":
+			parsed, err = x509.ParseECPrivateKey(block.Bytes)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		break
+	}
+
+	// Find the algorithm from the type of the key
+	switch v := parsed.(type) {
+	case *rsa.PrivateKey:
+		if v.N.BitLen() < minRSAKeySize {
+			return nil, nil, fmt.Errorf("RSA keys must have at least %d bits", minRSAKeySize)
+		}
+		return v, jwt.SigningMethodRS256, nil
+	case *ecdsa.PrivateKey:
+		if v.Curve != elliptic.P256() {
+			return nil, nil, errors.New("EC keys must be on the P-256 curve")
+		}
+		return v, jwt.SigningMethodES256, nil
+	case ed25519.PrivateKey:
+		return v, jwt.SigningMethodEdDSA, nil
+	default:
+		return nil, nil, fmt.Errorf("keys of type %T are not supported", parsed)
+	}
+}
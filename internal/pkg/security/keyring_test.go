@@ -0,0 +1,191 @@
+package security
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid/v5"
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/Intiqo/app-platform/internal/pkg/config"
+)
+
+// memorySecrets keeps the secrets in memory
+type memorySecrets map[string]string
+
+func (m memorySecrets) GetSecret(name string) (result string, err error) {
+	result, ok := m[name]
+	if !ok {
+		return result, errors.New("could not find the secret")
+	}
+	return result, nil
+}
+
+// encodeKey encodes a private key as PKCS #8 PEM
+func encodeKey(t *testing.T, key crypto.Signer) string {
+	b, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("Error encoding the key: %v", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: b}))
+}
+
+func TestKeyring(t *testing.T) {
+	ctx := context.Background()
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	// Keep the RSA key in a file, and the others in the secrets manager
+	dir := t.TempDir()
+	rsaFile := filepath.Join(dir, "rsa.pem")
+	err := os.WriteFile(rsaFile, []byte(encodeKey(t, rsaKey)), 0600)
+	if err != nil {
+		t.Fatalf("Error writing the key: %v", err)
+	}
+	sm := memorySecrets{"app/jwt/ec": encodeKey(t, ecKey), "app/jwt/ed": encodeKey(t, edKey)}
+
+	t.Run("success - sign & verify with each type of key", func(t *testing.T) {
+		for _, v := range []struct {
+			source string
+			alg    string
+			kty    string
+		}{
+			{source: rsaFile, alg: "RS256", kty: "RSA"},
+			{source: "secret:app/jwt/ec", alg: "ES256", kty: "EC"},
+			{source: "secret:app/jwt/ed", alg: "EdDSA", kty: "OKP"},
+		} {
+			cfg := config.AppConfig{AuthExpiryPeriod: 1, AuthSigningKeys: "key-1=" + v.source}
+			keys, err := NewKeyring(cfg, sm)
+			if err != nil {
+				t.Fatalf("Error loading the keys: %v", err)
+			}
+			m := NewJwtSecurityManager(cfg, newMemoryStore(), keys)
+			token, err := m.GenerateAuthToken(TokenMetadata{UserID: uuid.Must(uuid.NewV4()), Role: "admin"})
+			if err != nil {
+				t.Fatalf("Error generating the auth token: %v", err)
+			}
+			parsed, _, err := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{})
+			if err != nil {
+				t.Fatalf("Error parsing the auth token: %v", err)
+			}
+			if parsed.Header["kid"] != "key-1" || parsed.Method.Alg() != v.alg {
+				t.Errorf("Expected the token to be signed by key-1 with %s, got %v with %s", v.alg, parsed.Header["kid"], parsed.Method.Alg())
+			}
+			result, err := m.VerifyAuthToken(ctx, token)
+			if err != nil {
+				t.Fatalf("Error verifying the auth token: %v", err)
+			}
+			if result.Role != "admin" {
+				t.Errorf("Expected the metadata of the token")
+			}
+
+			jwks := m.JWKS()
+			if len(jwks.Keys) != 1 || jwks.Keys[0].KeyID != "key-1" || jwks.Keys[0].KeyType != v.kty || jwks.Keys[0].Algorithm != v.alg {
+				t.Errorf("Expected key-1 to be published as %s, got %+v", v.kty, jwks.Keys)
+			}
+		}
+	})
+
+	t.Run("success - rotate the keys with an overlap", func(t *testing.T) {
+		now := time.Now()
+		next := now.Add(2 * time.Hour).UTC().Format(time.RFC3339)
+		cfg := config.AppConfig{AuthExpiryPeriod: 1, AuthSigningKeys: "old=" + rsaFile + ", new=secret:app/jwt/ec@" + next}
+		keys, err := NewKeyring(cfg, sm)
+		if err != nil {
+			t.Fatalf("Error loading the keys: %v", err)
+		}
+
+		// The new key is published an hour before it takes over, and the old one for an hour after
+		for _, v := range []struct {
+			at        time.Time
+			signing   string
+			published []string
+		}{
+			{at: now, signing: "old", published: []string{"old"}},
+			{at: now.Add(90 * time.Minute), signing: "old", published: []string{"old", "new"}},
+			{at: now.Add(150 * time.Minute), signing: "new", published: []string{"old", "new"}},
+			{at: now.Add(4 * time.Hour), signing: "new", published: []string{"new"}},
+		} {
+			if id := keys.Signing(v.at).ID; id != v.signing {
+				t.Errorf("Expected %s to sign at %s, got %s", v.signing, v.at, id)
+			}
+			var published []string
+			for _, k := range keys.JWKS(v.at).Keys {
+				published = append(published, k.KeyID)
+			}
+			if strings.Join(published, ",") != strings.Join(v.published, ",") {
+				t.Errorf("Expected %v to be published at %s, got %v", v.published, v.at, published)
+			}
+		}
+		if keys.Verifying("old", now.Add(4*time.Hour)) != nil {
+			t.Errorf("Expected the old key to be retired")
+		}
+	})
+
+	t.Run("failure - verify a token signed by an unknown key", func(t *testing.T) {
+		cfg := config.AppConfig{AuthExpiryPeriod: 1, AuthSigningKeys: "key-1=" + rsaFile}
+		keys, err := NewKeyring(cfg, sm)
+		if err != nil {
+			t.Fatalf("Error loading the keys: %v", err)
+		}
+		other, err := NewKeyring(config.AppConfig{AuthExpiryPeriod: 1, AuthSigningKeys: "key-2=secret:app/jwt/ed"}, sm)
+		if err != nil {
+			t.Fatalf("Error loading the keys: %v", err)
+		}
+		token, err := NewJwtSecurityManager(cfg, newMemoryStore(), other).GenerateAuthToken(TokenMetadata{UserID: uuid.Must(uuid.NewV4())})
+		if err != nil {
+			t.Fatalf("Error generating the auth token: %v", err)
+		}
+		hs256, err := NewJwtSecurityManager(cfg, newMemoryStore(), nil).GenerateAuthToken(TokenMetadata{UserID: uuid.Must(uuid.NewV4())})
+		if err != nil {
+			t.Fatalf("Error generating the auth token: %v", err)
+		}
+		m := NewJwtSecurityManager(cfg, newMemoryStore(), keys)
+		for _, v := range []string{token, hs256} {
+			_, err = m.VerifyAuthToken(ctx, v)
+			if !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("Expected the token to be invalid, got %v", err)
+			}
+		}
+	})
+
+	t.Run("failure - invalid keys", func(t *testing.T) {
+		smallKey, _ := rsa.GenerateKey(rand.Reader, 1024)
+		p384Key, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+		invalid := memorySecrets{"small": encodeKey(t, smallKey), "p384": encodeKey(t, p384Key), "empty": ""}
+		later := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+		for _, v := range []string{
+			"key-1",
+			"key-1=secret:missing",
+			"key-1=secret:small",
+			"key-1=secret:p384",
+			"key-1=secret:empty",
+			"key-1=" + filepath.Join(dir, "missing.pem"),
+			"key-1=" + rsaFile + ",key-1=secret:app/jwt/ec@" + later,
+			"key-1=" + rsaFile + ",key-2=secret:app/jwt/ec",
+			"key-1=" + rsaFile + "@" + later,
+		} {
+			for name, secret := range sm {
+				invalid[name] = secret
+			}
+			_, err := NewKeyring(config.AppConfig{AuthSigningKeys: v}, invalid)
+			if err == nil {
+				t.Errorf("Expected an error loading the keys %s", v)
+			}
+		}
+	})
+}
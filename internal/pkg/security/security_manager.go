@@ -1,6 +1,20 @@
 package security
 
-import "github.com/gofrs/uuid/v5"
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/gofrs/uuid/v5"
+)
+
+// ErrInvalidToken is returned for the tokens that are malformed, expired, revoked or unknown
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+// ErrTokenReused is returned when a refresh token that was already used is presented again.
+// Every token issued from the same refresh token is revoked, since one of them is likely in the wrong hands.
+var ErrTokenReused = errors.New("refresh token was already used")
 
 // TokenMetadata represents the metadata in the auth token
 type TokenMetadata struct {
@@ -8,10 +22,86 @@ type TokenMetadata struct {
 	OrganizationID  uuid.UUID   `json:"organization_id"`
 	OrganizationIDs []uuid.UUID `json:"organization_ids"`
 	Role            string      `json:"role"`
+
+	// TokenID & Expiry identify a verified auth token & tell when it expires. They aren't part of the metadata that tokens are issued for.
+	TokenID string    `json:"-"`
+	Expiry  time.Time `json:"-"`
+}
+
+// Validate checks that the metadata identifies a user, and that the organization they act for is one of theirs
+func (m TokenMetadata) Validate() error {
+	if m.UserID == uuid.Nil {
+		return errors.New("the user_id claim is required")
+	}
+	if m.OrganizationID != uuid.Nil && len(m.OrganizationIDs) > 0 && !slices.Contains(m.OrganizationIDs, m.OrganizationID) {
+		return errors.New("the organization_id claim must be one of the organization_ids")
+	}
+	return nil
+}
+
+// TokenPair is an auth token, along with the refresh token that gets a new pair once the auth token expires
+type TokenPair struct {
+	AuthToken          string
+	AuthTokenExpiry    time.Time
+	RefreshToken       string
+	RefreshTokenExpiry time.Time
+}
+
+// RefreshToken is a refresh token as it is stored, with the hash of the token rather than the token itself
+type RefreshToken struct {
+	ID uuid.UUID
+	// FamilyID is shared by all the refresh tokens rotated from the same one
+	FamilyID uuid.UUID
+	UserID   uuid.UUID
+	Hash     string
+	Metadata TokenMetadata
+	// AuthTokenID & AuthTokenExpiry identify the auth token issued along with the refresh token, so that it is revoked with it
+	AuthTokenID     string
+	AuthTokenExpiry time.Time
+	ExpiresAt       time.Time
+	UsedAt          *time.Time
+	RevokedAt       *time.Time
+}
+
+// Store stores the refresh tokens, and the denylist of the auth tokens revoked before they expire
+type Store interface {
+	// CreateRefreshToken stores a new refresh token
+	CreateRefreshToken(ctx context.Context, token *RefreshToken) (err error)
+	// FindRefreshToken finds a refresh token by its hash
+	FindRefreshToken(ctx context.Context, hash string) (result RefreshToken, ok bool, err error)
+	// RotateRefreshToken marks a refresh token as used & stores the one replacing it, unless it was used or revoked in the meantime
+	RotateRefreshToken(ctx context.Context, used RefreshToken, next *RefreshToken) (ok bool, err error)
+	// RevokeRefreshTokens revokes the refresh tokens of a family, along with the auth tokens issued with them
+	RevokeRefreshTokens(ctx context.Context, familyID uuid.UUID) (err error)
+	// RevokeUserRefreshTokens revokes the refresh tokens of a user, along with the auth tokens issued with them
+	RevokeUserRefreshTokens(ctx context.Context, userID uuid.UUID) (err error)
+	// DenyAuthToken adds an auth token to the denylist until it expires
+	DenyAuthToken(ctx context.Context, tokenID string, expiry time.Time) (err error)
+	// IsAuthTokenDenied checks if an auth token is in the denylist
+	IsAuthTokenDenied(ctx context.Context, tokenID string) (denied bool, err error)
+	// PurgeExpiredTokens deletes the refresh tokens & denylist entries that expired before the time given
+	PurgeExpiredTokens(ctx context.Context, before time.Time) (count int64, err error)
 }
 
 // Manager defines the interface for a security manager
 type Manager interface {
-	// GenerateAuthToken generates an auth token for a user.
+	// GenerateAuthToken generates an auth token for a user. The metadata must be valid.
 	GenerateAuthToken(metadata TokenMetadata) (token string, err error)
+	// VerifyAuthToken verifies an auth token, and returns its metadata. Expired, revoked & malformed tokens are rejected with ErrInvalidToken.
+	VerifyAuthToken(ctx context.Context, token string) (result TokenMetadata, err error)
+	// IssueTokens issues an auth token for a user, along with a refresh token that starts a new family.
+	IssueTokens(ctx context.Context, metadata TokenMetadata) (result TokenPair, err error)
+	// RefreshTokens exchanges a refresh token for a new pair of tokens. The refresh token can't be used again.
+	// A refresh token that was already used fails with ErrTokenReused, and revokes its whole family.
+	RefreshTokens(ctx context.Context, refreshToken string) (result TokenPair, err error)
+	// RevokeAuthToken revokes a verified auth token until it expires
+	RevokeAuthToken(ctx context.Context, metadata TokenMetadata) (err error)
+	// RevokeRefreshToken revokes a refresh token of the user, along with its whole family
+	RevokeRefreshToken(ctx context.Context, userID uuid.UUID, refreshToken string) (err error)
+	// RevokeUserTokens revokes all the refresh tokens of a user, along with the auth tokens issued with them
+	RevokeUserTokens(ctx context.Context, userID uuid.UUID) (err error)
+	// PurgeExpiredTokens deletes the stored tokens that have expired, and returns how many were deleted
+	PurgeExpiredTokens(ctx context.Context) (count int64, err error)
+	// JWKS returns the public keys that auth tokens are verified with. It has no key when tokens are signed with the shared secret.
+	JWKS() JWKSet
 }
@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"hash/fnv"
+	"log/slog"
+	"sync"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/Intiqo/app-platform/internal/domain"
+)
+
+type pgxAdvisoryLocker struct {
+	db *pgxpool.Pool
+}
+
+// NewAdvisoryLocker creates a new locker backed by Postgres session level advisory locks.
+// Every lock holds on to a connection of the pool until it is released, so that it is lost if the instance goes away.
+func NewAdvisoryLocker(db *pgxpool.Pool) domain.Locker {
+	return &pgxAdvisoryLocker{
+		db: db,
+	}
+}
+
+func (l *pgxAdvisoryLocker) TryLock(ctx context.Context, key string) (result domain.Lock, ok bool, err error) {
+	// Acquire a dedicated connection, since the lock belongs to the session
+	conn, err := l.db.Acquire(ctx)
+	if err != nil {
+		return result, false, err
+	}
+
+	// Try to take the lock
+	id := advisoryLockID(key)
+	err = conn.QueryRow(ctx, `SELECT pg_try_advisory_lock($1)`, id).Scan(&ok)
+	if err != nil || !ok {
+		conn.Release()
+		return result, false, err
+	}
+	return &advisoryLock{conn: conn, id: id}, true, nil
+}
+
+// advisoryLock is an advisory lock held by the session of the connection
+type advisoryLock struct {
+	mu   sync.Mutex
+	conn *pgxpool.Conn
+	id   int64
+}
+
+func (l *advisoryLock) Held(ctx context.Context) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.conn == nil {
+		return false
+	}
+
+	// The lock is held as long as the session is alive
+	return l.conn.Ping(ctx) == nil
+}
+
+func (l *advisoryLock) Release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.conn == nil {
+		return
+	}
+
+	// Unlock, and close the connection if that fails so that the session, and the lock with it, doesn't outlive the lock
+	_, err := l.conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, l.id)
+	if err != nil {
+		slog.Error("failed to release advisory lock", "error", err)
+		_ = l.conn.Conn().Close(context.Background())
+	}
+	l.conn.Release()
+	l.conn = nil
+}
+
+// advisoryLockID derives the 64-bit id of the advisory lock from its key
+func advisoryLockID(key string) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	return int64(h.Sum64())
+}
@@ -0,0 +1,534 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/gofrs/uuid/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/Intiqo/app-platform/internal/database"
+	"github.com/Intiqo/app-platform/internal/domain"
+)
+
+// querier is implemented by both the pool and the transactions, so that queries can run on either
+type querier interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults
+}
+
+// querierFor returns the transaction carried by the context, or the pool when there is none
+func querierFor(ctx context.Context, db *pgxpool.Pool) querier {
+	if tx, ok := ctx.Value(TxKey).(pgx.Tx); ok {
+		return tx
+	}
+	return db
+}
+
+// replicaPicker picks the read replica that takes a read, if any
+type replicaPicker interface {
+	Pick() *pgxpool.Pool
+}
+
+// readerFor returns the querier that reads run on: the transaction carried by the context, the pool when the context
+// asks for primary reads, or else one of the healthy read replicas, falling back to the pool when there is none
+func readerFor(ctx context.Context, db *pgxpool.Pool, replicas replicaPicker) querier {
+	if tx, ok := ctx.Value(TxKey).(pgx.Tx); ok {
+		return tx
+	}
+	if domain.PrimaryReadsFromContext(ctx) {
+		return db
+	}
+	if replica := replicas.Pick(); replica != nil {
+		return replica
+	}
+	return db
+}
+
+// Table describes how the entities of a type are stored.
+// Tables must have an id column, and an updated_at column to be updated.
+type Table struct {
+	// Name is the name of the table
+	Name string
+	// InsertColumns are the columns written when an entity is created
+	InsertColumns []string
+	// GeneratedColumns are the columns generated by the database when an entity is created, and read back into it
+	GeneratedColumns []string
+	// UpdateColumns are the columns written when an entity is updated
+	UpdateColumns []string
+	// Fields are the fields that entities can be filtered & sorted by
+	Fields queryFields
+	// DefaultSortKeys order the entities when no sort keys are given. They should end with a unique field.
+	DefaultSortKeys []domain.SortKey
+	// SoftDelete marks entities as deleted through the deleted_at column instead of deleting them
+	SoftDelete bool
+	// Versioned increments the version column on every update, and only updates entities whose version hasn't changed
+	Versioned bool
+	// OrganizationOwned confines the queries to the rows of the organization the caller acts for, through the row level security policies of the table
+	OrganizationOwned bool
+}
+
+// Base implements the queries that are common to all the entities, whose fields are matched to columns by their db tags.
+// Every query runs in the transaction carried by the context, if any. Otherwise, reads go to the read replicas, and every query on the tables
+// owned by organizations runs in a transaction of its own unless the caller is the platform itself, so that row level security applies to it.
+type Base[T any] struct {
+	db       *pgxpool.Pool
+	replicas *database.Replicas
+	sqt      sq.StatementBuilderType
+	t        Table
+}
+
+// NewBase creates a new base repository for the entities stored in the table.
+// Reads go to the replicas given unless they run in a transaction or the context asks for primary reads. Without replicas, they go to the pool.
+func NewBase[T any](db *pgxpool.Pool, replicas *database.Replicas, t Table) Base[T] {
+	return Base[T]{
+		db:       db,
+		replicas: replicas,
+		sqt:      sq.StatementBuilder.PlaceholderFormat(sq.Dollar),
+		t:        t,
+	}
+}
+
+// FindByID finds an entity by its ID
+func (r Base[T]) FindByID(ctx context.Context, id uuid.UUID) (result T, err error) {
+	// Check if the context has a transaction
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	q := readerFor(ctx, r.db, r.replicas)
+
+	// Construct the query
+	qb := r.sqt.Select("*").
+		From(r.t.Name).
+		Where(sq.Eq{"id": id})
+	if r.t.SoftDelete {
+		qb = qb.Where("deleted_at IS NULL")
+	}
+	dq, dargs, err := qb.ToSql()
+	if err != nil {
+		return result, err
+	}
+
+	// Execute the query & collect the result, within the organization the caller acts for
+	err = r.withinOrganization(ctx, q, func(q querier) (err error) {
+		rows, err := q.Query(ctx, dq, dargs...)
+		if err != nil {
+			return err
+		}
+		result, err = pgx.CollectOneRow(rows, pgx.RowToStructByNameLax[T])
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return result, domain.DataNotFoundError{}
+		}
+		return result, err
+	}
+	return result, nil
+}
+
+// Filter filters the entities meeting the criteria, along with the conditions given through the query options.
+// limit and offset, or the cursor, specified through query options are used for pagination.
+func (r Base[T]) Filter(ctx context.Context, criteria sq.And, opts domain.QueryOptions) (result []T, page domain.PageInfo, err error) {
+	// Check if the context has a transaction
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	q := readerFor(ctx, r.db, r.replicas)
+
+	// Build the criteria
+	f := r.sqt
+	for _, v := range criteria {
+		f = f.Where(v)
+	}
+	if r.t.SoftDelete {
+		f = f.Where("deleted_at IS NULL")
+	}
+
+	// Add the conditions given by the caller
+	conditions, err := r.t.Fields.where(opts.Conditions)
+	if err != nil {
+		return result, page, err
+	}
+	if len(conditions) > 0 {
+		f = f.Where(conditions)
+	}
+
+	// Build the queries, counting the entities unless paginating by keyset
+	count := !opts.Keyset && opts.Cursor == ""
+	cq, cargs, err := f.Select("COUNT(*)").From(r.t.Name).ToSql()
+	if err != nil {
+		return result, page, err
+	}
+	qb, pq, err := r.t.Fields.page(f.Select("*").From(r.t.Name), opts, r.t.DefaultSortKeys)
+	if err != nil {
+		return result, page, err
+	}
+	dq, dargs, err := qb.ToSql()
+	if err != nil {
+		return result, page, err
+	}
+
+	// Execute the queries & collect the data into the result, within the organization the caller acts for
+	err = r.withinOrganization(ctx, q, func(q querier) (err error) {
+		if count {
+			err = q.QueryRow(ctx, cq, cargs...).Scan(&page.Total)
+			if err != nil {
+				return err
+			}
+		}
+		rows, err := q.Query(ctx, dq, dargs...)
+		if err != nil {
+			return err
+		}
+		result, err = pgx.CollectRows(rows, pgx.RowToStructByNameLax[T])
+		return err
+	})
+	if err != nil {
+		return result, page, err
+	}
+
+	// Work out the cursors of the pages around the result
+	return paginate(result, page, pq)
+}
+
+// Create creates an entity, reading the generated columns back into it
+func (r Base[T]) Create(ctx context.Context, entity *T) (err error) {
+	// Check if the context has a transaction
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	q := querierFor(ctx, r.db)
+
+	// Construct the query
+	dq, dargs, err := r.insert(entity)
+	if err != nil {
+		return err
+	}
+	dest, err := fieldPointers(entity, r.t.GeneratedColumns)
+	if err != nil {
+		return err
+	}
+
+	// Execute the query & collect the result, within the organization the caller acts for
+	return r.withinOrganization(ctx, q, func(q querier) error {
+		return q.QueryRow(ctx, dq, dargs...).Scan(dest...)
+	})
+}
+
+// CreateMultiple creates multiple entities in a single round trip
+func (r Base[T]) CreateMultiple(ctx context.Context, entities []*T) (err error) {
+	// Check if the context has a transaction
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	q := querierFor(ctx, r.db)
+
+	// Add queries to the batch
+	b := &pgx.Batch{}
+	for _, entity := range entities {
+		dq, dargs, err := r.insert(entity)
+		if err != nil {
+			return err
+		}
+		dest, err := fieldPointers(entity, r.t.GeneratedColumns)
+		if err != nil {
+			return err
+		}
+		b.Queue(dq, dargs...).QueryRow(func(row pgx.Row) error {
+			return row.Scan(dest...)
+		})
+	}
+
+	// Execute the batch, within the organization the caller acts for
+	return r.withinOrganization(ctx, q, func(q querier) error {
+		return q.SendBatch(ctx, b).Close()
+	})
+}
+
+// Update updates an entity.
+// Versioned entities fail with a conflict error if the version in the database no longer matches the version of the entity.
+func (r Base[T]) Update(ctx context.Context, entity *T) (err error) {
+	// Check if the context has a transaction
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	q := querierFor(ctx, r.db)
+
+	// Construct the query
+	dq, dargs, dest, err := r.update(entity)
+	if err != nil {
+		return err
+	}
+
+	// Execute the query & collect the result, within the organization the caller acts for
+	err = r.withinOrganization(ctx, q, func(q querier) error {
+		return q.QueryRow(ctx, dq, dargs...).Scan(dest...)
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return r.notUpdated(ctx, entity)
+	}
+	return err
+}
+
+// UpdateMultiple updates multiple entities in a single round trip, the same way as Update
+func (r Base[T]) UpdateMultiple(ctx context.Context, entities []*T) (err error) {
+	// Check if the context has a transaction
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	q := querierFor(ctx, r.db)
+
+	// Add queries to the batch
+	b := &pgx.Batch{}
+	var notUpdated *T
+	for _, entity := range entities {
+		dq, dargs, dest, err := r.update(entity)
+		if err != nil {
+			return err
+		}
+		b.Queue(dq, dargs...).QueryRow(func(row pgx.Row) error {
+			err := row.Scan(dest...)
+			if errors.Is(err, pgx.ErrNoRows) {
+				notUpdated = entity
+			}
+			return err
+		})
+	}
+
+	// Execute the batch, within the organization the caller acts for
+	err = r.withinOrganization(ctx, q, func(q querier) error {
+		return q.SendBatch(ctx, b).Close()
+	})
+
+	// Find out why an entity wasn't updated, now that the batch is done with the connection
+	if notUpdated != nil {
+		return r.notUpdated(ctx, notUpdated)
+	}
+	return err
+}
+
+// Delete deletes entities by their IDs, only marking them as deleted if the table is soft deleted.
+// It fails with a not found error unless every entity exists & is visible to the caller.
+func (r Base[T]) Delete(ctx context.Context, ids ...uuid.UUID) (err error) {
+	// Check if the context has a transaction
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	q := querierFor(ctx, r.db)
+
+	// Construct the query
+	var dq string
+	var dargs []interface{}
+	if r.t.SoftDelete {
+		dq, dargs, err = r.sqt.Update(r.t.Name).
+			Set("deleted_at", sq.Expr("NOW()")).
+			Where(sq.Eq{"id": ids}).
+			Where("deleted_at IS NULL").
+			ToSql()
+	} else {
+		dq, dargs, err = r.sqt.Delete(r.t.Name).
+			Where(sq.Eq{"id": ids}).
+			ToSql()
+	}
+	if err != nil {
+		return err
+	}
+
+	// Execute the query, within the organization the caller acts for
+	return r.exec(ctx, q, dq, dargs, countUnique(ids))
+}
+
+// Restore restores soft deleted entities by their IDs.
+// It fails with a not found error unless every entity is deleted & visible to the caller.
+func (r Base[T]) Restore(ctx context.Context, ids ...uuid.UUID) (err error) {
+	// Check if the context has a transaction
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	q := querierFor(ctx, r.db)
+	if !r.t.SoftDelete {
+		return fmt.Errorf("%s are not soft deleted, so they can't be restored", r.t.Name)
+	}
+
+	// Construct the query
+	dq, dargs, err := r.sqt.Update(r.t.Name).
+		Set("deleted_at", nil).
+		Set("updated_at", sq.Expr("NOW()")).
+		Where(sq.Eq{"id": ids}).
+		Where("deleted_at IS NOT NULL").
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	// Execute the query, within the organization the caller acts for
+	return r.exec(ctx, q, dq, dargs, countUnique(ids))
+}
+
+// withinOrganization runs fn with the querier, within the organization the caller acts for if the table is owned by organizations
+func (r Base[T]) withinOrganization(ctx context.Context, q querier, fn func(q querier) error) (err error) {
+	if !r.t.OrganizationOwned {
+		return fn(q)
+	}
+	return withinOrganization(ctx, q, fn)
+}
+
+// insert builds the query creating an entity
+func (r Base[T]) insert(entity *T) (dq string, dargs []interface{}, err error) {
+	values, err := fieldValues(entity, r.t.InsertColumns)
+	if err != nil {
+		return dq, dargs, err
+	}
+	return r.sqt.Insert(r.t.Name).
+		Columns(r.t.InsertColumns...).
+		Values(values...).
+		Suffix("RETURNING " + strings.Join(r.t.GeneratedColumns, ", ")).
+		ToSql()
+}
+
+// update builds the query updating an entity, along with the fields that the columns it returns are read into
+func (r Base[T]) update(entity *T) (dq string, dargs []interface{}, dest []interface{}, err error) {
+	values, err := fieldValues(entity, append([]string{"id"}, r.t.UpdateColumns...))
+	if err != nil {
+		return dq, dargs, dest, err
+	}
+	ub := r.sqt.Update(r.t.Name).
+		Where(sq.Eq{"id": values[0]})
+	for idx, col := range r.t.UpdateColumns {
+		ub = ub.Set(col, values[idx+1])
+	}
+	ub = ub.Set("updated_at", sq.Expr("NOW()"))
+	returning := []string{"updated_at"}
+	if r.t.Versioned {
+		version, err := fieldValues(entity, []string{"version"})
+		if err != nil {
+			return dq, dargs, dest, err
+		}
+		ub = ub.Set("version", sq.Expr("version + 1")).
+			Where(sq.Eq{"version": version[0]})
+		returning = append(returning, "version")
+	}
+	if r.t.SoftDelete {
+		ub = ub.Where("deleted_at IS NULL")
+	}
+	dest, err = fieldPointers(entity, returning)
+	if err != nil {
+		return dq, dargs, dest, err
+	}
+	dq, dargs, err = ub.Suffix("RETURNING " + strings.Join(returning, ", ")).ToSql()
+	return dq, dargs, dest, err
+}
+
+// notUpdated explains why an update didn't match an entity.
+// The entity was either deleted or isn't visible to the caller, changed since the version that was expected,
+// or is kept from the caller by the row level security policies that let it be read but not written.
+func (r Base[T]) notUpdated(ctx context.Context, entity *T) error {
+	id, err := fieldValues(entity, []string{"id"})
+	if err != nil {
+		return err
+	}
+	current, err := r.FindByID(domain.ContextWithPrimaryReads(ctx), id[0].(uuid.UUID))
+	if err != nil {
+		return err
+	}
+	if r.t.Versioned {
+		expected, err := fieldValues(entity, []string{"version"})
+		if err != nil {
+			return err
+		}
+		actual, err := fieldValues(&current, []string{"version"})
+		if err != nil {
+			return err
+		}
+		if expected[0] != actual[0] {
+			return domain.ConflictError{
+				Code:    domain.ErrorCodeCONFLICT,
+				Message: domain.MessageVERSIONCONFLICT,
+			}
+		}
+	}
+	return domain.ForbiddenAccessError{}
+}
+
+// exec runs a query deleting or restoring entities within the organization the caller acts for,
+// failing with a not found error when it doesn't affect as many entities as wanted
+func (r Base[T]) exec(ctx context.Context, q querier, dq string, dargs []interface{}, wanted int) (err error) {
+	var tag pgconn.CommandTag
+	err = r.withinOrganization(ctx, q, func(q querier) (err error) {
+		tag, err = q.Exec(ctx, dq, dargs...)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() < int64(wanted) {
+		return domain.DataNotFoundError{}
+	}
+	return nil
+}
+
+// countUnique counts the IDs, leaving out the duplicates
+func countUnique(ids []uuid.UUID) int {
+	unique := make(map[uuid.UUID]bool, len(ids))
+	for _, id := range ids {
+		unique[id] = true
+	}
+	return len(unique)
+}
+
+// fieldByColumn finds the field of the entity, or of its embedded structs, that is tagged with the column
+func fieldByColumn(v reflect.Value, column string) (result reflect.Value, ok bool) {
+	v = reflect.Indirect(v)
+	for i := range v.NumField() {
+		field := v.Type().Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			result, ok = fieldByColumn(v.Field(i), column)
+			if ok {
+				return result, true
+			}
+			continue
+		}
+		if field.Tag.Get("db") == column {
+			return v.Field(i), true
+		}
+	}
+	return result, false
+}
+
+// fieldValues reads the values of the fields of the entity tagged with the columns
+func fieldValues(entity any, columns []string) (result []interface{}, err error) {
+	v := reflect.ValueOf(entity)
+	result = make([]interface{}, 0, len(columns))
+	for _, col := range columns {
+		fv, ok := fieldByColumn(v, col)
+		if !ok {
+			return result, fmt.Errorf("%s has no field for the column %s", reflect.Indirect(v).Type(), col)
+		}
+		result = append(result, fv.Interface())
+	}
+	return result, nil
+}
+
+// fieldPointers returns pointers to the fields of the entity tagged with the columns, for the columns to be scanned into
+func fieldPointers(entity any, columns []string) (result []interface{}, err error) {
+	v := reflect.ValueOf(entity)
+	result = make([]interface{}, 0, len(columns))
+	for _, col := range columns {
+		fv, ok := fieldByColumn(v, col)
+		if !ok {
+			return result, fmt.Errorf("%s has no field for the column %s", reflect.Indirect(v).Type(), col)
+		}
+		result = append(result, fv.Addr().Interface())
+	}
+	return result, nil
+}
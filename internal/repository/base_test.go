@@ -0,0 +1,241 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/Intiqo/app-platform/internal/domain"
+)
+
+// testEntity is an entity stored in a table that Base knows how to query
+type testEntity struct {
+	ID        uuid.UUID `db:"id"`
+	Name      string    `db:"name"`
+	Version   int64     `db:"version"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
+
+// testTable describes how test entities are stored
+var testTable = Table{
+	Name:             "test_entities",
+	InsertColumns:    []string{"name"},
+	GeneratedColumns: []string{"id", "version", "updated_at"},
+	UpdateColumns:    []string{"name"},
+	SoftDelete:       true,
+}
+
+// entityTx is a transaction that answers the queries of Base with the entity as it is stored & visible to the caller, if any.
+// Updates match the entity when it is stored with the version given, and deletes & restores affect the given number of rows.
+type entityTx struct {
+	pgx.Tx
+	stored   *testEntity
+	matches  bool
+	affected int64
+}
+
+func (tx *entityTx) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	return &entityRows{stored: tx.stored}, nil
+}
+
+func (tx *entityTx) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return entityRow{matches: tx.matches}
+}
+
+func (tx *entityTx) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	return pgconn.NewCommandTag(fmt.Sprintf("UPDATE %d", tx.affected)), nil
+}
+
+func (tx *entityTx) SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults {
+	return &entityBatchResults{tx: tx, b: b}
+}
+
+// entityBatchResults answer the queries of a batch the same way as the transaction they are sent to
+type entityBatchResults struct {
+	pgx.BatchResults
+	tx *entityTx
+	b  *pgx.Batch
+}
+
+func (br *entityBatchResults) QueryRow() pgx.Row {
+	return br.tx.QueryRow(context.Background(), "")
+}
+
+func (br *entityBatchResults) Close() error {
+	for _, v := range br.b.QueuedQueries {
+		err := v.Fn(br)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// entityRow is the row returned by an update, which holds nothing when the update didn't match the entity
+type entityRow struct {
+	matches bool
+}
+
+func (r entityRow) Scan(dest ...any) error {
+	if !r.matches {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// entityRows are the rows returned by a select, which hold the stored entity if any
+type entityRows struct {
+	pgx.Rows
+	stored *testEntity
+	read   bool
+}
+
+func (r *entityRows) Close() {}
+
+func (r *entityRows) Err() error {
+	return nil
+}
+
+func (r *entityRows) FieldDescriptions() []pgconn.FieldDescription {
+	return []pgconn.FieldDescription{{Name: "id"}, {Name: "name"}, {Name: "version"}, {Name: "updated_at"}}
+}
+
+func (r *entityRows) Next() bool {
+	if r.stored == nil || r.read {
+		return false
+	}
+	r.read = true
+	return true
+}
+
+func (r *entityRows) Scan(dest ...any) error {
+	values := []any{r.stored.ID, r.stored.Name, r.stored.Version, r.stored.UpdatedAt}
+	for idx, v := range values {
+		reflect.ValueOf(dest[idx]).Elem().Set(reflect.ValueOf(v))
+	}
+	return nil
+}
+
+// staticReplicas always picks the same replica
+type staticReplicas struct {
+	pool *pgxpool.Pool
+}
+
+func (r staticReplicas) Pick() *pgxpool.Pool {
+	return r.pool
+}
+
+func TestReaderFor(t *testing.T) {
+	db, replica := new(pgxpool.Pool), new(pgxpool.Pool)
+	tx := &entityTx{}
+	withTx := context.WithValue(context.Background(), TxKey, pgx.Tx(tx))
+
+	t.Run("success - route the reads", func(t *testing.T) {
+		for _, v := range []struct {
+			name     string
+			ctx      context.Context
+			replicas replicaPicker
+			want     querier
+		}{
+			{name: "transaction", ctx: withTx, replicas: staticReplicas{pool: replica}, want: tx},
+			{name: "transaction asking for primary reads", ctx: domain.ContextWithPrimaryReads(withTx), replicas: staticReplicas{pool: replica}, want: tx},
+			{name: "primary reads", ctx: domain.ContextWithPrimaryReads(context.Background()), replicas: staticReplicas{pool: replica}, want: db},
+			{name: "healthy replica", ctx: context.Background(), replicas: staticReplicas{pool: replica}, want: replica},
+			{name: "no healthy replica", ctx: context.Background(), replicas: staticReplicas{}, want: db},
+		} {
+			if got := readerFor(v.ctx, db, v.replicas); got != v.want {
+				t.Errorf("Expected the reads with %s to go to %v, got %v", v.name, v.want, got)
+			}
+		}
+	})
+
+	t.Run("success - write to the transaction or else the pool", func(t *testing.T) {
+		if got := querierFor(withTx, db); got != tx {
+			t.Errorf("Expected the writes to go to the transaction, got %v", got)
+		}
+		if got := querierFor(context.Background(), db); got != db {
+			t.Errorf("Expected the writes to go to the pool, got %v", got)
+		}
+	})
+}
+
+func TestBaseUpdate(t *testing.T) {
+	versioned := testTable
+	versioned.Versioned = true
+	id := uuid.Must(uuid.NewV4())
+
+	for _, v := range []struct {
+		name    string
+		t       Table
+		stored  *testEntity
+		matches bool
+		errWant error
+	}{
+		{name: "success - update the entity", t: versioned, stored: &testEntity{ID: id, Version: 1}, matches: true, errWant: nil},
+		{name: "failure - versioned entity not found", t: versioned, stored: nil, errWant: domain.DataNotFoundError{}},
+		{name: "failure - entity not found", t: testTable, stored: nil, errWant: domain.DataNotFoundError{}},
+		{name: "failure - entity changed since", t: versioned, stored: &testEntity{ID: id, Version: 2}, errWant: domain.ConflictError{}},
+		{name: "failure - versioned entity kept from the caller", t: versioned, stored: &testEntity{ID: id, Version: 1}, errWant: domain.ForbiddenAccessError{}},
+		{name: "failure - entity kept from the caller", t: testTable, stored: &testEntity{ID: id, Version: 2}, errWant: domain.ForbiddenAccessError{}},
+	} {
+		t.Run(v.name, func(t *testing.T) {
+			tx := &entityTx{stored: v.stored, matches: v.matches}
+			ctx := context.WithValue(context.Background(), TxKey, pgx.Tx(tx))
+			r := NewBase[testEntity](nil, nil, v.t)
+
+			entity := &testEntity{ID: id, Name: "name", Version: 1}
+			err := r.Update(ctx, entity)
+			if v.errWant == nil && err != nil {
+				t.Fatalf("Error updating the entity: %v", err)
+			}
+			if v.errWant != nil && reflect.TypeOf(err) != reflect.TypeOf(v.errWant) {
+				t.Errorf("Expected %T, got %v", v.errWant, err)
+			}
+
+			err = r.UpdateMultiple(ctx, []*testEntity{entity})
+			if v.errWant == nil && err != nil {
+				t.Fatalf("Error updating the entities: %v", err)
+			}
+			if v.errWant != nil && reflect.TypeOf(err) != reflect.TypeOf(v.errWant) {
+				t.Errorf("Expected %T updating multiple entities, got %v", v.errWant, err)
+			}
+		})
+	}
+}
+
+func TestBaseDelete(t *testing.T) {
+	a, b := uuid.Must(uuid.NewV4()), uuid.Must(uuid.NewV4())
+
+	for _, v := range []struct {
+		name     string
+		ids      []uuid.UUID
+		affected int64
+		errWant  error
+	}{
+		{name: "success - delete the entities", ids: []uuid.UUID{a, b}, affected: 2, errWant: nil},
+		{name: "success - delete the entities given twice once", ids: []uuid.UUID{a, b, a}, affected: 2, errWant: nil},
+		{name: "failure - entity not found", ids: []uuid.UUID{a}, affected: 0, errWant: domain.DataNotFoundError{}},
+		{name: "failure - some of the entities not found", ids: []uuid.UUID{a, b}, affected: 1, errWant: domain.DataNotFoundError{}},
+	} {
+		t.Run(v.name, func(t *testing.T) {
+			tx := &entityTx{affected: v.affected}
+			ctx := context.WithValue(context.Background(), TxKey, pgx.Tx(tx))
+			r := NewBase[testEntity](nil, nil, testTable)
+
+			for _, fn := range []func(ctx context.Context, ids ...uuid.UUID) error{r.Delete, r.Restore} {
+				err := fn(ctx, v.ids...)
+				if !errors.Is(err, v.errWant) && (v.errWant != nil || err != nil) {
+					t.Errorf("Expected %v, got %v", v.errWant, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/Intiqo/app-platform/internal/domain"
+)
+
+type pgxJobRepository struct {
+	db  *pgxpool.Pool
+	sqt sq.StatementBuilderType
+}
+
+// NewJobRepository creates a new job repository
+func NewJobRepository(db *pgxpool.Pool) domain.JobRepository {
+	return &pgxJobRepository{
+		db:  db,
+		sqt: sq.StatementBuilder.PlaceholderFormat(sq.Dollar),
+	}
+}
+
+func (r *pgxJobRepository) Enqueue(ctx context.Context, entity *domain.Job) (err error) {
+	// Check if the context has a transaction
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	q := querierFor(ctx, r.db)
+
+	// Construct the query, leaving the job alone if the unique key is taken.
+	// The conflict target matches the jobs_unique_key_idx index.
+	dq, dargs, err := r.sqt.Insert("jobs").
+		Columns("name", "args", "unique_key", "max_attempts", "run_at").
+		Values(entity.Name, entity.Args, entity.UniqueKey, entity.MaxAttempts, entity.RunAt).
+		Suffix("ON CONFLICT (unique_key) WHERE status IN ('pending', 'running') DO NOTHING RETURNING *").
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	// Execute the query
+	rows, err := q.Query(ctx, dq, dargs...)
+	if err != nil {
+		return err
+	}
+	result, err := pgx.CollectOneRow(rows, pgx.RowToStructByNameLax[domain.Job])
+	if err == nil {
+		*entity = result
+		return nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return err
+	}
+
+	// Return the job that holds the unique key instead
+	dq, dargs, err = r.sqt.Select("*").
+		From("jobs").
+		Where(sq.Eq{"unique_key": entity.UniqueKey}).
+		Where(sq.Eq{"status": []string{domain.JobStatusPending, domain.JobStatusRunning}}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+	rows, err = q.Query(ctx, dq, dargs...)
+	if err != nil {
+		return err
+	}
+	result, err = pgx.CollectOneRow(rows, pgx.RowToStructByNameLax[domain.Job])
+	if err != nil {
+		return err
+	}
+	*entity = result
+	return nil
+}
+
+func (r *pgxJobRepository) Dequeue(ctx context.Context, names []string, limit int) (result []domain.Job, err error) {
+	// Check if the context has a transaction
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	q := querierFor(ctx, r.db)
+
+	// Construct the query, claiming the jobs that no other worker is claiming.
+	// The subquery is numbered along with the outer query, so it keeps the default placeholders.
+	due := sq.Select("id").
+		From("jobs").
+		Where(sq.Eq{"status": domain.JobStatusPending}).
+		Where("run_at <= NOW()").
+		Where(sq.Eq{"name": names}).
+		OrderBy("run_at").
+		Limit(uint64(limit)).
+		Suffix("FOR UPDATE SKIP LOCKED")
+	dq, dargs, err := r.sqt.Update("jobs").
+		Set("status", domain.JobStatusRunning).
+		Set("attempts", sq.Expr("attempts + 1")).
+		Set("locked_at", sq.Expr("NOW()")).
+		Set("updated_at", sq.Expr("NOW()")).
+		Where(sq.Expr("id IN (?)", due)).
+		Suffix("RETURNING *").
+		ToSql()
+	if err != nil {
+		return result, err
+	}
+
+	// Execute the query
+	rows, err := q.Query(ctx, dq, dargs...)
+	if err != nil {
+		return result, err
+	}
+
+	// Collect the result
+	return pgx.CollectRows(rows, pgx.RowToStructByNameLax[domain.Job])
+}
+
+func (r *pgxJobRepository) Complete(ctx context.Context, job domain.Job) (err error) {
+	return r.finish(ctx, job, r.sqt.Update("jobs").
+		Set("status", domain.JobStatusSucceeded).
+		Set("last_error", nil).
+		Set("finished_at", sq.Expr("NOW()")))
+}
+
+func (r *pgxJobRepository) Retry(ctx context.Context, job domain.Job, reason string, runAt time.Time) (err error) {
+	return r.finish(ctx, job, r.sqt.Update("jobs").
+		Set("status", domain.JobStatusPending).
+		Set("last_error", reason).
+		Set("run_at", runAt).
+		Set("locked_at", nil))
+}
+
+func (r *pgxJobRepository) Kill(ctx context.Context, job domain.Job, reason string) (err error) {
+	return r.finish(ctx, job, r.sqt.Update("jobs").
+		Set("status", domain.JobStatusDead).
+		Set("last_error", reason).
+		Set("finished_at", sq.Expr("NOW()")))
+}
+
+func (r *pgxJobRepository) Rescue(ctx context.Context, lockedBefore time.Time) (count int64, err error) {
+	// Check if the context has a transaction
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	q := querierFor(ctx, r.db)
+
+	// Construct the query
+	dq, dargs, err := r.sqt.Update("jobs").
+		Set("status", domain.JobStatusPending).
+		Set("locked_at", nil).
+		Set("updated_at", sq.Expr("NOW()")).
+		Where(sq.Eq{"status": domain.JobStatusRunning}).
+		Where(sq.Lt{"locked_at": lockedBefore}).
+		ToSql()
+	if err != nil {
+		return count, err
+	}
+
+	// Execute the query
+	tag, err := q.Exec(ctx, dq, dargs...)
+	if err != nil {
+		return count, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// finish applies the outcome of a run of a job, unless the job was rescued & claimed again in the meantime
+func (r *pgxJobRepository) finish(ctx context.Context, job domain.Job, ub sq.UpdateBuilder) (err error) {
+	// Check if the context has a transaction
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	q := querierFor(ctx, r.db)
+
+	// Construct the query
+	dq, dargs, err := ub.Set("updated_at", sq.Expr("NOW()")).
+		Where(sq.Eq{"id": job.ID}).
+		Where(sq.Eq{"status": domain.JobStatusRunning}).
+		Where(sq.Eq{"locked_at": job.LockedAt}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	// Execute the query
+	_, err = q.Exec(ctx, dq, dargs...)
+	return err
+}
@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/gofrs/uuid/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/Intiqo/app-platform/internal/domain"
+)
+
+// outboxTable describes how outbox events are stored
+var outboxTable = Table{
+	Name:             "outbox",
+	InsertColumns:    []string{"topic", "aggregate_id", "payload"},
+	GeneratedColumns: []string{"id", "available_at", "created_at"},
+}
+
+type pgxOutboxRepository struct {
+	db   *pgxpool.Pool
+	sqt  sq.StatementBuilderType
+	base Base[domain.OutboxEvent]
+}
+
+// NewOutboxRepository creates a new outbox repository.
+// The outbox is only read by the relay, which needs the events that were just added, so it doesn't use the read replicas.
+func NewOutboxRepository(db *pgxpool.Pool) domain.OutboxRepository {
+	return &pgxOutboxRepository{
+		db:   db,
+		sqt:  sq.StatementBuilder.PlaceholderFormat(sq.Dollar),
+		base: NewBase[domain.OutboxEvent](db, nil, outboxTable),
+	}
+}
+
+func (r *pgxOutboxRepository) Add(ctx context.Context, events ...*domain.OutboxEvent) (err error) {
+	if len(events) == 0 {
+		return nil
+	}
+	return r.base.CreateMultiple(ctx, events)
+}
+
+func (r *pgxOutboxRepository) Claim(ctx context.Context, limit int, lockedBefore time.Time) (result []domain.OutboxEvent, err error) {
+	// Check if the context has a transaction
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	q := querierFor(ctx, r.db)
+
+	// Construct the query, claiming the events that no other relay is claiming or holding on to.
+	// The subquery is numbered along with the outer query, so it keeps the default placeholders.
+	due := sq.Select("id").
+		From(outboxTable.Name).
+		Where("published_at IS NULL").
+		Where("available_at <= NOW()").
+		Where(sq.Or{sq.Eq{"locked_at": nil}, sq.Lt{"locked_at": lockedBefore}}).
+		OrderBy("available_at", "created_at").
+		Limit(uint64(limit)).
+		Suffix("FOR UPDATE SKIP LOCKED")
+	dq, dargs, err := r.sqt.Update(outboxTable.Name).
+		Set("locked_at", sq.Expr("NOW()")).
+		Where(sq.Expr("id IN (?)", due)).
+		Suffix("RETURNING *").
+		ToSql()
+	if err != nil {
+		return result, err
+	}
+
+	// Execute the query
+	rows, err := q.Query(ctx, dq, dargs...)
+	if err != nil {
+		return result, err
+	}
+
+	// Collect the result, oldest first
+	result, err = pgx.CollectRows(rows, pgx.RowToStructByNameLax[domain.OutboxEvent])
+	if err != nil {
+		return result, err
+	}
+	slices.SortFunc(result, func(a, b domain.OutboxEvent) int {
+		if c := a.AvailableAt.Compare(b.AvailableAt); c != 0 {
+			return c
+		}
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return result, nil
+}
+
+func (r *pgxOutboxRepository) MarkPublished(ctx context.Context, ids []uuid.UUID) (err error) {
+	// Check if the context has a transaction
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	q := querierFor(ctx, r.db)
+
+	// Construct the query
+	dq, dargs, err := r.sqt.Update(outboxTable.Name).
+		Set("published_at", sq.Expr("NOW()")).
+		Set("locked_at", nil).
+		Where(sq.Eq{"id": ids}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	// Execute the query
+	_, err = q.Exec(ctx, dq, dargs...)
+	return err
+}
+
+func (r *pgxOutboxRepository) MarkFailed(ctx context.Context, id uuid.UUID, reason string, delay time.Duration) (err error) {
+	// Check if the context has a transaction
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	q := querierFor(ctx, r.db)
+
+	// Construct the query
+	dq, dargs, err := r.sqt.Update(outboxTable.Name).
+		Set("attempts", sq.Expr("attempts + 1")).
+		Set("last_error", reason).
+		Set("available_at", sq.Expr("NOW() + make_interval(secs => ?)", delay.Seconds())).
+		Set("locked_at", nil).
+		Where(sq.Eq{"id": id}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	// Execute the query
+	_, err = q.Exec(ctx, dq, dargs...)
+	return err
+}
@@ -0,0 +1,342 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+
+	"github.com/Intiqo/app-platform/internal/domain"
+)
+
+// queryFields maps the fields that callers may filter & sort the entities of a table by to their columns.
+// Fields that aren't listed are rejected, so that callers can never inject SQL through them.
+type queryFields map[string]string
+
+// column finds the column of a field
+func (f queryFields) column(field string) (result string, err error) {
+	result, ok := f[field]
+	if !ok {
+		return result, domain.UserError{
+			Code:    domain.ErrorCodeINVALIDREQUEST,
+			Message: fmt.Sprintf("The field %s can't be used to filter or sort", field),
+		}
+	}
+	return result, nil
+}
+
+// where builds the clause matching the entities that meet all the conditions
+func (f queryFields) where(conditions []domain.Condition) (result sq.And, err error) {
+	result = make(sq.And, 0, len(conditions))
+	for _, v := range conditions {
+		col, err := f.column(v.Field)
+		if err != nil {
+			return result, err
+		}
+
+		// Make sure the operator is given the number of values it takes
+		want := 1
+		switch v.Op {
+		case domain.FilterOpIn:
+			want = max(len(v.Values), 1)
+		case domain.FilterOpBetween:
+			want = 2
+		case domain.FilterOpIsNull:
+			want = 0
+		}
+		if len(v.Values) != want {
+			return result, domain.UserError{
+				Code:    domain.ErrorCodeINVALIDREQUEST,
+				Message: fmt.Sprintf("The %s condition on %s takes %d value(s), got %d", v.Op, v.Field, want, len(v.Values)),
+			}
+		}
+
+		// Build the clause, always passing the values as arguments
+		var clause sq.Sqlizer
+		switch v.Op {
+		case domain.FilterOpEq:
+			clause = sq.Eq{col: v.Values[0]}
+		case domain.FilterOpNe:
+			clause = sq.NotEq{col: v.Values[0]}
+		case domain.FilterOpIn:
+			clause = sq.Eq{col: v.Values}
+		case domain.FilterOpLike:
+			clause = sq.Like{col: v.Values[0]}
+		case domain.FilterOpGt:
+			clause = sq.Gt{col: v.Values[0]}
+		case domain.FilterOpLt:
+			clause = sq.Lt{col: v.Values[0]}
+		case domain.FilterOpBetween:
+			clause = sq.And{sq.GtOrEq{col: v.Values[0]}, sq.LtOrEq{col: v.Values[1]}}
+		case domain.FilterOpIsNull:
+			clause = sq.Eq{col: nil}
+		default:
+			return result, domain.UserError{
+				Code:    domain.ErrorCodeINVALIDREQUEST,
+				Message: fmt.Sprintf("The operator %s is not supported", v.Op),
+			}
+		}
+		result = append(result, clause)
+	}
+	return result, nil
+}
+
+// orderColumn is a column that the entities of a query are ordered by.
+// Nulls come first in ascending order, and last in descending order, so that reversing every column reverses the order.
+type orderColumn struct {
+	column string
+	desc   bool
+}
+
+func (c orderColumn) String() string {
+	if c.desc {
+		return c.column + " DESC NULLS LAST"
+	}
+	return c.column + " ASC NULLS FIRST"
+}
+
+// order builds the columns ordering the entities by the sort keys, followed by the default ones.
+// The default sort keys should end with a unique field, so that pagination is stable.
+func (f queryFields) order(keys []domain.SortKey, defaults []domain.SortKey) (result []orderColumn, err error) {
+	result = make([]orderColumn, 0, len(keys)+len(defaults))
+	seen := make(map[string]bool, len(keys)+len(defaults))
+	for _, v := range append(slices.Clip(keys), defaults...) {
+		col, err := f.column(v.Field)
+		if err != nil {
+			return result, err
+		}
+		if seen[col] {
+			continue
+		}
+		seen[col] = true
+		switch strings.ToLower(v.Direction) {
+		case "", domain.SortDirectionAsc:
+			result = append(result, orderColumn{column: col})
+		case domain.SortDirectionDesc:
+			result = append(result, orderColumn{column: col, desc: true})
+		default:
+			return result, domain.UserError{
+				Code:    domain.ErrorCodeINVALIDREQUEST,
+				Message: fmt.Sprintf("The sort direction %s is not supported", v.Direction),
+			}
+		}
+	}
+	return result, nil
+}
+
+// pageQuery describes how a page of entities is fetched, either by offset or by keyset
+type pageQuery struct {
+	order    []orderColumn
+	limit    int64
+	keyset   bool
+	cursor   bool
+	backward bool
+}
+
+// pageCursor is the content of the opaque cursors handed out with keyset pagination.
+// It holds a fingerprint of the order columns, and their values for the entity the page starts after, or ends before when going backward.
+type pageCursor struct {
+	Order    uint32    `json:"o"`
+	Values   []*string `json:"v"`
+	Backward bool      `json:"b,omitempty"`
+}
+
+// page orders the query and selects the page of entities asked for by the query options.
+// In keyset mode, the page is selected with a condition on the order columns rather than an offset, and one extra entity is fetched
+// to find out if there are more. The entities must then be passed through paginate.
+func (f queryFields) page(qb sq.SelectBuilder, opts domain.QueryOptions, defaults []domain.SortKey) (result sq.SelectBuilder, p pageQuery, err error) {
+	p.order, err = f.order(opts.SortKeys, defaults)
+	if err != nil {
+		return qb, p, err
+	}
+	p.limit = opts.Limit
+	p.keyset = opts.Keyset || opts.Cursor != ""
+
+	// Paginate by offset
+	if !p.keyset {
+		qb = qb.OrderBy(orderBy(p.order)...)
+		if opts.Limit > 0 {
+			qb = qb.Limit(uint64(opts.Limit))
+		}
+		if opts.Offset > 0 {
+			qb = qb.Offset(uint64(opts.Offset))
+		}
+		return qb, p, nil
+	}
+
+	// Select the entities after the cursor, in the reverse order when going backward
+	order := p.order
+	if opts.Cursor != "" {
+		var c pageCursor
+		c, err = decodePageCursor(opts.Cursor)
+		if err != nil || c.Order != orderHash(p.order) || len(c.Values) != len(p.order) {
+			return qb, p, domain.UserError{
+				Code:    domain.ErrorCodeINVALIDREQUEST,
+				Message: "The cursor is invalid, or was issued for different sort keys",
+			}
+		}
+		p.cursor = true
+		p.backward = c.Backward
+		if p.backward {
+			order = reverseOrder(order)
+		}
+		qb = qb.Where(after(order, c.Values))
+	}
+	qb = qb.OrderBy(orderBy(order)...)
+	if opts.Limit > 0 {
+		qb = qb.Limit(uint64(opts.Limit + 1))
+	}
+	return qb, p, nil
+}
+
+// paginate trims the extra entity fetched in keyset mode, and works out the cursors of the pages around the entities
+func paginate[T any](entities []T, page domain.PageInfo, p pageQuery) (result []T, _ domain.PageInfo, err error) {
+	if !p.keyset {
+		return entities, page, nil
+	}
+
+	// Trim the extra entity, which is the furthest from the cursor
+	more := p.limit > 0 && int64(len(entities)) > p.limit
+	if more {
+		entities = entities[:p.limit]
+	}
+	if p.backward {
+		slices.Reverse(entities)
+	}
+	if len(entities) == 0 {
+		return entities, page, nil
+	}
+
+	// There is a next page if there are more entities after this page, or if this page was reached by going backward, and vice versa
+	if more || p.backward {
+		page.NextCursor, err = encodePageCursor(entities[len(entities)-1], p.order, false)
+		if err != nil {
+			return entities, page, err
+		}
+	}
+	if more && p.backward || p.cursor && !p.backward {
+		page.PrevCursor, err = encodePageCursor(entities[0], p.order, true)
+		if err != nil {
+			return entities, page, err
+		}
+	}
+	return entities, page, nil
+}
+
+// after builds the condition selecting the entities that come after the given values of the order columns
+func after(order []orderColumn, values []*string) sq.Or {
+	result := make(sq.Or, 0, len(order))
+	for i, c := range order {
+		clause := make(sq.And, 0, i+1)
+		for j := range i {
+			if values[j] == nil {
+				clause = append(clause, sq.Expr(order[j].column+" IS NULL"))
+			} else {
+				clause = append(clause, sq.Expr(order[j].column+" = ?", *values[j]))
+			}
+		}
+		switch {
+		case !c.desc && values[i] == nil:
+			clause = append(clause, sq.Expr(c.column+" IS NOT NULL"))
+		case !c.desc:
+			clause = append(clause, sq.Expr(c.column+" > ?", *values[i]))
+		case values[i] == nil:
+			// Nulls come last in descending order, so nothing comes after them in this column
+			continue
+		default:
+			clause = append(clause, sq.Or{sq.Expr(c.column+" < ?", *values[i]), sq.Expr(c.column + " IS NULL")})
+		}
+		result = append(result, clause)
+	}
+	if len(result) == 0 {
+		result = append(result, sq.Expr("FALSE"))
+	}
+	return result
+}
+
+// orderBy builds the ORDER BY expressions of the order columns
+func orderBy(order []orderColumn) []string {
+	result := make([]string, 0, len(order))
+	for _, v := range order {
+		result = append(result, v.String())
+	}
+	return result
+}
+
+// orderHash fingerprints the order columns, so that cursors can't be used with other sort keys than the ones they were issued for
+func orderHash(order []orderColumn) uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(strings.Join(orderBy(order), ",")))
+	return h.Sum32()
+}
+
+// reverseOrder reverses the direction of the order columns
+func reverseOrder(order []orderColumn) []orderColumn {
+	result := make([]orderColumn, 0, len(order))
+	for _, v := range order {
+		result = append(result, orderColumn{column: v.column, desc: !v.desc})
+	}
+	return result
+}
+
+// encodePageCursor encodes the cursor of the page starting after the entity, or ending before it when going backward
+func encodePageCursor(entity any, order []orderColumn, backward bool) (result string, err error) {
+	c := pageCursor{
+		Order:    orderHash(order),
+		Values:   make([]*string, 0, len(order)),
+		Backward: backward,
+	}
+	for _, v := range order {
+		value, err := columnValue(entity, v.column)
+		if err != nil {
+			return result, err
+		}
+		c.Values = append(c.Values, value)
+	}
+	b, err := json.Marshal(c)
+	if err != nil {
+		return result, err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// decodePageCursor decodes a cursor handed out by encodePageCursor
+func decodePageCursor(cursor string) (result pageCursor, err error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return result, err
+	}
+	err = json.Unmarshal(b, &result)
+	return result, err
+}
+
+// columnValue reads the value of a column from the field of the entity, or of its embedded structs, that is tagged with it.
+// Values are formatted the way Postgres parses them back, and nil pointers are nulls.
+func columnValue(entity any, column string) (result *string, err error) {
+	fv, ok := fieldByColumn(reflect.ValueOf(entity), column)
+	if !ok {
+		return nil, fmt.Errorf("%s has no field for the column %s", reflect.Indirect(reflect.ValueOf(entity)).Type(), column)
+	}
+	if fv.Kind() == reflect.Pointer {
+		if fv.IsNil() {
+			return nil, nil
+		}
+		fv = fv.Elem()
+	}
+	var value string
+	switch x := fv.Interface().(type) {
+	case time.Time:
+		value = x.Format(time.RFC3339Nano)
+	case fmt.Stringer:
+		value = x.String()
+	default:
+		value = fmt.Sprint(x)
+	}
+	return &value, nil
+}
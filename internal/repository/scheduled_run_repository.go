@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/Intiqo/app-platform/internal/database"
+	"github.com/Intiqo/app-platform/internal/domain"
+)
+
+// scheduledRunTable describes how the runs of scheduled tasks are stored
+var scheduledRunTable = Table{
+	Name:             "scheduled_runs",
+	InsertColumns:    []string{"task", "scheduled_at", "instance"},
+	GeneratedColumns: []string{"id", "status", "started_at"},
+	Fields: queryFields{
+		"id":          "id",
+		"task":        "task",
+		"status":      "status",
+		"instance":    "instance",
+		"scheduledAt": "scheduled_at",
+		"startedAt":   "started_at",
+		"finishedAt":  "finished_at",
+	},
+	DefaultSortKeys: []domain.SortKey{
+		{Field: "scheduledAt", Direction: domain.SortDirectionDesc},
+		{Field: "id", Direction: domain.SortDirectionDesc},
+	},
+}
+
+type pgxScheduledRunRepository struct {
+	db   *pgxpool.Pool
+	sqt  sq.StatementBuilderType
+	base Base[domain.ScheduledRun]
+}
+
+// NewScheduledRunRepository creates a new repository of the runs of scheduled tasks
+func NewScheduledRunRepository(db *pgxpool.Pool, replicas *database.Replicas) domain.ScheduledRunRepository {
+	return &pgxScheduledRunRepository{
+		db:   db,
+		sqt:  sq.StatementBuilder.PlaceholderFormat(sq.Dollar),
+		base: NewBase[domain.ScheduledRun](db, replicas, scheduledRunTable),
+	}
+}
+
+func (r *pgxScheduledRunRepository) Start(ctx context.Context, entity *domain.ScheduledRun) (started bool, err error) {
+	// Check if the context has a transaction
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	q := querierFor(ctx, r.db)
+
+	// Construct the query, leaving the run alone if it was already started.
+	// The conflict target matches the scheduled_runs_task_scheduled_at_idx index.
+	dq, dargs, err := r.sqt.Insert(scheduledRunTable.Name).
+		Columns(scheduledRunTable.InsertColumns...).
+		Values(entity.Task, entity.ScheduledAt, entity.Instance).
+		Suffix("ON CONFLICT (task, scheduled_at) DO NOTHING RETURNING id, status, started_at").
+		ToSql()
+	if err != nil {
+		return false, err
+	}
+
+	// Execute the query & collect the result
+	err = q.QueryRow(ctx, dq, dargs...).Scan(&entity.ID, &entity.Status, &entity.StartedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *pgxScheduledRunRepository) Finish(ctx context.Context, entity *domain.ScheduledRun) (err error) {
+	// Check if the context has a transaction
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	q := querierFor(ctx, r.db)
+
+	// Construct the query
+	dq, dargs, err := r.sqt.Update(scheduledRunTable.Name).
+		Set("status", entity.Status).
+		Set("error", entity.Error).
+		Set("finished_at", sq.Expr("NOW()")).
+		Where(sq.Eq{"id": entity.ID}).
+		Suffix("RETURNING finished_at").
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	// Execute the query & collect the result
+	return q.QueryRow(ctx, dq, dargs...).Scan(&entity.FinishedAt)
+}
+
+func (r *pgxScheduledRunRepository) FindLatest(ctx context.Context, tasks []string) (result map[string]domain.ScheduledRun, err error) {
+	// Check if the context has a transaction
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	q := querierFor(ctx, r.db)
+
+	// Construct the query
+	dq, dargs, err := r.sqt.Select("DISTINCT ON (task) *").
+		From(scheduledRunTable.Name).
+		Where(sq.Eq{"task": tasks}).
+		OrderBy("task", "scheduled_at DESC").
+		ToSql()
+	if err != nil {
+		return result, err
+	}
+
+	// Execute the query
+	rows, err := q.Query(ctx, dq, dargs...)
+	if err != nil {
+		return result, err
+	}
+	runs, err := pgx.CollectRows(rows, pgx.RowToStructByNameLax[domain.ScheduledRun])
+	if err != nil {
+		return result, err
+	}
+
+	// Collect the result
+	result = make(map[string]domain.ScheduledRun, len(runs))
+	for _, v := range runs {
+		result[v.Task] = v
+	}
+	return result, nil
+}
+
+func (r *pgxScheduledRunRepository) Filter(ctx context.Context, in domain.FilterScheduledRunsByCriteriaInput, opts domain.QueryOptions) (result []domain.ScheduledRun, page domain.PageInfo, err error) {
+	// Build the criteria
+	criteria := sq.And{}
+	if in.Task != "" {
+		criteria = append(criteria, sq.Eq{"task": in.Task})
+	}
+
+	// Filter the runs
+	return r.base.Filter(ctx, criteria, opts)
+}
@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/puddle/v2"
+
+	"github.com/Intiqo/app-platform/internal/domain"
+)
+
+// settingsChangedChannel is the channel on which the settings table trigger publishes changes
+const settingsChangedChannel = "settings_changed"
+
+// listenRetryInterval is the time to wait before listening again after the connection is lost
+const listenRetryInterval = 5 * time.Second
+
+type pgxSettingChangeListener struct {
+	db *pgxpool.Pool
+}
+
+// NewSettingChangeListener creates a new setting change listener
+func NewSettingChangeListener(db *pgxpool.Pool) domain.SettingChangeListener {
+	return &pgxSettingChangeListener{
+		db: db,
+	}
+}
+
+func (l *pgxSettingChangeListener) Listen(ctx context.Context, onConnect func(), onChange func(event domain.SettingChangeEvent)) (err error) {
+	for {
+		err = l.listen(ctx, onConnect, onChange)
+
+		// Stop listening if we were asked to, or if the pool is gone
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if errors.Is(err, puddle.ErrClosedPool) {
+			return err
+		}
+
+		// Listen again after a while
+		slog.Error("lost connection while listening for setting changes", "error", err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(listenRetryInterval):
+		}
+	}
+}
+
+// listen holds on to a connection and waits for notifications until an error occurs
+func (l *pgxSettingChangeListener) listen(ctx context.Context, onConnect func(), onChange func(event domain.SettingChangeEvent)) (err error) {
+	// Acquire a dedicated connection
+	conn, err := l.db.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Close the connection once done, instead of releasing it, so that it doesn't go back to the pool still listening.
+	// It is kept alive when waiting is cancelled, and would go on buffering notifications for whoever acquires it next.
+	defer func() {
+		_ = conn.Hijack().Close(context.Background())
+	}()
+
+	// Subscribe to the channel
+	_, err = conn.Exec(ctx, "LISTEN "+settingsChangedChannel)
+	if err != nil {
+		return err
+	}
+	onConnect()
+
+	// Wait for notifications
+	for {
+		n, err := conn.Conn().WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+
+		var event domain.SettingChangeEvent
+		err = json.Unmarshal([]byte(n.Payload), &event)
+		if err != nil {
+			slog.Error("failed to decode setting change notification", "payload", n.Payload, "error", err)
+			continue
+		}
+		onChange(event)
+	}
+}
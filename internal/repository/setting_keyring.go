@@ -0,0 +1,415 @@
+package repository
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/gofrs/uuid/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/Intiqo/app-platform/internal/domain"
+	"github.com/Intiqo/app-platform/internal/pkg/config"
+	"github.com/Intiqo/app-platform/internal/pkg/secrets"
+)
+
+// settingDataKeysLockID identifies the advisory lock that serializes the rotation of data keys across instances
+const settingDataKeysLockID = "setting_data_keys"
+
+// dataKeySize is the size of the AES-256 data & master keys, in bytes
+const dataKeySize = 32
+
+type pgxSettingKeyring struct {
+	db *pgxpool.Pool
+	sm secrets.Manager
+
+	masterKeyName string
+
+	mu         sync.RWMutex
+	masterKeys map[string][]byte
+	dataKeys   map[uuid.UUID]settingDataKey
+}
+
+// settingDataKey is a data key, decrypted with its master key
+type settingDataKey struct {
+	key []byte
+	// legacy data keys encrypted values before they were bound to their setting & data key
+	legacy bool
+}
+
+// NewSettingKeyring creates a new keyring for sensitive settings.
+// Data keys are stored in the database, encrypted with the master key held in the secrets manager.
+func NewSettingKeyring(cfg config.AppConfig, db *pgxpool.Pool, sm secrets.Manager) domain.SettingKeyring {
+	k := &pgxSettingKeyring{
+		db: db,
+		sm: sm,
+
+		masterKeyName: cfg.SettingMasterKeyName,
+
+		masterKeys: make(map[string][]byte),
+		dataKeys:   make(map[uuid.UUID]settingDataKey),
+	}
+	return k
+}
+
+func (k *pgxSettingKeyring) Encrypt(ctx context.Context, plaintext string, settingID, dataKeyID uuid.UUID) (ciphertext string, err error) {
+	// Find the data key
+	if dataKeyID == uuid.Nil {
+		return ciphertext, domain.UserError{
+			Code:    domain.ErrorCodeINVALIDREQUEST,
+			Message: "Sensitive settings can't be stored since no master key is configured",
+		}
+	}
+	dataKey, err := k.dataKey(ctx, dataKeyID)
+	if err != nil {
+		return ciphertext, err
+	}
+
+	// Encrypt the value, bound to the setting & the data key
+	b, err := seal(dataKey.key, []byte(plaintext), settingValueAAD(settingID, dataKeyID))
+	if err != nil {
+		return ciphertext, err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+func (k *pgxSettingKeyring) Decrypt(ctx context.Context, ciphertext string, settingID, dataKeyID uuid.UUID) (plaintext string, err error) {
+	// Find the data key
+	dataKey, err := k.dataKey(ctx, dataKeyID)
+	if err != nil {
+		return plaintext, err
+	}
+
+	// Decrypt the value, which isn't bound to anything when it was encrypted with a legacy data key
+	b, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return plaintext, err
+	}
+	var aad []byte
+	if !dataKey.legacy {
+		aad = settingValueAAD(settingID, dataKeyID)
+	}
+	b, err = open(dataKey.key, b, aad)
+	if err != nil {
+		return plaintext, err
+	}
+	return string(b), nil
+}
+
+func (k *pgxSettingKeyring) ActiveKeyID(ctx context.Context) (result uuid.UUID, err error) {
+	if k.masterKeyName == "" {
+		return uuid.Nil, nil
+	}
+
+	// Find the active data key, within the transaction carried by the context if any
+	var masterKeyName string
+	q := `SELECT id, master_key_name FROM setting_data_keys WHERE retired_at IS NULL`
+	err = querierFor(ctx, k.db).QueryRow(ctx, q).Scan(&result, &masterKeyName)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return result, err
+	}
+	if err == nil && masterKeyName == k.masterKeyName {
+		return result, nil
+	}
+
+	// Create a new data key, since there's none yet or the master key has been rotated
+	return k.rotate(ctx, true)
+}
+
+func (k *pgxSettingKeyring) Rotate(ctx context.Context) (result uuid.UUID, err error) {
+	if k.masterKeyName == "" {
+		return result, domain.UserError{
+			Code:    domain.ErrorCodeINVALIDREQUEST,
+			Message: "Data keys can't be rotated since no master key is configured",
+		}
+	}
+	return k.rotate(ctx, false)
+}
+
+func (k *pgxSettingKeyring) Prune(ctx context.Context) (count int64, err error) {
+	q := `DELETE FROM setting_data_keys k WHERE retired_at IS NOT NULL
+		AND NOT EXISTS (SELECT 1 FROM settings WHERE data_key_id = k.id)
+		AND NOT EXISTS (SELECT 1 FROM setting_revisions WHERE data_key_id = k.id)`
+	tag, err := k.db.Exec(ctx, q)
+	if err != nil {
+		return count, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// rotate retires the active data key in favour of a new one.
+// When onlyIfStale is set, the active data key is kept if it's encrypted with the current master key,
+// so that instances racing to replace a stale data key only replace it once.
+func (k *pgxSettingKeyring) rotate(ctx context.Context, onlyIfStale bool) (result uuid.UUID, err error) {
+	// Generate the data key & encrypt it with the master key
+	masterKey, err := k.masterKey(k.masterKeyName)
+	if err != nil {
+		return result, err
+	}
+	dataKey := make([]byte, dataKeySize)
+	_, err = rand.Read(dataKey)
+	if err != nil {
+		return result, err
+	}
+	encryptedKey, err := seal(masterKey, dataKey, nil)
+	if err != nil {
+		return result, err
+	}
+
+	// Begin a transaction, serialized with the other instances
+	tx, err := k.db.Begin(ctx)
+	if err != nil {
+		return result, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	_, err = tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, settingDataKeysLockID)
+	if err != nil {
+		return result, err
+	}
+
+	// Keep the active data key if another instance has replaced it in the meantime
+	if onlyIfStale {
+		var masterKeyName string
+		err = tx.QueryRow(ctx, `SELECT id, master_key_name FROM setting_data_keys WHERE retired_at IS NULL`).Scan(&result, &masterKeyName)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return result, err
+		}
+		if err == nil && masterKeyName == k.masterKeyName {
+			return result, nil
+		}
+	}
+
+	// Retire the active data key & store the new one
+	_, err = tx.Exec(ctx, `UPDATE setting_data_keys SET retired_at = NOW() WHERE retired_at IS NULL`)
+	if err != nil {
+		return result, err
+	}
+	q := `INSERT INTO setting_data_keys (encrypted_key, master_key_name) VALUES ($1, $2) RETURNING id`
+	err = tx.QueryRow(ctx, q, base64.StdEncoding.EncodeToString(encryptedKey), k.masterKeyName).Scan(&result)
+	if err != nil {
+		return result, err
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		return result, err
+	}
+
+	// Remember the data key
+	k.mu.Lock()
+	k.dataKeys[result] = settingDataKey{key: dataKey}
+	k.mu.Unlock()
+	return result, nil
+}
+
+// dataKey returns the decrypted data key with the given ID
+func (k *pgxSettingKeyring) dataKey(ctx context.Context, id uuid.UUID) (result settingDataKey, err error) {
+	// Look up the data keys that were already decrypted
+	k.mu.RLock()
+	result, ok := k.dataKeys[id]
+	k.mu.RUnlock()
+	if ok {
+		return result, nil
+	}
+
+	// Find the data key
+	var encryptedKey, masterKeyName string
+	q := `SELECT encrypted_key, master_key_name, legacy FROM setting_data_keys WHERE id = $1`
+	err = k.db.QueryRow(ctx, q, id).Scan(&encryptedKey, &masterKeyName, &result.legacy)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return result, fmt.Errorf("setting data key %s not found", id)
+		}
+		return result, err
+	}
+
+	// Decrypt the data key with the master key it was encrypted with
+	masterKey, err := k.masterKey(masterKeyName)
+	if err != nil {
+		return result, err
+	}
+	b, err := base64.StdEncoding.DecodeString(encryptedKey)
+	if err != nil {
+		return result, err
+	}
+	result.key, err = open(masterKey, b, nil)
+	if err != nil {
+		return result, fmt.Errorf("failed to decrypt setting data key %s: %w", id, err)
+	}
+
+	// Remember the data key
+	k.mu.Lock()
+	k.dataKeys[id] = result
+	k.mu.Unlock()
+	return result, nil
+}
+
+// masterKey returns the master key with the given name from the secrets manager
+func (k *pgxSettingKeyring) masterKey(name string) (result []byte, err error) {
+	// Look up the master keys that were already loaded
+	k.mu.RLock()
+	result, ok := k.masterKeys[name]
+	k.mu.RUnlock()
+	if ok {
+		return result, nil
+	}
+
+	// Load the master key
+	secret, err := k.sm.GetSecret(name)
+	if err != nil {
+		return result, fmt.Errorf("failed to load setting master key %s: %w", name, err)
+	}
+	result, err = base64.StdEncoding.DecodeString(strings.TrimSpace(secret))
+	if err != nil {
+		return result, fmt.Errorf("setting master key %s is not base64 encoded: %w", name, err)
+	}
+	if len(result) != dataKeySize {
+		return result, fmt.Errorf("setting master key %s must be %d bytes long, got %d", name, dataKeySize, len(result))
+	}
+
+	// Remember the master key
+	k.mu.Lock()
+	k.masterKeys[name] = result
+	k.mu.Unlock()
+	return result, nil
+}
+
+// seal encrypts the plaintext using AES-GCM, prefixing the ciphertext with the nonce.
+// The additional data isn't part of the ciphertext, but the same has to be given to open it.
+func seal(key, plaintext, additionalData []byte) (result []byte, err error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return result, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return result, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// open decrypts a ciphertext produced by seal with the same additional data
+func open(key, ciphertext, additionalData []byte) (result []byte, err error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return result, err
+	}
+	if len(ciphertext) < aead.NonceSize() {
+		return result, errors.New("ciphertext is too short")
+	}
+	nonce, ciphertext := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, additionalData)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// settingValueAAD is the additional data that the value of a setting is encrypted with,
+// so that a ciphertext can't be passed off as the value of another setting or as encrypted with another data key
+func settingValueAAD(settingID, dataKeyID uuid.UUID) []byte {
+	return append(settingID.Bytes(), dataKeyID.Bytes()...)
+}
+
+// activeKeyIDFor finds the active data key once for a write, when any of the values it writes is sensitive
+func activeKeyIDFor(ctx context.Context, k domain.SettingKeyring, sensitive bool) (result uuid.UUID, err error) {
+	if !sensitive {
+		return uuid.Nil, nil
+	}
+	return k.ActiveKeyID(ctx)
+}
+
+// sealSettingValue returns the value of a setting as it is stored, encrypting it with the data key when the setting is sensitive
+func sealSettingValue(ctx context.Context, k domain.SettingKeyring, dataKeyID, settingID uuid.UUID, sensitive bool, value string) (result string, usedKeyID *uuid.UUID, err error) {
+	if !sensitive {
+		return value, nil, nil
+	}
+	result, err = k.Encrypt(ctx, value, settingID, dataKeyID)
+	if err != nil {
+		return result, nil, err
+	}
+	return result, &dataKeyID, nil
+}
+
+// openSettingValue returns the value of a setting as it was stored by sealSettingValue
+func openSettingValue(ctx context.Context, k domain.SettingKeyring, sensitive bool, value string, settingID uuid.UUID, dataKeyID *uuid.UUID) (result string, err error) {
+	if !sensitive {
+		return value, nil
+	}
+	if dataKeyID == nil {
+		return result, errors.New("sensitive setting has no data key")
+	}
+	return k.Decrypt(ctx, value, settingID, *dataKeyID)
+}
+
+// reencrypt re-encrypts up to limit sensitive values of a table that are encrypted with a data key other than the active one.
+// settingIDColumn holds the ID of the setting that the values are bound to.
+// The rows are locked while they are re-encrypted, so that instances can re-encrypt values concurrently.
+func reencrypt(ctx context.Context, db *pgxpool.Pool, k domain.SettingKeyring, table, settingIDColumn string, limit int) (count int64, err error) {
+	// Find the active data key
+	activeKeyID, err := k.ActiveKeyID(ctx)
+	if err != nil || activeKeyID == uuid.Nil {
+		return count, err
+	}
+
+	// Begin a transaction
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return count, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	// Find the values encrypted with other data keys
+	type encryptedValue struct {
+		ID        uuid.UUID
+		SettingID uuid.UUID
+		Value     string
+		DataKeyID uuid.UUID
+	}
+	q := fmt.Sprintf(`SELECT id, %s, value, data_key_id FROM %s WHERE sensitive AND data_key_id <> $1 LIMIT $2 FOR UPDATE SKIP LOCKED`, settingIDColumn, table)
+	rows, err := tx.Query(ctx, q, activeKeyID, limit)
+	if err != nil {
+		return count, err
+	}
+	values, err := pgx.CollectRows(rows, pgx.RowToStructByPos[encryptedValue])
+	if err != nil {
+		return count, err
+	}
+
+	// Re-encrypt the values
+	b := &pgx.Batch{}
+	q = fmt.Sprintf(`UPDATE %s SET value = $1, data_key_id = $2 WHERE id = $3`, table)
+	for _, v := range values {
+		plaintext, err := k.Decrypt(ctx, v.Value, v.SettingID, v.DataKeyID)
+		if err != nil {
+			return count, fmt.Errorf("failed to decrypt %s %s: %w", table, v.ID, err)
+		}
+		ciphertext, err := k.Encrypt(ctx, plaintext, v.SettingID, activeKeyID)
+		if err != nil {
+			return count, err
+		}
+		b.Queue(q, ciphertext, activeKeyID, v.ID)
+	}
+	err = tx.SendBatch(ctx, b).Close()
+	if err != nil {
+		return count, err
+	}
+
+	// Commit the transaction
+	err = tx.Commit(ctx)
+	if err != nil {
+		return count, err
+	}
+	return int64(len(values)), nil
+}
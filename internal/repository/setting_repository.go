@@ -3,57 +3,80 @@ package repository
 import (
 	"context"
 	"errors"
+	"slices"
+	"time"
 
 	sq "github.com/Masterminds/squirrel"
 	"github.com/gofrs/uuid/v5"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 
+	"github.com/Intiqo/app-platform/internal/database"
 	"github.com/Intiqo/app-platform/internal/domain"
 )
 
+// settingQueryFields are the fields that settings can be filtered & sorted by.
+// Values aren't part of them, since the values of sensitive settings are encrypted.
+var settingQueryFields = queryFields{
+	"id":             "id",
+	"key":            "key",
+	"scope":          "scope",
+	"scopeId":        "scope_id",
+	"organizationId": "organization_id",
+	"sensitive":      "sensitive",
+	"version":        "version",
+	"createdAt":      "created_at",
+	"updatedAt":      "updated_at",
+}
+
+// settingDefaultSortKeys order the settings the same way as the cache, when no sort keys are given
+var settingDefaultSortKeys = []domain.SortKey{
+	{Field: "key"},
+	{Field: "scope"},
+	{Field: "scopeId"},
+	{Field: "id"},
+}
+
+// settingTable describes how settings are stored
+var settingTable = Table{
+	Name:              "settings",
+	InsertColumns:     []string{"id", "key", "value", "scope", "scope_id", "organization_id", "sensitive", "data_key_id"},
+	GeneratedColumns:  []string{"id", "version", "created_at", "updated_at"},
+	UpdateColumns:     []string{"key", "value", "sensitive", "data_key_id"},
+	Fields:            settingQueryFields,
+	DefaultSortKeys:   settingDefaultSortKeys,
+	SoftDelete:        true,
+	Versioned:         true,
+	OrganizationOwned: true,
+}
+
 type pgxSettingRepository struct {
-	db  *pgxpool.Pool
-	sqt sq.StatementBuilderType
+	db   *pgxpool.Pool
+	k    domain.SettingKeyring
+	base Base[domain.Setting]
 }
 
-// NewSettingRepository creates a new setting repository
-func NewSettingRepository(db *pgxpool.Pool) domain.SettingRepository {
+// NewSettingRepository creates a new setting repository.
+// The values of sensitive settings are encrypted with the keyring before they are stored, and decrypted when they are read.
+// Settings are found & filtered on the read replicas, outside of transactions.
+func NewSettingRepository(db *pgxpool.Pool, replicas *database.Replicas, k domain.SettingKeyring) domain.SettingRepository {
 	return &pgxSettingRepository{
-		db:  db,
-		sqt: sq.StatementBuilder.PlaceholderFormat(sq.Dollar),
+		db:   db,
+		k:    k,
+		base: NewBase[domain.Setting](db, replicas, settingTable),
 	}
 }
 
 func (r *pgxSettingRepository) FindByID(ctx context.Context, id uuid.UUID) (result domain.Setting, err error) {
-	// Check if the context has a transaction
-	if ctx == nil {
-		ctx = context.Background()
-	}
-	txVal := ctx.Value(TxKey)
-
-	// Construct the query
-	q := `SELECT * FROM settings WHERE id = $1 AND deleted_at IS NULL`
-	args := []interface{}{id}
-
-	// Execute the query
-	var rows pgx.Rows
-	if txVal != nil {
-		tx := txVal.(pgx.Tx)
-		rows, err = tx.Query(ctx, q, args...)
-	} else {
-		rows, err = r.db.Query(ctx, q, args...)
-	}
-	defer rows.Close()
+	// Find the setting
+	result, err = r.base.FindByID(ctx, id)
 	if err != nil {
 		return result, err
 	}
-	if rows == nil {
-		return result, domain.DataNotFoundError{}
-	}
 
-	// Collect the results
-	result, err = pgx.CollectOneRow(rows, pgx.RowToStructByNameLax[domain.Setting])
+	// Decrypt the value
+	result.Value, err = openSettingValue(ctx, r.k, result.Sensitive, result.Value, result.ID, result.DataKeyID)
 	if err != nil {
 		return result, err
 	}
@@ -62,249 +85,257 @@ func (r *pgxSettingRepository) FindByID(ctx context.Context, id uuid.UUID) (resu
 	return result, nil
 }
 
-func (r *pgxSettingRepository) Filter(ctx context.Context, in domain.FilterSettingsByCriteriaInput, opts domain.QueryOptions) (result []domain.Setting, total int64, err error) {
-	// Check if the context has a transaction
-	if ctx == nil {
-		ctx = context.Background()
-	}
-	txVal := ctx.Value(TxKey)
-
+func (r *pgxSettingRepository) Filter(ctx context.Context, in domain.FilterSettingsByCriteriaInput, opts domain.QueryOptions) (result []domain.Setting, page domain.PageInfo, err error) {
 	// Build the criteria
-	f := r.sqt
-
+	criteria := sq.And{}
 	if len(in.Keys) > 0 {
-		f = f.Where(sq.Eq{"key": in.Keys})
+		criteria = append(criteria, sq.Eq{"key": in.Keys})
+	}
+	if in.Scope != "" {
+		criteria = append(criteria, sq.Eq{"scope": in.Scope})
+	}
+	if in.ScopeID != nil {
+		criteria = append(criteria, sq.Eq{"scope_id": *in.ScopeID})
 	}
 
-	f = f.Where("deleted_at IS NULL")
-
-	// Build the count query
-	cb := f.Select("COUNT(*)").
-		From("settings")
-	cq, cargs, err := cb.ToSql()
+	// Filter the settings
+	result, page, err = r.base.Filter(ctx, criteria, opts)
 	if err != nil {
-		return result, total, err
+		return result, page, err
 	}
 
-	// Execute the count query
-	if txVal != nil {
-		tx := txVal.(pgx.Tx)
-		err = tx.QueryRow(ctx, cq, cargs...).Scan(&total)
-	} else {
-		err = r.db.QueryRow(ctx, cq, cargs...).Scan(&total)
-	}
-	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return result, total, nil
+	// Decrypt the values
+	for idx, v := range result {
+		result[idx].Value, err = openSettingValue(ctx, r.k, v.Sensitive, v.Value, v.ID, v.DataKeyID)
+		if err != nil {
+			return result, page, err
 		}
-		return result, total, err
 	}
 
-	// Build the query
-	qb := f.Select("*").
-		From("settings")
+	// Return the result
+	return result, page, nil
+}
 
-	if opts.Limit > 0 {
-		qb = qb.Limit(uint64(opts.Limit))
-	}
-	if opts.Offset > 0 {
-		qb = qb.Offset(uint64(opts.Offset))
-	}
-	dq, dargs, err := qb.ToSql()
+func (r *pgxSettingRepository) Create(ctx context.Context, entity *domain.Setting) (err error) {
+	// Encrypt the value
+	sealed, err := r.seal(ctx, entity)
 	if err != nil {
-		return result, total, err
+		return err
 	}
 
-	var rows pgx.Rows
-	if txVal != nil {
-		tx := txVal.(pgx.Tx)
-		rows, err = tx.Query(ctx, dq, dargs...)
-	} else {
-		rows, err = r.db.Query(ctx, dq, dargs...)
-	}
-	defer rows.Close()
+	// Create the setting
+	err = r.base.Create(ctx, &sealed)
 	if err != nil {
-		return result, total, err
-	}
-	if rows == nil {
-		return result, total, nil
-	}
-
-	// Collect the data into the result
-	result, err = pgx.CollectRows(rows, pgx.RowToStructByNameLax[domain.Setting])
-	if err != nil && errors.Is(err, pgx.ErrNoRows) {
-		return result, total, nil
+		return duplicateKeyError(err)
 	}
 
-	return result, total, err
+	// Return the result, keeping the value as it was given
+	sealed.Value = entity.Value
+	*entity = sealed
+	return nil
 }
 
-func (r *pgxSettingRepository) Create(ctx context.Context, entity *domain.Setting) (err error) {
-	// Check if the context has a transaction
-	if ctx == nil {
-		ctx = context.Background()
-	}
-	txVal := ctx.Value(TxKey)
-
-	// Construct the query
-	q := `INSERT INTO settings (key, value) VALUES ($1, $2) RETURNING id, created_at, updated_at`
-	args := []interface{}{entity.Key, entity.Value}
-
-	// Execute the query
-	var row pgx.Row
-	if txVal != nil {
-		tx := txVal.(pgx.Tx)
-		row = tx.QueryRow(ctx, q, args...)
-	} else {
-		row = r.db.QueryRow(ctx, q, args...)
+func (r *pgxSettingRepository) CreateMultiple(ctx context.Context, entities []*domain.Setting) (err error) {
+	// Encrypt the values
+	sealed, err := r.sealMultiple(ctx, entities)
+	if err != nil {
+		return err
 	}
 
-	// Collect the result
-	err = row.Scan(&entity.ID, &entity.CreatedAt, &entity.UpdatedAt)
+	// Create the settings
+	err = r.base.CreateMultiple(ctx, sealed)
 	if err != nil {
-		return err
+		return duplicateKeyError(err)
 	}
 
-	// Return the result
-	return err
+	// Return the result, keeping the values as they were given
+	unseal(entities, sealed)
+	return nil
 }
 
-func (r *pgxSettingRepository) CreateMultiple(ctx context.Context, entities []*domain.Setting) (err error) {
+func (r *pgxSettingRepository) CreateMissing(ctx context.Context, entities []*domain.Setting) (err error) {
 	// Check if the context has a transaction
 	if ctx == nil {
 		ctx = context.Background()
 	}
-	txVal := ctx.Value(TxKey)
+	q := querierFor(ctx, r.db)
+
+	// Encrypt the values
+	sealed, err := r.sealMultiple(ctx, entities)
+	if err != nil {
+		return err
+	}
 
 	// Create a batch
 	b := &pgx.Batch{}
 
 	// Add queries to the batch
-	q := `INSERT INTO settings (key, value) VALUES ($1, $2) RETURNING id, created_at, updated_at`
-	for idx, entity := range entities {
-		// Create the data
-		args := []interface{}{entity.Key, entity.Value}
-		b.Queue(q, args...).QueryRow(func(row pgx.Row) error {
-			return row.Scan(&entities[idx].ID, &entities[idx].CreatedAt, &entities[idx].UpdatedAt)
+	for idx, v := range sealed {
+		args := []interface{}{v.ID, v.Key, v.Value, v.Scope, v.ScopeID, v.OrganizationID, v.Sensitive, v.DataKeyID}
+		b.Queue(createMissingSettingQuery, args...).QueryRow(func(row pgx.Row) error {
+			err := row.Scan(&v.Version, &v.CreatedAt, &v.UpdatedAt)
+			if err != nil {
+				if errors.Is(err, pgx.ErrNoRows) {
+					return nil
+				}
+				return err
+			}
+
+			// Return the result, keeping the value as it was given
+			v.Value = entities[idx].Value
+			*entities[idx] = *v
+			return nil
 		})
 	}
 
-	// Execute the batch
-	if txVal != nil {
-		tx := txVal.(pgx.Tx)
-		err = tx.SendBatch(ctx, b).Close()
-	} else {
-		err = r.db.SendBatch(ctx, b).Close()
-	}
+	// Execute the batch, within the organization the caller acts for
+	return withinOrganization(ctx, q, func(q querier) error {
+		return q.SendBatch(ctx, b).Close()
+	})
+}
 
-	// Return the result
-	return err
+func (r *pgxSettingRepository) Restore(ctx context.Context, id uuid.UUID) (err error) {
+	// Restore the setting, which fails if its key has been used by another setting since
+	err = r.base.Restore(ctx, id)
+	if err != nil {
+		return duplicateKeyError(err)
+	}
+	return nil
 }
 
 func (r *pgxSettingRepository) Update(ctx context.Context, entity *domain.Setting) (err error) {
-	// Check if the context has a transaction
-	if ctx == nil {
-		ctx = context.Background()
-	}
-	txVal := ctx.Value(TxKey)
-
-	// Construct the query
-	q := `UPDATE settings SET key = $1, value = $2, updated_at = NOW() WHERE id = $3 RETURNING updated_at`
-	args := []interface{}{entity.Key, entity.Value, entity.ID}
-
-	// Execute the query
-	var row pgx.Row
-	if txVal != nil {
-		tx := txVal.(pgx.Tx)
-		row = tx.QueryRow(ctx, q, args...)
-	} else {
-		row = r.db.QueryRow(ctx, q, args...)
+	// Encrypt the value
+	sealed, err := r.seal(ctx, entity)
+	if err != nil {
+		return err
 	}
 
-	// Collect the result
-	err = row.Scan(&entity.UpdatedAt)
+	// Update the setting
+	err = r.base.Update(ctx, &sealed)
 	if err != nil {
-		return err
+		return duplicateKeyError(err)
 	}
 
-	// Return the result
-	return err
+	// Return the result, keeping the value as it was given
+	sealed.Value = entity.Value
+	*entity = sealed
+	return nil
 }
 
 func (r *pgxSettingRepository) UpdateMultiple(ctx context.Context, entities []*domain.Setting) (err error) {
-	// Check if the context has a transaction
-	if ctx == nil {
-		ctx = context.Background()
-	}
-	txVal := ctx.Value(TxKey)
-
-	// Create a batch
-	b := &pgx.Batch{}
-
-	// Add queries to the batch
-	q := `UPDATE settings SET key = $1, value = $2, updated_at = NOW() WHERE id = $3 RETURNING updated_at`
-	for idx, entity := range entities {
-		// Create the data
-		args := []interface{}{entity.Key, entity.Value, entity.ID}
-		b.Queue(q, args...).QueryRow(func(row pgx.Row) error {
-			return row.Scan(&entities[idx].UpdatedAt)
-		})
+	// Encrypt the values
+	sealed, err := r.sealMultiple(ctx, entities)
+	if err != nil {
+		return err
 	}
 
-	// Execute the batch
-	if txVal != nil {
-		tx := txVal.(pgx.Tx)
-		err = tx.SendBatch(ctx, b).Close()
-	} else {
-		err = r.db.SendBatch(ctx, b).Close()
+	// Update the settings
+	err = r.base.UpdateMultiple(ctx, sealed)
+	if err != nil {
+		return duplicateKeyError(err)
 	}
 
-	// Return the result
-	return err
+	// Return the result, keeping the values as they were given
+	unseal(entities, sealed)
+	return nil
 }
 
 func (r *pgxSettingRepository) DeleteByID(ctx context.Context, id uuid.UUID) (err error) {
+	return r.base.Delete(ctx, id)
+}
+
+func (r *pgxSettingRepository) DeleteByIDs(ctx context.Context, ids []uuid.UUID) (err error) {
+	return r.base.Delete(ctx, ids...)
+}
+
+func (r *pgxSettingRepository) Reencrypt(ctx context.Context, limit int) (count int64, err error) {
+	return reencrypt(ctx, r.db, r.k, "settings", "id", limit)
+}
+
+func (r *pgxSettingRepository) Purge(ctx context.Context, deletedBefore time.Time) (count int64, err error) {
 	// Check if the context has a transaction
 	if ctx == nil {
 		ctx = context.Background()
 	}
-	txVal := ctx.Value(TxKey)
-
-	// Construct the query
-	q := `UPDATE settings SET deleted_at = NOW() WHERE id = $1`
-	args := []interface{}{id}
-
-	// Execute the query
-	if txVal != nil {
-		tx := txVal.(pgx.Tx)
-		_, err = tx.Exec(ctx, q, args...)
-	} else {
-		_, err = r.db.Exec(ctx, q, args...)
-	}
+	q := querierFor(ctx, r.db)
 
-	// Return the result
-	return err
+	// Delete the revisions first since they reference the settings, within the organization the caller acts for
+	err = withinOrganization(ctx, q, func(q querier) error {
+		_, err := q.Exec(ctx, `DELETE FROM setting_revisions WHERE setting_id IN (SELECT id FROM settings WHERE deleted_at < $1)`, deletedBefore)
+		if err != nil {
+			return err
+		}
+		tag, err := q.Exec(ctx, `DELETE FROM settings WHERE deleted_at < $1`, deletedBefore)
+		if err != nil {
+			return err
+		}
+		count = tag.RowsAffected()
+		return nil
+	})
+	return count, err
 }
 
-func (r *pgxSettingRepository) DeleteByIDs(ctx context.Context, ids []uuid.UUID) (err error) {
-	// Check if the context has a transaction
-	if ctx == nil {
-		ctx = context.Background()
+// seal copies the setting with its value encrypted, ready to be stored
+func (r *pgxSettingRepository) seal(ctx context.Context, entity *domain.Setting) (result domain.Setting, err error) {
+	sealed, err := r.sealMultiple(ctx, []*domain.Setting{entity})
+	if err != nil {
+		return result, err
 	}
-	txVal := ctx.Value(TxKey)
-
-	// Construct the query
-	q := `UPDATE settings SET deleted_at = NOW() WHERE id = ANY($1)`
-	args := []interface{}{ids}
-
-	// Execute the query
-	if txVal != nil {
-		tx := txVal.(pgx.Tx)
-		_, err = tx.Exec(ctx, q, args...)
-	} else {
-		_, err = r.db.Exec(ctx, q, args...)
+	return *sealed[0], nil
+}
+
+// sealMultiple copies the settings with their values encrypted with the active data key, ready to be stored.
+// New settings are given their IDs up front, since their values are bound to them.
+func (r *pgxSettingRepository) sealMultiple(ctx context.Context, entities []*domain.Setting) (result []*domain.Setting, err error) {
+	// Find the active data key once for all the settings
+	sensitive := slices.ContainsFunc(entities, func(v *domain.Setting) bool { return v.Sensitive })
+	dataKeyID, err := activeKeyIDFor(ctx, r.k, sensitive)
+	if err != nil {
+		return result, err
 	}
 
-	// Return the result
+	// Encrypt the values
+	result = make([]*domain.Setting, 0, len(entities))
+	for _, entity := range entities {
+		sealed := *entity
+		if sealed.ID == uuid.Nil {
+			sealed.ID, err = uuid.NewV4()
+			if err != nil {
+				return result, err
+			}
+		}
+		sealed.Value, sealed.DataKeyID, err = sealSettingValue(ctx, r.k, dataKeyID, sealed.ID, sealed.Sensitive, sealed.Value)
+		if err != nil {
+			return result, err
+		}
+		result = append(result, &sealed)
+	}
+	return result, nil
+}
+
+// unseal copies the stored settings back into the given ones, keeping their values as they were given
+func unseal(entities []*domain.Setting, sealed []*domain.Setting) {
+	for idx, entity := range entities {
+		sealed[idx].Value = entity.Value
+		*entity = *sealed[idx]
+	}
+}
+
+// createMissingSettingQuery inserts a setting, unless there already is an active setting with the same key & scope.
+// The conflict target matches the settings_key_scope_unique_idx index.
+const createMissingSettingQuery = `INSERT INTO settings (id, key, value, scope, scope_id, organization_id, sensitive, data_key_id) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	ON CONFLICT (key, scope, COALESCE(scope_id, '00000000-0000-0000-0000-000000000000')) WHERE deleted_at IS NULL DO NOTHING
+	RETURNING version, created_at, updated_at`
+
+// duplicateKeyError reports the violation of the uniqueness of keys within their scopes as a user error.
+// Such violations slip past the checks of the service when settings are written concurrently.
+func duplicateKeyError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == "settings_key_scope_unique_idx" {
+		return domain.UserError{
+			Code:    domain.ErrorCodeINVALIDREQUEST,
+			Message: "A setting with the same key already exists for its scope",
+		}
+	}
 	return err
 }
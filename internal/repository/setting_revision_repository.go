@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"context"
+	"slices"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/gofrs/uuid/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/Intiqo/app-platform/internal/database"
+	"github.com/Intiqo/app-platform/internal/domain"
+)
+
+// settingRevisionQueryFields are the fields that setting revisions can be filtered & sorted by
+var settingRevisionQueryFields = queryFields{
+	"id":             "id",
+	"settingId":      "setting_id",
+	"key":            "key",
+	"scope":          "scope",
+	"scopeId":        "scope_id",
+	"organizationId": "organization_id",
+	"sensitive":      "sensitive",
+	"operation":      "operation",
+	"changedBy":      "changed_by",
+	"createdAt":      "created_at",
+}
+
+// settingRevisionDefaultSortKeys order the most recent revisions first, when no sort keys are given
+var settingRevisionDefaultSortKeys = []domain.SortKey{
+	{Field: "createdAt", Direction: domain.SortDirectionDesc},
+	{Field: "id", Direction: domain.SortDirectionDesc},
+}
+
+// settingRevisionTable describes how setting revisions are stored.
+// Revisions are never changed once they are recorded.
+var settingRevisionTable = Table{
+	Name:              "setting_revisions",
+	InsertColumns:     []string{"setting_id", "key", "value", "scope", "scope_id", "organization_id", "sensitive", "data_key_id", "operation", "changed_by"},
+	GeneratedColumns:  []string{"id", "created_at"},
+	Fields:            settingRevisionQueryFields,
+	DefaultSortKeys:   settingRevisionDefaultSortKeys,
+	OrganizationOwned: true,
+}
+
+type pgxSettingRevisionRepository struct {
+	db   *pgxpool.Pool
+	k    domain.SettingKeyring
+	base Base[domain.SettingRevision]
+}
+
+// NewSettingRevisionRepository creates a new setting revision repository.
+// The values of sensitive settings are encrypted with the keyring before they are stored, and decrypted when they are read.
+func NewSettingRevisionRepository(db *pgxpool.Pool, replicas *database.Replicas, k domain.SettingKeyring) domain.SettingRevisionRepository {
+	return &pgxSettingRevisionRepository{
+		db:   db,
+		k:    k,
+		base: NewBase[domain.SettingRevision](db, replicas, settingRevisionTable),
+	}
+}
+
+func (r *pgxSettingRevisionRepository) FindByID(ctx context.Context, id uuid.UUID) (result domain.SettingRevision, err error) {
+	// Find the revision
+	result, err = r.base.FindByID(ctx, id)
+	if err != nil {
+		return result, err
+	}
+
+	// Decrypt the value
+	result.Value, err = openSettingValue(ctx, r.k, result.Sensitive, result.Value, result.SettingID, result.DataKeyID)
+	if err != nil {
+		return result, err
+	}
+
+	// Return the result
+	return result, nil
+}
+
+func (r *pgxSettingRevisionRepository) Filter(ctx context.Context, in domain.FilterSettingRevisionsByCriteriaInput, opts domain.QueryOptions) (result []domain.SettingRevision, page domain.PageInfo, err error) {
+	// Build the criteria
+	criteria := sq.And{}
+	if in.Key != "" {
+		criteria = append(criteria, sq.Eq{"key": in.Key})
+	}
+	if in.SettingID != uuid.Nil {
+		criteria = append(criteria, sq.Eq{"setting_id": in.SettingID})
+	}
+
+	// Filter the revisions
+	result, page, err = r.base.Filter(ctx, criteria, opts)
+	if err != nil {
+		return result, page, err
+	}
+
+	// Decrypt the values
+	for idx, v := range result {
+		result[idx].Value, err = openSettingValue(ctx, r.k, v.Sensitive, v.Value, v.SettingID, v.DataKeyID)
+		if err != nil {
+			return result, page, err
+		}
+	}
+
+	// Return the result
+	return result, page, nil
+}
+
+func (r *pgxSettingRevisionRepository) CreateMultiple(ctx context.Context, entities []*domain.SettingRevision) (err error) {
+	// Encrypt the values with the active data key, bound to the settings they belong to
+	sensitive := slices.ContainsFunc(entities, func(v *domain.SettingRevision) bool { return v.Sensitive })
+	dataKeyID, err := activeKeyIDFor(ctx, r.k, sensitive)
+	if err != nil {
+		return err
+	}
+	sealed := make([]*domain.SettingRevision, 0, len(entities))
+	for _, entity := range entities {
+		v := *entity
+		v.Value, v.DataKeyID, err = sealSettingValue(ctx, r.k, dataKeyID, entity.SettingID, entity.Sensitive, entity.Value)
+		if err != nil {
+			return err
+		}
+		sealed = append(sealed, &v)
+	}
+
+	// Create the revisions
+	err = r.base.CreateMultiple(ctx, sealed)
+	if err != nil {
+		return err
+	}
+
+	// Return the result, keeping the values as they were given
+	for idx, entity := range entities {
+		sealed[idx].Value = entity.Value
+		*entity = *sealed[idx]
+	}
+	return nil
+}
+
+func (r *pgxSettingRevisionRepository) Reencrypt(ctx context.Context, limit int) (count int64, err error) {
+	return reencrypt(ctx, r.db, r.k, "setting_revisions", "setting_id", limit)
+}
@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/Intiqo/app-platform/internal/domain"
+)
+
+// tenantRole is the role that the row level security policies of the tables owned by organizations apply to
+const tenantRole = "app_tenant"
+
+// scopeToOrganization confines the transaction to the rows of the organization the caller acts for, unless it's the platform itself.
+// It sets app.org_id for the policies to match rows against, and switches to the tenant role for them to apply, both until the transaction ends.
+// Callers that act for no organization get an empty app.org_id, which only lets them see the global settings.
+func scopeToOrganization(ctx context.Context, tx pgx.Tx) (err error) {
+	if domain.PlatformFromContext(ctx) {
+		return nil
+	}
+	var organization string
+	if organizationID, ok := domain.OrganizationIDFromContext(ctx); ok {
+		organization = organizationID.String()
+	}
+
+	// Send both statements in a single round trip
+	b := &pgx.Batch{}
+	b.Queue(`SELECT set_config('app.org_id', $1, true)`, organization)
+	b.Queue(`SET LOCAL ROLE ` + tenantRole)
+	err = tx.SendBatch(ctx, b).Close()
+	if err != nil {
+		return fmt.Errorf("failed to scope the transaction to the organization: %w", err)
+	}
+	return nil
+}
+
+// withinOrganization runs fn with the querier, in a transaction scoped to the organization the caller acts for.
+// Transactions carried by the context are already scoped when they begin, and the platform itself needs no transaction,
+// so fn runs with the querier as is for both of them.
+func withinOrganization(ctx context.Context, q querier, fn func(q querier) error) (err error) {
+	if _, ok := q.(pgx.Tx); ok {
+		return fn(q)
+	}
+	if domain.PlatformFromContext(ctx) {
+		return fn(q)
+	}
+
+	// Begin a transaction on the same pool, so that reads still go to the replicas
+	tx, err := q.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			rollback(ctx, tx)
+		}
+	}()
+	err = scopeToOrganization(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	// Run the function & end the transaction
+	err = fn(tx)
+	if err != nil {
+		return err
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
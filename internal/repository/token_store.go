@@ -0,0 +1,240 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/gofrs/uuid/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/Intiqo/app-platform/internal/pkg/security"
+)
+
+// refreshTokenColumns are the columns a refresh token is read from, in the order they are scanned
+var refreshTokenColumns = []string{"id", "family_id", "user_id", "token_hash", "metadata", "auth_token_id", "auth_token_expires_at", "expires_at", "used_at", "revoked_at"}
+
+type pgxTokenStore struct {
+	db  *pgxpool.Pool
+	sqt sq.StatementBuilderType
+}
+
+// NewTokenStore creates a new store of the refresh tokens & the revoked auth tokens.
+// Tokens are always read from the primary, since they are checked right after they are written.
+func NewTokenStore(db *pgxpool.Pool) security.Store {
+	return &pgxTokenStore{
+		db:  db,
+		sqt: sq.StatementBuilder.PlaceholderFormat(sq.Dollar),
+	}
+}
+
+func (r *pgxTokenStore) CreateRefreshToken(ctx context.Context, token *security.RefreshToken) (err error) {
+	// Check if the context has a transaction
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	q := querierFor(ctx, r.db)
+
+	// Construct the query
+	metadata, err := json.Marshal(token.Metadata)
+	if err != nil {
+		return err
+	}
+	dq, dargs, err := r.sqt.Insert("refresh_tokens").
+		Columns("family_id", "user_id", "token_hash", "metadata", "auth_token_id", "auth_token_expires_at", "expires_at").
+		Values(token.FamilyID, token.UserID, token.Hash, metadata, token.AuthTokenID, token.AuthTokenExpiry, token.ExpiresAt).
+		Suffix("RETURNING id").
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	// Execute the query
+	return q.QueryRow(ctx, dq, dargs...).Scan(&token.ID)
+}
+
+func (r *pgxTokenStore) FindRefreshToken(ctx context.Context, hash string) (result security.RefreshToken, ok bool, err error) {
+	// Check if the context has a transaction
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	q := querierFor(ctx, r.db)
+
+	// Construct the query
+	dq, dargs, err := r.sqt.Select(refreshTokenColumns...).
+		From("refresh_tokens").
+		Where(sq.Eq{"token_hash": hash}).
+		ToSql()
+	if err != nil {
+		return result, false, err
+	}
+
+	// Execute the query
+	var metadata []byte
+	err = q.QueryRow(ctx, dq, dargs...).Scan(&result.ID, &result.FamilyID, &result.UserID, &result.Hash, &metadata,
+		&result.AuthTokenID, &result.AuthTokenExpiry, &result.ExpiresAt, &result.UsedAt, &result.RevokedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return result, false, nil
+	}
+	if err != nil {
+		return result, false, err
+	}
+	err = json.Unmarshal(metadata, &result.Metadata)
+	if err != nil {
+		return result, false, err
+	}
+	return result, true, nil
+}
+
+func (r *pgxTokenStore) RotateRefreshToken(ctx context.Context, used security.RefreshToken, next *security.RefreshToken) (ok bool, err error) {
+	// Check if the context has a transaction
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	q := querierFor(ctx, r.db)
+
+	// Construct the query, which only stores the next token if the used one wasn't used or revoked in the meantime.
+	// The select is numbered along with the outer query, so it keeps the default placeholders, and is typed for the insert.
+	metadata, err := json.Marshal(next.Metadata)
+	if err != nil {
+		return false, err
+	}
+	values := sq.Select().
+		Column("?::uuid", next.FamilyID).
+		Column("?::uuid", next.UserID).
+		Column("?::text", next.Hash).
+		Column("?::jsonb", metadata).
+		Column("?::text", next.AuthTokenID).
+		Column("?::timestamptz", next.AuthTokenExpiry).
+		Column("?::timestamptz", next.ExpiresAt).
+		From("used")
+	dq, dargs, err := r.sqt.Insert("refresh_tokens").
+		Prefix("WITH used AS (UPDATE refresh_tokens SET used_at = NOW() WHERE id = ? AND used_at IS NULL AND revoked_at IS NULL RETURNING id)", used.ID).
+		Columns("family_id", "user_id", "token_hash", "metadata", "auth_token_id", "auth_token_expires_at", "expires_at").
+		Select(values).
+		Suffix("RETURNING id").
+		ToSql()
+	if err != nil {
+		return false, err
+	}
+
+	// Execute the query
+	err = q.QueryRow(ctx, dq, dargs...).Scan(&next.ID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (r *pgxTokenStore) RevokeRefreshTokens(ctx context.Context, familyID uuid.UUID) (err error) {
+	return r.revoke(ctx, sq.Eq{"family_id": familyID})
+}
+
+func (r *pgxTokenStore) RevokeUserRefreshTokens(ctx context.Context, userID uuid.UUID) (err error) {
+	return r.revoke(ctx, sq.Eq{"user_id": userID})
+}
+
+// revoke revokes the refresh tokens meeting the condition, and denies the auth tokens issued with them that haven't expired
+func (r *pgxTokenStore) revoke(ctx context.Context, cond sq.Eq) (err error) {
+	// Check if the context has a transaction
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	q := querierFor(ctx, r.db)
+
+	// Construct the query.
+	// The update is numbered along with the outer query, so it keeps the default placeholders.
+	revoked, rargs, err := sq.Update("refresh_tokens").
+		Set("revoked_at", sq.Expr("COALESCE(revoked_at, NOW())")).
+		Where(cond).
+		Suffix("RETURNING auth_token_id, auth_token_expires_at").
+		ToSql()
+	if err != nil {
+		return err
+	}
+	dq, dargs, err := r.sqt.Insert("revoked_tokens").
+		Prefix("WITH revoked AS ("+revoked+")", rargs...).
+		Columns("token_id", "expires_at").
+		Select(sq.Select("auth_token_id", "auth_token_expires_at").From("revoked").Where("auth_token_expires_at > NOW()")).
+		Suffix("ON CONFLICT (token_id) DO NOTHING").
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	// Execute the query
+	_, err = q.Exec(ctx, dq, dargs...)
+	return err
+}
+
+func (r *pgxTokenStore) DenyAuthToken(ctx context.Context, tokenID string, expiry time.Time) (err error) {
+	// Check if the context has a transaction
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	q := querierFor(ctx, r.db)
+
+	// Construct the query
+	dq, dargs, err := r.sqt.Insert("revoked_tokens").
+		Columns("token_id", "expires_at").
+		Values(tokenID, expiry).
+		Suffix("ON CONFLICT (token_id) DO NOTHING").
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	// Execute the query
+	_, err = q.Exec(ctx, dq, dargs...)
+	return err
+}
+
+func (r *pgxTokenStore) IsAuthTokenDenied(ctx context.Context, tokenID string) (denied bool, err error) {
+	// Check if the context has a transaction
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	q := querierFor(ctx, r.db)
+
+	// Construct the query
+	dq, dargs, err := r.sqt.Select("1").
+		From("revoked_tokens").
+		Where(sq.Eq{"token_id": tokenID}).
+		Prefix("SELECT EXISTS (").
+		Suffix(")").
+		ToSql()
+	if err != nil {
+		return false, err
+	}
+
+	// Execute the query
+	err = q.QueryRow(ctx, dq, dargs...).Scan(&denied)
+	return denied, err
+}
+
+func (r *pgxTokenStore) PurgeExpiredTokens(ctx context.Context, before time.Time) (count int64, err error) {
+	// Check if the context has a transaction
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	q := querierFor(ctx, r.db)
+
+	// Delete the expired refresh tokens & denylist entries
+	for _, table := range []string{"refresh_tokens", "revoked_tokens"} {
+		dq, dargs, err := r.sqt.Delete(table).
+			Where(sq.Lt{"expires_at": before}).
+			ToSql()
+		if err != nil {
+			return count, err
+		}
+		tag, err := q.Exec(ctx, dq, dargs...)
+		if err != nil {
+			return count, err
+		}
+		count += tag.RowsAffected()
+	}
+	return count, nil
+}
@@ -3,8 +3,13 @@ package repository
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log/slog"
+	"math/rand/v2"
+	"time"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 
 	"github.com/Intiqo/app-platform/internal/domain"
@@ -14,8 +19,16 @@ type TxKeyType string
 
 const TxKey TxKeyType = "App-Transactioner"
 
+// defaultTxRetries is the number of times a transaction that fails to serialize is retried, unless told otherwise
+const defaultTxRetries = 3
+
+// txBeginner begins the transactions that the transactioner runs functions in
+type txBeginner interface {
+	BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error)
+}
+
 type transactioner struct {
-	db *pgxpool.Pool
+	db txBeginner
 }
 
 func NewTransactioner(db *pgxpool.Pool) domain.Transactioner {
@@ -25,7 +38,7 @@ func NewTransactioner(db *pgxpool.Pool) domain.Transactioner {
 }
 
 func (t *transactioner) Begin(ctx context.Context) (result context.Context, err error) {
-	tx, err := t.db.Begin(ctx)
+	tx, err := t.begin(ctx, pgx.TxOptions{})
 	if err != nil {
 		return result, err
 	}
@@ -34,7 +47,7 @@ func (t *transactioner) Begin(ctx context.Context) (result context.Context, err
 }
 
 func (t *transactioner) Commit(ctx context.Context) (err error) {
-	tx, ok := ctx.Value(TxKey).(*pgxpool.Tx)
+	tx, ok := ctx.Value(TxKey).(pgx.Tx)
 	if !ok {
 		return ErrTransactionNotFound
 	}
@@ -45,14 +58,118 @@ func (t *transactioner) Rollback(ctx context.Context, err error) {
 	if err == nil {
 		return
 	}
-	tx, ok := ctx.Value(TxKey).(*pgxpool.Tx)
+	tx, ok := ctx.Value(TxKey).(pgx.Tx)
 	if !ok {
 		slog.Error("no transaction found")
+		return
 	}
 	err = tx.Rollback(ctx)
+	if err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+		slog.Error("failed to rollback transaction", "error", err)
+	}
+}
+
+func (t *transactioner) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error, opts ...domain.TxOptions) (err error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var o domain.TxOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+
+	// Nest the function within a savepoint of the transaction carried by the context.
+	// It can't be retried on its own, since a serialization failure aborts the whole transaction.
+	if tx, ok := ctx.Value(TxKey).(pgx.Tx); ok {
+		return t.run(ctx, tx.Begin, fn)
+	}
+
+	// Run the function in a new transaction, retrying it as long as it fails to serialize
+	txOpts := pgx.TxOptions{
+		IsoLevel: pgx.TxIsoLevel(o.IsolationLevel),
+	}
+	if o.ReadOnly {
+		txOpts.AccessMode = pgx.ReadOnly
+	}
+	retries := o.MaxRetries
+	if retries == 0 {
+		retries = defaultTxRetries
+	}
+	begin := func(ctx context.Context) (pgx.Tx, error) {
+		return t.begin(ctx, txOpts)
+	}
+	for attempt := 0; ; attempt++ {
+		err = t.run(ctx, begin, fn)
+		if !isSerializationFailure(err) || attempt >= retries {
+			return err
+		}
+		slog.Warn("retrying transaction that failed to serialize", "attempt", attempt+1, "error", err)
+
+		// Back off for a while, so that the transactions that conflicted don't run into each other again
+		backoff := time.Duration(rand.Int64N(int64(10*time.Millisecond) << attempt))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+	}
+}
+
+// begin begins a transaction, scoped to the organization the caller acts for unless it's the platform itself.
+// Savepoints aren't scoped on their own, since they are part of a transaction that already is.
+func (t *transactioner) begin(ctx context.Context, txOpts pgx.TxOptions) (tx pgx.Tx, err error) {
+	tx, err = t.db.BeginTx(ctx, txOpts)
+	if err != nil {
+		return tx, err
+	}
+	err = scopeToOrganization(ctx, tx)
+	if err != nil {
+		rollback(ctx, tx)
+		return nil, err
+	}
+	return tx, nil
+}
+
+// run runs the function in the transaction started by begin, committing it if the function succeeds and rolling it back otherwise.
+// The transaction is rolled back if the function panics, and the panic carries on.
+func (t *transactioner) run(ctx context.Context, begin func(ctx context.Context) (pgx.Tx, error), fn func(ctx context.Context) error) (err error) {
+	tx, err := begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			rollback(ctx, tx)
+			panic(p)
+		}
+		if err != nil {
+			rollback(ctx, tx)
+		}
+	}()
+
+	err = fn(context.WithValue(ctx, TxKey, tx))
 	if err != nil {
+		return err
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// rollback rolls back the transaction, logging the failure to do so
+func rollback(ctx context.Context, tx pgx.Tx) {
+	err := tx.Rollback(ctx)
+	if err != nil && !errors.Is(err, pgx.ErrTxClosed) {
 		slog.Error("failed to rollback transaction", "error", err)
 	}
 }
 
+// isSerializationFailure reports whether the transaction failed because it couldn't be serialized with concurrent transactions
+func isSerializationFailure(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "40001"
+}
+
 var ErrTransactionNotFound = errors.New("no transaction found")
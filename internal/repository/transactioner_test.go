@@ -0,0 +1,220 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/Intiqo/app-platform/internal/domain"
+)
+
+// fakeTx records what happens to a transaction, or to a savepoint when it has a parent
+type fakeTx struct {
+	pgx.Tx
+	parent *fakeTx
+	// commitErr is returned by Commit, as when the transaction fails to serialize on commit
+	commitErr error
+
+	savepoints []*fakeTx
+	committed  bool
+	rolledBack bool
+}
+
+func (tx *fakeTx) Begin(ctx context.Context) (pgx.Tx, error) {
+	savepoint := &fakeTx{parent: tx}
+	tx.savepoints = append(tx.savepoints, savepoint)
+	return savepoint, nil
+}
+
+func (tx *fakeTx) Commit(ctx context.Context) error {
+	if tx.committed || tx.rolledBack {
+		return pgx.ErrTxClosed
+	}
+	tx.committed = true
+	return tx.commitErr
+}
+
+func (tx *fakeTx) Rollback(ctx context.Context) error {
+	if tx.committed || tx.rolledBack {
+		return pgx.ErrTxClosed
+	}
+	tx.rolledBack = true
+	return nil
+}
+
+// fakeDB begins fake transactions, failing to commit the first ones with the given errors
+type fakeDB struct {
+	commitErrs []error
+
+	txs  []*fakeTx
+	opts []pgx.TxOptions
+}
+
+func (db *fakeDB) BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error) {
+	tx := &fakeTx{}
+	if len(db.txs) < len(db.commitErrs) {
+		tx.commitErr = db.commitErrs[len(db.txs)]
+	}
+	db.txs = append(db.txs, tx)
+	db.opts = append(db.opts, txOptions)
+	return tx, nil
+}
+
+// serializationFailure is the error that a transaction fails with when it can't be serialized with concurrent ones
+var serializationFailure = &pgconn.PgError{Code: "40001"}
+
+func TestWithinTransaction(t *testing.T) {
+	// Act as the platform, so that the transactions aren't scoped to an organization
+	ctx := domain.ContextAsPlatform(context.Background())
+
+	t.Run("success - commit the transaction", func(t *testing.T) {
+		db := &fakeDB{}
+		tr := &transactioner{db: db}
+		err := tr.WithinTransaction(ctx, func(ctx context.Context) error {
+			if _, ok := ctx.Value(TxKey).(pgx.Tx); !ok {
+				t.Errorf("Expected the context to carry the transaction")
+			}
+			return nil
+		}, domain.TxOptions{IsolationLevel: domain.TxIsolationLevelSerializable, ReadOnly: true})
+		if err != nil {
+			t.Fatalf("Error running the transaction: %v", err)
+		}
+		if len(db.txs) != 1 || !db.txs[0].committed {
+			t.Errorf("Expected a single transaction to be committed, got %d", len(db.txs))
+		}
+		if db.opts[0].IsoLevel != pgx.Serializable || db.opts[0].AccessMode != pgx.ReadOnly {
+			t.Errorf("Expected the transaction to be serializable & read only, got %v", db.opts[0])
+		}
+	})
+
+	t.Run("success - roll back only the savepoint of a nested function that fails", func(t *testing.T) {
+		db := &fakeDB{}
+		tr := &transactioner{db: db}
+		innerErr := errors.New("inner failure")
+		err := tr.WithinTransaction(ctx, func(ctx context.Context) error {
+			err := tr.WithinTransaction(ctx, func(ctx context.Context) error {
+				return innerErr
+			})
+			if !errors.Is(err, innerErr) {
+				t.Errorf("Expected the nested function to fail with %v, got %v", innerErr, err)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Error running the transaction: %v", err)
+		}
+		if len(db.txs) != 1 {
+			t.Fatalf("Expected the nested function to run in the same transaction, got %d transactions", len(db.txs))
+		}
+		tx := db.txs[0]
+		if len(tx.savepoints) != 1 || !tx.savepoints[0].rolledBack || tx.savepoints[0].committed {
+			t.Errorf("Expected the savepoint to be rolled back")
+		}
+		if !tx.committed || tx.rolledBack {
+			t.Errorf("Expected the transaction to be committed")
+		}
+	})
+
+	t.Run("success - don't retry a nested function that fails to serialize", func(t *testing.T) {
+		db := &fakeDB{}
+		tr := &transactioner{db: db}
+		err := tr.WithinTransaction(ctx, func(ctx context.Context) error {
+			return tr.WithinTransaction(ctx, func(ctx context.Context) error {
+				return serializationFailure
+			})
+		}, domain.TxOptions{MaxRetries: -1})
+		if !errors.Is(err, serializationFailure) {
+			t.Fatalf("Expected the serialization failure, got %v", err)
+		}
+		if len(db.txs) != 1 || len(db.txs[0].savepoints) != 1 {
+			t.Errorf("Expected the nested function to run once, got %d transactions", len(db.txs))
+		}
+	})
+
+	t.Run("success - roll back the transaction and carry on panicking", func(t *testing.T) {
+		db := &fakeDB{}
+		tr := &transactioner{db: db}
+		defer func() {
+			p := recover()
+			if p != "panic" {
+				t.Errorf("Expected the panic to carry on, got %v", p)
+			}
+			if len(db.txs) != 1 || !db.txs[0].rolledBack || db.txs[0].committed {
+				t.Errorf("Expected the transaction to be rolled back")
+			}
+		}()
+		_ = tr.WithinTransaction(ctx, func(ctx context.Context) error {
+			panic("panic")
+		})
+		t.Errorf("Expected the function to panic")
+	})
+
+	t.Run("success - retry the transaction as long as it fails to serialize", func(t *testing.T) {
+		for _, v := range []struct {
+			name         string
+			maxRetries   int
+			failures     int
+			attemptsWant int
+			errWant      bool
+		}{
+			{name: "default retries", maxRetries: 0, failures: 10, attemptsWant: defaultTxRetries + 1, errWant: true},
+			{name: "more failures than retries", maxRetries: 2, failures: 10, attemptsWant: 3, errWant: true},
+			{name: "fewer failures than retries", maxRetries: 2, failures: 1, attemptsWant: 2, errWant: false},
+			{name: "no retries", maxRetries: -1, failures: 10, attemptsWant: 1, errWant: true},
+		} {
+			db := &fakeDB{}
+			tr := &transactioner{db: db}
+			attempts := 0
+			err := tr.WithinTransaction(ctx, func(ctx context.Context) error {
+				attempts++
+				if attempts <= v.failures {
+					return serializationFailure
+				}
+				return nil
+			}, domain.TxOptions{MaxRetries: v.maxRetries})
+			if attempts != v.attemptsWant || len(db.txs) != v.attemptsWant {
+				t.Errorf("Expected %d attempts with %s, got %d", v.attemptsWant, v.name, attempts)
+			}
+			if (err != nil) != v.errWant {
+				t.Errorf("Expected an error with %s: %t, got %v", v.name, v.errWant, err)
+			}
+			for idx, tx := range db.txs {
+				if failed := idx < v.failures; tx.rolledBack != failed || tx.committed == failed {
+					t.Errorf("Expected attempt %d with %s to be rolled back: %t", idx+1, v.name, failed)
+				}
+			}
+		}
+	})
+
+	t.Run("success - retry the transaction when it fails to serialize on commit", func(t *testing.T) {
+		db := &fakeDB{commitErrs: []error{serializationFailure}}
+		tr := &transactioner{db: db}
+		err := tr.WithinTransaction(ctx, func(ctx context.Context) error {
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Error running the transaction: %v", err)
+		}
+		if len(db.txs) != 2 || !db.txs[1].committed {
+			t.Errorf("Expected the transaction to be committed on the second attempt, got %d attempts", len(db.txs))
+		}
+	})
+
+	t.Run("failure - don't retry other failures", func(t *testing.T) {
+		db := &fakeDB{}
+		tr := &transactioner{db: db}
+		failure := &pgconn.PgError{Code: "23505"}
+		err := tr.WithinTransaction(ctx, func(ctx context.Context) error {
+			return failure
+		})
+		if !errors.Is(err, failure) {
+			t.Fatalf("Expected the failure, got %v", err)
+		}
+		if len(db.txs) != 1 || !db.txs[0].rolledBack {
+			t.Errorf("Expected a single transaction to be rolled back, got %d", len(db.txs))
+		}
+	})
+}
@@ -0,0 +1,176 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a parsed cron expression
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+	// domAny & dowAny record if the days of the month & week are unrestricted,
+	// since a day matches either of them when both are restricted
+	domAny, dowAny bool
+}
+
+// descriptors are the shorthands that can be used instead of the five fields
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// field describes the values that a field of a cron expression takes
+type field struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var (
+	minuteField = field{name: "minute", min: 0, max: 59}
+	hourField   = field{name: "hour", min: 0, max: 23}
+	domField    = field{name: "day of month", min: 1, max: 31}
+	monthField  = field{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6, "jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	// Sunday is both 0 and 7
+	dowField = field{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+// Parse parses a standard cron expression with five fields (minute, hour, day of month, month & day of week),
+// or one of the @yearly, @monthly, @weekly, @daily & @hourly shorthands.
+// Fields take *, values, ranges (a-b), steps (*/n or a-b/n) and comma separated lists of them.
+func Parse(spec string) (result Schedule, err error) {
+	spec = strings.TrimSpace(spec)
+	if v, ok := descriptors[strings.ToLower(spec)]; ok {
+		spec = v
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return result, fmt.Errorf("invalid cron expression %q: expected 5 fields, got %d", spec, len(fields))
+	}
+
+	for i, v := range []struct {
+		f    field
+		bits *uint64
+	}{
+		{minuteField, &result.minute},
+		{hourField, &result.hour},
+		{domField, &result.dom},
+		{monthField, &result.month},
+		{dowField, &result.dow},
+	} {
+		*v.bits, err = v.f.parse(fields[i])
+		if err != nil {
+			return result, fmt.Errorf("invalid cron expression %q: %w", spec, err)
+		}
+	}
+	if result.dow&(1<<7) != 0 {
+		result.dow |= 1
+	}
+	result.domAny = strings.HasPrefix(fields[2], "*")
+	result.dowAny = strings.HasPrefix(fields[4], "*")
+	return result, nil
+}
+
+// parse parses a field into a bit set of the values it matches
+func (f field) parse(s string) (result uint64, err error) {
+	for _, part := range strings.Split(s, ",") {
+		// Split the step off
+		rng, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			rng = part[:i]
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step <= 0 {
+				return result, fmt.Errorf("invalid step in the %s field: %q", f.name, part)
+			}
+		}
+
+		// Work out the range
+		lo, hi := f.min, f.max
+		switch {
+		case rng == "*":
+		case strings.Contains(rng, "-"):
+			bounds := strings.SplitN(rng, "-", 2)
+			lo, err = f.value(bounds[0])
+			if err != nil {
+				return result, err
+			}
+			hi, err = f.value(bounds[1])
+			if err != nil {
+				return result, err
+			}
+		default:
+			lo, err = f.value(rng)
+			if err != nil {
+				return result, err
+			}
+			// A single value with a step runs from that value to the end of the range
+			if step == 1 {
+				hi = lo
+			}
+		}
+		if lo > hi {
+			return result, fmt.Errorf("invalid range in the %s field: %q", f.name, part)
+		}
+
+		for v := lo; v <= hi; v += step {
+			result |= 1 << v
+		}
+	}
+	return result, nil
+}
+
+// value parses a value of the field, by number or by name
+func (f field) value(s string) (result int, err error) {
+	if v, ok := f.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	result, err = strconv.Atoi(s)
+	if err != nil || result < f.min || result > f.max {
+		return result, fmt.Errorf("invalid value in the %s field: %q, expected %d-%d", f.name, s, f.min, f.max)
+	}
+	return result, nil
+}
+
+// Next returns the first time after t that matches the schedule, to the minute, in the location of t.
+// It returns the zero time if nothing matches within the next five years, e.g. for the 30th of February.
+func (s Schedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case s.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case s.hour&(1<<uint(t.Hour())) == 0:
+			t = t.Truncate(time.Hour).Add(time.Hour)
+		case s.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// dayMatches checks if the day of t matches the schedule.
+// When both the day of the month & the day of the week are restricted, a day matching either of them matches.
+func (s Schedule) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domAny || s.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
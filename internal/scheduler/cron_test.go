@@ -0,0 +1,49 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSchedule(t *testing.T) {
+	// Saturday, 18th of October 2025
+	from := time.Date(2025, time.October, 18, 10, 30, 15, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		spec     string
+		expected time.Time
+	}{
+		{"success - every minute", "* * * * *", time.Date(2025, time.October, 18, 10, 31, 0, 0, time.UTC)},
+		{"success - every day at 3 AM", "0 3 * * *", time.Date(2025, time.October, 19, 3, 0, 0, 0, time.UTC)},
+		{"success - every 15 minutes", "*/15 * * * *", time.Date(2025, time.October, 18, 10, 45, 0, 0, time.UTC)},
+		{"success - weekdays by name", "0 9 * * mon-fri", time.Date(2025, time.October, 20, 9, 0, 0, 0, time.UTC)},
+		{"success - sunday as 7", "0 0 * * 7", time.Date(2025, time.October, 19, 0, 0, 0, 0, time.UTC)},
+		{"success - list of hours", "0 8,12 * * *", time.Date(2025, time.October, 18, 12, 0, 0, 0, time.UTC)},
+		{"success - shorthand", "@monthly", time.Date(2025, time.November, 1, 0, 0, 0, 0, time.UTC)},
+		{"success - day of month or day of week", "0 0 1 * mon", time.Date(2025, time.October, 20, 0, 0, 0, 0, time.UTC)},
+		{"success - leap day", "0 0 29 feb *", time.Date(2028, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{"success - no matching day", "0 0 30 feb *", time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(tt.spec)
+			if err != nil {
+				t.Fatalf("Error parsing %q: %v", tt.spec, err)
+			}
+			got := s.Next(from)
+			if !got.Equal(tt.expected) {
+				t.Errorf("Expected the next run of %q to be at %s, got %s", tt.spec, tt.expected, got)
+			}
+		})
+	}
+
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "5-1 * * * *", "*/0 * * * *", "* * * foo *"} {
+		t.Run("failure - invalid expression "+spec, func(t *testing.T) {
+			_, err := Parse(spec)
+			if err == nil {
+				t.Errorf("Expected an error parsing %q", spec)
+			}
+		})
+	}
+}
@@ -0,0 +1,279 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Intiqo/app-platform/internal/domain"
+)
+
+// leaderLockKey is the key of the lock held by the instance that runs the scheduled tasks
+const leaderLockKey = "app-platform:scheduler"
+
+// tickPeriod is how often the tasks are checked for runs that are due
+const tickPeriod = time.Second
+
+// electionPeriod is how often the instances that aren't leading try to take the lead
+const electionPeriod = 5 * time.Second
+
+// heartbeatPeriod is how often the leader checks that it still holds the lock
+const heartbeatPeriod = 15 * time.Second
+
+// Task is a task run on a schedule
+type Task func(ctx context.Context) (err error)
+
+// Info describes a task registered with the scheduler
+type Info struct {
+	Name     string
+	Schedule string
+	// Next is the next time the task is due to run
+	Next time.Time
+}
+
+// entry is a task registered with the scheduler
+type entry struct {
+	name     string
+	spec     string
+	schedule Schedule
+	task     Task
+	next     time.Time
+	running  bool
+}
+
+// Scheduler runs tasks on cron schedules.
+// Every instance registers the same tasks, and the one that holds the leader lock runs them, so that every run happens once.
+type Scheduler struct {
+	r        domain.ScheduledRunRepository
+	l        domain.Locker
+	instance string
+
+	mu      sync.Mutex
+	entries map[string]*entry
+	leader  domain.Lock
+
+	running sync.WaitGroup
+}
+
+// NewScheduler creates a new scheduler and starts electing the leader that runs the tasks.
+// The returned cleanup function stops running the tasks, waits for the running ones to return, and gives up the lead.
+func NewScheduler(r domain.ScheduledRunRepository, l domain.Locker) (*Scheduler, func(), error) {
+	instance, err := os.Hostname()
+	if err != nil {
+		return nil, nil, err
+	}
+	s := &Scheduler{
+		r:        r,
+		l:        l,
+		instance: instance,
+		entries:  make(map[string]*entry),
+	}
+
+	// Run the tasks that are due, while leading
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.loop(ctx)
+	}()
+	cleanup := func() {
+		cancel()
+		<-done
+		s.running.Wait()
+		s.resign()
+	}
+
+	return s, cleanup, nil
+}
+
+// Register registers a task to run on the cron schedule, replacing any task registered with the same name
+func (s *Scheduler) Register(name string, spec string, task Task) (err error) {
+	schedule, err := Parse(spec)
+	if err != nil {
+		return fmt.Errorf("failed to register the task %s: %w", name, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[name] = &entry{
+		name:     name,
+		spec:     spec,
+		schedule: schedule,
+		task:     task,
+		next:     schedule.Next(time.Now()),
+	}
+	return nil
+}
+
+// Tasks describes the tasks registered with the scheduler, by name
+func (s *Scheduler) Tasks() (result []Info) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	result = make([]Info, 0, len(s.entries))
+	for _, v := range s.entries {
+		result = append(result, Info{
+			Name:     v.name,
+			Schedule: v.spec,
+			Next:     v.schedule.Next(now),
+		})
+	}
+	slices.SortFunc(result, func(a, b Info) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return result
+}
+
+// IsLeader checks if this instance is the one running the tasks
+func (s *Scheduler) IsLeader() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.leader != nil
+}
+
+// loop takes the lead when it is free, and runs the tasks that are due while leading, until the context is done
+func (s *Scheduler) loop(ctx context.Context) {
+	ticker := time.NewTicker(tickPeriod)
+	defer ticker.Stop()
+	var lastElection, lastHeartbeat time.Time
+	for {
+		now := time.Now()
+		switch {
+		case !s.IsLeader():
+			if now.Sub(lastElection) >= electionPeriod {
+				lastElection = now
+				s.elect(ctx)
+			}
+		case now.Sub(lastHeartbeat) >= heartbeatPeriod:
+			lastHeartbeat = now
+			s.heartbeat(ctx)
+		}
+		if s.IsLeader() {
+			s.runDue(ctx, now)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// elect tries to take the lead
+func (s *Scheduler) elect(ctx context.Context) {
+	lock, ok, err := s.l.TryLock(ctx, leaderLockKey)
+	if err != nil {
+		if ctx.Err() == nil {
+			slog.Error("failed to take the lead of the scheduler", "error", err)
+		}
+		return
+	}
+	if !ok {
+		return
+	}
+
+	// Start from the runs due from now on, rather than catching up with the ones missed while no one was leading
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.leader = lock
+	now := time.Now()
+	for _, v := range s.entries {
+		v.next = v.schedule.Next(now)
+	}
+	slog.Info("took the lead of the scheduler", "instance", s.instance)
+}
+
+// heartbeat gives up the lead if the lock was lost along with the connection to the database
+func (s *Scheduler) heartbeat(ctx context.Context) {
+	s.mu.Lock()
+	lock := s.leader
+	s.mu.Unlock()
+	if lock == nil || lock.Held(ctx) || ctx.Err() != nil {
+		return
+	}
+	slog.Warn("lost the lead of the scheduler", "instance", s.instance)
+	s.resign()
+}
+
+// resign gives up the lead
+func (s *Scheduler) resign() {
+	s.mu.Lock()
+	lock := s.leader
+	s.leader = nil
+	s.mu.Unlock()
+	if lock != nil {
+		lock.Release()
+	}
+}
+
+// runDue starts the tasks that are due, unless their previous run is still going
+func (s *Scheduler) runDue(ctx context.Context, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, v := range s.entries {
+		if v.next.IsZero() || now.Before(v.next) || v.running {
+			continue
+		}
+		scheduledAt := v.next
+		v.next = v.schedule.Next(now)
+		v.running = true
+		s.running.Add(1)
+		go func() {
+			defer func() {
+				s.mu.Lock()
+				v.running = false
+				s.mu.Unlock()
+				s.running.Done()
+			}()
+			s.run(ctx, v.name, v.task, scheduledAt)
+		}()
+	}
+}
+
+// run runs a task for the time it was due, and records the run, unless another instance already ran it
+func (s *Scheduler) run(ctx context.Context, name string, task Task, scheduledAt time.Time) {
+	run := domain.ScheduledRun{
+		Task:        name,
+		ScheduledAt: scheduledAt,
+		Instance:    s.instance,
+	}
+	started, err := s.r.Start(ctx, &run)
+	if err != nil {
+		slog.Error("failed to start scheduled task", "task", name, "error", err)
+		return
+	}
+	if !started {
+		return
+	}
+
+	// Run the task, recording the outcome even if the scheduler is stopped in the meantime
+	err = safeRun(ctx, task)
+	run.Status = domain.ScheduledRunStatusSucceeded
+	if err != nil {
+		slog.Error("scheduled task failed", "task", name, "error", err)
+		reason := err.Error()
+		run.Status = domain.ScheduledRunStatusFailed
+		run.Error = &reason
+	}
+	err = s.r.Finish(context.WithoutCancel(ctx), &run)
+	if err != nil {
+		slog.Error("failed to record the run of a scheduled task", "task", name, "error", err)
+	}
+}
+
+// safeRun runs the task, turning a panic into an error
+func safeRun(ctx context.Context, task Task) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("task panicked: %v\n%s", p, debug.Stack())
+		}
+	}()
+	return task(ctx)
+}
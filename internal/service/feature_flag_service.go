@@ -0,0 +1,181 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/gofrs/uuid/v5"
+
+	"github.com/Intiqo/app-platform/internal/domain"
+)
+
+type appFeatureFlagService struct {
+	s domain.SettingService
+}
+
+// NewFeatureFlagService creates a new feature flag service, backed by settings
+func NewFeatureFlagService(s domain.SettingService) domain.FeatureFlagService {
+	return &appFeatureFlagService{
+		s: s,
+	}
+}
+
+func (f *appFeatureFlagService) IsEnabled(ctx context.Context, flag string) (result bool) {
+	key := domain.FeatureFlagKeyPrefix + flag
+
+	// The kill switch of the global rules can't be overridden, so check it first
+	settings, _, err := f.s.Filter(ctx, domain.FilterSettingsByCriteriaInput{Keys: []string{key}, Scope: domain.SettingScopeGlobal}, domain.QueryOptions{})
+	if err != nil {
+		slog.Error("failed to find feature flag", "flag", flag, "error", err)
+		return false
+	}
+	for _, v := range settings {
+		var rules domain.FeatureFlagRules
+		err = json.Unmarshal([]byte(v.Value), &rules)
+		if err != nil {
+			slog.Error("failed to decode feature flag", "flag", flag, "error", err)
+			return false
+		}
+		if rules.KillSwitch {
+			return false
+		}
+	}
+
+	// Find the rules that apply to the caller
+	setting, err := f.s.Resolve(ctx, key)
+	if err != nil {
+		if !errors.Is(err, domain.DataNotFoundError{}) {
+			slog.Error("failed to resolve feature flag", "flag", flag, "error", err)
+		}
+		return false
+	}
+	var rules domain.FeatureFlagRules
+	err = json.Unmarshal([]byte(setting.Value), &rules)
+	if err != nil {
+		slog.Error("failed to decode feature flag", "flag", flag, "error", err)
+		return false
+	}
+
+	// Evaluate the rules
+	claims, _ := domain.ClaimsFromContext(ctx)
+	return evaluateFeatureFlag(flag, rules, claims)
+}
+
+func (f *appFeatureFlagService) Evaluate(ctx context.Context) (result map[string]bool, err error) {
+	// Find the flags
+	flags, err := f.FindAll(ctx)
+	if err != nil {
+		return result, err
+	}
+
+	// Evaluate the flags, taking the overrides that apply to the caller into account
+	result = make(map[string]bool, len(flags))
+	for _, v := range flags {
+		result[v.Name] = f.IsEnabled(ctx, v.Name)
+	}
+	return result, nil
+}
+
+func (f *appFeatureFlagService) FindAll(ctx context.Context) (result []domain.FeatureFlag, err error) {
+	// Find the global settings holding the flags
+	settings, _, err := f.s.Filter(ctx, domain.FilterSettingsByCriteriaInput{Scope: domain.SettingScopeGlobal}, domain.QueryOptions{})
+	if err != nil {
+		return result, err
+	}
+
+	// Decode the flags
+	result = make([]domain.FeatureFlag, 0)
+	for _, v := range settings {
+		name, ok := strings.CutPrefix(v.Key, domain.FeatureFlagKeyPrefix)
+		if !ok {
+			continue
+		}
+		flag := domain.FeatureFlag{
+			Name: name,
+		}
+		err = json.Unmarshal([]byte(v.Value), &flag.FeatureFlagRules)
+		if err != nil {
+			slog.Error("failed to decode feature flag", "flag", name, "error", err)
+			continue
+		}
+		result = append(result, flag)
+	}
+	return result, nil
+}
+
+func (f *appFeatureFlagService) Save(ctx context.Context, name string, in domain.FeatureFlagRules) (result domain.FeatureFlag, err error) {
+	// Validate the name
+	if !domain.FeatureFlagNamePattern.MatchString(name) {
+		return result, domain.UserError{
+			Code:    domain.ErrorCodeINVALIDREQUEST,
+			Message: fmt.Sprintf("The name of a feature flag must match %s", domain.FeatureFlagNamePattern),
+		}
+	}
+
+	// Encode the rules
+	value, err := json.Marshal(in)
+	if err != nil {
+		return result, err
+	}
+
+	// Create or replace the setting holding the flag, in a single transaction
+	_, err = f.s.Import(ctx, domain.ImportSettingsInput{
+		SettingExport: domain.SettingExport{
+			Settings: []domain.SettingExportItem{
+				{
+					Key:   domain.FeatureFlagKeyPrefix + name,
+					Value: string(value),
+					Scope: domain.SettingScopeGlobal,
+				},
+			},
+		},
+	})
+	if err != nil {
+		return result, err
+	}
+
+	return domain.FeatureFlag{
+		Name:             name,
+		FeatureFlagRules: in,
+	}, nil
+}
+
+// evaluateFeatureFlag checks if the rules of a flag enable it for the caller
+func evaluateFeatureFlag(flag string, rules domain.FeatureFlagRules, claims domain.Claims) bool {
+	// The kill switch overrides every other rule
+	if rules.KillSwitch {
+		return false
+	}
+
+	// Enable the flag for the targeted roles & organizations
+	if claims.Role != "" && slices.Contains(rules.Roles, claims.Role) {
+		return true
+	}
+	if claims.OrganizationID != uuid.Nil && slices.Contains(rules.OrganizationIDs, claims.OrganizationID) {
+		return true
+	}
+
+	// Enable the flag for a stable percentage of the users
+	switch {
+	case rules.Percentage >= 100:
+		return true
+	case rules.Percentage <= 0 || claims.UserID == uuid.Nil:
+		return false
+	}
+	return featureFlagBucket(flag, claims.UserID) < rules.Percentage
+}
+
+// featureFlagBucket places a user in one of 100 buckets for a flag.
+// The flag is part of the hash, so that the same users don't get every flag that's being rolled out first.
+func featureFlagBucket(flag string, userID uuid.UUID) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(flag))
+	_, _ = h.Write(userID.Bytes())
+	return int(h.Sum32() % 100)
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/gofrs/uuid/v5"
+
+	"github.com/Intiqo/app-platform/internal/domain"
+)
+
+func TestEvaluateFeatureFlag(t *testing.T) {
+	organizationID := uuid.Must(uuid.NewV4())
+	userID := uuid.Must(uuid.NewV4())
+
+	t.Run("success - evaluate the rules for the caller", func(t *testing.T) {
+		for _, v := range []struct {
+			name        string
+			rules       domain.FeatureFlagRules
+			claims      domain.Claims
+			enabledWant bool
+		}{
+			{name: "no rules", rules: domain.FeatureFlagRules{}, claims: domain.Claims{UserID: userID}, enabledWant: false},
+			{name: "targeted role", rules: domain.FeatureFlagRules{Roles: []string{"beta"}}, claims: domain.Claims{Role: "beta"}, enabledWant: true},
+			{name: "other role", rules: domain.FeatureFlagRules{Roles: []string{"beta"}}, claims: domain.Claims{Role: "member", UserID: userID}, enabledWant: false},
+			{name: "no role", rules: domain.FeatureFlagRules{Roles: []string{""}}, claims: domain.Claims{UserID: userID}, enabledWant: false},
+			{name: "targeted organization", rules: domain.FeatureFlagRules{OrganizationIDs: []uuid.UUID{organizationID}}, claims: domain.Claims{OrganizationID: organizationID}, enabledWant: true},
+			{name: "other organization", rules: domain.FeatureFlagRules{OrganizationIDs: []uuid.UUID{organizationID}}, claims: domain.Claims{OrganizationID: uuid.Must(uuid.NewV4())}, enabledWant: false},
+			{name: "no organization", rules: domain.FeatureFlagRules{OrganizationIDs: []uuid.UUID{uuid.Nil}}, claims: domain.Claims{}, enabledWant: false},
+			{name: "kill switch over role", rules: domain.FeatureFlagRules{KillSwitch: true, Roles: []string{"beta"}}, claims: domain.Claims{Role: "beta"}, enabledWant: false},
+			{name: "kill switch over organization", rules: domain.FeatureFlagRules{KillSwitch: true, OrganizationIDs: []uuid.UUID{organizationID}}, claims: domain.Claims{OrganizationID: organizationID}, enabledWant: false},
+			{name: "kill switch over percentage", rules: domain.FeatureFlagRules{KillSwitch: true, Percentage: 100}, claims: domain.Claims{UserID: userID}, enabledWant: false},
+			{name: "0 percent", rules: domain.FeatureFlagRules{Percentage: 0}, claims: domain.Claims{UserID: userID}, enabledWant: false},
+			{name: "100 percent", rules: domain.FeatureFlagRules{Percentage: 100}, claims: domain.Claims{UserID: userID}, enabledWant: true},
+			{name: "100 percent without a user", rules: domain.FeatureFlagRules{Percentage: 100}, claims: domain.Claims{}, enabledWant: true},
+			{name: "99 percent without a user", rules: domain.FeatureFlagRules{Percentage: 99}, claims: domain.Claims{}, enabledWant: false},
+		} {
+			if evaluateFeatureFlag("test_flag", v.rules, v.claims) != v.enabledWant {
+				t.Errorf("Expected the flag to be enabled for %s: %t", v.name, v.enabledWant)
+			}
+		}
+	})
+
+	t.Run("success - enable the flag for the users in the buckets below the percentage", func(t *testing.T) {
+		bucket := featureFlagBucket("test_flag", userID)
+		for _, v := range []struct {
+			percentage  int
+			enabledWant bool
+		}{
+			{percentage: bucket, enabledWant: false},
+			{percentage: bucket + 1, enabledWant: true},
+		} {
+			rules := domain.FeatureFlagRules{Percentage: v.percentage}
+			if evaluateFeatureFlag("test_flag", rules, domain.Claims{UserID: userID}) != v.enabledWant {
+				t.Errorf("Expected the flag to be enabled at %d percent for a user in bucket %d: %t", v.percentage, bucket, v.enabledWant)
+			}
+		}
+	})
+}
+
+func TestFeatureFlagBucket(t *testing.T) {
+	t.Run("success - place users in a stable bucket", func(t *testing.T) {
+		for range 100 {
+			userID := uuid.Must(uuid.NewV4())
+			bucket := featureFlagBucket("test_flag", userID)
+			if bucket < 0 || bucket >= 100 {
+				t.Fatalf("Expected a bucket between 0 & 99, got %d", bucket)
+			}
+			for range 3 {
+				if got := featureFlagBucket("test_flag", userID); got != bucket {
+					t.Fatalf("Expected the user to stay in bucket %d, got %d", bucket, got)
+				}
+			}
+		}
+	})
+
+	t.Run("success - keep users in the same bucket across releases", func(t *testing.T) {
+		// Changing the hash would reshuffle the users of every flag being rolled out
+		userID := uuid.FromStringOrNil("550e8400-e29b-41d4-a716-446655440000")
+		if got := featureFlagBucket("test_flag", userID); got != 79 {
+			t.Errorf("Expected the user to be in bucket 79, got %d", got)
+		}
+	})
+
+	t.Run("success - spread users across the buckets", func(t *testing.T) {
+		enabled := 0
+		same := 0
+		for range 1000 {
+			userID := uuid.Must(uuid.NewV4())
+			a := featureFlagBucket("flag_a", userID)
+			if a < 50 {
+				enabled++
+			}
+			if a == featureFlagBucket("flag_b", userID) {
+				same++
+			}
+		}
+		if enabled < 400 || enabled > 600 {
+			t.Errorf("Expected about half of the users to be in the lower half of the buckets, got %d out of 1000", enabled)
+		}
+		if same > 100 {
+			t.Errorf("Expected the users to be placed in different buckets for different flags, got %d out of 1000 in the same bucket", same)
+		}
+	})
+}
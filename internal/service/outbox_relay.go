@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/gofrs/uuid/v5"
+
+	"github.com/Intiqo/app-platform/internal/domain"
+	"github.com/Intiqo/app-platform/internal/pkg/publisher"
+)
+
+// outboxPollPeriod is how often the outbox is looked up for events to publish
+const outboxPollPeriod = time.Second
+
+// outboxBatchSize is the number of events claimed & published at a time
+const outboxBatchSize = 100
+
+// outboxLeasePeriod is how long a relay holds on to the events it claimed, after which other relays claim them again.
+// It must be longer than a batch can take to publish.
+const outboxLeasePeriod = 30 * time.Minute
+
+// outboxMaxBackoff caps the time to wait before publishing an event that failed to publish again
+const outboxMaxBackoff = time.Hour
+
+type appOutboxRelay struct {
+	tr domain.Transactioner
+	r  domain.OutboxRepository
+	p  publisher.Publisher
+}
+
+// NewOutboxRelay creates a new outbox relay, which publishes the events recorded in the outbox in the background.
+// The returned cleanup function stops publishing events.
+func NewOutboxRelay(tr domain.Transactioner, r domain.OutboxRepository, p publisher.Publisher) (domain.OutboxRelay, func(), error) {
+	s := &appOutboxRelay{
+		tr: tr,
+		r:  r,
+		p:  p,
+	}
+
+	// Publish the events as they are recorded, as the platform itself since events are recorded for every organization
+	ctx, cancel := context.WithCancel(domain.ContextAsPlatform(context.Background()))
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.run(ctx)
+	}()
+	cleanup := func() {
+		cancel()
+		wg.Wait()
+	}
+
+	return s, cleanup, nil
+}
+
+func (s *appOutboxRelay) Flush(ctx context.Context) (count int, err error) {
+	for {
+		published, pending, err := s.flushBatch(ctx)
+		count += published
+		if err != nil || pending < outboxBatchSize {
+			return count, err
+		}
+	}
+}
+
+// run publishes the events that are due every poll period, until the context is done
+func (s *appOutboxRelay) run(ctx context.Context) {
+	ticker := time.NewTicker(outboxPollPeriod)
+	defer ticker.Stop()
+	for {
+		_, err := s.Flush(ctx)
+		if err != nil && ctx.Err() == nil {
+			slog.Error("failed to publish outbox events", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// flushBatch publishes a batch of events that are due, claiming them until they are all published or put off.
+// The events are claimed and marked in short transactions of their own, so that no row stays locked while they are published.
+// pending is the number of events that were found, so that the caller knows if there may be more.
+func (s *appOutboxRelay) flushBatch(ctx context.Context) (published int, pending int, err error) {
+	// Claim the events that are due, along with the ones whose relay went away
+	events, err := s.r.Claim(ctx, outboxBatchSize, time.Now().Add(-outboxLeasePeriod))
+	if err != nil {
+		return 0, 0, err
+	}
+	pending = len(events)
+
+	// Publish the events, keeping track of the ones that fail to publish
+	ids := make([]uuid.UUID, 0, len(events))
+	var failed []outboxFailure
+	for _, v := range events {
+		err = s.p.Publish(ctx, publisher.Message{
+			ID:          v.ID,
+			Topic:       v.Topic,
+			AggregateID: v.AggregateID,
+			Payload:     v.Payload,
+			CreatedAt:   v.CreatedAt,
+		})
+		if err != nil {
+			slog.Warn("failed to publish outbox event", "id", v.ID, "topic", v.Topic, "attempts", v.Attempts+1, "error", err)
+			failed = append(failed, outboxFailure{id: v.ID, reason: err.Error(), delay: outboxBackoff(v.Attempts + 1)})
+			continue
+		}
+		ids = append(ids, v.ID)
+	}
+
+	// Mark the events that were published, and put off the ones that failed to publish
+	err = s.tr.WithinTransaction(ctx, func(ctx context.Context) (err error) {
+		for _, v := range failed {
+			err = s.r.MarkFailed(ctx, v.id, v.reason, v.delay)
+			if err != nil {
+				return err
+			}
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		return s.r.MarkPublished(ctx, ids)
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return len(ids), pending, nil
+}
+
+// outboxFailure is the failure to publish an event, which puts off publishing it again
+type outboxFailure struct {
+	id     uuid.UUID
+	reason string
+	delay  time.Duration
+}
+
+// outboxBackoff is the time to wait before publishing an event again, doubling with every failed attempt
+func outboxBackoff(attempts int) time.Duration {
+	if attempts > 12 {
+		return outboxMaxBackoff
+	}
+	return min(time.Second<<attempts, outboxMaxBackoff)
+}
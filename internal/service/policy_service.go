@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/Intiqo/app-platform/internal/domain"
+	"github.com/Intiqo/app-platform/internal/pkg/config"
+)
+
+// permissionWildcard grants every permission, or every permission of a resource when it follows the resource, e.g. setting:*
+const permissionWildcard = "*"
+
+type appPolicyService struct {
+	// grants are the permissions granted to each role
+	grants map[string][]domain.Permission
+}
+
+// NewPolicyService creates a new policy service, granting permissions to the roles as configured in RBAC_ROLE_PERMISSIONS.
+// Roles are given as role=permissions pairs separated by semicolons, with the permissions separated by commas, e.g. admin=*;editor=setting:read,setting:write.
+// Permissions can be * for all of them, or resource:* for all the permissions of a resource. Unknown permissions fail the configuration.
+func NewPolicyService(cfg config.AppConfig) (domain.PolicyService, error) {
+	p := &appPolicyService{grants: map[string][]domain.Permission{}}
+	for _, v := range strings.Split(cfg.RbacRolePermissions, ";") {
+		if v = strings.TrimSpace(v); v == "" {
+			continue
+		}
+		role, permissions, ok := strings.Cut(v, "=")
+		role = strings.TrimSpace(role)
+		if !ok || role == "" {
+			return nil, fmt.Errorf("the permissions of a role must be given as role=permissions, got %s", v)
+		}
+		if _, ok := p.grants[role]; ok {
+			return nil, fmt.Errorf("the permissions of the role %s are configured more than once", role)
+		}
+
+		// Expand the wildcards into the permissions they match
+		granted := []domain.Permission{}
+		for _, pattern := range strings.Split(permissions, ",") {
+			if pattern = strings.TrimSpace(pattern); pattern == "" {
+				continue
+			}
+			matched := matchPermissions(pattern)
+			if len(matched) == 0 {
+				return nil, fmt.Errorf("the role %s is granted the unknown permission %s", role, pattern)
+			}
+			for _, m := range matched {
+				if !slices.Contains(granted, m) {
+					granted = append(granted, m)
+				}
+			}
+		}
+		slices.Sort(granted)
+		p.grants[role] = granted
+	}
+	return p, nil
+}
+
+func (p *appPolicyService) Permissions(role string) (result []domain.Permission) {
+	return slices.Clone(p.grants[role])
+}
+
+func (p *appPolicyService) Can(role string, permissions ...domain.Permission) bool {
+	granted, ok := p.grants[role]
+	if !ok {
+		return false
+	}
+	for _, v := range permissions {
+		if !slices.Contains(granted, v) {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *appPolicyService) Authorize(ctx context.Context, permissions ...domain.Permission) (err error) {
+	role, _ := domain.RoleFromContext(ctx)
+	if !p.Can(role, permissions...) {
+		return domain.ForbiddenAccessError{}
+	}
+	return nil
+}
+
+// matchPermissions finds the registered permissions that match a pattern
+func matchPermissions(pattern string) (result []domain.Permission) {
+	resource, wildcard := strings.CutSuffix(pattern, ":"+permissionWildcard)
+	for v := range domain.PermissionDefinitions {
+		switch {
+		case pattern == permissionWildcard:
+			result = append(result, v)
+		case wildcard && strings.HasPrefix(string(v), resource+":"):
+			result = append(result, v)
+		case string(v) == pattern:
+			result = append(result, v)
+		}
+	}
+	return result
+}
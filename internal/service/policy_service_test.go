@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/Intiqo/app-platform/internal/domain"
+	"github.com/Intiqo/app-platform/internal/pkg/config"
+)
+
+func TestPolicyService(t *testing.T) {
+	p, err := NewPolicyService(config.AppConfig{RbacRolePermissions: "admin=*; editor=setting:*, feature_flag:read ;viewer=setting:read"})
+	if err != nil {
+		t.Fatalf("Error creating the policy service: %v", err)
+	}
+
+	t.Run("success - grant the permissions of the roles", func(t *testing.T) {
+		for _, v := range []struct {
+			role        string
+			permissions []domain.Permission
+			can         bool
+		}{
+			{role: "admin", permissions: []domain.Permission{domain.PermissionSchedulerRead, domain.PermissionSettingReadSensitive}, can: true},
+			{role: "editor", permissions: []domain.Permission{domain.PermissionSettingWrite, domain.PermissionSettingReadSensitive, domain.PermissionFeatureFlagRead}, can: true},
+			{role: "editor", permissions: []domain.Permission{domain.PermissionSettingRead, domain.PermissionFeatureFlagWrite}, can: false},
+			{role: "viewer", permissions: []domain.Permission{domain.PermissionSettingRead}, can: true},
+			{role: "viewer", permissions: []domain.Permission{domain.PermissionSettingReadSensitive}, can: false},
+			{role: "guest", permissions: []domain.Permission{domain.PermissionSettingRead}, can: false},
+			{role: "", permissions: []domain.Permission{domain.PermissionSettingRead}, can: false},
+		} {
+			if p.Can(v.role, v.permissions...) != v.can {
+				t.Errorf("Expected %s to be granted %v: %t", v.role, v.permissions, v.can)
+			}
+		}
+		if len(p.Permissions("admin")) != len(domain.PermissionDefinitions) {
+			t.Errorf("Expected admin to be granted every permission, got %v", p.Permissions("admin"))
+		}
+		if !slices.Equal(p.Permissions("viewer"), []domain.Permission{domain.PermissionSettingRead}) {
+			t.Errorf("Expected viewer to be granted setting:read, got %v", p.Permissions("viewer"))
+		}
+	})
+
+	t.Run("success - authorize the caller in the context", func(t *testing.T) {
+		ctx := domain.ContextWithClaims(context.Background(), domain.Claims{Role: "viewer"})
+		err := p.Authorize(ctx, domain.PermissionSettingRead)
+		if err != nil {
+			t.Errorf("Expected viewer to be authorized, got %v", err)
+		}
+		err = p.Authorize(ctx, domain.PermissionSettingWrite)
+		if !errors.Is(err, domain.ForbiddenAccessError{}) {
+			t.Errorf("Expected viewer to be forbidden, got %v", err)
+		}
+		err = p.Authorize(context.Background(), domain.PermissionSettingRead)
+		if !errors.Is(err, domain.ForbiddenAccessError{}) {
+			t.Errorf("Expected a caller without claims to be forbidden, got %v", err)
+		}
+	})
+
+	t.Run("failure - invalid configuration", func(t *testing.T) {
+		for _, v := range []string{
+			"admin",
+			"=setting:read",
+			"admin=setting:delete",
+			"admin=user:*",
+			"admin=*;admin=setting:read",
+		} {
+			_, err := NewPolicyService(config.AppConfig{RbacRolePermissions: v})
+			if err == nil {
+				t.Errorf("Expected an error creating the policy service with %s", v)
+			}
+		}
+	})
+}
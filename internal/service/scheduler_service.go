@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/Intiqo/app-platform/internal/domain"
+	"github.com/Intiqo/app-platform/internal/pkg/config"
+	"github.com/Intiqo/app-platform/internal/pkg/security"
+	"github.com/Intiqo/app-platform/internal/scheduler"
+)
+
+// Tasks run by the scheduler
+const (
+	// taskSettingPurge permanently deletes the settings that were deleted long enough ago
+	taskSettingPurge = "setting.purge"
+	// taskAuthTokenPurge deletes the refresh tokens & denylist entries that have expired
+	taskAuthTokenPurge = "auth.token.purge"
+)
+
+// settingPurgeSchedule is the cron schedule of the purge of deleted settings
+const settingPurgeSchedule = "0 3 * * *"
+
+// authTokenPurgeSchedule is the cron schedule of the purge of expired tokens
+const authTokenPurgeSchedule = "0 * * * *"
+
+// defaultSettingPurgeAfterDays is the number of days deleted settings are kept for, unless configured through SETTING_PURGE_AFTER_DAYS
+const defaultSettingPurgeAfterDays = 90
+
+type appSchedulerService struct {
+	sch *scheduler.Scheduler
+	r   domain.ScheduledRunRepository
+}
+
+// NewSchedulerService creates a new scheduler service, registering the periodic tasks of the platform with the scheduler
+func NewSchedulerService(cfg config.AppConfig, sch *scheduler.Scheduler, r domain.ScheduledRunRepository, s domain.SettingService, sm security.Manager) (domain.SchedulerService, error) {
+	purgeAfter := cfg.SettingPurgeAfterDays
+	if purgeAfter <= 0 {
+		purgeAfter = defaultSettingPurgeAfterDays
+	}
+	err := sch.Register(taskSettingPurge, settingPurgeSchedule, func(ctx context.Context) error {
+		// Purge the settings of every organization
+		count, err := s.Purge(domain.ContextAsPlatform(ctx), time.Now().AddDate(0, 0, -purgeAfter))
+		if err != nil {
+			return err
+		}
+		slog.Info("purged deleted settings", "count", count)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = sch.Register(taskAuthTokenPurge, authTokenPurgeSchedule, func(ctx context.Context) error {
+		count, err := sm.PurgeExpiredTokens(ctx)
+		if err != nil {
+			return err
+		}
+		slog.Info("purged expired tokens", "count", count)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &appSchedulerService{
+		sch: sch,
+		r:   r,
+	}, nil
+}
+
+func (s *appSchedulerService) FindTasks(ctx context.Context) (result []domain.ScheduledTask, err error) {
+	// Find the tasks, along with their latest runs
+	tasks := s.sch.Tasks()
+	names := make([]string, 0, len(tasks))
+	for _, v := range tasks {
+		names = append(names, v.Name)
+	}
+	latest, err := s.r.FindLatest(ctx, names)
+	if err != nil {
+		return result, err
+	}
+
+	// Describe the tasks
+	result = make([]domain.ScheduledTask, 0, len(tasks))
+	for _, v := range tasks {
+		task := domain.ScheduledTask{
+			Name:     v.Name,
+			Schedule: v.Schedule,
+		}
+		if run, ok := latest[v.Name]; ok {
+			task.LastRun = &run
+		}
+		if !v.Next.IsZero() {
+			task.NextRun = &v.Next
+		}
+		result = append(result, task)
+	}
+	return result, nil
+}
+
+func (s *appSchedulerService) FindRuns(ctx context.Context, in domain.FilterScheduledRunsByCriteriaInput, opts domain.QueryOptions) (result []domain.ScheduledRun, page domain.PageInfo, err error) {
+	return s.r.Filter(ctx, in, opts)
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/gofrs/uuid/v5"
+
+	"github.com/Intiqo/app-platform/internal/domain"
+)
+
+// settingCache holds an in-process copy of all the settings that are not deleted
+type settingCache struct {
+	mu     sync.RWMutex
+	loaded bool
+	byID   map[uuid.UUID]domain.Setting
+}
+
+func newSettingCache() *settingCache {
+	return &settingCache{
+		byID: make(map[uuid.UUID]domain.Setting),
+	}
+}
+
+// isLoaded reports whether the cache holds all the settings
+func (c *settingCache) isLoaded() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.loaded
+}
+
+// replaceAll replaces the contents of the cache with the given settings
+func (c *settingCache) replaceAll(settings []domain.Setting) {
+	byID := make(map[uuid.UUID]domain.Setting, len(settings))
+	for _, v := range settings {
+		byID[v.ID] = v
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.byID = byID
+	c.loaded = true
+}
+
+// put adds or replaces settings in the cache
+func (c *settingCache) put(settings ...domain.Setting) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, v := range settings {
+		c.byID[v.ID] = v
+	}
+}
+
+// remove removes settings from the cache
+func (c *settingCache) remove(ids ...uuid.UUID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, id := range ids {
+		delete(c.byID, id)
+	}
+}
+
+// findByID finds a setting in the cache by its ID, among the ones visible to the caller
+func (c *settingCache) findByID(ctx context.Context, id uuid.UUID) (result domain.Setting, ok bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	result, ok = c.byID[id]
+	return result, ok && visibleTo(ctx, result)
+}
+
+// filter returns the settings matching the criteria among the ones visible to the caller ordered by key,
+// along with the total number of matches
+func (c *settingCache) filter(ctx context.Context, in domain.FilterSettingsByCriteriaInput, opts domain.QueryOptions) (result []domain.Setting, total int64) {
+	c.mu.RLock()
+	result = make([]domain.Setting, 0, len(c.byID))
+	for _, v := range c.byID {
+		if !visibleTo(ctx, v) {
+			continue
+		}
+		if len(in.Keys) > 0 && !slices.Contains(in.Keys, v.Key) {
+			continue
+		}
+		if in.Scope != "" && v.Scope != in.Scope {
+			continue
+		}
+		if in.ScopeID != nil && (v.ScopeID == nil || *v.ScopeID != *in.ScopeID) {
+			continue
+		}
+		result = append(result, v)
+	}
+	c.mu.RUnlock()
+
+	// Order the settings so that pagination is stable
+	slices.SortFunc(result, compareSettings)
+	total = int64(len(result))
+
+	// Paginate the settings
+	start := min(opts.Offset, total)
+	end := total
+	if opts.Limit > 0 {
+		end = min(start+opts.Limit, total)
+	}
+	return result[start:end], total
+}
+
+// visibleTo reports whether a setting is visible to the caller, the same way as the row level security policies of settings.
+// Callers see the settings of the organization they act for along with the global ones, and only the platform itself sees everything.
+func visibleTo(ctx context.Context, setting domain.Setting) bool {
+	if domain.PlatformFromContext(ctx) || setting.Scope == domain.SettingScopeGlobal {
+		return true
+	}
+	organizationID, ok := domain.OrganizationIDFromContext(ctx)
+	return ok && setting.OrganizationID != nil && *setting.OrganizationID == organizationID
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gofrs/uuid/v5"
+
+	"github.com/Intiqo/app-platform/internal/domain"
+)
+
+// settingsReadPolicy is the row level security policy that lets organizations read settings, which visibleTo mirrors
+const settingsReadPolicy = `scope = 'global' OR organization_id = NULLIF(current_setting('app.org_id', true), '')::uuid`
+
+// settingsReadPolicyPattern matches the policy in the up section of a migration
+var settingsReadPolicyPattern = regexp.MustCompile(`(?s)POLICY settings_organization_read ON settings.*?USING \((.*?)\);`)
+
+// readPolicy evaluates the policy against a row for the organization the transaction is scoped to, as the database does
+func readPolicy(setting domain.Setting, orgID string) bool {
+	if setting.Scope == domain.SettingScopeGlobal {
+		return true
+	}
+	// NULLIF turns an empty organization into NULL, which never equals the organization of the row
+	if orgID == "" || setting.OrganizationID == nil {
+		return false
+	}
+	return setting.OrganizationID.String() == orgID
+}
+
+func TestVisibleTo(t *testing.T) {
+	t.Run("success - mirror the policy of the latest migration", func(t *testing.T) {
+		files, err := filepath.Glob(filepath.Join("..", "database", "migrations", "*.sql"))
+		if err != nil {
+			t.Fatalf("Error listing the migrations: %v", err)
+		}
+		var got string
+		for _, v := range files {
+			data, err := os.ReadFile(v)
+			if err != nil {
+				t.Fatalf("Error reading the migration %s: %v", v, err)
+			}
+			up, _, _ := strings.Cut(string(data), "-- +goose Down")
+			if m := settingsReadPolicyPattern.FindStringSubmatch(up); m != nil {
+				got = strings.Join(strings.Fields(m[1]), " ")
+			}
+		}
+		if got != settingsReadPolicy {
+			t.Errorf("Expected the policy to be %q so that visibleTo mirrors it, got %q", settingsReadPolicy, got)
+		}
+	})
+
+	t.Run("success - see the rows the policy lets the caller see", func(t *testing.T) {
+		orgA := uuid.Must(uuid.NewV4())
+		orgB := uuid.Must(uuid.NewV4())
+
+		var settings []domain.Setting
+		for _, scope := range []string{domain.SettingScopeGlobal, domain.SettingScopeOrganization, domain.SettingScopeUser} {
+			for _, organizationID := range []*uuid.UUID{nil, &orgA, &orgB} {
+				settings = append(settings, domain.Setting{Scope: scope, OrganizationID: organizationID})
+			}
+		}
+
+		for _, v := range []struct {
+			name string
+			ctx  context.Context
+		}{
+			{name: "no claims", ctx: context.Background()},
+			{name: "no organization", ctx: domain.ContextWithClaims(context.Background(), domain.Claims{Role: "admin"})},
+			{name: "organization A", ctx: domain.ContextWithClaims(context.Background(), domain.Claims{OrganizationID: orgA})},
+			{name: "organization B", ctx: domain.ContextWithClaims(context.Background(), domain.Claims{OrganizationID: orgB})},
+		} {
+			// Scope the caller the same way as the transactions of the repositories
+			var orgID string
+			if organizationID, ok := domain.OrganizationIDFromContext(v.ctx); ok {
+				orgID = organizationID.String()
+			}
+			for _, s := range settings {
+				if want := readPolicy(s, orgID); visibleTo(v.ctx, s) != want {
+					t.Errorf("Expected a %s setting of organization %v to be visible to %s: %t", s.Scope, s.OrganizationID, v.name, want)
+				}
+			}
+		}
+	})
+
+	t.Run("success - see every row as the platform", func(t *testing.T) {
+		ctx := domain.ContextAsPlatform(context.Background())
+		organizationID := uuid.Must(uuid.NewV4())
+		for _, s := range []domain.Setting{
+			{Scope: domain.SettingScopeUser},
+			{Scope: domain.SettingScopeOrganization, OrganizationID: &organizationID},
+		} {
+			if !visibleTo(ctx, s) {
+				t.Errorf("Expected a %s setting of organization %v to be visible to the platform", s.Scope, s.OrganizationID)
+			}
+		}
+	})
+}
@@ -2,47 +2,1290 @@ package service
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/gofrs/uuid/v5"
 
 	"github.com/Intiqo/app-platform/internal/domain"
 )
 
+// reencryptPeriod is how often values encrypted with retired data keys are looked for
+const reencryptPeriod = time.Minute
+
+// reencryptBatchSize is the number of values re-encrypted at a time
+const reencryptBatchSize = 100
+
 type appSettingService struct {
 	tr domain.Transactioner
 	r  domain.SettingRepository
+	rr domain.SettingRevisionRepository
+	k  domain.SettingKeyring
+	l  domain.SettingChangeListener
+	o  domain.OutboxRepository
+	p  domain.PolicyService
+
+	cache *settingCache
+
+	// reencryptRequests wakes up the re-encryption of sensitive settings after the data key is rotated
+	reencryptRequests chan struct{}
+
+	subscribersMu    sync.RWMutex
+	subscribers      map[int]func(event domain.SettingChangeEvent)
+	nextSubscriberID int
 }
 
-// NewSettingService creates a new setting service
-func NewSettingService(tr domain.Transactioner, r domain.SettingRepository) domain.SettingService {
+// NewSettingService creates a new setting service.
+// Every change is recorded in the outbox, in the same transaction, to be published to other systems.
+// The values of sensitive settings are masked in what's returned to callers whose role isn't granted the permission to read them.
+// The returned cleanup function stops listening for setting changes and re-encrypting sensitive settings.
+func NewSettingService(tr domain.Transactioner, r domain.SettingRepository, rr domain.SettingRevisionRepository, k domain.SettingKeyring, l domain.SettingChangeListener, o domain.OutboxRepository, p domain.PolicyService) (domain.SettingService, func(), error) {
 	s := &appSettingService{
 		tr: tr,
 
-		r: r,
+		r:  r,
+		rr: rr,
+		k:  k,
+		l:  l,
+		o:  o,
+		p:  p,
+
+		cache:             newSettingCache(),
+		reencryptRequests: make(chan struct{}, 1),
+		subscribers:       make(map[int]func(event domain.SettingChangeEvent)),
 	}
 	s.createDefaultSettings()
-	return s
+
+	// Load all the settings into the cache
+	err := s.reload()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Keep the cache up to date with the changes made by all the instances
+	ctx, cancel := context.WithCancel(domain.ContextAsPlatform(context.Background()))
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		_ = s.l.Listen(ctx, s.onListenerConnect, s.onSettingChange)
+	}()
+
+	// Keep the values of sensitive settings encrypted with the active data key
+	go func() {
+		defer wg.Done()
+		s.runReencryption(ctx)
+	}()
+	cleanup := func() {
+		cancel()
+		wg.Wait()
+	}
+
+	return s, cleanup, nil
+}
+
+func (s *appSettingService) FindByID(ctx context.Context, id uuid.UUID) (result domain.Setting, err error) {
+	result, err = s.findByID(ctx, id)
+	if err != nil {
+		return result, err
+	}
+	return s.maskSetting(ctx, result), nil
+}
+
+// findByID finds a setting by its ID, with its value as is even if it's sensitive
+func (s *appSettingService) findByID(ctx context.Context, id uuid.UUID) (result domain.Setting, err error) {
+	// Look up the cache first
+	result, ok := s.cache.findByID(ctx, id)
+	if ok {
+		return result, nil
+	}
+
+	// Fall back to the database
+	result, err = s.r.FindByID(ctx, id)
+	if err != nil {
+		return result, err
+	}
+	s.cache.put(result)
+	return result, nil
+}
+
+func (s *appSettingService) Filter(ctx context.Context, in domain.FilterSettingsByCriteriaInput, options domain.QueryOptions) (result []domain.Setting, page domain.PageInfo, err error) {
+	result, page, err = s.filter(ctx, in, options)
+	if err != nil {
+		return result, page, err
+	}
+	return s.maskSettings(ctx, result), page, nil
+}
+
+// filter finds the settings matching the criteria, with their values as is even if they're sensitive
+func (s *appSettingService) filter(ctx context.Context, in domain.FilterSettingsByCriteriaInput, options domain.QueryOptions) (result []domain.Setting, page domain.PageInfo, err error) {
+	// Serve from the cache once it holds all the settings, unless the caller needs the database to apply conditions, sort keys or a cursor
+	if s.cache.isLoaded() && len(options.Conditions) == 0 && len(options.SortKeys) == 0 && !options.Keyset && options.Cursor == "" {
+		result, page.Total = s.cache.filter(ctx, in, options)
+		return result, page, nil
+	}
+	return s.r.Filter(ctx, in, options)
+}
+
+func (s *appSettingService) Subscribe(fn func(event domain.SettingChangeEvent)) (unsubscribe func()) {
+	s.subscribersMu.Lock()
+	defer s.subscribersMu.Unlock()
+	id := s.nextSubscriberID
+	s.nextSubscriberID++
+	s.subscribers[id] = fn
+
+	return func() {
+		s.subscribersMu.Lock()
+		defer s.subscribersMu.Unlock()
+		delete(s.subscribers, id)
+	}
+}
+
+func (s *appSettingService) Create(ctx context.Context, in domain.CreateSettingInput) (result domain.Setting, err error) {
+	// Begin a transaction
+	ctx, err = s.tr.Begin(ctx)
+	if err != nil {
+		return result, err
+	}
+	defer func() { s.tr.Rollback(ctx, err) }()
+
+	// Validate the value against the declared schema
+	err = s.validateValue(in.Key, in.Value)
+	if err != nil {
+		return result, err
+	}
+
+	// Validate the scope
+	scope, err := validateScope(in.Scope, in.ScopeID)
+	if err != nil {
+		return result, err
+	}
+
+	// Make sure the caller can create a setting owned by the organization of the scope
+	result = domain.Setting{
+		Key:            in.Key,
+		Value:          in.Value,
+		Scope:          scope,
+		ScopeID:        in.ScopeID,
+		OrganizationID: ownerOf(ctx, scope, in.ScopeID),
+		Sensitive:      isSensitive(in.Key, in.Sensitive),
+	}
+	err = ensureOwned(ctx, result.OrganizationID)
+	if err != nil {
+		return result, err
+	}
+
+	// Ensure the key is not in use within the scope
+	err = s.ensureUnique(ctx, result)
+	if err != nil {
+		return result, err
+	}
+
+	// Create the setting
+	err = s.r.Create(ctx, &result)
+	if err != nil {
+		return result, err
+	}
+
+	// Record the revision
+	err = s.recordRevisions(ctx, domain.SettingRevisionOperationCreate, result)
+	if err != nil {
+		return result, err
+	}
+
+	// Commit the transaction
+	err = s.tr.Commit(ctx)
+	if err != nil {
+		return result, err
+	}
+
+	// Make the change visible to this instance right away
+	s.cache.put(result)
+	return s.maskSetting(ctx, result), nil
+}
+
+func (s *appSettingService) CreateMultiple(ctx context.Context, in domain.CreateSettingsInput) (result []domain.Setting, err error) {
+	// Make sure that the values and scopes are valid, and that the caller can create settings owned by the organizations of the scopes
+	entities := make([]*domain.Setting, 0, len(in.Settings))
+	for _, v := range in.Settings {
+		err = s.validateValue(v.Key, v.Value)
+		if err != nil {
+			return result, err
+		}
+		var scope string
+		scope, err = validateScope(v.Scope, v.ScopeID)
+		if err != nil {
+			return result, err
+		}
+		organizationID := ownerOf(ctx, scope, v.ScopeID)
+		err = ensureOwned(ctx, organizationID)
+		if err != nil {
+			return result, err
+		}
+		entities = append(entities, &domain.Setting{
+			Key:            v.Key,
+			Value:          v.Value,
+			Scope:          scope,
+			ScopeID:        v.ScopeID,
+			OrganizationID: organizationID,
+			Sensitive:      isSensitive(v.Key, v.Sensitive),
+		})
+	}
+
+	// Begin a transaction
+	ctx, err = s.tr.Begin(ctx)
+	if err != nil {
+		return result, err
+	}
+	defer func() { s.tr.Rollback(ctx, err) }()
+
+	// Ensure the keys are not in use within their scopes
+	err = s.ensureUnique(ctx, derefSettings(entities)...)
+	if err != nil {
+		return result, err
+	}
+
+	// Create the settings
+	err = s.r.CreateMultiple(ctx, entities)
+	if err != nil {
+		return result, err
+	}
+
+	// Record the revisions
+	err = s.recordRevisions(ctx, domain.SettingRevisionOperationCreate, derefSettings(entities)...)
+	if err != nil {
+		return result, err
+	}
+
+	// Commit the transaction
+	err = s.tr.Commit(ctx)
+	if err != nil {
+		return result, err
+	}
+
+	// Make the changes visible to this instance right away
+	result = derefSettings(entities)
+	s.cache.put(result...)
+	return s.maskSettings(ctx, result), nil
+}
+
+func (s *appSettingService) Update(ctx context.Context, id uuid.UUID, in domain.UpdateSettingInput) (result domain.Setting, err error) {
+	return s.Patch(ctx, id, domain.PatchSettingInput{
+		Key:     &in.Key,
+		Value:   &in.Value,
+		Version: in.Version,
+	})
+}
+
+func (s *appSettingService) UpdateMultiple(ctx context.Context, in domain.UpdateSettingsInput) (result []domain.Setting, err error) {
+	// Make sure that the request doesn't contain the same setting twice
+	ids := make(map[uuid.UUID]bool, len(in.Settings))
+	for _, v := range in.Settings {
+		if ids[v.ID] {
+			return result, domain.UserError{
+				Code:    domain.ErrorCodeINVALIDREQUEST,
+				Message: fmt.Sprintf("The setting %s is specified more than once", v.ID),
+			}
+		}
+		ids[v.ID] = true
+	}
+
+	// Begin a transaction
+	ctx, err = s.tr.Begin(ctx)
+	if err != nil {
+		return result, err
+	}
+	defer func() { s.tr.Rollback(ctx, err) }()
+
+	// Load & update the settings, leaving the sensitive values unchanged when they're given masked
+	entities := make([]*domain.Setting, 0, len(in.Settings))
+	for _, v := range in.Settings {
+		var entity domain.Setting
+		entity, err = s.r.FindByID(ctx, v.ID)
+		if err != nil {
+			return result, err
+		}
+		err = ensureOwned(ctx, entity.OrganizationID)
+		if err != nil {
+			return result, err
+		}
+		err = ensureVersion(entity, v.Version)
+		if err != nil {
+			return result, err
+		}
+		if entity.Key != v.Key {
+			// Settings renamed to a key declared as sensitive become sensitive
+			entity.Key = v.Key
+			entity.Sensitive = isSensitive(entity.Key, entity.Sensitive)
+		}
+		if !isMaskedValue(entity, v.Value) {
+			entity.Value = v.Value
+		}
+		err = s.validateValue(entity.Key, entity.Value)
+		if err != nil {
+			return result, err
+		}
+		entities = append(entities, &entity)
+	}
+
+	// Ensure the keys are not used by other settings within their scopes
+	err = s.ensureUnique(ctx, derefSettings(entities)...)
+	if err != nil {
+		return result, err
+	}
+
+	err = s.r.UpdateMultiple(ctx, entities)
+	if err != nil {
+		return result, err
+	}
+
+	// Record the revisions
+	err = s.recordRevisions(ctx, domain.SettingRevisionOperationUpdate, derefSettings(entities)...)
+	if err != nil {
+		return result, err
+	}
+
+	// Commit the transaction
+	err = s.tr.Commit(ctx)
+	if err != nil {
+		return result, err
+	}
+
+	// Make the changes visible to this instance right away
+	result = derefSettings(entities)
+	s.cache.put(result...)
+	return s.maskSettings(ctx, result), nil
+}
+
+func (s *appSettingService) Patch(ctx context.Context, id uuid.UUID, in domain.PatchSettingInput) (result domain.Setting, err error) {
+	// Begin a transaction
+	ctx, err = s.tr.Begin(ctx)
+	if err != nil {
+		return result, err
+	}
+	defer func() { s.tr.Rollback(ctx, err) }()
+
+	// Find the setting
+	result, err = s.r.FindByID(ctx, id)
+	if err != nil {
+		return result, err
+	}
+	err = ensureOwned(ctx, result.OrganizationID)
+	if err != nil {
+		return result, err
+	}
+	err = ensureVersion(result, in.Version)
+	if err != nil {
+		return result, err
+	}
+
+	// Apply the changes
+	if in.Key != nil && *in.Key != result.Key {
+		result.Key = *in.Key
+		result.Sensitive = isSensitive(result.Key, result.Sensitive)
+		err = s.ensureUnique(ctx, result)
+		if err != nil {
+			return result, err
+		}
+	}
+	if in.Value != nil && !isMaskedValue(result, *in.Value) {
+		result.Value = *in.Value
+	}
+
+	// Validate the value against the declared schema
+	err = s.validateValue(result.Key, result.Value)
+	if err != nil {
+		return result, err
+	}
+
+	// Update the setting
+	err = s.r.Update(ctx, &result)
+	if err != nil {
+		return result, err
+	}
+
+	// Record the revision
+	err = s.recordRevisions(ctx, domain.SettingRevisionOperationUpdate, result)
+	if err != nil {
+		return result, err
+	}
+
+	// Commit the transaction
+	err = s.tr.Commit(ctx)
+	if err != nil {
+		return result, err
+	}
+
+	// Make the change visible to this instance right away
+	s.cache.put(result)
+	return s.maskSetting(ctx, result), nil
+}
+
+func (s *appSettingService) DeleteByID(ctx context.Context, id uuid.UUID) (err error) {
+	// Begin a transaction
+	ctx, err = s.tr.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { s.tr.Rollback(ctx, err) }()
+
+	// Make sure the setting exists & the caller can delete it
+	setting, err := s.r.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	err = ensureOwned(ctx, setting.OrganizationID)
+	if err != nil {
+		return err
+	}
+
+	// Delete the setting
+	err = s.r.DeleteByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	// Record the revision
+	err = s.recordRevisions(ctx, domain.SettingRevisionOperationDelete, setting)
+	if err != nil {
+		return err
+	}
+
+	// Commit the transaction
+	err = s.tr.Commit(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Make the change visible to this instance right away
+	s.cache.remove(id)
+	return nil
+}
+
+func (s *appSettingService) DeleteByIDs(ctx context.Context, in domain.DeleteSettingsInput) (err error) {
+	// Begin a transaction
+	ctx, err = s.tr.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { s.tr.Rollback(ctx, err) }()
+
+	// Make sure all the settings exist & the caller can delete them
+	settings := make([]domain.Setting, 0, len(in.IDs))
+	for _, id := range in.IDs {
+		var setting domain.Setting
+		setting, err = s.r.FindByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		err = ensureOwned(ctx, setting.OrganizationID)
+		if err != nil {
+			return err
+		}
+		settings = append(settings, setting)
+	}
+
+	// Delete the settings
+	err = s.r.DeleteByIDs(ctx, in.IDs)
+	if err != nil {
+		return err
+	}
+
+	// Record the revisions
+	err = s.recordRevisions(ctx, domain.SettingRevisionOperationDelete, settings...)
+	if err != nil {
+		return err
+	}
+
+	// Commit the transaction
+	err = s.tr.Commit(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Make the changes visible to this instance right away
+	s.cache.remove(in.IDs...)
+	return nil
+}
+
+func (s *appSettingService) Purge(ctx context.Context, deletedBefore time.Time) (count int64, err error) {
+	// Purge the settings along with their revisions, so that the history of a setting is never left half gone
+	err = s.tr.WithinTransaction(ctx, func(ctx context.Context) (err error) {
+		count, err = s.r.Purge(ctx, deletedBefore)
+		return err
+	})
+	return count, err
+}
+
+func (s *appSettingService) FindRevisions(ctx context.Context, in domain.FilterSettingRevisionsByCriteriaInput, options domain.QueryOptions) (result []domain.SettingRevision, page domain.PageInfo, err error) {
+	result, page, err = s.rr.Filter(ctx, in, options)
+	if err != nil {
+		return result, page, err
+	}
+
+	// Mask the sensitive values the caller can't read
+	if !s.canReadSensitive(ctx) {
+		for idx, v := range result {
+			result[idx] = v.Masked()
+		}
+	}
+	return result, page, nil
+}
+
+func (s *appSettingService) Rollback(ctx context.Context, revisionID uuid.UUID) (result domain.Setting, err error) {
+	// Begin a transaction
+	ctx, err = s.tr.Begin(ctx)
+	if err != nil {
+		return result, err
+	}
+	defer func() { s.tr.Rollback(ctx, err) }()
+
+	// Find the revision to restore, making sure the caller can change the setting it was made to
+	revision, err := s.rr.FindByID(ctx, revisionID)
+	if err != nil {
+		return result, err
+	}
+	err = ensureOwned(ctx, revision.OrganizationID)
+	if err != nil {
+		return result, err
+	}
+
+	// The schema might have changed since the revision was made
+	err = s.validateValue(revision.Key, revision.Value)
+	if err != nil {
+		return result, err
+	}
+
+	// Find the setting the revision was made to, restoring it if it was deleted since
+	result, err = s.r.FindByID(ctx, revision.SettingID)
+	if errors.Is(err, domain.DataNotFoundError{}) {
+		err = s.r.Restore(ctx, revision.SettingID)
+		if err != nil {
+			return result, err
+		}
+		result, err = s.r.FindByID(ctx, revision.SettingID)
+	}
+	if err != nil {
+		return result, err
+	}
+
+	// Restore the key & value of the setting, unless it's changed by someone else in the meantime
+	if result.Key != revision.Key {
+		result.Key = revision.Key
+		result.Sensitive = isSensitive(result.Key, result.Sensitive)
+		err = s.ensureUnique(ctx, result)
+		if err != nil {
+			return result, err
+		}
+	}
+	result.Value = revision.Value
+	err = s.r.Update(ctx, &result)
+	if err != nil {
+		return result, err
+	}
+
+	// Record the revision
+	err = s.recordRevisions(ctx, domain.SettingRevisionOperationRollback, result)
+	if err != nil {
+		return result, err
+	}
+
+	// Commit the transaction
+	err = s.tr.Commit(ctx)
+	if err != nil {
+		return result, err
+	}
+
+	// Make the change visible to this instance right away
+	s.cache.put(result)
+	return s.maskSetting(ctx, result), nil
+}
+
+func (s *appSettingService) Resolve(ctx context.Context, key string) (result domain.Setting, err error) {
+	claims, _ := domain.ClaimsFromContext(ctx)
+
+	// Find the candidates across all the scopes
+	settings, _, err := s.filter(ctx, domain.FilterSettingsByCriteriaInput{Keys: []string{key}}, domain.QueryOptions{})
+	if err != nil {
+		return result, err
+	}
+
+	// Pick the most specific setting that applies to the caller
+	found := false
+	rank := 0
+	for _, v := range settings {
+		r := scopeRank(v, claims)
+		if r > rank {
+			result = v
+			rank = r
+			found = true
+		}
+	}
+	if found {
+		return s.maskSetting(ctx, result), nil
+	}
+
+	// Fall back to the default value
+	def, ok := domain.SettingDefinitions[key]
+	if !ok {
+		return result, domain.DataNotFoundError{}
+	}
+	result = domain.Setting{
+		Key:       def.Key,
+		Value:     def.Default,
+		Scope:     domain.SettingScopeGlobal,
+		Sensitive: def.Sensitive,
+	}
+	return s.maskSetting(ctx, result), nil
+}
+
+func (s *appSettingService) Export(ctx context.Context) (result domain.SettingExport, err error) {
+	// Find all the settings
+	settings, _, err := s.r.Filter(ctx, domain.FilterSettingsByCriteriaInput{}, domain.QueryOptions{})
+	if err != nil {
+		return result, err
+	}
+
+	// Order the settings so that exports can be compared with each other
+	slices.SortFunc(settings, compareSettings)
+
+	// Export the settings, masking the sensitive values the caller can't read
+	settings = s.maskSettings(ctx, settings)
+	result.Settings = make([]domain.SettingExportItem, 0, len(settings))
+	for _, v := range settings {
+		result.Settings = append(result.Settings, exportItemOf(v))
+	}
+	return result, nil
+}
+
+func (s *appSettingService) Import(ctx context.Context, in domain.ImportSettingsInput) (result domain.SettingImportResult, err error) {
+	result = domain.SettingImportResult{
+		DryRun:  in.DryRun,
+		Added:   []domain.SettingExportItem{},
+		Changed: []domain.SettingValueChange{},
+		Removed: []domain.SettingExportItem{},
+	}
+
+	// Make sure that the values and scopes are valid and that no setting is imported twice.
+	// The masked values of sensitive settings are left unchanged.
+	imported := make([]domain.Setting, 0, len(in.Settings))
+	identities := make(map[settingIdentity]bool, len(in.Settings))
+	masked := make(map[settingIdentity]bool)
+	for _, v := range in.Settings {
+		isMasked := isSensitive(v.Key, v.Sensitive) && v.Value == domain.SettingMaskedValue
+		if !isMasked {
+			err = s.validateValue(v.Key, v.Value)
+			if err != nil {
+				return result, err
+			}
+		}
+		setting := domain.Setting{
+			Key:       v.Key,
+			Value:     v.Value,
+			ScopeID:   v.ScopeID,
+			Sensitive: isSensitive(v.Key, v.Sensitive),
+		}
+		setting.Scope, err = validateScope(v.Scope, v.ScopeID)
+		if err != nil {
+			return result, err
+		}
+		setting.OrganizationID = ownerOf(ctx, setting.Scope, v.ScopeID)
+		identity := identityOf(setting)
+		if identities[identity] {
+			return result, domain.UserError{
+				Code:    domain.ErrorCodeINVALIDREQUEST,
+				Message: fmt.Sprintf("The key %s is specified more than once for the %s scope", v.Key, setting.Scope),
+			}
+		}
+		identities[identity] = true
+		masked[identity] = isMasked
+		imported = append(imported, setting)
+	}
+
+	// Begin a transaction, unless the changes are only being reported
+	if !in.DryRun {
+		ctx, err = s.tr.Begin(ctx)
+		if err != nil {
+			return result, err
+		}
+		defer func() { s.tr.Rollback(ctx, err) }()
+	}
+
+	// Find the existing settings, only looking at the imported keys unless the others are pruned
+	var criteria domain.FilterSettingsByCriteriaInput
+	if !in.Prune {
+		for _, v := range imported {
+			criteria.Keys = append(criteria.Keys, v.Key)
+		}
+	}
+	existing, _, err := s.r.Filter(ctx, criteria, domain.QueryOptions{})
+	if err != nil {
+		return result, err
+	}
+	slices.SortFunc(existing, compareSettings)
+	existingByIdentity := make(map[settingIdentity]domain.Setting, len(existing))
+	for _, v := range existing {
+		existingByIdentity[identityOf(v)] = v
+	}
+
+	// Work out the changes, making sure the caller can make them
+	var toCreate, toUpdate []*domain.Setting
+	for _, v := range imported {
+		current, ok := existingByIdentity[identityOf(v)]
+		switch {
+		case masked[identityOf(v)]:
+			if !ok {
+				return result, domain.UserError{
+					Code:    domain.ErrorCodeINVALIDREQUEST,
+					Message: fmt.Sprintf("The value of the sensitive setting %s is masked", v.Key),
+				}
+			}
+		case !ok:
+			err = ensureOwned(ctx, v.OrganizationID)
+			if err != nil {
+				return result, err
+			}
+			toCreate = append(toCreate, &v)
+			result.Added = append(result.Added, exportItemOf(v))
+		case current.Value != v.Value:
+			err = ensureOwned(ctx, current.OrganizationID)
+			if err != nil {
+				return result, err
+			}
+			result.Changed = append(result.Changed, domain.SettingValueChange{
+				SettingExportItem: exportItemOf(v),
+				PreviousValue:     current.Value,
+			})
+			current.Value = v.Value
+			toUpdate = append(toUpdate, &current)
+		}
+	}
+	var toDelete []domain.Setting
+	var ids []uuid.UUID
+	if in.Prune {
+		for _, v := range existing {
+			if !identities[identityOf(v)] {
+				err = ensureOwned(ctx, v.OrganizationID)
+				if err != nil {
+					return result, err
+				}
+				toDelete = append(toDelete, v)
+				ids = append(ids, v.ID)
+				result.Removed = append(result.Removed, exportItemOf(v))
+			}
+		}
+	}
+	if in.DryRun {
+		return s.maskImportResult(ctx, result), nil
+	}
+
+	// Apply the changes
+	if len(toCreate) > 0 {
+		err = s.r.CreateMultiple(ctx, toCreate)
+		if err != nil {
+			return result, err
+		}
+		err = s.recordRevisions(ctx, domain.SettingRevisionOperationCreate, derefSettings(toCreate)...)
+		if err != nil {
+			return result, err
+		}
+	}
+	if len(toUpdate) > 0 {
+		err = s.r.UpdateMultiple(ctx, toUpdate)
+		if err != nil {
+			return result, err
+		}
+		err = s.recordRevisions(ctx, domain.SettingRevisionOperationUpdate, derefSettings(toUpdate)...)
+		if err != nil {
+			return result, err
+		}
+	}
+	if len(toDelete) > 0 {
+		err = s.r.DeleteByIDs(ctx, ids)
+		if err != nil {
+			return result, err
+		}
+		err = s.recordRevisions(ctx, domain.SettingRevisionOperationDelete, toDelete...)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	// Commit the transaction
+	err = s.tr.Commit(ctx)
+	if err != nil {
+		return result, err
+	}
+
+	// Make the changes visible to this instance right away
+	s.cache.put(derefSettings(toCreate)...)
+	s.cache.put(derefSettings(toUpdate)...)
+	s.cache.remove(ids...)
+	return s.maskImportResult(ctx, result), nil
+}
+
+func (s *appSettingService) RotateKey(ctx context.Context) (err error) {
+	// Rotate the data key
+	_, err = s.k.Rotate(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Re-encrypt the values with the new data key right away, unless it's already due
+	select {
+	case s.reencryptRequests <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (s *appSettingService) Definitions() (result []domain.SettingDefinition) {
+	result = make([]domain.SettingDefinition, 0, len(domain.SettingDefinitions))
+	for _, v := range domain.SettingDefinitions {
+		result = append(result, v)
+	}
+	slices.SortFunc(result, func(a, b domain.SettingDefinition) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+	return result
+}
+
+func (s *appSettingService) GetString(key string) (result string, err error) {
+	return s.getValue(key, domain.SettingTypeString)
+}
+
+func (s *appSettingService) GetInt(key string) (result int64, err error) {
+	value, err := s.getValue(key, domain.SettingTypeInt)
+	if err != nil {
+		return result, err
+	}
+	return domain.ParseSettingInt(value)
 }
 
-func (s *appSettingService) FindByID(id uuid.UUID) (result domain.Setting, err error) {
-	return s.r.FindByID(context.TODO(), id)
+func (s *appSettingService) GetBool(key string) (result bool, err error) {
+	value, err := s.getValue(key, domain.SettingTypeBool)
+	if err != nil {
+		return result, err
+	}
+	return domain.ParseSettingBool(value)
 }
 
-func (s *appSettingService) Filter(in domain.FilterSettingsByCriteriaInput, options domain.QueryOptions) (result []domain.Setting, total int64, err error) {
-	return s.r.Filter(context.TODO(), in, options)
+func (s *appSettingService) GetDuration(key string) (result time.Duration, err error) {
+	value, err := s.getValue(key, domain.SettingTypeDuration)
+	if err != nil {
+		return result, err
+	}
+	return domain.ParseSettingDuration(value)
+}
+
+func (s *appSettingService) GetJSON(key string, v interface{}) (err error) {
+	value, err := s.getValue(key, domain.SettingTypeJSON)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(value), v)
+}
+
+func (s *appSettingService) GetList(key string) (result []string, err error) {
+	value, err := s.getValue(key, domain.SettingTypeList)
+	if err != nil {
+		return result, err
+	}
+	return domain.ParseSettingList(value), nil
+}
+
+// getValue returns the raw value of a global setting, or its declared default when it isn't set.
+// Settings that are declared with a different type than the one requested are rejected.
+// Sensitive values are returned as is, since the typed getters are only used by the platform itself.
+func (s *appSettingService) getValue(key string, t domain.SettingType) (result string, err error) {
+	// Make sure the setting is being read as the declared type
+	def, defined := domain.SettingDefinitions[key]
+	if defined && def.Type != t {
+		return result, fmt.Errorf("setting %s is of type %s, not %s", key, def.Type, t)
+	}
+
+	// Find the setting
+	settings, _, err := s.filter(domain.ContextAsPlatform(context.TODO()), domain.FilterSettingsByCriteriaInput{Keys: []string{key}, Scope: domain.SettingScopeGlobal}, domain.QueryOptions{})
+	if err != nil {
+		return result, err
+	}
+	if len(settings) > 0 {
+		return settings[0].Value, nil
+	}
+
+	// Fall back to the default value
+	if !defined {
+		return result, domain.DataNotFoundError{}
+	}
+	return def.Default, nil
+}
+
+// canReadSensitive checks if the role of the caller is granted the permission to read the values of sensitive settings
+func (s *appSettingService) canReadSensitive(ctx context.Context) bool {
+	role, _ := domain.RoleFromContext(ctx)
+	return s.p.Can(role, domain.PermissionSettingReadSensitive)
+}
+
+// maskSetting masks the value of the setting if it's sensitive and the caller can't read it
+func (s *appSettingService) maskSetting(ctx context.Context, setting domain.Setting) domain.Setting {
+	if s.canReadSensitive(ctx) {
+		return setting
+	}
+	return setting.Masked()
+}
+
+// maskSettings masks the values of the sensitive settings if the caller can't read them
+func (s *appSettingService) maskSettings(ctx context.Context, settings []domain.Setting) []domain.Setting {
+	if s.canReadSensitive(ctx) {
+		return settings
+	}
+	for idx, v := range settings {
+		settings[idx] = v.Masked()
+	}
+	return settings
+}
+
+// maskImportResult masks the values of the sensitive settings reported by an import if the caller can't read them
+func (s *appSettingService) maskImportResult(ctx context.Context, result domain.SettingImportResult) domain.SettingImportResult {
+	if s.canReadSensitive(ctx) {
+		return result
+	}
+	for idx, v := range result.Added {
+		result.Added[idx] = v.Masked()
+	}
+	for idx, v := range result.Changed {
+		result.Changed[idx] = v.Masked()
+	}
+	for idx, v := range result.Removed {
+		result.Removed[idx] = v.Masked()
+	}
+	return result
+}
+
+// isSensitive checks if a setting is sensitive, either because it's flagged as such or because it's declared as such
+func isSensitive(key string, sensitive bool) bool {
+	return sensitive || domain.SettingDefinitions[key].Sensitive
+}
+
+// isMaskedValue checks if a value given for a setting is the mask of its sensitive value,
+// as returned to callers who can't read it, which must leave the value unchanged rather than replace it
+func isMaskedValue(setting domain.Setting, value string) bool {
+	return isSensitive(setting.Key, setting.Sensitive) && value == domain.SettingMaskedValue
+}
+
+// validateValue validates the value of a setting against its declaration in the registry.
+// Settings that are not declared in the registry are treated as free-form strings.
+func (s *appSettingService) validateValue(key, value string) (err error) {
+	def, ok := domain.SettingDefinitions[key]
+	if !ok {
+		return nil
+	}
+	return def.Validate(value)
+}
+
+// recordRevisions records the state of the settings right after they were changed by an operation
+func (s *appSettingService) recordRevisions(ctx context.Context, operation string, settings ...domain.Setting) (err error) {
+	// Find out who made the change
+	var changedBy *uuid.UUID
+	if userID, ok := domain.UserIDFromContext(ctx); ok {
+		changedBy = &userID
+	}
+
+	// Create the revisions
+	revisions := make([]*domain.SettingRevision, 0, len(settings))
+	for _, v := range settings {
+		revisions = append(revisions, &domain.SettingRevision{
+			SettingID:      v.ID,
+			Key:            v.Key,
+			Value:          v.Value,
+			Scope:          v.Scope,
+			ScopeID:        v.ScopeID,
+			OrganizationID: v.OrganizationID,
+			Sensitive:      v.Sensitive,
+			Operation:      operation,
+			ChangedBy:      changedBy,
+		})
+	}
+	err = s.rr.CreateMultiple(ctx, revisions)
+	if err != nil {
+		return err
+	}
+
+	// Record the events to publish to other systems, leaving out the values of sensitive settings
+	events := make([]*domain.OutboxEvent, 0, len(settings))
+	for _, v := range settings {
+		payload := domain.SettingChangedPayload{
+			ID:        v.ID,
+			Key:       v.Key,
+			Scope:     v.Scope,
+			ScopeID:   v.ScopeID,
+			Sensitive: v.Sensitive,
+			Version:   v.Version,
+			Operation: operation,
+			ChangedBy: changedBy,
+		}
+		if !v.Sensitive {
+			payload.Value = &v.Value
+		}
+		b, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		events = append(events, &domain.OutboxEvent{
+			Topic:       domain.OutboxTopicSettingChanged,
+			AggregateID: v.ID,
+			Payload:     b,
+		})
+	}
+	return s.o.Add(ctx, events...)
+}
+
+// settingIdentity identifies a setting that isn't deleted, since a key can only be used once within a scope
+type settingIdentity struct {
+	key     string
+	scope   string
+	scopeID uuid.UUID
+}
+
+func identityOf(setting domain.Setting) settingIdentity {
+	result := settingIdentity{
+		key:   setting.Key,
+		scope: setting.Scope,
+	}
+	if setting.ScopeID != nil {
+		result.scopeID = *setting.ScopeID
+	}
+	return result
+}
+
+// ensureUnique makes sure that the settings being written don't share a key within the same scope,
+// either with each other or with the settings that are already stored.
+// Settings being written that already exist are identified by their ID.
+func (s *appSettingService) ensureUnique(ctx context.Context, settings ...domain.Setting) (err error) {
+	// Check the settings being written against each other
+	written := make(map[settingIdentity]bool, len(settings))
+	ids := make(map[uuid.UUID]bool, len(settings))
+	in := domain.FilterSettingsByCriteriaInput{
+		Keys: make([]string, 0, len(settings)),
+	}
+	for _, v := range settings {
+		identity := identityOf(v)
+		if written[identity] {
+			return domain.UserError{
+				Code:    domain.ErrorCodeINVALIDREQUEST,
+				Message: fmt.Sprintf("The key %s is specified more than once for the %s scope", v.Key, v.Scope),
+			}
+		}
+		written[identity] = true
+		ids[v.ID] = true
+		in.Keys = append(in.Keys, v.Key)
+	}
+
+	// Check the settings being written against the stored ones
+	existing, _, err := s.r.Filter(ctx, in, domain.QueryOptions{})
+	if err != nil {
+		return err
+	}
+	for _, v := range existing {
+		if ids[v.ID] {
+			continue
+		}
+		if written[identityOf(v)] {
+			return domain.UserError{
+				Code:    domain.ErrorCodeINVALIDREQUEST,
+				Message: fmt.Sprintf("A setting with the key %s already exists for the %s scope", v.Key, v.Scope),
+			}
+		}
+	}
+	return nil
+}
+
+// ensureVersion makes sure that a setting hasn't been changed since the version the caller expects it to be at.
+// A version of 0 means that the caller doesn't expect any version in particular.
+func ensureVersion(setting domain.Setting, version int64) (err error) {
+	if version == 0 || version == setting.Version {
+		return nil
+	}
+	return domain.ConflictError{
+		Code:    domain.ErrorCodeCONFLICT,
+		Message: domain.MessageVERSIONCONFLICT,
+	}
+}
+
+// validateScope validates that the scope ID is given for organization and user scopes only.
+// It returns the scope, defaulting to the global scope.
+func validateScope(scope string, scopeID *uuid.UUID) (result string, err error) {
+	result = scope
+	if result == "" {
+		result = domain.SettingScopeGlobal
+	}
+	hasScopeID := scopeID != nil && *scopeID != uuid.Nil
+	switch {
+	case result == domain.SettingScopeGlobal && scopeID != nil:
+		return result, domain.UserError{
+			Code:    domain.ErrorCodeINVALIDREQUEST,
+			Message: "Global settings can't have a scope ID",
+		}
+	case result != domain.SettingScopeGlobal && !hasScopeID:
+		return result, domain.UserError{
+			Code:    domain.ErrorCodeINVALIDREQUEST,
+			Message: fmt.Sprintf("Settings with the %s scope must have a scope ID", result),
+		}
+	}
+	return result, nil
+}
+
+// organizationOf returns the organization the caller acts for, if any
+func organizationOf(ctx context.Context) *uuid.UUID {
+	organizationID, ok := domain.OrganizationIDFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	return &organizationID
+}
+
+// ownerOf returns the organization that owns a setting with the scope: the organization it's scoped to, or else the organization
+// the caller acts for. Global settings are owned by no organization, so that they are shared by all of them.
+func ownerOf(ctx context.Context, scope string, scopeID *uuid.UUID) *uuid.UUID {
+	switch scope {
+	case domain.SettingScopeGlobal:
+		return nil
+	case domain.SettingScopeOrganization:
+		return scopeID
+	}
+	return organizationOf(ctx)
+}
+
+// ensureOwned makes sure that the caller can change a setting owned by the organization, the same way as the row level security policies of settings.
+// Callers can only change the settings of the organization they act for, and not the global ones, which only the platform itself can change.
+func ensureOwned(ctx context.Context, organizationID *uuid.UUID) (err error) {
+	if domain.PlatformFromContext(ctx) {
+		return nil
+	}
+	caller := organizationOf(ctx)
+	if caller == nil || organizationID == nil || *organizationID != *caller {
+		return domain.ForbiddenAccessError{}
+	}
+	return nil
+}
+
+// scopeRank ranks how specifically a setting applies to the caller, with 0 meaning it doesn't apply at all
+func scopeRank(setting domain.Setting, claims domain.Claims) int {
+	switch setting.Scope {
+	case domain.SettingScopeUser:
+		if setting.ScopeID != nil && claims.UserID != uuid.Nil && *setting.ScopeID == claims.UserID {
+			return 3
+		}
+	case domain.SettingScopeOrganization:
+		if setting.ScopeID != nil && claims.OrganizationID != uuid.Nil && *setting.ScopeID == claims.OrganizationID {
+			return 2
+		}
+	case domain.SettingScopeGlobal:
+		return 1
+	}
+	return 0
+}
+
+// runReencryption re-encrypts the values of sensitive settings periodically or when requested, until the context is done
+func (s *appSettingService) runReencryption(ctx context.Context) {
+	ticker := time.NewTicker(reencryptPeriod)
+	defer ticker.Stop()
+	for {
+		s.reencrypt(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.reencryptRequests:
+		}
+	}
+}
+
+// reencrypt re-encrypts the values of sensitive settings & their revisions that are encrypted with retired data keys,
+// then deletes the data keys that are no longer used
+func (s *appSettingService) reencrypt(ctx context.Context) {
+	for _, fn := range []func(ctx context.Context, limit int) (int64, error){s.r.Reencrypt, s.rr.Reencrypt} {
+		for {
+			count, err := fn(ctx, reencryptBatchSize)
+			if err != nil {
+				if ctx.Err() == nil {
+					slog.Error("failed to re-encrypt sensitive settings", "error", err)
+				}
+				return
+			}
+			if count < reencryptBatchSize {
+				break
+			}
+		}
+	}
+
+	_, err := s.k.Prune(ctx)
+	if err != nil && ctx.Err() == nil {
+		slog.Error("failed to prune setting data keys", "error", err)
+	}
+}
+
+// reload replaces the contents of the cache with all the settings in the database
+func (s *appSettingService) reload() (err error) {
+	settings, _, err := s.r.Filter(domain.ContextWithPrimaryReads(domain.ContextAsPlatform(context.TODO())), domain.FilterSettingsByCriteriaInput{}, domain.QueryOptions{})
+	if err != nil {
+		return err
+	}
+	s.cache.replaceAll(settings)
+	return nil
+}
+
+// onListenerConnect reloads the cache since changes may have been missed while the listener was disconnected
+func (s *appSettingService) onListenerConnect() {
+	err := s.reload()
+	if err != nil {
+		slog.Error("failed to reload settings", "error", err)
+	}
+}
+
+// onSettingChange refreshes the changed setting in the cache and notifies the subscribers
+func (s *appSettingService) onSettingChange(event domain.SettingChangeEvent) {
+	// Refresh the setting from the primary, which the replicas may not have caught up with yet
+	setting, err := s.r.FindByID(domain.ContextWithPrimaryReads(domain.ContextAsPlatform(context.TODO())), event.ID)
+	switch {
+	case err == nil:
+		s.cache.put(setting)
+	case errors.Is(err, domain.DataNotFoundError{}):
+		s.cache.remove(event.ID)
+	default:
+		slog.Error("failed to refresh setting", "id", event.ID, "error", err)
+		s.cache.remove(event.ID)
+	}
+
+	// Notify the subscribers
+	s.subscribersMu.RLock()
+	subscribers := make([]func(event domain.SettingChangeEvent), 0, len(s.subscribers))
+	for _, fn := range s.subscribers {
+		subscribers = append(subscribers, fn)
+	}
+	s.subscribersMu.RUnlock()
+	for _, fn := range subscribers {
+		fn(event)
+	}
 }
 
 // Creates default settings in the system
 func (s *appSettingService) createDefaultSettings() {
 	settingsToCreate := make([]*domain.Setting, 0)
-	existingSettings, _, err := s.r.Filter(context.TODO(), domain.FilterSettingsByCriteriaInput{}, domain.QueryOptions{})
+	existingSettings, _, err := s.r.Filter(domain.ContextWithPrimaryReads(domain.ContextAsPlatform(context.TODO())), domain.FilterSettingsByCriteriaInput{Scope: domain.SettingScopeGlobal}, domain.QueryOptions{})
 	if err != nil {
 		log.Fatalf("Error getting existing settings: %v", err)
 	}
 
 	// Check if default settings already exist
-	for k, v := range domain.SettingDefaultValues {
+	for k, v := range domain.SettingDefinitions {
 		settingFound := false
 		for _, s := range existingSettings {
 			if s.Key == k {
@@ -52,8 +1295,10 @@ func (s *appSettingService) createDefaultSettings() {
 		}
 		if !settingFound {
 			settingsToCreate = append(settingsToCreate, &domain.Setting{
-				Key:   k,
-				Value: v,
+				Key:       k,
+				Value:     v.Default,
+				Scope:     domain.SettingScopeGlobal,
+				Sensitive: v.Sensitive,
 			})
 		}
 	}
@@ -63,10 +1308,63 @@ func (s *appSettingService) createDefaultSettings() {
 		return
 	}
 
-	// Create default settings
-	log.Println("Creating default settings...")
-	err = s.r.CreateMultiple(context.TODO(), settingsToCreate)
+	// Create the default settings that other instances haven't created in the meantime
+	var createdSettings []domain.Setting
+	err = s.tr.WithinTransaction(domain.ContextAsPlatform(context.TODO()), func(ctx context.Context) (err error) {
+		// Forget the settings created by an earlier attempt that was rolled back
+		for _, v := range settingsToCreate {
+			v.ID = uuid.Nil
+		}
+		err = s.r.CreateMissing(ctx, settingsToCreate)
+		if err != nil {
+			return err
+		}
+		createdSettings = make([]domain.Setting, 0, len(settingsToCreate))
+		for _, v := range settingsToCreate {
+			if v.ID != uuid.Nil {
+				createdSettings = append(createdSettings, *v)
+			}
+		}
+		if len(createdSettings) == 0 {
+			return nil
+		}
+		return s.recordRevisions(ctx, domain.SettingRevisionOperationCreate, createdSettings...)
+	})
 	if err != nil {
 		log.Fatalf("Error creating default settings: %v", err)
 	}
+	if len(createdSettings) > 0 {
+		log.Printf("Created %d default settings", len(createdSettings))
+	}
+}
+
+// exportItemOf returns the setting as it is exported to other environments
+func exportItemOf(setting domain.Setting) domain.SettingExportItem {
+	return domain.SettingExportItem{
+		Key:       setting.Key,
+		Value:     setting.Value,
+		Scope:     setting.Scope,
+		ScopeID:   setting.ScopeID,
+		Sensitive: setting.Sensitive,
+	}
+}
+
+// compareSettings orders settings by key, then scope
+func compareSettings(a, b domain.Setting) int {
+	if c := strings.Compare(a.Key, b.Key); c != 0 {
+		return c
+	}
+	if c := strings.Compare(a.Scope, b.Scope); c != 0 {
+		return c
+	}
+	return strings.Compare(identityOf(a).scopeID.String(), identityOf(b).scopeID.String())
+}
+
+// derefSettings converts a slice of setting pointers into a slice of settings
+func derefSettings(entities []*domain.Setting) (result []domain.Setting) {
+	result = make([]domain.Setting, 0, len(entities))
+	for _, v := range entities {
+		result = append(result, *v)
+	}
+	return result
 }
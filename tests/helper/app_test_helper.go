@@ -2,19 +2,26 @@ package helper
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"log"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/go-playground/validator/v10"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/labstack/echo/v4"
 
+	"github.com/Intiqo/app-platform/internal/database"
 	"github.com/Intiqo/app-platform/internal/dependency"
+	"github.com/Intiqo/app-platform/internal/domain"
 	"github.com/Intiqo/app-platform/internal/http/api"
 	"github.com/Intiqo/app-platform/internal/http/transport"
 	"github.com/Intiqo/app-platform/internal/pkg/config"
+	"github.com/Intiqo/app-platform/internal/pkg/security"
 )
 
 type echoHandler func(c echo.Context) error
@@ -22,6 +29,48 @@ type TearDownSuite func(tb testing.TB)
 
 // SetupSuite sets up the test suite
 func SetupSuite(tb testing.TB) (a *api.AppApi, e *echo.Echo, td TearDownSuite) {
+	cfg, awsCfg, db := setupDatabase(tb)
+
+	// Create a new Echo instance
+	e = echo.New()
+	// Set up the validator middleware
+	e.Validator = &transport.CustomValidator{Validator: validator.New()}
+
+	a, cleanup, err := dependency.NewAppApi(
+		cfg, awsCfg, db,
+	)
+	if err != nil {
+		tb.Fatalf("Error initializing the dependency graph: %v", err)
+	}
+
+	td = func(tb testing.TB) {
+		cleanup()
+		db.Close()
+	}
+
+	return a, e, td
+}
+
+// SetupDatabase sets up a migrated database, for the tests that query it directly
+func SetupDatabase(tb testing.TB) (db *pgxpool.Pool, td TearDownSuite) {
+	_, _, db = setupDatabase(tb)
+	td = func(tb testing.TB) {
+		db.Close()
+	}
+	return db, td
+}
+
+// SetupDependencies sets up a migrated database along with the config, for the tests that build the dependencies themselves
+func SetupDependencies(tb testing.TB) (cfg config.AppConfig, awsCfg aws.Config, db *pgxpool.Pool, td TearDownSuite) {
+	cfg, awsCfg, db = setupDatabase(tb)
+	td = func(tb testing.TB) {
+		db.Close()
+	}
+	return cfg, awsCfg, db, td
+}
+
+// setupDatabase loads the config & connects to the database, applying the pending migrations
+func setupDatabase(tb testing.TB) (cfg config.AppConfig, awsCfg aws.Config, db *pgxpool.Pool) {
 	opts := config.Options{
 		ConfigSource: config.SourceEnv,
 		ConfigFile:   "../../test.env",
@@ -32,40 +81,35 @@ func SetupSuite(tb testing.TB) (a *api.AppApi, e *echo.Echo, td TearDownSuite) {
 		log.Fatalf("failed to load aws config: %v", err)
 	}
 
-	cfg, err := dependency.NewConfig(awsCfg, opts)
+	cfg, err = dependency.NewConfig(awsCfg, opts)
 	if err != nil {
 		tb.Fatalf("Error initializing the config: %v", err)
 	}
 
 	// Initialize the database
-	db, err := dependency.NewDatabase(cfg)
+	db, err = dependency.NewDatabase(cfg)
 	if err != nil {
 		tb.Fatalf("Error initializing the database: %v", err)
 	}
 
-	// Create a new Echo instance
-	e = echo.New()
-	// Set up the validator middleware
-	e.Validator = &transport.CustomValidator{Validator: validator.New()}
-
-	a, err = dependency.NewAppApi(
-		cfg, awsCfg, db,
-	)
+	// Apply the pending migrations
+	m, err := database.NewMigrator(db)
 	if err != nil {
-		tb.Fatalf("Error initializing the dependency graph: %v", err)
+		tb.Fatalf("Error loading the migrations: %v", err)
 	}
-
-	td = func(tb testing.TB) {
-		db.Close()
+	_, err = m.Up(context.Background())
+	if err != nil {
+		tb.Fatalf("Error migrating the database: %v", err)
 	}
 
-	return a, e, td
+	return cfg, awsCfg, db
 }
 
-// SendRequest sends a request to the given handler
+// SendRequest sends a request to the given handler.
+// Requests are made by the platform itself, which sees the settings of every organization, unless the handler is wrapped with WithClaims.
 func SendRequest(e *echo.Echo, handler echoHandler, method, path string, pathParams map[string]string, queryParams map[string]string, body interface{}) (rec *httptest.ResponseRecorder, err error) {
 	var req *http.Request
-	if method == http.MethodPost || method == http.MethodPut || method == http.MethodDelete {
+	if method == http.MethodPost || method == http.MethodPut || method == http.MethodPatch || method == http.MethodDelete {
 		// Create a request with a JSON body
 		reqJSON, _ := json.Marshal(body)
 		req = httptest.NewRequest(method, path, bytes.NewReader(reqJSON))
@@ -75,6 +119,7 @@ func SendRequest(e *echo.Echo, handler echoHandler, method, path string, pathPar
 
 	// Set the headers
 	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req = req.WithContext(domain.ContextAsPlatform(req.Context()))
 
 	// Create a response recorder
 	rec = httptest.NewRecorder()
@@ -99,6 +144,35 @@ func SendRequest(e *echo.Echo, handler echoHandler, method, path string, pathPar
 	return rec, err
 }
 
+// WithClaims wraps the handler so that it's called on behalf of a caller with the given token claims
+func WithClaims(handler echoHandler, claims jwt.MapClaims) echoHandler {
+	return func(c echo.Context) error {
+		// Read the claims into the metadata, as the auth middleware does once it verifies the token
+		var metadata security.TokenMetadata
+		b, err := json.Marshal(claims)
+		if err != nil {
+			return err
+		}
+		err = json.Unmarshal(b, &metadata)
+		if err != nil {
+			return err
+		}
+		c.Set("user", metadata)
+
+		// Make the request on behalf of the caller, rather than the platform itself
+		c.SetRequest(c.Request().WithContext(context.WithValue(c.Request().Context(), domain.PlatformKey, false)))
+		return handler(c)
+	}
+}
+
+// WithHeader wraps the handler so that it's called with the given request header set
+func WithHeader(handler echoHandler, key, value string) echoHandler {
+	return func(c echo.Context) error {
+		c.Request().Header.Set(key, value)
+		return handler(c)
+	}
+}
+
 func ParseResponse(t *testing.T, rec *httptest.ResponseRecorder, resp interface{}) {
 	err := json.Unmarshal(rec.Body.Bytes(), &resp)
 	if err != nil {
@@ -1,13 +1,23 @@
 package integration
 
 import (
+	"context"
+	"encoding/json"
 	"net/http"
+	"net/http/httptest"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/gofrs/uuid/v5"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
 
+	"github.com/Intiqo/app-platform/internal/dependency"
 	"github.com/Intiqo/app-platform/internal/domain"
+	"github.com/Intiqo/app-platform/internal/http/api"
+	"github.com/Intiqo/app-platform/internal/http/transport"
 	"github.com/Intiqo/app-platform/tests/helper"
 )
 
@@ -234,3 +244,1329 @@ func TestFindSettingByID(t *testing.T) {
 		}
 	})
 }
+
+func TestCreateSetting(t *testing.T) {
+	t.Run("should create a setting", func(t *testing.T) {
+		// Setup the tests
+		tApi, e, teardownSuite := helper.SetupSuite(t)
+		defer teardownSuite(t)
+
+		// Create and send a request
+		reqBody := domain.CreateSettingInput{
+			Key:   "test.create_setting",
+			Value: "created",
+		}
+		rec, err := helper.SendRequest(e, tApi.SettingHandler.Create, http.MethodPost, "/setting", nil, nil, reqBody)
+		if err != nil {
+			t.Fatalf("Error sending request: %v", err)
+		}
+
+		// Check the status code
+		codeWanted := http.StatusCreated
+		codeGot := rec.Code
+		if codeWanted != codeGot {
+			t.Fatalf("Wanted status code %v, got %v", codeWanted, codeGot)
+		}
+
+		// Parse & verify the data
+		var bResp domain.BaseResponse
+		helper.ParseResponse(t, rec, &bResp)
+		var cData domain.Setting
+		helper.ParseEntityData(t, bResp.Data, &cData)
+		defer deleteSetting(t, tApi, e, cData.ID)
+		if cData.ID == uuid.Nil {
+			t.Fatalf("Wanted valid setting ID, got %v", cData.ID)
+		}
+		if cData.Key != reqBody.Key || cData.Value != reqBody.Value {
+			t.Fatalf("Wanted setting %v=%v, got %v=%v", reqBody.Key, reqBody.Value, cData.Key, cData.Value)
+		}
+	})
+
+	t.Run("should return error for a key that is in use", func(t *testing.T) {
+		// Setup the tests
+		tApi, e, teardownSuite := helper.SetupSuite(t)
+		defer teardownSuite(t)
+
+		// Create and send a request
+		reqBody := domain.CreateSettingInput{
+			Key:   domain.SettingKeyAppName,
+			Value: "Duplicate",
+		}
+		_, err := helper.SendRequest(e, tApi.SettingHandler.Create, http.MethodPost, "/setting", nil, nil, reqBody)
+		if _, ok := err.(domain.UserError); !ok {
+			t.Fatalf("Wanted user error, got %v", err)
+		}
+	})
+
+	t.Run("should return error for a missing key", func(t *testing.T) {
+		// Setup the tests
+		tApi, e, teardownSuite := helper.SetupSuite(t)
+		defer teardownSuite(t)
+
+		// Create and send a request
+		reqBody := domain.CreateSettingInput{
+			Value: "no key",
+		}
+		_, err := helper.SendRequest(e, tApi.SettingHandler.Create, http.MethodPost, "/setting", nil, nil, reqBody)
+		if err == nil {
+			t.Fatalf("Expected error, but got nothing")
+		}
+	})
+
+	t.Run("should create only one of the settings with the same key created concurrently", func(t *testing.T) {
+		// Setup the tests
+		tApi, e, teardownSuite := helper.SetupSuite(t)
+		defer teardownSuite(t)
+
+		// Create and send the requests at the same time
+		reqBody := domain.CreateSettingInput{
+			Key:   "test.concurrent_setting",
+			Value: "concurrent",
+		}
+		var wg sync.WaitGroup
+		errs := make([]error, 5)
+		recs := make([]*httptest.ResponseRecorder, len(errs))
+		for idx := range errs {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				recs[idx], errs[idx] = helper.SendRequest(e, tApi.SettingHandler.Create, http.MethodPost, "/setting", nil, nil, reqBody)
+			}()
+		}
+		wg.Wait()
+
+		// Verify that only one of the requests succeeded
+		created := 0
+		for idx, err := range errs {
+			if err == nil {
+				created++
+				var bResp domain.BaseResponse
+				helper.ParseResponse(t, recs[idx], &bResp)
+				var entityData domain.Setting
+				helper.ParseEntityData(t, bResp.Data, &entityData)
+				defer deleteSetting(t, tApi, e, entityData.ID)
+				continue
+			}
+			if _, ok := err.(domain.UserError); !ok {
+				t.Fatalf("Wanted user error, got %v", err)
+			}
+		}
+		if created != 1 {
+			t.Fatalf("Wanted %v settings created, got %v", 1, created)
+		}
+	})
+}
+
+func TestCreateAndDeleteMultipleSettings(t *testing.T) {
+	t.Run("should create and delete multiple settings", func(t *testing.T) {
+		// Setup the tests
+		tApi, e, teardownSuite := helper.SetupSuite(t)
+		defer teardownSuite(t)
+
+		// Create and send a request
+		reqBody := domain.CreateSettingsInput{
+			Settings: []domain.CreateSettingInput{
+				{Key: "test.bulk_one", Value: "1"},
+				{Key: "test.bulk_two", Value: "2"},
+			},
+		}
+		rec, err := helper.SendRequest(e, tApi.SettingHandler.CreateMultiple, http.MethodPost, "/setting/bulk", nil, nil, reqBody)
+		if err != nil {
+			t.Fatalf("Error sending request: %v", err)
+		}
+
+		// Check the status code
+		codeWanted := http.StatusCreated
+		codeGot := rec.Code
+		if codeWanted != codeGot {
+			t.Fatalf("Wanted status code %v, got %v", codeWanted, codeGot)
+		}
+
+		// Parse & verify the data
+		var bResp domain.BaseResponse
+		helper.ParseResponse(t, rec, &bResp)
+		var entityData []domain.Setting
+		helper.ParseEntityData(t, bResp.Data, &entityData)
+		lenWanted := 2
+		lenGot := len(entityData)
+		if lenWanted != lenGot {
+			t.Fatalf("Wanted %v settings, got %v", lenWanted, lenGot)
+		}
+
+		// Delete the settings
+		delBody := domain.DeleteSettingsInput{
+			IDs: []uuid.UUID{entityData[0].ID, entityData[1].ID},
+		}
+		rec, err = helper.SendRequest(e, tApi.SettingHandler.DeleteByIDs, http.MethodDelete, "/setting/bulk", nil, nil, delBody)
+		if err != nil {
+			t.Fatalf("Error sending request: %v", err)
+		}
+
+		// Check the status code
+		codeWanted = http.StatusNoContent
+		codeGot = rec.Code
+		if codeWanted != codeGot {
+			t.Fatalf("Wanted status code %v, got %v", codeWanted, codeGot)
+		}
+	})
+
+	t.Run("should not create any setting when a key is repeated", func(t *testing.T) {
+		// Setup the tests
+		tApi, e, teardownSuite := helper.SetupSuite(t)
+		defer teardownSuite(t)
+
+		// Create and send a request
+		reqBody := domain.CreateSettingsInput{
+			Settings: []domain.CreateSettingInput{
+				{Key: "test.bulk_repeat", Value: "1"},
+				{Key: "test.bulk_repeat", Value: "2"},
+			},
+		}
+		_, err := helper.SendRequest(e, tApi.SettingHandler.CreateMultiple, http.MethodPost, "/setting/bulk", nil, nil, reqBody)
+		if _, ok := err.(domain.UserError); !ok {
+			t.Fatalf("Wanted user error, got %v", err)
+		}
+	})
+}
+
+func TestUpdateSetting(t *testing.T) {
+	t.Run("should replace a setting", func(t *testing.T) {
+		// Setup the tests
+		tApi, e, teardownSuite := helper.SetupSuite(t)
+		defer teardownSuite(t)
+
+		// Create a setting to update
+		setting := createSetting(t, tApi, e, "test.update_setting", "before")
+		defer deleteSetting(t, tApi, e, setting.ID)
+
+		// Create and send a request
+		pathParams := map[string]string{}
+		pathParams["id"] = setting.ID.String()
+		reqBody := domain.UpdateSettingInput{
+			Key:   "test.update_setting",
+			Value: "after",
+		}
+		rec, err := helper.SendRequest(e, tApi.SettingHandler.Update, http.MethodPut, "/setting/"+setting.ID.String(), pathParams, nil, reqBody)
+		if err != nil {
+			t.Fatalf("Error sending request: %v", err)
+		}
+
+		// Check the status code
+		codeWanted := http.StatusOK
+		codeGot := rec.Code
+		if codeWanted != codeGot {
+			t.Fatalf("Wanted status code %v, got %v", codeWanted, codeGot)
+		}
+
+		// Parse & verify the data
+		var bResp domain.BaseResponse
+		helper.ParseResponse(t, rec, &bResp)
+		var cData domain.Setting
+		helper.ParseEntityData(t, bResp.Data, &cData)
+		if cData.Value != reqBody.Value {
+			t.Fatalf("Wanted setting value %v, got %v", reqBody.Value, cData.Value)
+		}
+	})
+
+	t.Run("should patch only the value of a setting", func(t *testing.T) {
+		// Setup the tests
+		tApi, e, teardownSuite := helper.SetupSuite(t)
+		defer teardownSuite(t)
+
+		// Create a setting to patch
+		setting := createSetting(t, tApi, e, "test.patch_setting", "before")
+		defer deleteSetting(t, tApi, e, setting.ID)
+
+		// Create and send a request
+		pathParams := map[string]string{}
+		pathParams["id"] = setting.ID.String()
+		value := "after"
+		reqBody := domain.PatchSettingInput{
+			Value: &value,
+		}
+		rec, err := helper.SendRequest(e, tApi.SettingHandler.Patch, http.MethodPatch, "/setting/"+setting.ID.String(), pathParams, nil, reqBody)
+		if err != nil {
+			t.Fatalf("Error sending request: %v", err)
+		}
+
+		// Check the status code
+		codeWanted := http.StatusOK
+		codeGot := rec.Code
+		if codeWanted != codeGot {
+			t.Fatalf("Wanted status code %v, got %v", codeWanted, codeGot)
+		}
+
+		// Parse & verify the data
+		var bResp domain.BaseResponse
+		helper.ParseResponse(t, rec, &bResp)
+		var cData domain.Setting
+		helper.ParseEntityData(t, bResp.Data, &cData)
+		if cData.Key != setting.Key || cData.Value != value {
+			t.Fatalf("Wanted setting %v=%v, got %v=%v", setting.Key, value, cData.Key, cData.Value)
+		}
+	})
+
+	t.Run("should return error when renaming to a key that is in use", func(t *testing.T) {
+		// Setup the tests
+		tApi, e, teardownSuite := helper.SetupSuite(t)
+		defer teardownSuite(t)
+
+		// Create a setting to patch
+		setting := createSetting(t, tApi, e, "test.rename_setting", "value")
+		defer deleteSetting(t, tApi, e, setting.ID)
+
+		// Create and send a request
+		pathParams := map[string]string{}
+		pathParams["id"] = setting.ID.String()
+		key := domain.SettingKeyAppName
+		reqBody := domain.PatchSettingInput{
+			Key: &key,
+		}
+		_, err := helper.SendRequest(e, tApi.SettingHandler.Patch, http.MethodPatch, "/setting/"+setting.ID.String(), pathParams, nil, reqBody)
+		if _, ok := err.(domain.UserError); !ok {
+			t.Fatalf("Wanted user error, got %v", err)
+		}
+	})
+}
+
+func TestDeleteSettingByID(t *testing.T) {
+	t.Run("should delete a setting", func(t *testing.T) {
+		// Setup the tests
+		tApi, e, teardownSuite := helper.SetupSuite(t)
+		defer teardownSuite(t)
+
+		// Create a setting to delete
+		setting := createSetting(t, tApi, e, "test.delete_setting", "value")
+
+		// Delete the setting
+		deleteSetting(t, tApi, e, setting.ID)
+
+		// Make sure the setting can no longer be found
+		pathParams := map[string]string{}
+		pathParams["id"] = setting.ID.String()
+		_, err := helper.SendRequest(e, tApi.SettingHandler.FindByID, http.MethodGet, "/setting/"+setting.ID.String(), pathParams, nil, nil)
+		if _, ok := err.(domain.DataNotFoundError); !ok {
+			t.Fatalf("Wanted data not found error, got %v", err)
+		}
+	})
+
+	t.Run("should return error for an unknown setting", func(t *testing.T) {
+		// Setup the tests
+		tApi, e, teardownSuite := helper.SetupSuite(t)
+		defer teardownSuite(t)
+
+		id := uuid.Must(uuid.NewV4()).String()
+		pathParams := map[string]string{}
+		pathParams["id"] = id
+
+		// Create and send a request
+		_, err := helper.SendRequest(e, tApi.SettingHandler.DeleteByID, http.MethodDelete, "/setting/"+id, pathParams, nil, nil)
+		if _, ok := err.(domain.DataNotFoundError); !ok {
+			t.Fatalf("Wanted data not found error, got %v", err)
+		}
+	})
+}
+
+// createSetting creates a setting through the API and fails the test if it couldn't be created
+func createSetting(t *testing.T, tApi *api.AppApi, e *echo.Echo, key, value string) (result domain.Setting) {
+	reqBody := domain.CreateSettingInput{
+		Key:   key,
+		Value: value,
+	}
+	rec, err := helper.SendRequest(e, tApi.SettingHandler.Create, http.MethodPost, "/setting", nil, nil, reqBody)
+	if err != nil {
+		t.Fatalf("Error creating setting: %v", err)
+	}
+	var bResp domain.BaseResponse
+	helper.ParseResponse(t, rec, &bResp)
+	helper.ParseEntityData(t, bResp.Data, &result)
+	return result
+}
+
+// deleteSetting deletes a setting through the API and fails the test if it couldn't be deleted
+func deleteSetting(t *testing.T, tApi *api.AppApi, e *echo.Echo, id uuid.UUID) {
+	pathParams := map[string]string{}
+	pathParams["id"] = id.String()
+	rec, err := helper.SendRequest(e, tApi.SettingHandler.DeleteByID, http.MethodDelete, "/setting/"+id.String(), pathParams, nil, nil)
+	if err != nil {
+		t.Fatalf("Error deleting setting: %v", err)
+	}
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("Wanted status code %v, got %v", http.StatusNoContent, rec.Code)
+	}
+}
+
+func TestSettingDefinitions(t *testing.T) {
+	t.Run("should list the setting definitions", func(t *testing.T) {
+		// Setup the tests
+		tApi, e, teardownSuite := helper.SetupSuite(t)
+		defer teardownSuite(t)
+
+		// Create and send a request
+		rec, err := helper.SendRequest(e, tApi.SettingHandler.Definitions, http.MethodGet, "/setting/definition", nil, nil, nil)
+		if err != nil {
+			t.Fatalf("Error sending request: %v", err)
+		}
+
+		// Check the status code
+		codeWanted := http.StatusOK
+		codeGot := rec.Code
+		if codeWanted != codeGot {
+			t.Fatalf("Wanted status code %v, got %v", codeWanted, codeGot)
+		}
+
+		// Parse & verify the data
+		var bResp domain.BaseResponse
+		helper.ParseResponse(t, rec, &bResp)
+		var entityData []domain.SettingDefinition
+		helper.ParseEntityData(t, bResp.Data, &entityData)
+		lenWanted := len(domain.SettingDefinitions)
+		lenGot := len(entityData)
+		if lenWanted != lenGot {
+			t.Fatalf("Wanted %v definitions, got %v", lenWanted, lenGot)
+		}
+	})
+
+	t.Run("should reject a value that doesn't match the declared schema", func(t *testing.T) {
+		// Setup the tests
+		tApi, e, teardownSuite := helper.SetupSuite(t)
+		defer teardownSuite(t)
+
+		// Find the test phone code setting
+		reqBody := domain.FilterSettingsByCriteriaInput{
+			Keys: []string{domain.SettingTestPhoneCode},
+		}
+		rec, err := helper.SendRequest(e, tApi.SettingHandler.Filter, http.MethodPost, "/setting/filter", nil, nil, reqBody)
+		if err != nil {
+			t.Fatalf("Error sending request: %v", err)
+		}
+		var resp domain.PaginationResponse
+		helper.ParseResponse(t, rec, &resp)
+		var entityData []domain.Setting
+		helper.ParseEntityData(t, resp.Data, &entityData)
+		if len(entityData) != 1 {
+			t.Fatalf("Wanted 1 setting, got %v", len(entityData))
+		}
+
+		// Try to store a value that isn't a 6 digit code
+		pathParams := map[string]string{}
+		pathParams["id"] = entityData[0].ID.String()
+		value := "abc"
+		patchBody := domain.PatchSettingInput{
+			Value: &value,
+		}
+		_, err = helper.SendRequest(e, tApi.SettingHandler.Patch, http.MethodPatch, "/setting/"+entityData[0].ID.String(), pathParams, nil, patchBody)
+		if _, ok := err.(domain.ValidationError); !ok {
+			t.Fatalf("Wanted validation error, got %v", err)
+		}
+	})
+}
+
+func TestSeedDefaultSettings(t *testing.T) {
+	t.Run("should seed each default setting once when instances start concurrently", func(t *testing.T) {
+		// Setup the tests
+		cfg, awsCfg, db, teardownDependencies := helper.SetupDependencies(t)
+		defer teardownDependencies(t)
+
+		// Delete the default settings, so that every instance finds them missing
+		keys := make([]string, 0, len(domain.SettingDefinitions))
+		for k := range domain.SettingDefinitions {
+			keys = append(keys, k)
+		}
+		_, err := db.Exec(context.Background(), `UPDATE settings SET deleted_at = NOW() WHERE key = ANY($1) AND scope = 'global' AND deleted_at IS NULL`, keys)
+		if err != nil {
+			t.Fatalf("Error deleting the default settings: %v", err)
+		}
+
+		// Start the setting services of several instances at once, each seeding the default settings
+		instances := 5
+		var wg sync.WaitGroup
+		errs := make(chan error, instances)
+		for range instances {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, cleanup, err := dependency.NewSettingService(cfg, awsCfg, db)
+				if err != nil {
+					errs <- err
+					return
+				}
+				cleanup()
+			}()
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			t.Fatalf("Error creating the setting service: %v", err)
+		}
+
+		// Verify that each default setting exists exactly once
+		rows, err := db.Query(context.Background(), `SELECT key, COUNT(*) FROM settings WHERE key = ANY($1) AND scope = 'global' AND deleted_at IS NULL GROUP BY key`, keys)
+		if err != nil {
+			t.Fatalf("Error counting the default settings: %v", err)
+		}
+		defer rows.Close()
+		counts := make(map[string]int64)
+		for rows.Next() {
+			var key string
+			var count int64
+			err = rows.Scan(&key, &count)
+			if err != nil {
+				t.Fatalf("Error scanning the default settings: %v", err)
+			}
+			counts[key] = count
+		}
+		if rows.Err() != nil {
+			t.Fatalf("Error counting the default settings: %v", rows.Err())
+		}
+		for _, k := range keys {
+			if counts[k] != 1 {
+				t.Fatalf("Wanted 1 %s setting, got %v", k, counts[k])
+			}
+		}
+	})
+}
+
+func TestSettingCacheInvalidation(t *testing.T) {
+	t.Run("should see changes made by another instance", func(t *testing.T) {
+		// Setup two instances of the platform
+		tApi, e, teardownSuite := helper.SetupSuite(t)
+		defer teardownSuite(t)
+		tOtherApi, otherE, teardownOtherSuite := helper.SetupSuite(t)
+		defer teardownOtherSuite(t)
+
+		// Create a setting through the first instance
+		setting := createSetting(t, tApi, e, "test.cache_invalidation", "value")
+		defer deleteSetting(t, tApi, e, setting.ID)
+
+		// Wait for the second instance to be notified of the change
+		reqBody := domain.FilterSettingsByCriteriaInput{
+			Keys: []string{setting.Key},
+		}
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			rec, err := helper.SendRequest(otherE, tOtherApi.SettingHandler.Filter, http.MethodPost, "/setting/filter", nil, nil, reqBody)
+			if err != nil {
+				t.Fatalf("Error sending request: %v", err)
+			}
+			var resp domain.PaginationResponse
+			helper.ParseResponse(t, rec, &resp)
+			if resp.Total == 1 {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("Wanted the other instance to see setting %v, but it didn't", setting.Key)
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+	})
+}
+
+func TestSettingRevisions(t *testing.T) {
+	t.Run("should record revisions and roll back to one of them", func(t *testing.T) {
+		// Setup the tests
+		tApi, e, teardownSuite := helper.SetupSuite(t)
+		defer teardownSuite(t)
+
+		// Create a setting and change its value
+		setting := createSetting(t, tApi, e, "test.revision_setting", "first")
+		defer deleteSetting(t, tApi, e, setting.ID)
+		pathParams := map[string]string{}
+		pathParams["id"] = setting.ID.String()
+		value := "second"
+		patchBody := domain.PatchSettingInput{
+			Value: &value,
+		}
+		_, err := helper.SendRequest(e, tApi.SettingHandler.Patch, http.MethodPatch, "/setting/"+setting.ID.String(), pathParams, nil, patchBody)
+		if err != nil {
+			t.Fatalf("Error sending request: %v", err)
+		}
+
+		// List the revisions of the setting
+		queryParams := map[string]string{}
+		queryParams["key"] = setting.Key
+		rec, err := helper.SendRequest(e, tApi.SettingHandler.FindRevisions, http.MethodGet, "/setting/revision", nil, queryParams, nil)
+		if err != nil {
+			t.Fatalf("Error sending request: %v", err)
+		}
+
+		// Check the status code
+		codeWanted := http.StatusOK
+		codeGot := rec.Code
+		if codeWanted != codeGot {
+			t.Fatalf("Wanted status code %v, got %v", codeWanted, codeGot)
+		}
+
+		// Parse & verify the response
+		var resp domain.PaginationResponse
+		helper.ParseResponse(t, rec, &resp)
+		var revisions []domain.SettingRevision
+		helper.ParseEntityData(t, resp.Data, &revisions)
+		lenWanted := 2
+		lenGot := len(revisions)
+		if lenWanted != lenGot {
+			t.Fatalf("Wanted %v revisions, got %v", lenWanted, lenGot)
+		}
+		if revisions[0].Value != value || revisions[1].Operation != domain.SettingRevisionOperationCreate {
+			t.Fatalf("Wanted the latest revision first, got %v", revisions)
+		}
+
+		// Roll back to the first revision
+		pathParams = map[string]string{}
+		pathParams["id"] = revisions[1].ID.String()
+		rec, err = helper.SendRequest(e, tApi.SettingHandler.Rollback, http.MethodPost, "/setting/revision/"+revisions[1].ID.String()+"/rollback", pathParams, nil, nil)
+		if err != nil {
+			t.Fatalf("Error sending request: %v", err)
+		}
+
+		// Parse & verify the data
+		var bResp domain.BaseResponse
+		helper.ParseResponse(t, rec, &bResp)
+		var cData domain.Setting
+		helper.ParseEntityData(t, bResp.Data, &cData)
+		if cData.ID != setting.ID || cData.Value != setting.Value {
+			t.Fatalf("Wanted setting %v=%v, got %v=%v", setting.ID, setting.Value, cData.ID, cData.Value)
+		}
+	})
+
+	t.Run("should roll back the setting a revision was made to, after it's renamed", func(t *testing.T) {
+		// Setup the tests
+		tApi, e, teardownSuite := helper.SetupSuite(t)
+		defer teardownSuite(t)
+
+		// Create a setting and rename it
+		setting := createSetting(t, tApi, e, "test.revision_setting", "first")
+		defer deleteSetting(t, tApi, e, setting.ID)
+		pathParams := map[string]string{}
+		pathParams["id"] = setting.ID.String()
+		key := "test.renamed_revision_setting"
+		value := "second"
+		patchBody := domain.PatchSettingInput{
+			Key:   &key,
+			Value: &value,
+		}
+		_, err := helper.SendRequest(e, tApi.SettingHandler.Patch, http.MethodPatch, "/setting/"+setting.ID.String(), pathParams, nil, patchBody)
+		if err != nil {
+			t.Fatalf("Error sending request: %v", err)
+		}
+
+		// List the revisions of the setting
+		queryParams := map[string]string{}
+		queryParams["settingId"] = setting.ID.String()
+		rec, err := helper.SendRequest(e, tApi.SettingHandler.FindRevisions, http.MethodGet, "/setting/revision", nil, queryParams, nil)
+		if err != nil {
+			t.Fatalf("Error sending request: %v", err)
+		}
+		var resp domain.PaginationResponse
+		helper.ParseResponse(t, rec, &resp)
+		var revisions []domain.SettingRevision
+		helper.ParseEntityData(t, resp.Data, &revisions)
+		if len(revisions) != 2 {
+			t.Fatalf("Wanted 2 revisions, got %v", len(revisions))
+		}
+
+		// Roll back to the first revision
+		pathParams = map[string]string{}
+		pathParams["id"] = revisions[1].ID.String()
+		rec, err = helper.SendRequest(e, tApi.SettingHandler.Rollback, http.MethodPost, "/setting/revision/"+revisions[1].ID.String()+"/rollback", pathParams, nil, nil)
+		if err != nil {
+			t.Fatalf("Error sending request: %v", err)
+		}
+
+		// Parse & verify the data
+		var bResp domain.BaseResponse
+		helper.ParseResponse(t, rec, &bResp)
+		var cData domain.Setting
+		helper.ParseEntityData(t, bResp.Data, &cData)
+		if cData.ID != setting.ID || cData.Key != setting.Key || cData.Value != setting.Value {
+			t.Fatalf("Wanted setting %v %v=%v, got %v %v=%v", setting.ID, setting.Key, setting.Value, cData.ID, cData.Key, cData.Value)
+		}
+	})
+}
+
+func TestResolveSetting(t *testing.T) {
+	t.Run("should prefer the organization override of the caller", func(t *testing.T) {
+		// Setup the tests
+		tApi, e, teardownSuite := helper.SetupSuite(t)
+		defer teardownSuite(t)
+
+		// Override the app name for an organization
+		organizationID := uuid.Must(uuid.NewV4())
+		reqBody := domain.CreateSettingInput{
+			Key:     domain.SettingKeyAppName,
+			Value:   "Tenant App",
+			Scope:   domain.SettingScopeOrganization,
+			ScopeID: &organizationID,
+		}
+		rec, err := helper.SendRequest(e, tApi.SettingHandler.Create, http.MethodPost, "/setting", nil, nil, reqBody)
+		if err != nil {
+			t.Fatalf("Error sending request: %v", err)
+		}
+		var bResp domain.BaseResponse
+		helper.ParseResponse(t, rec, &bResp)
+		var setting domain.Setting
+		helper.ParseEntityData(t, bResp.Data, &setting)
+		defer deleteSetting(t, tApi, e, setting.ID)
+
+		// Resolve the setting as a member of the organization and as someone outside of it
+		for _, v := range []struct {
+			organizationID uuid.UUID
+			valueWanted    string
+		}{
+			{organizationID: organizationID, valueWanted: reqBody.Value},
+			{organizationID: uuid.Must(uuid.NewV4()), valueWanted: domain.SettingDefinitions[domain.SettingKeyAppName].Default},
+		} {
+			resolve := helper.WithClaims(tApi.SettingHandler.Resolve, jwt.MapClaims{"organization_id": v.organizationID.String()})
+			pathParams := map[string]string{}
+			pathParams["key"] = domain.SettingKeyAppName
+			rec, err = helper.SendRequest(e, resolve, http.MethodGet, "/setting/resolve/"+domain.SettingKeyAppName, pathParams, nil, nil)
+			if err != nil {
+				t.Fatalf("Error sending request: %v", err)
+			}
+
+			// Parse & verify the data
+			helper.ParseResponse(t, rec, &bResp)
+			var entityData domain.Setting
+			helper.ParseEntityData(t, bResp.Data, &entityData)
+			if v.valueWanted != entityData.Value {
+				t.Fatalf("Wanted value %v, got %v", v.valueWanted, entityData.Value)
+			}
+		}
+	})
+
+	t.Run("should reject an organization override without an organization", func(t *testing.T) {
+		// Setup the tests
+		tApi, e, teardownSuite := helper.SetupSuite(t)
+		defer teardownSuite(t)
+
+		// Create and send a request
+		reqBody := domain.CreateSettingInput{
+			Key:   domain.SettingKeyAppName,
+			Value: "Tenant App",
+			Scope: domain.SettingScopeOrganization,
+		}
+		_, err := helper.SendRequest(e, tApi.SettingHandler.Create, http.MethodPost, "/setting", nil, nil, reqBody)
+		if _, ok := err.(domain.UserError); !ok {
+			t.Fatalf("Wanted user error, got %v", err)
+		}
+	})
+}
+
+func TestExportAndImportSettings(t *testing.T) {
+	t.Run("should report the changes of a dry run and apply them otherwise", func(t *testing.T) {
+		// Setup the tests
+		tApi, e, teardownSuite := helper.SetupSuite(t)
+		defer teardownSuite(t)
+
+		// Export the settings
+		rec, err := helper.SendRequest(e, tApi.SettingHandler.Export, http.MethodGet, "/setting/export", nil, nil, nil)
+		if err != nil {
+			t.Fatalf("Error sending request: %v", err)
+		}
+		var export domain.SettingExport
+		helper.ParseResponse(t, rec, &export)
+		if len(export.Settings) == 0 {
+			t.Fatalf("Wanted the settings to be exported, got none")
+		}
+
+		// Add a setting to the export and import it as a dry run
+		export.Settings = append(export.Settings, domain.SettingExportItem{
+			Key:   "test.import_setting",
+			Value: "imported",
+		})
+		queryParams := map[string]string{}
+		queryParams["dryRun"] = "true"
+		rec, err = helper.SendRequest(e, tApi.SettingHandler.Import, http.MethodPost, "/setting/import", nil, queryParams, export)
+		if err != nil {
+			t.Fatalf("Error sending request: %v", err)
+		}
+		var bResp domain.BaseResponse
+		helper.ParseResponse(t, rec, &bResp)
+		var result domain.SettingImportResult
+		helper.ParseEntityData(t, bResp.Data, &result)
+		if len(result.Added) != 1 || len(result.Changed) != 0 || len(result.Removed) != 0 {
+			t.Fatalf("Wanted 1 setting to be added, got %v", result)
+		}
+
+		// Make sure the dry run didn't create the setting
+		reqBody := domain.FilterSettingsByCriteriaInput{
+			Keys: []string{"test.import_setting"},
+		}
+		rec, err = helper.SendRequest(e, tApi.SettingHandler.Filter, http.MethodPost, "/setting/filter", nil, nil, reqBody)
+		if err != nil {
+			t.Fatalf("Error sending request: %v", err)
+		}
+		var resp domain.PaginationResponse
+		helper.ParseResponse(t, rec, &resp)
+		if resp.Total != 0 {
+			t.Fatalf("Wanted the dry run not to create the setting, got %v settings", resp.Total)
+		}
+
+		// Import the settings for real
+		_, err = helper.SendRequest(e, tApi.SettingHandler.Import, http.MethodPost, "/setting/import", nil, nil, export)
+		if err != nil {
+			t.Fatalf("Error sending request: %v", err)
+		}
+
+		// Make sure the setting was created
+		rec, err = helper.SendRequest(e, tApi.SettingHandler.Filter, http.MethodPost, "/setting/filter", nil, nil, reqBody)
+		if err != nil {
+			t.Fatalf("Error sending request: %v", err)
+		}
+		helper.ParseResponse(t, rec, &resp)
+		var entityData []domain.Setting
+		helper.ParseEntityData(t, resp.Data, &entityData)
+		if len(entityData) != 1 {
+			t.Fatalf("Wanted the setting to be imported, got %v settings", len(entityData))
+		}
+		deleteSetting(t, tApi, e, entityData[0].ID)
+	})
+}
+
+func TestSensitiveSetting(t *testing.T) {
+	t.Run("should only reveal the value of a sensitive setting to authorised roles", func(t *testing.T) {
+		// Setup the tests
+		tApi, e, teardownSuite := helper.SetupSuite(t)
+		defer teardownSuite(t)
+
+		// Create a sensitive setting
+		reqBody := domain.CreateSettingInput{
+			Key:       "test.sensitive_setting",
+			Value:     "secret",
+			Sensitive: true,
+		}
+		rec, err := helper.SendRequest(e, tApi.SettingHandler.Create, http.MethodPost, "/setting", nil, nil, reqBody)
+		if err != nil {
+			t.Fatalf("Error sending request: %v", err)
+		}
+		var bResp domain.BaseResponse
+		helper.ParseResponse(t, rec, &bResp)
+		var setting domain.Setting
+		helper.ParseEntityData(t, bResp.Data, &setting)
+		defer deleteSetting(t, tApi, e, setting.ID)
+
+		// Make sure the value is masked in the response
+		if setting.Value != domain.SettingMaskedValue {
+			t.Fatalf("Wanted the value to be masked, got %v", setting.Value)
+		}
+
+		// Find the setting as an authorised role
+		pathParams := map[string]string{}
+		pathParams["id"] = setting.ID.String()
+		findByID := helper.WithClaims(tApi.SettingHandler.FindByID, jwt.MapClaims{"role": "admin"})
+		rec, err = helper.SendRequest(e, findByID, http.MethodGet, "/setting/"+setting.ID.String(), pathParams, nil, nil)
+		if err != nil {
+			t.Fatalf("Error sending request: %v", err)
+		}
+
+		// Parse & verify the data
+		helper.ParseResponse(t, rec, &bResp)
+		var entityData domain.Setting
+		helper.ParseEntityData(t, bResp.Data, &entityData)
+		if reqBody.Value != entityData.Value {
+			t.Fatalf("Wanted value %v, got %v", reqBody.Value, entityData.Value)
+		}
+	})
+
+	t.Run("should mask the value of a sensitive setting wherever the setting service returns it", func(t *testing.T) {
+		// Setup the tests
+		tApi, e, teardownSuite := helper.SetupSuite(t)
+		defer teardownSuite(t)
+
+		// Create a sensitive setting
+		reqBody := domain.CreateSettingInput{
+			Key:       "test.sensitive_setting",
+			Value:     "secret",
+			Sensitive: true,
+		}
+		rec, err := helper.SendRequest(e, tApi.SettingHandler.Create, http.MethodPost, "/setting", nil, nil, reqBody)
+		if err != nil {
+			t.Fatalf("Error sending request: %v", err)
+		}
+		var bResp domain.BaseResponse
+		helper.ParseResponse(t, rec, &bResp)
+		var setting domain.Setting
+		helper.ParseEntityData(t, bResp.Data, &setting)
+		defer deleteSetting(t, tApi, e, setting.ID)
+
+		// Resolve the setting
+		pathParams := map[string]string{}
+		pathParams["key"] = setting.Key
+		rec, err = helper.SendRequest(e, tApi.SettingHandler.Resolve, http.MethodGet, "/setting/resolve/"+setting.Key, pathParams, nil, nil)
+		if err != nil {
+			t.Fatalf("Error sending request: %v", err)
+		}
+		var resolved domain.Setting
+		helper.ParseResponse(t, rec, &bResp)
+		helper.ParseEntityData(t, bResp.Data, &resolved)
+		if resolved.Value != domain.SettingMaskedValue {
+			t.Fatalf("Wanted the resolved value to be masked, got %v", resolved.Value)
+		}
+
+		// List the revisions of the setting
+		queryParams := map[string]string{}
+		queryParams["settingId"] = setting.ID.String()
+		rec, err = helper.SendRequest(e, tApi.SettingHandler.FindRevisions, http.MethodGet, "/setting/revision", nil, queryParams, nil)
+		if err != nil {
+			t.Fatalf("Error sending request: %v", err)
+		}
+		var resp domain.PaginationResponse
+		helper.ParseResponse(t, rec, &resp)
+		var revisions []domain.SettingRevision
+		helper.ParseEntityData(t, resp.Data, &revisions)
+		if len(revisions) != 1 || revisions[0].Value != domain.SettingMaskedValue {
+			t.Fatalf("Wanted the value of the revision to be masked, got %v", revisions)
+		}
+
+		// Export the settings
+		rec, err = helper.SendRequest(e, tApi.SettingHandler.Export, http.MethodGet, "/setting/export", nil, nil, nil)
+		if err != nil {
+			t.Fatalf("Error sending request: %v", err)
+		}
+		var export domain.SettingExport
+		helper.ParseResponse(t, rec, &export)
+		for _, v := range export.Settings {
+			if v.Key == setting.Key && v.Value != domain.SettingMaskedValue {
+				t.Fatalf("Wanted the exported value to be masked, got %v", v.Value)
+			}
+		}
+	})
+
+	t.Run("should make a setting sensitive when it's renamed to a key declared as sensitive", func(t *testing.T) {
+		// Setup the tests
+		tApi, e, teardownSuite := helper.SetupSuite(t)
+		defer teardownSuite(t)
+
+		// Declare a sensitive setting
+		key := "test.declared_sensitive_setting"
+		domain.SettingDefinitions[key] = domain.SettingDefinition{
+			Key:       key,
+			Type:      domain.SettingTypeString,
+			Sensitive: true,
+		}
+		defer delete(domain.SettingDefinitions, key)
+
+		// Create a setting that isn't sensitive
+		reqBody := domain.CreateSettingInput{
+			Key:   "test.plain_setting",
+			Value: "secret",
+		}
+		rec, err := helper.SendRequest(e, tApi.SettingHandler.Create, http.MethodPost, "/setting", nil, nil, reqBody)
+		if err != nil {
+			t.Fatalf("Error sending request: %v", err)
+		}
+		var bResp domain.BaseResponse
+		helper.ParseResponse(t, rec, &bResp)
+		var setting domain.Setting
+		helper.ParseEntityData(t, bResp.Data, &setting)
+		defer deleteSetting(t, tApi, e, setting.ID)
+
+		// Rename the setting to the declared key
+		pathParams := map[string]string{}
+		pathParams["id"] = setting.ID.String()
+		patchBody := domain.PatchSettingInput{
+			Key: &key,
+		}
+		rec, err = helper.SendRequest(e, tApi.SettingHandler.Patch, http.MethodPatch, "/setting/"+setting.ID.String(), pathParams, nil, patchBody)
+		if err != nil {
+			t.Fatalf("Error sending request: %v", err)
+		}
+
+		// Parse & verify the data
+		helper.ParseResponse(t, rec, &bResp)
+		var entityData domain.Setting
+		helper.ParseEntityData(t, bResp.Data, &entityData)
+		if !entityData.Sensitive || entityData.Value != domain.SettingMaskedValue {
+			t.Fatalf("Wanted the renamed setting to be sensitive & masked, got %v", entityData)
+		}
+	})
+
+	t.Run("should leave the value of a sensitive setting unchanged when given masked", func(t *testing.T) {
+		// Setup the tests
+		tApi, e, teardownSuite := helper.SetupSuite(t)
+		defer teardownSuite(t)
+
+		// Create a sensitive setting
+		reqBody := domain.CreateSettingInput{
+			Key:       "test.sensitive_setting",
+			Value:     "secret",
+			Sensitive: true,
+		}
+		rec, err := helper.SendRequest(e, tApi.SettingHandler.Create, http.MethodPost, "/setting", nil, nil, reqBody)
+		if err != nil {
+			t.Fatalf("Error sending request: %v", err)
+		}
+		var bResp domain.BaseResponse
+		helper.ParseResponse(t, rec, &bResp)
+		var setting domain.Setting
+		helper.ParseEntityData(t, bResp.Data, &setting)
+		defer deleteSetting(t, tApi, e, setting.ID)
+
+		// Send the masked value back, as a caller who can't read it would
+		pathParams := map[string]string{}
+		pathParams["id"] = setting.ID.String()
+		updateBody := domain.UpdateSettingInput{
+			Key:   setting.Key,
+			Value: setting.Value,
+		}
+		_, err = helper.SendRequest(e, tApi.SettingHandler.Update, http.MethodPut, "/setting/"+setting.ID.String(), pathParams, nil, updateBody)
+		if err != nil {
+			t.Fatalf("Error sending request: %v", err)
+		}
+
+		// Find the setting as an authorised role
+		findByID := helper.WithClaims(tApi.SettingHandler.FindByID, jwt.MapClaims{"role": "admin"})
+		rec, err = helper.SendRequest(e, findByID, http.MethodGet, "/setting/"+setting.ID.String(), pathParams, nil, nil)
+		if err != nil {
+			t.Fatalf("Error sending request: %v", err)
+		}
+
+		// Parse & verify the data
+		helper.ParseResponse(t, rec, &bResp)
+		var entityData domain.Setting
+		helper.ParseEntityData(t, bResp.Data, &entityData)
+		if reqBody.Value != entityData.Value {
+			t.Fatalf("Wanted value %v, got %v", reqBody.Value, entityData.Value)
+		}
+	})
+}
+
+func TestFeatureFlags(t *testing.T) {
+	t.Run("should enable a flag for the targeted roles unless it's killed", func(t *testing.T) {
+		// Setup the tests
+		tApi, e, teardownSuite := helper.SetupSuite(t)
+		defer teardownSuite(t)
+
+		// Save a flag for beta testers
+		flag := "test_flag"
+		reqBody := domain.FeatureFlagRules{
+			Description: "A flag for beta testers",
+			Roles:       []string{"beta"},
+		}
+		pathParams := map[string]string{}
+		pathParams["name"] = flag
+		_, err := helper.SendRequest(e, tApi.FeatureFlagHandler.Save, http.MethodPut, "/feature-flag/"+flag, pathParams, nil, reqBody)
+		if err != nil {
+			t.Fatalf("Error sending request: %v", err)
+		}
+		defer func() {
+			for _, v := range findSettingsByKey(t, tApi, e, domain.FeatureFlagKeyPrefix+flag) {
+				deleteSetting(t, tApi, e, v.ID)
+			}
+		}()
+
+		// Evaluate the flag as a beta tester and as someone else
+		for _, v := range []struct {
+			role        string
+			killSwitch  bool
+			enabledWant bool
+		}{
+			{role: "beta", enabledWant: true},
+			{role: "member", enabledWant: false},
+			{role: "beta", killSwitch: true, enabledWant: false},
+		} {
+			if v.killSwitch {
+				reqBody.KillSwitch = true
+				_, err = helper.SendRequest(e, tApi.FeatureFlagHandler.Save, http.MethodPut, "/feature-flag/"+flag, pathParams, nil, reqBody)
+				if err != nil {
+					t.Fatalf("Error sending request: %v", err)
+				}
+			}
+
+			evaluate := helper.WithClaims(tApi.FeatureFlagHandler.Evaluate, jwt.MapClaims{"role": v.role})
+			rec, err := helper.SendRequest(e, evaluate, http.MethodGet, "/feature-flag/evaluate", nil, nil, nil)
+			if err != nil {
+				t.Fatalf("Error sending request: %v", err)
+			}
+
+			// Parse & verify the data
+			var bResp domain.BaseResponse
+			helper.ParseResponse(t, rec, &bResp)
+			var entityData map[string]bool
+			helper.ParseEntityData(t, bResp.Data, &entityData)
+			if v.enabledWant != entityData[flag] {
+				t.Fatalf("Wanted flag enabled %v for role %s, got %v", v.enabledWant, v.role, entityData[flag])
+			}
+		}
+	})
+
+	t.Run("should keep a killed flag disabled for the organizations that override it", func(t *testing.T) {
+		// Setup the tests
+		tApi, e, teardownSuite := helper.SetupSuite(t)
+		defer teardownSuite(t)
+
+		// Kill a flag globally
+		flag := "test_killed_flag"
+		pathParams := map[string]string{}
+		pathParams["name"] = flag
+		_, err := helper.SendRequest(e, tApi.FeatureFlagHandler.Save, http.MethodPut, "/feature-flag/"+flag, pathParams, nil, domain.FeatureFlagRules{KillSwitch: true})
+		if err != nil {
+			t.Fatalf("Error sending request: %v", err)
+		}
+		defer func() {
+			for _, v := range findSettingsByKey(t, tApi, e, domain.FeatureFlagKeyPrefix+flag) {
+				deleteSetting(t, tApi, e, v.ID)
+			}
+		}()
+
+		// Override the flag for an organization, enabling it for everyone in it
+		organizationID := uuid.Must(uuid.NewV4())
+		value, err := json.Marshal(domain.FeatureFlagRules{Percentage: 100})
+		if err != nil {
+			t.Fatalf("Error encoding the rules: %v", err)
+		}
+		reqBody := domain.CreateSettingInput{
+			Key:     domain.FeatureFlagKeyPrefix + flag,
+			Value:   string(value),
+			Scope:   domain.SettingScopeOrganization,
+			ScopeID: &organizationID,
+		}
+		_, err = helper.SendRequest(e, tApi.SettingHandler.Create, http.MethodPost, "/setting", nil, nil, reqBody)
+		if err != nil {
+			t.Fatalf("Error sending request: %v", err)
+		}
+
+		// Evaluate the flag as a member of the organization
+		evaluate := helper.WithClaims(tApi.FeatureFlagHandler.Evaluate, jwt.MapClaims{"organization_id": organizationID.String()})
+		rec, err := helper.SendRequest(e, evaluate, http.MethodGet, "/feature-flag/evaluate", nil, nil, nil)
+		if err != nil {
+			t.Fatalf("Error sending request: %v", err)
+		}
+
+		// Parse & verify the data
+		var bResp domain.BaseResponse
+		helper.ParseResponse(t, rec, &bResp)
+		var entityData map[string]bool
+		helper.ParseEntityData(t, bResp.Data, &entityData)
+		if entityData[flag] {
+			t.Fatalf("Wanted flag disabled by the kill switch, got enabled")
+		}
+	})
+
+	t.Run("should reject an invalid flag name", func(t *testing.T) {
+		// Setup the tests
+		tApi, e, teardownSuite := helper.SetupSuite(t)
+		defer teardownSuite(t)
+
+		// Create and send a request
+		pathParams := map[string]string{}
+		pathParams["name"] = "Test Flag"
+		_, err := helper.SendRequest(e, tApi.FeatureFlagHandler.Save, http.MethodPut, "/feature-flag/Test%20Flag", pathParams, nil, domain.FeatureFlagRules{})
+		if _, ok := err.(domain.UserError); !ok {
+			t.Fatalf("Wanted user error, got %v", err)
+		}
+	})
+}
+
+func findSettingsByKey(t *testing.T, tApi *api.AppApi, e *echo.Echo, key string) (result []domain.Setting) {
+	reqBody := domain.FilterSettingsByCriteriaInput{Keys: []string{key}}
+	rec, err := helper.SendRequest(e, tApi.SettingHandler.Filter, http.MethodPost, "/setting/filter", nil, nil, reqBody)
+	if err != nil {
+		t.Fatalf("Error sending request: %v", err)
+	}
+	var pResp domain.PaginationResponse
+	helper.ParseResponse(t, rec, &pResp)
+	helper.ParseEntityData(t, pResp.Data, &result)
+	return result
+}
+
+func TestSettingVersions(t *testing.T) {
+	t.Run("should reject an update of a stale version through If-Match", func(t *testing.T) {
+		// Setup the tests
+		tApi, e, teardownSuite := helper.SetupSuite(t)
+		defer teardownSuite(t)
+
+		// Create a setting to update
+		setting := createSetting(t, tApi, e, "test.versioned_setting", "before")
+		defer deleteSetting(t, tApi, e, setting.ID)
+
+		// Find the setting & its ETag
+		pathParams := map[string]string{}
+		pathParams["id"] = setting.ID.String()
+		rec, err := helper.SendRequest(e, tApi.SettingHandler.FindByID, http.MethodGet, "/setting/"+setting.ID.String(), pathParams, nil, nil)
+		if err != nil {
+			t.Fatalf("Error sending request: %v", err)
+		}
+		eTag := rec.Header().Get(transport.HeaderETag)
+		if eTag != `"1"` {
+			t.Fatalf("Wanted ETag %v, got %v", `"1"`, eTag)
+		}
+
+		// Update the setting with the ETag
+		reqBody := domain.UpdateSettingInput{
+			Key:   "test.versioned_setting",
+			Value: "after",
+		}
+		update := helper.WithHeader(tApi.SettingHandler.Update, transport.HeaderIfMatch, eTag)
+		rec, err = helper.SendRequest(e, update, http.MethodPut, "/setting/"+setting.ID.String(), pathParams, nil, reqBody)
+		if err != nil {
+			t.Fatalf("Error sending request: %v", err)
+		}
+		var bResp domain.BaseResponse
+		helper.ParseResponse(t, rec, &bResp)
+		var entityData domain.Setting
+		helper.ParseEntityData(t, bResp.Data, &entityData)
+		if entityData.Version != 2 {
+			t.Fatalf("Wanted version %v, got %v", 2, entityData.Version)
+		}
+
+		// Update the setting again with the same, now stale, ETag
+		reqBody.Value = "lost"
+		_, err = helper.SendRequest(e, update, http.MethodPut, "/setting/"+setting.ID.String(), pathParams, nil, reqBody)
+		he, ok := err.(*echo.HTTPError)
+		if !ok || he.Code != http.StatusPreconditionFailed {
+			t.Fatalf("Wanted status code %v, got %v", http.StatusPreconditionFailed, err)
+		}
+	})
+
+	t.Run("should reject a bulk update of a stale version", func(t *testing.T) {
+		// Setup the tests
+		tApi, e, teardownSuite := helper.SetupSuite(t)
+		defer teardownSuite(t)
+
+		// Create a setting to update
+		setting := createSetting(t, tApi, e, "test.versioned_setting", "before")
+		defer deleteSetting(t, tApi, e, setting.ID)
+
+		// Create and send a request expecting a version that doesn't exist yet
+		reqBody := domain.UpdateSettingsInput{
+			Settings: []domain.UpdateSettingsItemInput{
+				{ID: setting.ID, Key: setting.Key, Value: "after", Version: setting.Version + 1},
+			},
+		}
+		_, err := helper.SendRequest(e, tApi.SettingHandler.UpdateMultiple, http.MethodPut, "/setting/bulk", nil, nil, reqBody)
+		if _, ok := err.(domain.ConflictError); !ok {
+			t.Fatalf("Wanted conflict error, got %v", err)
+		}
+	})
+}
+
+func TestFilterAndSortSettings(t *testing.T) {
+	t.Run("should filter & sort settings with the criteria given in the body", func(t *testing.T) {
+		// Setup the tests
+		tApi, e, teardownSuite := helper.SetupSuite(t)
+		defer teardownSuite(t)
+
+		// Create the settings to filter
+		for _, key := range []string{"test.sort_a", "test.sort_b"} {
+			setting := createSetting(t, tApi, e, key, key)
+			defer deleteSetting(t, tApi, e, setting.ID)
+		}
+
+		// Create and send a request
+		reqBody := domain.FilterSettingsByCriteriaInput{
+			QueryCriteria: domain.QueryCriteria{
+				Conditions: []domain.Condition{{Field: "key", Op: domain.FilterOpLike, Values: []string{"test.sort_%"}}},
+				SortKeys:   []domain.SortKey{{Field: "key", Direction: domain.SortDirectionDesc}},
+			},
+		}
+		rec, err := helper.SendRequest(e, tApi.SettingHandler.Filter, http.MethodPost, "/setting/filter", nil, nil, reqBody)
+		if err != nil {
+			t.Fatalf("Error sending request: %v", err)
+		}
+
+		// Parse & verify the data
+		var resp domain.PaginationResponse
+		helper.ParseResponse(t, rec, &resp)
+		var entityData []domain.Setting
+		helper.ParseEntityData(t, resp.Data, &entityData)
+		if resp.Total != 2 || len(entityData) != 2 {
+			t.Fatalf("Wanted %v settings, got %v", 2, resp.Total)
+		}
+		if entityData[0].Key != "test.sort_b" {
+			t.Fatalf("Wanted setting key %v first, got %v", "test.sort_b", entityData[0].Key)
+		}
+	})
+
+	t.Run("should filter & sort settings with the criteria given in the query string", func(t *testing.T) {
+		// Setup the tests
+		tApi, e, teardownSuite := helper.SetupSuite(t)
+		defer teardownSuite(t)
+
+		// Create the settings to filter
+		for _, key := range []string{"test.sort_a", "test.sort_b", "test.sort_c"} {
+			setting := createSetting(t, tApi, e, key, key)
+			defer deleteSetting(t, tApi, e, setting.ID)
+		}
+
+		// Create and send a request
+		queryParams := map[string]string{}
+		queryParams["sort"] = "key:desc"
+		queryParams["filter"] = "key:in:test.sort_a|test.sort_c"
+		rec, err := helper.SendRequest(e, tApi.SettingHandler.Filter, http.MethodPost, "/setting/filter", nil, queryParams, domain.FilterSettingsByCriteriaInput{})
+		if err != nil {
+			t.Fatalf("Error sending request: %v", err)
+		}
+
+		// Parse & verify the data
+		var resp domain.PaginationResponse
+		helper.ParseResponse(t, rec, &resp)
+		var entityData []domain.Setting
+		helper.ParseEntityData(t, resp.Data, &entityData)
+		if len(entityData) != 2 || entityData[0].Key != "test.sort_c" || entityData[1].Key != "test.sort_a" {
+			t.Fatalf("Wanted settings %v, got %v", []string{"test.sort_c", "test.sort_a"}, entityData)
+		}
+	})
+
+	t.Run("should reject a field that can't be filtered by", func(t *testing.T) {
+		// Setup the tests
+		tApi, e, teardownSuite := helper.SetupSuite(t)
+		defer teardownSuite(t)
+
+		// Create and send a request
+		queryParams := map[string]string{}
+		queryParams["filter"] = "value; DROP TABLE settings:eq:x"
+		_, err := helper.SendRequest(e, tApi.SettingHandler.Filter, http.MethodPost, "/setting/filter", nil, queryParams, domain.FilterSettingsByCriteriaInput{})
+		if _, ok := err.(domain.UserError); !ok {
+			t.Fatalf("Wanted user error, got %v", err)
+		}
+	})
+}
+
+func TestFilterSettingsByCursor(t *testing.T) {
+	t.Run("should page through settings by cursor in both directions", func(t *testing.T) {
+		// Setup the tests
+		tApi, e, teardownSuite := helper.SetupSuite(t)
+		defer teardownSuite(t)
+
+		// Create the settings to page through
+		for _, key := range []string{"test.cursor_a", "test.cursor_b", "test.cursor_c"} {
+			setting := createSetting(t, tApi, e, key, key)
+			defer deleteSetting(t, tApi, e, setting.ID)
+		}
+
+		// Fetch the pages, starting with an empty cursor
+		filterPage := func(cursor string) (keys []string, resp domain.CursorPaginationResponse) {
+			queryParams := map[string]string{}
+			queryParams["size"] = "2"
+			queryParams["cursor"] = cursor
+			queryParams["filter"] = "key:like:test.cursor_%"
+			rec, err := helper.SendRequest(e, tApi.SettingHandler.Filter, http.MethodPost, "/setting/filter", nil, queryParams, domain.FilterSettingsByCriteriaInput{})
+			if err != nil {
+				t.Fatalf("Error sending request: %v", err)
+			}
+			helper.ParseResponse(t, rec, &resp)
+			var entityData []domain.Setting
+			helper.ParseEntityData(t, resp.Data, &entityData)
+			for _, v := range entityData {
+				keys = append(keys, v.Key)
+			}
+			return keys, resp
+		}
+		var resp domain.CursorPaginationResponse
+		for _, v := range []struct {
+			cursor   func(prev domain.CursorPaginationResponse) string
+			keysWant []string
+		}{
+			{cursor: func(domain.CursorPaginationResponse) string { return "" }, keysWant: []string{"test.cursor_a", "test.cursor_b"}},
+			{cursor: func(prev domain.CursorPaginationResponse) string { return prev.Next }, keysWant: []string{"test.cursor_c"}},
+			{cursor: func(prev domain.CursorPaginationResponse) string { return prev.Prev }, keysWant: []string{"test.cursor_a", "test.cursor_b"}},
+		} {
+			var keys []string
+			keys, resp = filterPage(v.cursor(resp))
+			if strings.Join(keys, ",") != strings.Join(v.keysWant, ",") {
+				t.Fatalf("Wanted settings %v, got %v", v.keysWant, keys)
+			}
+		}
+	})
+}
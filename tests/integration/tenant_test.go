@@ -0,0 +1,406 @@
+package integration
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/gofrs/uuid/v5"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/labstack/echo/v4"
+
+	"github.com/Intiqo/app-platform/internal/domain"
+	"github.com/Intiqo/app-platform/internal/http/api"
+	"github.com/Intiqo/app-platform/internal/repository"
+	"github.com/Intiqo/app-platform/tests/helper"
+)
+
+func TestOrganizationIsolation(t *testing.T) {
+	t.Run("should hide the settings of an organization from the others", func(t *testing.T) {
+		// Setup the tests
+		tApi, e, teardownSuite := helper.SetupSuite(t)
+		defer teardownSuite(t)
+
+		// Create a setting for an organization
+		organizationID := uuid.Must(uuid.NewV4())
+		setting := createOrganizationSetting(t, tApi, e, "test.tenant_setting", organizationID)
+		defer deleteSetting(t, tApi, e, setting.ID)
+		if setting.OrganizationID == nil || *setting.OrganizationID != organizationID {
+			t.Fatalf("Wanted the setting to be owned by %v, got %v", organizationID, setting.OrganizationID)
+		}
+
+		// Read the setting as a member of the organization and as someone outside of it
+		for _, v := range []struct {
+			organizationID uuid.UUID
+			visible        bool
+		}{
+			{organizationID: organizationID, visible: true},
+			{organizationID: uuid.Must(uuid.NewV4()), visible: false},
+		} {
+			claims := jwt.MapClaims{"organization_id": v.organizationID.String()}
+
+			// Find the setting by ID, which the cache leaves to the database when the setting isn't visible
+			pathParams := map[string]string{}
+			pathParams["id"] = setting.ID.String()
+			findByID := helper.WithClaims(tApi.SettingHandler.FindByID, claims)
+			_, err := helper.SendRequest(e, findByID, http.MethodGet, "/setting/"+setting.ID.String(), pathParams, nil, nil)
+			if v.visible && err != nil {
+				t.Fatalf("Error sending request: %v", err)
+			}
+			if !v.visible && !errors.Is(err, domain.DataNotFoundError{}) {
+				t.Fatalf("Wanted data not found error, got %v", err)
+			}
+
+			// Filter the settings with a condition, which the cache leaves to the database
+			queryParams := map[string]string{}
+			queryParams["filter"] = "key:eq:" + setting.Key
+			filter := helper.WithClaims(tApi.SettingHandler.Filter, claims)
+			rec, err := helper.SendRequest(e, filter, http.MethodPost, "/setting/filter", nil, queryParams, domain.FilterSettingsByCriteriaInput{})
+			if err != nil {
+				t.Fatalf("Error sending request: %v", err)
+			}
+			var resp domain.PaginationResponse
+			helper.ParseResponse(t, rec, &resp)
+			var settings []domain.Setting
+			helper.ParseEntityData(t, resp.Data, &settings)
+			if v.visible != (len(settings) == 1) {
+				t.Fatalf("Wanted the setting to be visible: %v, got %v settings", v.visible, len(settings))
+			}
+
+			// List the revisions of the setting
+			queryParams = map[string]string{}
+			queryParams["settingId"] = setting.ID.String()
+			findRevisions := helper.WithClaims(tApi.SettingHandler.FindRevisions, claims)
+			rec, err = helper.SendRequest(e, findRevisions, http.MethodGet, "/setting/revision", nil, queryParams, nil)
+			if err != nil {
+				t.Fatalf("Error sending request: %v", err)
+			}
+			helper.ParseResponse(t, rec, &resp)
+			var revisions []domain.SettingRevision
+			helper.ParseEntityData(t, resp.Data, &revisions)
+			if v.visible != (len(revisions) == 1) {
+				t.Fatalf("Wanted the revision to be visible: %v, got %v revisions", v.visible, len(revisions))
+			}
+		}
+	})
+
+	t.Run("should only share the global settings created by the platform", func(t *testing.T) {
+		// Setup the tests
+		tApi, e, teardownSuite := helper.SetupSuite(t)
+		defer teardownSuite(t)
+
+		// Create a global setting along with a user one, as the platform
+		shared := createSetting(t, tApi, e, "test.shared_setting", "shared")
+		defer deleteSetting(t, tApi, e, shared.ID)
+		userID := uuid.Must(uuid.NewV4())
+		reqBody := domain.CreateSettingInput{
+			Key:     "test.user_setting",
+			Value:   "value",
+			Scope:   domain.SettingScopeUser,
+			ScopeID: &userID,
+		}
+		rec, err := helper.SendRequest(e, tApi.SettingHandler.Create, http.MethodPost, "/setting", nil, nil, reqBody)
+		if err != nil {
+			t.Fatalf("Error creating setting: %v", err)
+		}
+		var bResp domain.BaseResponse
+		helper.ParseResponse(t, rec, &bResp)
+		var setting domain.Setting
+		helper.ParseEntityData(t, bResp.Data, &setting)
+		defer deleteSetting(t, tApi, e, setting.ID)
+		if setting.OrganizationID != nil {
+			t.Fatalf("Wanted the setting to be owned by no organization, got %v", setting.OrganizationID)
+		}
+
+		// Read the settings as an organization
+		claims := jwt.MapClaims{"organization_id": uuid.Must(uuid.NewV4()).String()}
+		for _, v := range []struct {
+			setting domain.Setting
+			visible bool
+		}{
+			{setting: shared, visible: true},
+			{setting: setting, visible: false},
+		} {
+			// Find the setting by ID
+			pathParams := map[string]string{}
+			pathParams["id"] = v.setting.ID.String()
+			findByID := helper.WithClaims(tApi.SettingHandler.FindByID, claims)
+			_, err := helper.SendRequest(e, findByID, http.MethodGet, "/setting/"+v.setting.ID.String(), pathParams, nil, nil)
+			if v.visible && err != nil {
+				t.Fatalf("Error sending request: %v", err)
+			}
+			if !v.visible && !errors.Is(err, domain.DataNotFoundError{}) {
+				t.Fatalf("Wanted data not found error, got %v", err)
+			}
+
+			// Filter the settings by the key
+			queryParams := map[string]string{}
+			queryParams["filter"] = "key:eq:" + v.setting.Key
+			filter := helper.WithClaims(tApi.SettingHandler.Filter, claims)
+			rec, err := helper.SendRequest(e, filter, http.MethodPost, "/setting/filter", nil, queryParams, domain.FilterSettingsByCriteriaInput{})
+			if err != nil {
+				t.Fatalf("Error sending request: %v", err)
+			}
+			var resp domain.PaginationResponse
+			helper.ParseResponse(t, rec, &resp)
+			var settings []domain.Setting
+			helper.ParseEntityData(t, resp.Data, &settings)
+			if v.visible != (len(settings) == 1) {
+				t.Fatalf("Wanted the setting to be visible: %v, got %v settings", v.visible, len(settings))
+			}
+
+			// List the revisions of the setting
+			queryParams = map[string]string{}
+			queryParams["settingId"] = v.setting.ID.String()
+			findRevisions := helper.WithClaims(tApi.SettingHandler.FindRevisions, claims)
+			rec, err = helper.SendRequest(e, findRevisions, http.MethodGet, "/setting/revision", nil, queryParams, nil)
+			if err != nil {
+				t.Fatalf("Error sending request: %v", err)
+			}
+			helper.ParseResponse(t, rec, &resp)
+			var revisions []domain.SettingRevision
+			helper.ParseEntityData(t, resp.Data, &revisions)
+			if v.visible != (len(revisions) == 1) {
+				t.Fatalf("Wanted the revision to be visible: %v, got %v revisions", v.visible, len(revisions))
+			}
+		}
+	})
+
+	t.Run("should confine callers that act for no organization to the global settings", func(t *testing.T) {
+		// Setup the tests
+		tApi, e, teardownSuite := helper.SetupSuite(t)
+		defer teardownSuite(t)
+
+		// Create a setting for an organization, along with a global one
+		setting := createOrganizationSetting(t, tApi, e, "test.tenant_setting", uuid.Must(uuid.NewV4()))
+		defer deleteSetting(t, tApi, e, setting.ID)
+		shared := createSetting(t, tApi, e, "test.shared_setting", "shared")
+		defer deleteSetting(t, tApi, e, shared.ID)
+
+		// Read & change the settings with a token that carries no organization
+		claims := jwt.MapClaims{"role": "admin"}
+		value := "changed"
+		for _, v := range []struct {
+			id          uuid.UUID
+			findErrWant error
+		}{
+			{id: setting.ID, findErrWant: domain.DataNotFoundError{}},
+			{id: shared.ID, findErrWant: nil},
+		} {
+			pathParams := map[string]string{}
+			pathParams["id"] = v.id.String()
+			findByID := helper.WithClaims(tApi.SettingHandler.FindByID, claims)
+			_, err := helper.SendRequest(e, findByID, http.MethodGet, "/setting/"+v.id.String(), pathParams, nil, nil)
+			if v.findErrWant == nil && err != nil {
+				t.Fatalf("Error sending request: %v", err)
+			}
+			if v.findErrWant != nil && !errors.Is(err, v.findErrWant) {
+				t.Fatalf("Wanted %T, got %v", v.findErrWant, err)
+			}
+
+			// Neither setting can be changed, since only the platform itself changes the global settings
+			patch := helper.WithClaims(tApi.SettingHandler.Patch, claims)
+			_, err = helper.SendRequest(e, patch, http.MethodPatch, "/setting/"+v.id.String(), pathParams, nil, domain.PatchSettingInput{Value: &value})
+			if err == nil {
+				t.Fatalf("Wanted the setting %v not to be changed", v.id)
+			}
+		}
+	})
+
+	t.Run("should keep an organization from changing the settings of the others", func(t *testing.T) {
+		// Setup the tests
+		tApi, e, teardownSuite := helper.SetupSuite(t)
+		defer teardownSuite(t)
+
+		// Create a setting for an organization, along with a shared one
+		organizationID := uuid.Must(uuid.NewV4())
+		setting := createOrganizationSetting(t, tApi, e, "test.tenant_setting", organizationID)
+		defer deleteSetting(t, tApi, e, setting.ID)
+		shared := createSetting(t, tApi, e, "test.shared_setting", "shared")
+		defer deleteSetting(t, tApi, e, shared.ID)
+
+		// Change the settings as another organization
+		claims := jwt.MapClaims{"organization_id": uuid.Must(uuid.NewV4()).String()}
+		value := "changed"
+		for _, v := range []struct {
+			id      uuid.UUID
+			errWant error
+		}{
+			{id: setting.ID, errWant: domain.DataNotFoundError{}},
+			{id: shared.ID, errWant: domain.ForbiddenAccessError{}},
+		} {
+			pathParams := map[string]string{}
+			pathParams["id"] = v.id.String()
+			patch := helper.WithClaims(tApi.SettingHandler.Patch, claims)
+			_, err := helper.SendRequest(e, patch, http.MethodPatch, "/setting/"+v.id.String(), pathParams, nil, domain.PatchSettingInput{Value: &value})
+			if !errors.Is(err, v.errWant) {
+				t.Fatalf("Wanted %T, got %v", v.errWant, err)
+			}
+			deleteByID := helper.WithClaims(tApi.SettingHandler.DeleteByID, claims)
+			_, err = helper.SendRequest(e, deleteByID, http.MethodDelete, "/setting/"+v.id.String(), pathParams, nil, nil)
+			if !errors.Is(err, v.errWant) {
+				t.Fatalf("Wanted %T, got %v", v.errWant, err)
+			}
+		}
+
+		// Create a setting for the organization as another one
+		create := helper.WithClaims(tApi.SettingHandler.Create, claims)
+		reqBody := domain.CreateSettingInput{
+			Key:     "test.tenant_setting_2",
+			Value:   "value",
+			Scope:   domain.SettingScopeOrganization,
+			ScopeID: &organizationID,
+		}
+		_, err := helper.SendRequest(e, create, http.MethodPost, "/setting", nil, nil, reqBody)
+		if !errors.Is(err, domain.ForbiddenAccessError{}) {
+			t.Fatalf("Wanted forbidden access error, got %v", err)
+		}
+	})
+}
+
+func TestOrganizationRowLevelSecurity(t *testing.T) {
+	t.Run("should keep the rows of an organization from the others in the database", func(t *testing.T) {
+		// Setup the tests
+		tApi, e, teardownSuite := helper.SetupSuite(t)
+		defer teardownSuite(t)
+		db, teardownDatabase := helper.SetupDatabase(t)
+		defer teardownDatabase(t)
+
+		// Create a setting for an organization
+		organizationID := uuid.Must(uuid.NewV4())
+		setting := createOrganizationSetting(t, tApi, e, "test.tenant_setting", organizationID)
+		defer deleteSetting(t, tApi, e, setting.ID)
+
+		// Query the tables in transactions scoped to the organization, to another one and to none, bypassing the services & the cache
+		tr := repository.NewTransactioner(db)
+		for _, v := range []struct {
+			organizationID uuid.UUID
+			rowsWanted     int64
+		}{
+			{organizationID: organizationID, rowsWanted: 1},
+			{organizationID: uuid.Must(uuid.NewV4()), rowsWanted: 0},
+			{organizationID: uuid.Nil, rowsWanted: 0},
+		} {
+			ctx, err := tr.Begin(domain.ContextWithClaims(context.Background(), domain.Claims{OrganizationID: v.organizationID}))
+			if err != nil {
+				t.Fatalf("Error beginning transaction: %v", err)
+			}
+			tx := ctx.Value(repository.TxKey).(pgx.Tx)
+
+			// Read the setting & its revisions
+			var count int64
+			err = tx.QueryRow(ctx, `SELECT COUNT(*) FROM settings WHERE id = $1`, setting.ID).Scan(&count)
+			if err != nil {
+				t.Fatalf("Error counting settings: %v", err)
+			}
+			if count != v.rowsWanted {
+				t.Fatalf("Wanted %v settings, got %v", v.rowsWanted, count)
+			}
+			err = tx.QueryRow(ctx, `SELECT COUNT(*) FROM setting_revisions WHERE setting_id = $1`, setting.ID).Scan(&count)
+			if err != nil {
+				t.Fatalf("Error counting revisions: %v", err)
+			}
+			if count != v.rowsWanted {
+				t.Fatalf("Wanted %v revisions, got %v", v.rowsWanted, count)
+			}
+
+			// Change the setting, which only affects the rows the transaction can see
+			tag, err := tx.Exec(ctx, `UPDATE settings SET value = 'changed' WHERE id = $1`, setting.ID)
+			if err != nil {
+				t.Fatalf("Error updating setting: %v", err)
+			}
+			if tag.RowsAffected() != v.rowsWanted {
+				t.Fatalf("Wanted %v settings updated, got %v", v.rowsWanted, tag.RowsAffected())
+			}
+
+			// Create a setting for the organization, which fails when the transaction is scoped to another one
+			_, err = tx.Exec(
+				ctx, `INSERT INTO settings (key, value, scope, scope_id, organization_id) VALUES ('test.tenant_setting_2', 'value', 'organization', $1, $1)`,
+				organizationID,
+			)
+			var pgErr *pgconn.PgError
+			if v.rowsWanted == 0 && (!errors.As(err, &pgErr) || pgErr.Code != "42501") {
+				t.Fatalf("Wanted insufficient privilege error, got %v", err)
+			}
+			if v.rowsWanted == 1 && err != nil {
+				t.Fatalf("Error inserting setting: %v", err)
+			}
+
+			// Leave the rows as they were
+			err = tx.Rollback(ctx)
+			if err != nil {
+				t.Fatalf("Error rolling back transaction: %v", err)
+			}
+		}
+	})
+}
+
+func TestTenantRoleGrants(t *testing.T) {
+	t.Run("should keep organizations away from the tables they don't own", func(t *testing.T) {
+		// Setup the tests
+		db, teardownDatabase := helper.SetupDatabase(t)
+		defer teardownDatabase(t)
+
+		// Query the tables in transactions scoped to an organization
+		tr := repository.NewTransactioner(db)
+		for _, q := range []string{
+			`SELECT COUNT(*) FROM jobs`,
+			`SELECT COUNT(*) FROM scheduled_runs`,
+			`SELECT COUNT(*) FROM refresh_tokens`,
+			`SELECT encrypted_key FROM setting_data_keys`,
+		} {
+			ctx, err := tr.Begin(domain.ContextWithClaims(context.Background(), domain.Claims{OrganizationID: uuid.Must(uuid.NewV4())}))
+			if err != nil {
+				t.Fatalf("Error beginning transaction: %v", err)
+			}
+			tx := ctx.Value(repository.TxKey).(pgx.Tx)
+
+			_, err = tx.Exec(ctx, q)
+			var pgErr *pgconn.PgError
+			if !errors.As(err, &pgErr) || pgErr.Code != "42501" {
+				t.Fatalf("Wanted insufficient privilege error for %v, got %v", q, err)
+			}
+			_ = tx.Rollback(ctx)
+		}
+	})
+
+	t.Run("should let organizations find the active data key", func(t *testing.T) {
+		// Setup the tests
+		db, teardownDatabase := helper.SetupDatabase(t)
+		defer teardownDatabase(t)
+
+		// Find the active data key in a transaction scoped to an organization
+		tr := repository.NewTransactioner(db)
+		ctx, err := tr.Begin(domain.ContextWithClaims(context.Background(), domain.Claims{OrganizationID: uuid.Must(uuid.NewV4())}))
+		if err != nil {
+			t.Fatalf("Error beginning transaction: %v", err)
+		}
+		tx := ctx.Value(repository.TxKey).(pgx.Tx)
+		defer func() { _ = tx.Rollback(ctx) }()
+		_, err = tx.Exec(ctx, `SELECT id, master_key_name FROM setting_data_keys WHERE retired_at IS NULL`)
+		if err != nil {
+			t.Fatalf("Wanted the active data key to be found, got %v", err)
+		}
+	})
+}
+
+// createOrganizationSetting creates a setting scoped to the organization through the API
+func createOrganizationSetting(t *testing.T, tApi *api.AppApi, e *echo.Echo, key string, organizationID uuid.UUID) (result domain.Setting) {
+	reqBody := domain.CreateSettingInput{
+		Key:     key,
+		Value:   "value",
+		Scope:   domain.SettingScopeOrganization,
+		ScopeID: &organizationID,
+	}
+	rec, err := helper.SendRequest(e, tApi.SettingHandler.Create, http.MethodPost, "/setting", nil, nil, reqBody)
+	if err != nil {
+		t.Fatalf("Error creating setting: %v", err)
+	}
+	var bResp domain.BaseResponse
+	helper.ParseResponse(t, rec, &bResp)
+	helper.ParseEntityData(t, bResp.Data, &result)
+	return result
+}